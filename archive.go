@@ -0,0 +1,234 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Архив блога по годам и месяцам.
+// Если в директории настроек есть шаблон archive.html, модуль блога формирует по нему указатель архива
+// blog/archive/ и страницы архива blog/<год>/ и blog/<год>/<месяц>/ с постами, разбитыми на страницы
+// по posts_per_page. Шаблону передаются данные archivePageData: у указателя архива поля Year и Month равны нулю,
+// у страницы года равно нулю поле Month.
+// Список лет и месяцев с количеством постов доступен всем шаблонам в поле Site.Archive:
+//
+//	{{range .Site.Archive}}<h3><a href="{{.URL}}">{{.Year}}</a> ({{.Count}})</h3>
+//	  {{range .Months}}<a href="{{.URL}}">{{.Name}}</a> ({{.Count}}){{end}}
+//	{{end}}
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ArchiveTemplate — имя шаблона страниц архива блога в директории настроек сайта.
+const ArchiveTemplate = "archive.html"
+
+// Русские названия месяцев в именительном падеже.
+var RussianMonthName = map[time.Month]string{
+	time.January:   "Январь",
+	time.February:  "Февраль",
+	time.March:     "Март",
+	time.April:     "Апрель",
+	time.May:       "Май",
+	time.June:      "Июнь",
+	time.July:      "Июль",
+	time.August:    "Август",
+	time.September: "Сентябрь",
+	time.October:   "Октябрь",
+	time.November:  "Ноябрь",
+	time.December:  "Декабрь",
+}
+
+// ArchiveMonth описывает месяц архива блога.
+type ArchiveMonth struct {
+	Year  int
+	Month int
+	// Название месяца в именительном падеже.
+	Name string
+	// Количество постов месяца.
+	Count int
+	// Адрес страницы месяца, пустая строка если архив не формируется.
+	URL string
+}
+
+// ArchiveYear описывает год архива блога.
+type ArchiveYear struct {
+	Year int
+	// Количество постов года.
+	Count int
+	// Адрес страницы года, пустая строка если архив не формируется.
+	URL string
+	// Месяцы года, в которых есть посты, от новых к старым.
+	Months []ArchiveMonth
+}
+
+// archivePageData описывает данные для шаблона страницы архива блога.
+type archivePageData struct {
+	Site       *Site
+	Fuseaction string
+	Tags       []Tag
+	Archive    []ArchiveYear
+	// Год и месяц страницы; 0 у указателя архива, Month равен 0 у страницы года.
+	Year      int
+	Month     int
+	MonthName string
+	// Посты страницы и данные разбиения на страницы, как у ленты блога.
+	Blog           SortedBlogPostList
+	Pagenum        int
+	Next_page      int
+	Prev_url       string
+	Next_url       string
+	Total          int
+	Posts_per_page int
+}
+
+// archiveEnabled проверяет, есть ли в директории настроек шаблон страниц архива.
+func archiveEnabled(cfg *Config) bool {
+	_, err := os.Stat(filepath.Join(cfg.SettingsDir(), ArchiveTemplate))
+	return err == nil
+}
+
+// ArchivePath возвращает адрес страницы архива относительно домена сайта: указателя архива при year = 0,
+// года при month = 0, иначе месяца.
+func (cfg *Config) ArchivePath(year int, month int) string {
+	switch {
+	case year == 0:
+		return "/" + cfg.Output.Blog + "/archive/"
+	case month == 0:
+		return "/" + cfg.Output.Blog + "/" + strconv.Itoa(year) + "/"
+	}
+
+	return fmt.Sprintf("/%s/%d/%02d/", cfg.Output.Blog, year, month)
+}
+
+// buildArchive группирует посты, отсортированные от новых к старым, по годам и месяцам.
+// Адреса страниц архива заполняются, если архив формируется.
+func buildArchive(cfg *Config, posts []Post) []ArchiveYear {
+	withURLs := archiveEnabled(cfg)
+	archive := []ArchiveYear{}
+	for _, post := range posts {
+		year, month := post.SortDate.Year(), int(post.SortDate.Month())
+		if len(archive) == 0 || archive[len(archive)-1].Year != year {
+			archive = append(archive, ArchiveYear{Year: year})
+			if withURLs {
+				archive[len(archive)-1].URL = cfg.Domain + cfg.ArchivePath(year, 0)
+			}
+		}
+		y := &archive[len(archive)-1]
+		y.Count++
+		if len(y.Months) == 0 || y.Months[len(y.Months)-1].Month != month {
+			m := ArchiveMonth{Year: year, Month: month, Name: RussianMonthName[time.Month(month)]}
+			if withURLs {
+				m.URL = cfg.Domain + cfg.ArchivePath(year, month)
+			}
+			y.Months = append(y.Months, m)
+		}
+		y.Months[len(y.Months)-1].Count++
+	}
+
+	return archive
+}
+
+// archivePaths возвращает адреса страниц архива относительно домена сайта: указатель, годы и месяцы.
+func archivePaths(cfg *Config, archive []ArchiveYear) []string {
+	paths := []string{cfg.ArchivePath(0, 0)}
+	for _, year := range archive {
+		paths = append(paths, cfg.ArchivePath(year.Year, 0))
+		for _, month := range year.Months {
+			paths = append(paths, cfg.ArchivePath(year.Year, month.Month))
+		}
+	}
+
+	return paths
+}
+
+// writeBlogArchive формирует указатель архива и страницы архива по годам и месяцам из шаблона archive.html.
+func writeBlogArchive(site *Site, templatePath string, templatesDir string, sitemap *Sitemap, output *OutputWriter) error {
+	cfg := site.Config
+
+	// Указатель архива.
+	data := archivePageData{
+		Site:           site,
+		Fuseaction:     ArchiveTemplate,
+		Tags:           site.Tags,
+		Archive:        site.Archive,
+		Pagenum:        1,
+		Total:          len(site.Posts),
+		Posts_per_page: cfg.PostsPerPage,
+	}
+	if err := output.Render(cfg.PermalinkFile(cfg.ArchivePath(0, 0)), templatePath, templatesDir, data, ArchiveTemplate); err != nil {
+		return err
+	}
+	index := SitemapEntry{Kind: SitemapArchive, Loc: cfg.Domain + cfg.ArchivePath(0, 0)}
+	if len(site.Posts) > 0 {
+		index.LastMod = site.Posts[0].SortDate
+	}
+	sitemap.Add(index)
+
+	for _, year := range site.Archive {
+		if err := writeArchivePages(site, templatePath, templatesDir, year.Year, 0, sitemap, output); err != nil {
+			return err
+		}
+		for _, month := range year.Months {
+			if err := writeArchivePages(site, templatePath, templatesDir, year.Year, month.Month, sitemap, output); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// writeArchivePages формирует страницы архива года year (month = 0) или месяца month года year.
+func writeArchivePages(site *Site, templatePath string, templatesDir string, year int, month int, sitemap *Sitemap, output *OutputWriter) error {
+	cfg := site.Config
+
+	posts := []Post{}
+	for _, post := range site.Posts {
+		if post.SortDate.Year() == year && (month == 0 || int(post.SortDate.Month()) == month) {
+			posts = append(posts, post)
+		}
+	}
+
+	pageURL := cfg.Domain + cfg.ArchivePath(year, month)
+	pages, err := feedPages(cfg.Permalinks, pageURL, posts, cfg.PostsPerPage)
+	if err != nil {
+		return err
+	}
+	for _, page := range pages {
+		data := archivePageData{
+			Site:           site,
+			Fuseaction:     ArchiveTemplate,
+			Tags:           site.Tags,
+			Archive:        site.Archive,
+			Year:           year,
+			Month:          month,
+			Blog:           SortedBlogPostList(page.posts),
+			Pagenum:        page.num,
+			Next_page:      page.next,
+			Prev_url:       page.prevURL,
+			Next_url:       page.nextURL,
+			Total:          len(posts),
+			Posts_per_page: cfg.PostsPerPage,
+		}
+		if month != 0 {
+			data.MonthName = RussianMonthName[time.Month(month)]
+		}
+
+		file := permalinkFile(filepath.Dir(cfg.PermalinkFile(cfg.ArchivePath(year, month))), page.path)
+		if err := output.Render(file, templatePath, templatesDir, data, ArchiveTemplate, postSources(page.posts)...); err != nil {
+			return err
+		}
+
+		entry := SitemapEntry{Kind: SitemapArchive, Loc: pageURL + page.path}
+		if page.num > 1 {
+			entry.Kind = SitemapBlogPage
+		}
+		if len(page.posts) > 0 {
+			entry.LastMod = page.posts[0].SortDate
+		}
+		sitemap.Add(entry)
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuild_WritesBlogArchive(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.PostsPerPage = 1
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости"></tag></tags>`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "blog.html"), `{{range .Blog}}{{.Title}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "post.html"), `{{range .Site.Archive}}{{.Year}}={{.Count}};{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), ArchiveTemplate),
+		`{{.Year}}/{{.MonthName}}:{{range .Blog}}{{.Title}}{{end}}|{{.Next_url}}|{{range .Archive}}{{.Year}}({{range .Months}}{{.Name}}={{.Count}} {{.URL}};{{end}}){{end}}`)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "a.xml"), `<post><date>10.12.2025</date><tagid>1</tagid><title>Декабрьский</title></post>`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "b.xml"), `<post><date>01.03.2026</date><tagid>1</tagid><title>Первый</title></post>`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "c.xml"), `<post><date>20.03.2026</date><tagid>1</tagid><title>Второй</title></post>`)
+	if err := Build(cfg); err != nil {
+		t.Fatalf("Build вернул ошибку: %v", err)
+	}
+
+	index := "2026(Март=2 https://example.test/blog/2026/03/;)2025(Декабрь=1 https://example.test/blog/2025/12/;)"
+	files := readTree(t, cfg.Destination)
+	for rel, expected := range map[string]string{
+		"blog/archive/index.html": "0/:||" + index,
+		"blog/2026/index.html":    "2026/:Второй|https://example.test/blog/2026/2.html|" + index,
+		"blog/2026/2.html":        "2026/:Первый||" + index,
+		"blog/2026/03/index.html": "2026/Март:Второй|https://example.test/blog/2026/03/2.html|" + index,
+		"blog/2026/03/2.html":     "2026/Март:Первый||" + index,
+		"blog/2025/12/index.html": "2025/Декабрь:Декабрьский||" + index,
+		"blog/posts/a.html":       "2026=2;2025=1;",
+	} {
+		if content := files[filepath.FromSlash(rel)]; content != expected {
+			t.Errorf("%s = %q, ожидалось %q", rel, content, expected)
+		}
+	}
+	for _, loc := range []string{"blog/archive/", "blog/2025/", "blog/2026/03/"} {
+		if !strings.Contains(files[SitemapFile], "<loc>https://example.test/"+loc+"</loc>") {
+			t.Errorf("sitemap не содержит страницу архива %s:\n%s", loc, files[SitemapFile])
+		}
+	}
+}
+
+func TestBuild_ArchiveRequiresTemplate(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости"></tag></tags>`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "blog.html"), `{{range .Blog}}{{.Title}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "post.html"), `{{range .Site.Archive}}{{.Year}}[{{.URL}}]{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "a.xml"), `<post><date>01.03.2026</date><tagid>1</tagid><title>Пост</title></post>`)
+	if err := Build(cfg); err != nil {
+		t.Fatalf("Build вернул ошибку: %v", err)
+	}
+
+	files := readTree(t, cfg.Destination)
+	if content := files[filepath.Join("blog", "posts", "a.html")]; content != "2026[]" {
+		t.Errorf("Site.Archive без шаблона архива = %q, ожидалось %q", content, "2026[]")
+	}
+	for rel := range files {
+		if strings.HasPrefix(rel, filepath.Join("blog", "2026")) || strings.HasPrefix(rel, filepath.Join("blog", "archive")) {
+			t.Errorf("без шаблона archive.html сформирован файл архива %s", rel)
+		}
+	}
+}
@@ -4,11 +4,11 @@
 package main
 
 import (
-	"encoding/xml"
 	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -23,15 +23,51 @@ type Article struct {
 	Keywords    string `xml:"keywords"`
 	Description string `xml:"description"`
 	Pages       string `xml:"pages"`
+	// false исключает страницы публикации из sitemap.
+	Sitemap string `xml:"sitemap"`
+	// Старые адреса публикации, разделённые символом |, с которых формируются перенаправления.
+	Aliases string `xml:"aliases"`
 
 	// Вычисляемые поля.
 	Fuseaction string
+	Source     string
 	Pagetitles []string
-	Content    []string
+	Content    []TrustedHTML
+	// Исходные файлы страниц: N.html или N.md, пустая строка если файла страницы нет.
+	Pagesources []string
+	// Дополнительные поля заголовка (front matter) файла публикации.
+	Params map[string]interface{} `xml:"-"`
 }
 
+// loadArticlePage загружает контент страницы публикации с номером num.
+// Страница хранится в файле N.html либо в файле N.md, текст которого преобразуется из Markdown в HTML.
+// Возвращает контент и исходный файл страницы; если файла нет, возвращаются пустые строки.
+func loadArticlePage(articleDir string, num int) (string, string, error) {
+	for _, ext := range []string{".html", ".md"} {
+		source := filepath.Join(articleDir, strconv.Itoa(num)+ext)
+		contentBytes, err := ioutil.ReadFile(source)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return "", "", err
+		}
+		if IsMarkdownFile(source) {
+			return RenderMarkdown(string(contentBytes)), source, nil
+		}
+
+		return string(contentBytes), source, nil
+	}
+
+	return "", "", nil
+}
+
+// articlePageFilePattern — имя файла страницы публикации: N.html или N.md.
+var articlePageFilePattern = regexp.MustCompile(`^([1-9][0-9]*)\.(html|md)$`)
+
 // loadArticles загружает список публикаций.
-func loadArticles(articlesDir string) (*[]Article, error) {
+// validator — проверка исходных записей; nil прерывает загрузку на первой ошибке.
+func loadArticles(articlesDir string, validator *Validator) (*[]Article, error) {
 	var articles []Article
 
 	dir, err := os.Open(articlesDir)
@@ -44,24 +80,51 @@ func loadArticles(articlesDir string) (*[]Article, error) {
 	if err != nil {
 		return nil, err
 	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name() < files[j].Name()
+	})
 
+	// Исходные файлы публикаций по идентификатору для поиска повторов.
+	sources := map[string]string{}
 	for _, file := range files {
-		if !file.Mode().IsRegular() || filepath.Ext(file.Name()) != ".xml" {
+		if !file.Mode().IsRegular() {
 			continue
 		}
 
-		raw, err := ioutil.ReadFile(filepath.Join(articlesDir, file.Name()))
-		if err != nil {
-			return nil, err
-		}
-
 		var article Article
-		if err = xml.Unmarshal(raw, &article); err != nil {
-			return nil, err
+		var raw []byte
+		path := filepath.Join(articlesDir, file.Name())
+		if filepath.Ext(file.Name()) == ".xml" {
+			raw, err = ioutil.ReadFile(path)
+			if err != nil {
+				return nil, err
+			}
+			if problem := decodeXMLRecord(path, raw, &article); problem != nil {
+				if err = validator.Report(*problem); err != nil {
+					return nil, err
+				}
+				continue
+			}
+		} else if isFrontMatterCandidate(path) {
+			// Публикация из файла с заголовком (front matter), текст файла является аннотацией публикации.
+			ok, err := loadFrontMatterFile(path, &article, "Annotation")
+			if err != nil {
+				if err = validator.Report(frontMatterProblem(path, err)); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if !ok {
+				continue
+			}
+		} else {
+			continue
 		}
 
 		// Уникальный строковый идентификатор публикации.
 		article.Fuseaction = strings.TrimSuffix(file.Name(), filepath.Ext(file.Name()))
+		// Исходный файл публикации.
+		article.Source = path
 
 		// Проверяем, существует ли папка публикации в директории публикаций.
 		articleDir := filepath.Join(articlesDir, article.Fuseaction)
@@ -77,19 +140,35 @@ func loadArticles(articlesDir string) (*[]Article, error) {
 			article.Pagetitles = append(article.Pagetitles, title)
 
 			content := ""
+			source := ""
 			if articleFolderExists {
-				contentBytes, err := ioutil.ReadFile(filepath.Join(articleDir, strconv.Itoa(i+1)+".html"))
-				if err == nil {
-					content = string(contentBytes)
-				} else if !os.IsNotExist(err) {
+				content, source, err = loadArticlePage(articleDir, i+1)
+				if err != nil {
 					return nil, err
 				}
 			}
 
-			// Content всегда должен иметь ту же длину, что и Pagetitles.
-			article.Content = append(article.Content, content)
+			// Content и Pagesources всегда должны иметь ту же длину, что и Pagetitles.
+			article.Content = append(article.Content, TrustedHTML(content))
+			article.Pagesources = append(article.Pagesources, source)
 		}
 
+		problems, err := checkArticle(article, articleDir, raw)
+		if err != nil {
+			return nil, err
+		}
+		if source, ok := sources[article.Fuseaction]; ok {
+			problems.add(ProblemDuplicate, "идентификатор публикации %q совпадает с публикацией %s", article.Fuseaction, source)
+		}
+		skip, err := problems.report(validator)
+		if err != nil {
+			return nil, err
+		}
+		if skip {
+			continue
+		}
+		sources[article.Fuseaction] = path
+
 		// Добавляем публикацию в список публикаций.
 		articles = append(articles, article)
 	}
@@ -101,63 +180,113 @@ func loadArticles(articlesDir string) (*[]Article, error) {
 	return &articles, nil
 }
 
+// checkArticle проверяет поля публикации и файлы её страниц в директории articleDir:
+// каждой странице из списка pages соответствует непустой файл N.html или N.md, лишних файлов страниц нет.
+func checkArticle(article Article, articleDir string, raw []byte) (*recordProblems, error) {
+	problems := newRecordProblems(article.Source, "article", raw)
+	if len(strings.TrimSpace(article.Title)) == 0 {
+		problems.field(ProblemRequired, "title", "не указан заголовок публикации (title)")
+	}
+	if len(strings.TrimSpace(article.Pages)) == 0 {
+		problems.field(ProblemRequired, "pages", "не указаны страницы публикации (pages)")
+		return problems, nil
+	}
+
+	missing := []string{}
+	for i, source := range article.Pagesources {
+		if len(source) == 0 {
+			missing = append(missing, strconv.Itoa(i+1))
+		} else if len(strings.TrimSpace(string(article.Content[i]))) == 0 {
+			problems.problems = append(problems.problems, Problem{Kind: ProblemEmpty, File: source, Message: "пустая страница публикации"})
+		}
+	}
+	if len(missing) > 0 {
+		problems.field(ProblemPages, "pages", "страниц публикации %d, нет файлов страниц %s в %s", len(article.Pagesources), strings.Join(missing, ", "), articleDir)
+	}
+
+	entries, err := ioutil.ReadDir(articleDir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	for _, entry := range entries {
+		match := articlePageFilePattern.FindStringSubmatch(entry.Name())
+		if match == nil || entry.IsDir() {
+			continue
+		}
+		if num, _ := strconv.Atoi(match[1]); num > len(article.Pagesources) {
+			problems.field(ProblemPages, "pages", "страниц публикации %d, лишний файл страницы %s", len(article.Pagesources), filepath.Join(articleDir, entry.Name()))
+		}
+	}
+
+	return problems, nil
+}
+
 // createArticlesPage создаёт страницу аннотаций статей.
-// settingsDir — директория, в которой находятся шаблоны модуля публикаций.
-// articlesDir — исходная директория системы публикации статей.
-// destinationArticlesDir — целевая директория системы публикации статей.
-// templatesPath — директория шаблонов сайта.
-// articles — список статей.
-// domain — домен сайта.
-// sitemap — содержимое файла sitemap.
-func createArticlesPage(settingsDir string, articlesDir string, destinationArticlesDir string, templatesPath string, articles *[]Article, domain string, sitemap *string) error {
+// site — общие данные сайта, содержащие загруженные публикации; директории и домен берутся из его конфигурации.
+// sitemap — записи sitemap.
+// output — запись сформированных файлов в целевую директорию.
+func createArticlesPage(site *Site, sitemap *Sitemap, output *OutputWriter) error {
+	cfg := site.Config
+	destinationArticlesDir := cfg.DestinationArticlesDir()
+	articles := &site.Articles
+
 	// Проверяем, существует ли шаблон страницы списка публикаций.
-	articlesTemplate := filepath.Join(settingsDir, "articles.html")
+	articlesTemplate := filepath.Join(cfg.SettingsDir(), "articles.html")
 	if _, err := os.Stat(articlesTemplate); os.IsNotExist(err) {
 		return errors.New(ErrorMessages["parse_template_error"] + "отсутствует шаблон страницы списка статей.")
 	}
 
 	// Данные для парсинга шаблона.
 	data := struct {
+		Site       *Site
 		Fuseaction string
 		Articles   *[]Article
 	}{
+		site,
 		"articles.html",
 		articles,
 	}
 
-	content, err := ParseFileView(articlesTemplate, templatesPath, data, "articles")
-	if err != nil {
-		return errors.New(ErrorMessages["parse_template_error"] + err.Error())
+	sources := make([]string, 0, len(*articles))
+	for _, article := range *articles {
+		sources = append(sources, article.Source)
 	}
 
-	if err = ioutil.WriteFile(filepath.Join(destinationArticlesDir, "index.html"), []byte(content), 0755); err != nil {
-		return errors.New(ErrorMessages["error_creating_file"] + err.Error())
+	if err := output.Render(filepath.Join(destinationArticlesDir, "index.html"), articlesTemplate, cfg.TemplatesDir(), data, "articles", sources...); err != nil {
+		return err
 	}
 
 	// URL страницы на целевом сервере.
-	url := domain + "/articles/"
-	*sitemap += "<url><loc>" + url + "</loc></url>"
+	url := cfg.Domain + "/" + filepath.Base(destinationArticlesDir) + "/"
+	sitemap.Add(SitemapEntry{Kind: SitemapArticles, Loc: url, LastMod: latestModTime(sources...)})
 
 	return nil
 }
 
+// articlePageFile возвращает имя файла страницы публикации с номером page, начиная с нуля:
+// первая страница — index.html директории публикации, а при наличии шаблона оглавления article.html — 1.html.
+func articlePageFile(contentsTemplateEnabled bool, page int) string {
+	if page == 0 && !contentsTemplateEnabled {
+		return "index.html"
+	}
+
+	return strconv.Itoa(page+1) + ".html"
+}
+
 // createArticleFiles создаёт файлы указанной статьи.
-// settingsDir — директория, в которой находятся шаблоны модуля публикаций.
-// articlesDir — исходная директория системы публикации статей.
-// destinationArticlesDir — целевая директория системы публикации статей.
-// templatesPath — директория шаблонов сайта.
+// site — общие данные сайта; директории и домен берутся из его конфигурации.
 // article — статья.
-// domain — домен сайта.
-// sitemap — содержимое файла sitemap.
-func createArticleFiles(settingsDir string, articlesDir string, destinationArticlesDir string, templatesPath string, article Article, domain string, sitemap *string) error {
-	// Проверяем, существует ли директория статьи в целевой директории системы публикаций.
+// sitemap — записи sitemap.
+// output — запись сформированных файлов в целевую директорию.
+func createArticleFiles(site *Site, article Article, sitemap *Sitemap, output *OutputWriter) error {
+	cfg := site.Config
+	settingsDir := cfg.SettingsDir()
+	destinationArticlesDir := cfg.DestinationArticlesDir()
+	templatesPath := cfg.TemplatesDir()
+	domain := cfg.Domain
+
+	// Директория статьи в целевой директории системы публикаций создаётся при записи её страниц.
 	articleDestination := filepath.Join(destinationArticlesDir, article.Fuseaction)
-	if _, err := os.Stat(articleDestination); os.IsNotExist(err) {
-		// Пытаемся создать директорию статьи.
-		if err = os.Mkdir(articleDestination, 0755); err != nil {
-			return errors.New(ErrorMessages["error_creating_dir"] + err.Error())
-		}
-	}
 
 	// Проверяем, существует ли в исходной директории статей шаблон оглавления статьи.
 	contentsTemplateEnabled := true
@@ -167,6 +296,7 @@ func createArticleFiles(settingsDir string, articlesDir string, destinationArtic
 	} else {
 		// Формируем страницу контента статьи.
 		data := struct {
+			Site        *Site
 			Fuseaction  string
 			Title       string
 			Annotation  string
@@ -174,6 +304,7 @@ func createArticleFiles(settingsDir string, articlesDir string, destinationArtic
 			Description string
 			Pages       []string
 		}{
+			site,
 			"article.html",
 			article.Title,
 			article.Annotation,
@@ -182,24 +313,18 @@ func createArticleFiles(settingsDir string, articlesDir string, destinationArtic
 			article.Pagetitles,
 		}
 
-		content, err := ParseFileView(contentsTemplate, templatesPath, data, "article.html")
-		if err != nil {
-			return errors.New(ErrorMessages["parse_template_error"] + err.Error())
-		}
-
-		if err = ioutil.WriteFile(filepath.Join(articleDestination, "index.html"), []byte(content), 0755); err != nil {
-			return errors.New(ErrorMessages["error_creating_file"] + err.Error())
+		if err := output.Render(filepath.Join(articleDestination, "index.html"), contentsTemplate, templatesPath, data, "article.html", article.Source); err != nil {
+			return err
 		}
 
 		// URL страницы на целевом сервере.
-		url := domain + "/articles/" + article.Fuseaction + "/"
-		*sitemap += "<url><loc>" + url + "</loc></url>"
+		url := domain + "/" + filepath.Base(destinationArticlesDir) + "/" + article.Fuseaction + "/"
+		if !sitemapOptOut(article.Sitemap) {
+			sitemap.Add(SitemapEntry{Kind: SitemapArticle, Loc: url, LastMod: latestModTime(append([]string{article.Source}, article.Pagesources...)...)})
+		}
 	}
 
-	firstPage := "index.html"
-	if contentsTemplateEnabled {
-		firstPage = "1.html"
-	}
+	firstPage := articlePageFile(contentsTemplateEnabled, 0)
 
 	// Формируем файлы страниц.
 	pageTemplate := filepath.Join(settingsDir, "page.html")
@@ -213,7 +338,7 @@ func createArticleFiles(settingsDir string, articlesDir string, destinationArtic
 	}
 
 	for i := 0; i < len(article.Pagetitles); i++ {
-		pageContent := ""
+		pageContent := TrustedHTML("")
 		if i < len(article.Content) {
 			pageContent = article.Content[i]
 		}
@@ -221,10 +346,7 @@ func createArticleFiles(settingsDir string, articlesDir string, destinationArtic
 			continue
 		}
 
-		filename := firstPage
-		if i > 0 {
-			filename = strconv.Itoa(i+1) + ".html"
-		}
+		filename := articlePageFile(contentsTemplateEnabled, i)
 
 		nextpageTitle := ""
 		nextpageAddress := ""
@@ -245,9 +367,10 @@ func createArticleFiles(settingsDir string, articlesDir string, destinationArtic
 		}
 
 		data := struct {
+			Site         *Site
 			Fuseaction   string
 			Title        string
-			Content      string
+			Content      TrustedHTML
 			Keywords     string
 			Description  string
 			ThisTitle    string
@@ -259,6 +382,7 @@ func createArticleFiles(settingsDir string, articlesDir string, destinationArtic
 			PagesCount   int
 			PagesNumbers []int
 		}{
+			site,
 			"page.html",
 			article.Title,
 			pageContent,
@@ -274,50 +398,37 @@ func createArticleFiles(settingsDir string, articlesDir string, destinationArtic
 			pagesNumbers,
 		}
 
-		content, err := ParseFileView(pageTemplate, templatesPath, data, "page.html")
-		if err != nil {
-			return errors.New(ErrorMessages["parse_template_error"] + err.Error())
+		sources := []string{article.Source}
+		if i < len(article.Pagesources) && len(article.Pagesources[i]) > 0 {
+			sources = append(sources, article.Pagesources[i])
 		}
-
-		if err = ioutil.WriteFile(filepath.Join(articleDestination, filename), []byte(content), 0755); err != nil {
-			return errors.New(ErrorMessages["error_creating_file"] + err.Error())
+		if err := output.Render(filepath.Join(articleDestination, filename), pageTemplate, templatesPath, data, "page.html", sources...); err != nil {
+			return err
 		}
 
 		// URL страницы на целевом сервере.
-		url := domain + "/articles/" + article.Fuseaction + "/" + filename
-		*sitemap += "<url><loc>" + url + "</loc></url>"
+		url := domain + "/" + filepath.Base(destinationArticlesDir) + "/" + article.Fuseaction + "/" + filename
+		if !sitemapOptOut(article.Sitemap) {
+			sitemap.Add(SitemapEntry{Kind: SitemapArticle, Loc: url, LastMod: latestModTime(sources...)})
+		}
 	}
 
 	return nil
 }
 
 // CreateArticles формирует файлы публикаций.
-// settingsDir — директория файлов настроек сайта.
-// articlesDir — исходная директория файлов публикаций.
-// destinationArticlesDir — целевая директория файлов публикаций.
-// templatesDir — директория шаблонов сайта.
-// domain — домен сайта.
-// sitemap — содержимое файла sitemap.
-func CreateArticles(settingsDir string, articlesDir string, destinationArticlesDir string, templatesDir string, domain string, sitemap *string) error {
-	articles, err := loadArticles(articlesDir)
-	if err != nil {
-		return err
-	}
-
-	// Если в целевой директории отсутствует папка articles, создаём её.
-	if _, err := os.Stat(destinationArticlesDir); os.IsNotExist(err) {
-		if err = os.Mkdir(destinationArticlesDir, 0755); err != nil {
-			return err
-		}
-	}
-
-	if err = createArticlesPage(settingsDir, articlesDir, destinationArticlesDir, templatesDir, articles, domain, sitemap); err != nil {
+// site — общие данные сайта, содержащие загруженные публикации.
+// sitemap — записи sitemap.
+// output — запись сформированных файлов в целевую директорию.
+func CreateArticles(site *Site, sitemap *Sitemap, output *OutputWriter) error {
+	// Целевая директория публикаций создаётся при записи первой страницы (см. OutputWriter.writeFile).
+	if err := createArticlesPage(site, sitemap, output); err != nil {
 		return err
 	}
 
 	// Создаём файлы публикаций.
-	for _, article := range *articles {
-		if err = createArticleFiles(settingsDir, articlesDir, destinationArticlesDir, templatesDir, article, domain, sitemap); err != nil {
+	for _, article := range site.Articles {
+		if err := createArticleFiles(site, article, sitemap, output); err != nil {
 			return err
 		}
 	}
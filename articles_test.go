@@ -39,7 +39,7 @@ func TestLoadArticles_LoadsContentForEachPage(t *testing.T) {
 		t.Fatalf("не удалось создать вторую страницу статьи: %v", err)
 	}
 
-	articles, err := loadArticles(dir)
+	articles, err := loadArticles(dir, nil)
 	if err != nil {
 		t.Fatalf("loadArticles вернул ошибку: %v", err)
 	}
@@ -71,7 +71,7 @@ func TestLoadArticles_MissingContentFileKeepsContentLength(t *testing.T) {
 		t.Fatalf("не удалось создать первую страницу статьи: %v", err)
 	}
 
-	articles, err := loadArticles(dir)
+	articles, err := loadArticles(dir, nil)
 	if err != nil {
 		t.Fatalf("loadArticles вернул ошибку: %v", err)
 	}
@@ -87,9 +87,11 @@ func TestLoadArticles_MissingContentFileKeepsContentLength(t *testing.T) {
 func TestCreateArticleFiles_ContentShorterThanPageTitlesDoesNotPanic(t *testing.T) {
 	t.Parallel()
 
-	dir := t.TempDir()
-	settingsDir := filepath.Join(dir, "settings")
-	destinationDir := filepath.Join(dir, "dest")
+	cfg := newTestSiteConfig(t)
+	site := &Site{Config: cfg}
+	settingsDir := cfg.SettingsDir()
+	destinationDir := cfg.DestinationArticlesDir()
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
 	if err := os.MkdirAll(settingsDir, 0755); err != nil {
 		t.Fatalf("не удалось создать директорию настроек: %v", err)
 	}
@@ -107,11 +109,10 @@ func TestCreateArticleFiles_ContentShorterThanPageTitlesDoesNotPanic(t *testing.
 		Title:      "Статья",
 		Fuseaction: "article-1",
 		Pagetitles: []string{"Первая", "Вторая"},
-		Content:    []string{"Контент первой страницы"},
+		Content:    []TrustedHTML{"Контент первой страницы"},
 	}
-	sitemap := ""
 
-	if err := createArticleFiles(settingsDir, "", destinationDir, "", article, "https://example.test", &sitemap); err != nil {
+	if err := createArticleFiles(site, article, nil, newTestOutput(t, cfg.Destination)); err != nil {
 		t.Fatalf("createArticleFiles вернул ошибку: %v", err)
 	}
 
@@ -126,9 +127,11 @@ func TestCreateArticleFiles_ContentShorterThanPageTitlesDoesNotPanic(t *testing.
 func TestCreateArticleFiles_WithoutContentsTemplateUsesIndexForFirstPage(t *testing.T) {
 	t.Parallel()
 
-	dir := t.TempDir()
-	settingsDir := filepath.Join(dir, "settings")
-	destinationDir := filepath.Join(dir, "dest")
+	cfg := newTestSiteConfig(t)
+	site := &Site{Config: cfg}
+	settingsDir := cfg.SettingsDir()
+	destinationDir := cfg.DestinationArticlesDir()
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
 	if err := os.MkdirAll(settingsDir, 0755); err != nil {
 		t.Fatalf("не удалось создать директорию настроек: %v", err)
 	}
@@ -143,11 +146,10 @@ func TestCreateArticleFiles_WithoutContentsTemplateUsesIndexForFirstPage(t *test
 		Title:      "Статья",
 		Fuseaction: "article-1",
 		Pagetitles: []string{"Первая"},
-		Content:    []string{"Контент первой страницы"},
+		Content:    []TrustedHTML{"Контент первой страницы"},
 	}
-	sitemap := ""
 
-	if err := createArticleFiles(settingsDir, "", destinationDir, "", article, "https://example.test", &sitemap); err != nil {
+	if err := createArticleFiles(site, article, nil, newTestOutput(t, cfg.Destination)); err != nil {
 		t.Fatalf("createArticleFiles вернул ошибку: %v", err)
 	}
 
@@ -163,9 +165,11 @@ func TestCreateArticleFiles_WithoutContentsTemplateUsesIndexForFirstPage(t *test
 func TestCreateArticleFiles_MissingPageTemplate(t *testing.T) {
 	t.Parallel()
 
-	dir := t.TempDir()
-	settingsDir := filepath.Join(dir, "settings")
-	destinationDir := filepath.Join(dir, "dest")
+	cfg := newTestSiteConfig(t)
+	site := &Site{Config: cfg}
+	settingsDir := cfg.SettingsDir()
+	destinationDir := cfg.DestinationArticlesDir()
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
 	if err := os.MkdirAll(settingsDir, 0755); err != nil {
 		t.Fatalf("не удалось создать директорию настроек: %v", err)
 	}
@@ -173,9 +177,8 @@ func TestCreateArticleFiles_MissingPageTemplate(t *testing.T) {
 		t.Fatalf("не удалось создать целевую директорию: %v", err)
 	}
 
-	article := Article{Title: "Статья", Fuseaction: "article-1", Pagetitles: []string{"Первая"}, Content: []string{"Контент"}}
-	sitemap := ""
-	err := createArticleFiles(settingsDir, "", destinationDir, "", article, "https://example.test", &sitemap)
+	article := Article{Title: "Статья", Fuseaction: "article-1", Pagetitles: []string{"Первая"}, Content: []TrustedHTML{"Контент"}}
+	err := createArticleFiles(site, article, nil, newTestOutput(t, cfg.Destination))
 	if err == nil {
 		t.Fatal("ожидалась ошибка при отсутствии шаблона page.html")
 	}
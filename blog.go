@@ -1,5 +1,9 @@
 // Googol генератор статических html-страниц из шаблонов.
 // Модуль работы с блогом.
+// Пост может относиться к нескольким рубрикам (повторяющиеся элементы <tagid> или список <tags>) и выводится
+// в ленте каждой своей рубрики. Черновики (<draft>true</draft>) и посты с датой позже времени сборки
+// не публикуются: не попадают в ленты, рубрики, страницы постов и sitemap; параметры -drafts и -future
+// публикуют их для предварительного просмотра, список пропущенных постов выводится при сборке.
 
 package main
 
@@ -22,7 +26,11 @@ type Tag struct {
 	Id       int      `xml:"id,attr"`
 	Name     string   `xml:"name,attr"`
 	Epigraph string   `xml:"epigraph"`
-	Posts    int
+	// Адресное имя рубрики для шаблона адреса permalinks.tag, по умолчанию — название, преобразованное Slugify.
+	Slug  string `xml:"slug,attr"`
+	Posts int
+	// Адрес ленты рубрики на целевом сервере.
+	URL string
 }
 
 // TagsList описывает список рубрик блога.
@@ -34,22 +42,44 @@ type TagsList struct {
 // Post описывает пост блога.
 type Post struct {
 	// Загружаемые из файла поля.
-	Date             string `xml:"date"`
-	Author           string `xml:"author"`
-	Tagid            int    `xml:"tagid"`
-	Title            string `xml:"title"`
-	Sites            string `xml:"sites"`
-	Annotation       string `xml:"annotation"`
-	Short_annotation string `xml:"short_annotation"`
-	Content          string `xml:"content"`
+	Date             string      `xml:"date"`
+	Author           string      `xml:"author"`
+	Tagids           []int       `xml:"tagid"`
+	TagList          string      `xml:"tags"`
+	Title            string      `xml:"title"`
+	Sites            string      `xml:"sites"`
+	Annotation       string      `xml:"annotation"`
+	Short_annotation string      `xml:"short_annotation"`
+	Content          TrustedHTML `xml:"content"`
+	// Формат текста поста: html (по умолчанию) или markdown.
+	Format string `xml:"format"`
+	// false исключает страницу поста из sitemap.
+	Sitemap string `xml:"sitemap"`
+	// true — черновик: пост не публикуется без параметра -drafts.
+	Draft string `xml:"draft"`
+	// Ключевые слова поста, разделённые запятой, для подбора похожих постов.
+	Keywords string `xml:"keywords"`
+	// Старые адреса поста, разделённые символом |, с которых формируются перенаправления.
+	Aliases string `xml:"aliases"`
 
 	// Вычисляемые поля.
 	Fuseaction string
-	Tag        string
-	SortDate   time.Time
-	Day        int
-	Year       int
-	Month      string
+	Source     string
+	// Основная (первая) рубрика поста: id и название.
+	Tagid int `xml:"-"`
+	Tag   string
+	// Адрес страницы поста на целевом сервере.
+	URL string `xml:"-"`
+	// Все рубрики поста в порядке указания: {{range .Blogpost.Tags}}{{.Name}}{{end}}.
+	Tags     []Tag `xml:"-"`
+	SortDate time.Time
+	Day      int
+	Year     int
+	Month    string
+	// Файл <имя поста>.md с текстом поста в формате Markdown, пустая строка если его нет.
+	MarkdownSource string
+	// Дополнительные поля заголовка (front matter) файла поста.
+	Params map[string]interface{} `xml:"-"`
 }
 
 // SortedBlogPostList используется для сортировки списка постов по дате.
@@ -59,13 +89,56 @@ func (p SortedBlogPostList) Len() int           { return len(p) }
 func (p SortedBlogPostList) Less(i, j int) bool { return p[i].SortDate.Before(p[j].SortDate) }
 func (p SortedBlogPostList) Swap(i, j int)      { p[i], p[j] = p[j], p[i] }
 
+// SkippedPost описывает пост, не опубликованный сборкой.
+type SkippedPost struct {
+	// Исходный файл поста.
+	Source string
+	// Заголовок поста.
+	Title string
+	// Причина: черновик или дата публикации позже времени сборки.
+	Reason string
+}
+
+// postFlagSet проверяет, что логическое поле поста (draft) включено.
+func postFlagSet(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "true", "yes", "1", "on":
+		return true
+	}
+
+	return false
+}
+
+// publishedPosts разделяет посты на публикуемые и пропущенные.
+// Черновики публикуются только при drafts, посты с датой позже now — только при future.
+func publishedPosts(posts []Post, now time.Time, drafts bool, future bool) ([]Post, []SkippedPost) {
+	published := make([]Post, 0, len(posts))
+	var skipped []SkippedPost
+	for _, post := range posts {
+		switch {
+		case !drafts && postFlagSet(post.Draft):
+			skipped = append(skipped, SkippedPost{Source: post.Source, Title: post.Title, Reason: "черновик"})
+		case !future && post.SortDate.After(now):
+			skipped = append(skipped, SkippedPost{Source: post.Source, Title: post.Title, Reason: "дата публикации " + post.Date})
+		default:
+			published = append(published, post)
+		}
+	}
+
+	return published, skipped
+}
+
 // blogPageData описывает данные для шаблона страницы ленты блога.
 type blogPageData struct {
-	Fuseaction     string
-	Tags           []Tag
-	Blog           SortedBlogPostList
-	Pagenum        int
-	Next_page      int
+	Site       *Site
+	Fuseaction string
+	Tags       []Tag
+	Blog       SortedBlogPostList
+	Pagenum    int
+	Next_page  int
+	// Адреса предыдущей и следующей страниц ленты, пустые строки для первой и последней страниц.
+	Prev_url       string
+	Next_url       string
 	Total          int
 	Tagid          int
 	Posts_per_page int
@@ -93,6 +166,7 @@ func loadTags(settingsDir string) (*TagsList, error) {
 
 	for i := range tags.Tags {
 		tags.Tags[i].Posts = 0
+		tags.Tags[i].Slug = tagSlug(tags.Tags[i])
 	}
 
 	return &tags, nil
@@ -113,8 +187,102 @@ func findTagByID(tags *TagsList, id int) *Tag {
 	return nil
 }
 
+// postTagIDs возвращает id рубрик поста: значения элементов <tagid> и элементы списка <tags>,
+// разделённые символами | или запятой. Элемент списка — id или название рубрики.
+// Повторы пропускаются; пост без рубрик и пост с неизвестной рубрикой — ошибка.
+func postTagIDs(post Post, tags *TagsList) ([]int, error) {
+	ids := []int{}
+	seen := map[int]bool{}
+	add := func(id int) error {
+		if findTagByID(tags, id) == nil {
+			return fmt.Errorf("неизвестный tagid=%d", id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+		return nil
+	}
+
+	for _, id := range post.Tagids {
+		if err := add(id); err != nil {
+			return nil, err
+		}
+	}
+	for _, item := range strings.FieldsFunc(post.TagList, func(r rune) bool { return r == '|' || r == ',' }) {
+		item = strings.TrimSpace(item)
+		if len(item) == 0 {
+			continue
+		}
+		if id, err := strconv.Atoi(item); err == nil {
+			if err = add(id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		tag := findTagByName(tags, item)
+		if tag == nil {
+			return nil, fmt.Errorf("неизвестная рубрика %q", item)
+		}
+		if err := add(tag.Id); err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) == 0 {
+		return nil, errors.New("не указана рубрика (tagid или tags)")
+	}
+
+	return ids, nil
+}
+
+// findTagByName ищет рубрику по названию и возвращает указатель на неё.
+func findTagByName(tags *TagsList, name string) *Tag {
+	if tags == nil {
+		return nil
+	}
+
+	for i := range tags.Tags {
+		if tags.Tags[i].Name == name {
+			return &tags.Tags[i]
+		}
+	}
+
+	return nil
+}
+
+// countTagPosts пересчитывает количество постов рубрик и заполняет рубрики постов (Post.Tags)
+// с итоговыми счётчиками. Пост учитывается в каждой своей рубрике.
+func countTagPosts(posts []Post, tags *TagsList) {
+	if tags == nil {
+		return
+	}
+
+	for i := range tags.Tags {
+		tags.Tags[i].Posts = 0
+	}
+	for _, post := range posts {
+		for _, id := range post.Tagids {
+			if tag := findTagByID(tags, id); tag != nil {
+				tag.Posts++
+			}
+		}
+	}
+	for i := range posts {
+		posts[i].Tags = make([]Tag, 0, len(posts[i].Tagids))
+		for _, id := range posts[i].Tagids {
+			if tag := findTagByID(tags, id); tag != nil {
+				posts[i].Tags = append(posts[i].Tags, *tag)
+			}
+		}
+	}
+}
+
 // handleBlogFiles обходит поддиректории и обрабатывает xml-файлы постов блога.
-func handleBlogFiles(posts *SortedBlogPostList, tags *TagsList, totalPosts *int) filepath.WalkFunc {
+// Проблемы постов передаются проверке validator; пост с ошибкой пропускается.
+func handleBlogFiles(posts *SortedBlogPostList, tags *TagsList, totalPosts *int, validator *Validator) filepath.WalkFunc {
+	// Исходные файлы постов по идентификатору для поиска повторов в разных поддиректориях.
+	sources := map[string]string{}
+
 	return func(currentPath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -124,37 +292,77 @@ func handleBlogFiles(posts *SortedBlogPostList, tags *TagsList, totalPosts *int)
 		}
 
 		_, filename := filepath.Split(currentPath)
-		if filepath.Ext(filename) != ".xml" {
+
+		var post Post
+		var raw []byte
+		if filepath.Ext(filename) == ".xml" {
+			raw, err = ioutil.ReadFile(currentPath)
+			if err != nil {
+				return err
+			}
+			if problem := decodeXMLRecord(currentPath, raw, &post); problem != nil {
+				return validator.Report(*problem)
+			}
+
+			// Текст поста в формате Markdown преобразуется в HTML.
+			post.Content, post.MarkdownSource, err = markdownContent(currentPath, post.Format, string(post.Content))
+			if err != nil {
+				return err
+			}
+		} else if isFrontMatterCandidate(currentPath) {
+			// Пост из файла с заголовком (front matter), файлы без заголовка пропускаются.
+			ok, err := loadFrontMatterFile(currentPath, &post, "Content")
+			if err != nil {
+				return validator.Report(frontMatterProblem(currentPath, err))
+			}
+			if !ok {
+				return nil
+			}
+		} else {
 			return nil
 		}
 
-		raw, err := ioutil.ReadFile(currentPath)
-		if err != nil {
-			return err
-		}
+		// Уникальный строковый идентификатор поста.
+		post.Fuseaction = strings.TrimSuffix(filename, filepath.Ext(filename))
 
-		var post Post
-		if err = xml.Unmarshal(raw, &post); err != nil {
-			return err
+		problems := newRecordProblems(currentPath, "post", raw)
+		if len(strings.TrimSpace(post.Title)) == 0 {
+			problems.field(ProblemRequired, "title", "не указан заголовок поста (title)")
 		}
-
-		tag := findTagByID(tags, post.Tagid)
-		if tag == nil {
-			return fmt.Errorf("пост %s содержит неизвестный tagid=%d", currentPath, post.Tagid)
+		if len(strings.TrimSpace(post.Date)) == 0 {
+			problems.field(ProblemRequired, "date", "не указана дата поста (date)")
+		} else if post.SortDate, err = time.Parse("02.01.2006", post.Date); err != nil {
+			problems.field(ProblemDate, "date", "некорректная дата %q, ожидается ДД.ММ.ГГГГ", post.Date)
 		}
-
-		post.SortDate, err = time.Parse("02.01.2006", post.Date)
-		if err != nil {
-			return fmt.Errorf("пост %s содержит некорректную дату %q: %w", currentPath, post.Date, err)
+		// Рубрики поста: элементы <tagid> и список <tags>.
+		ids, err := postTagIDs(post, tags)
+		switch {
+		case err != nil && len(post.Tagids) == 0 && len(strings.TrimSpace(post.TagList)) == 0:
+			problems.field(ProblemRequired, "tagid", "%v", err)
+		case err != nil && len(post.Tagids) > 0:
+			problems.field(ProblemTag, "tagid", "%v", err)
+		case err != nil:
+			problems.field(ProblemTag, "tags", "%v", err)
+		}
+		if len(strings.TrimSpace(string(post.Content))) == 0 {
+			problems.field(ProblemEmpty, "content", "пустой текст поста")
+		}
+		if source, ok := sources[post.Fuseaction]; ok {
+			problems.add(ProblemDuplicate, "идентификатор поста %q совпадает с постом %s", post.Fuseaction, source)
 		}
+		if skip, err := problems.report(validator); skip || err != nil {
+			return err
+		}
+		sources[post.Fuseaction] = currentPath
 
 		*totalPosts++
-		tag.Posts++
 
-		// Уникальный строковый идентификатор поста.
-		post.Fuseaction = strings.TrimSuffix(filename, filepath.Ext(filename))
-		// Название рубрики поста.
-		post.Tag = tag.Name
+		// Исходный файл поста.
+		post.Source = currentPath
+		// Рубрики поста; основная рубрика — первая из указанных.
+		post.Tagids = ids
+		post.Tagid = ids[0]
+		post.Tag = findTagByID(tags, ids[0]).Name
 		// Поля даты для шаблонов.
 		post.Day = post.SortDate.Day()
 		post.Month = RussianMonth[post.SortDate.Month()]
@@ -170,13 +378,14 @@ func handleBlogFiles(posts *SortedBlogPostList, tags *TagsList, totalPosts *int)
 // loadBlog загружает список постов блога.
 // postsSourceDir — исходная директория постов блога.
 // tags — список рубрик блога.
-func loadBlog(postsSourceDir string, tags *TagsList) (*SortedBlogPostList, int, error) {
+// validator — проверка исходных записей; nil прерывает загрузку на первой ошибке.
+func loadBlog(postsSourceDir string, tags *TagsList, validator *Validator) (*SortedBlogPostList, int, error) {
 	var posts SortedBlogPostList
 	totalPosts := 0
 
 	if _, err := os.Stat(postsSourceDir); err == nil {
 		// Обрабатываем все файлы с расширением xml из директории блога и её поддиректорий.
-		err = filepath.Walk(postsSourceDir, handleBlogFiles(&posts, tags, &totalPosts))
+		err = filepath.Walk(postsSourceDir, handleBlogFiles(&posts, tags, &totalPosts, validator))
 		if err != nil {
 			return nil, 0, err
 		}
@@ -184,18 +393,48 @@ func loadBlog(postsSourceDir string, tags *TagsList) (*SortedBlogPostList, int,
 		return nil, 0, err
 	}
 
-	// Сортируем список постов блога.
+	// Сортируем список постов блога и считаем посты рубрик.
 	sort.Sort(sort.Reverse(posts))
+	countTagPosts(posts, tags)
 
 	return &posts, totalPosts, nil
 }
 
-// writeBlogFeedPages формирует страницы ленты блога.
-func writeBlogFeedPages(blogTemplatePath string, templatesDir string, targetDir string, activeTags []Tag, posts []Post, totalPosts int, tagID int, postsPerPage int) error {
+// postSources возвращает исходные файлы постов.
+func postSources(posts []Post) []string {
+	sources := make([]string, 0, len(posts))
+	for _, post := range posts {
+		sources = append(sources, post.Source)
+		if len(post.MarkdownSource) > 0 {
+			sources = append(sources, post.MarkdownSource)
+		}
+	}
+
+	return sources
+}
+
+// feedPage описывает страницу ленты постов.
+type feedPage struct {
+	// Посты страницы.
+	posts []Post
+	// Номер страницы, 1 если есть следующая страница.
+	num  int
+	next int
+	// Адрес страницы относительно адреса ленты, пустая строка для первой страницы.
+	path string
+	// Адреса предыдущей и следующей страниц, пустые строки для первой и последней страниц.
+	prevURL string
+	nextURL string
+}
+
+// feedPages разбивает посты на страницы ленты по postsPerPage постов; лента без постов состоит из одной
+// пустой страницы. pageURL — адрес ленты на целевом сервере.
+func feedPages(permalinks PermalinkOptions, pageURL string, posts []Post, postsPerPage int) ([]feedPage, error) {
 	if postsPerPage <= 0 {
-		return errors.New("количество постов на страницу должно быть больше нуля")
+		return nil, errors.New("количество постов на страницу должно быть больше нуля")
 	}
 
+	var pages []feedPage
 	currentPage := 1
 	for start := 0; start < len(posts) || start == 0; start += postsPerPage {
 		end := start + postsPerPage
@@ -203,35 +442,15 @@ func writeBlogFeedPages(blogTemplatePath string, templatesDir string, targetDir
 			end = len(posts)
 		}
 
-		nextPage := 0
-		if end < len(posts) {
-			nextPage = 1
-		}
-
-		data := blogPageData{
-			Fuseaction:     "blog.html",
-			Tags:           activeTags,
-			Blog:           SortedBlogPostList(posts[start:end]),
-			Pagenum:        currentPage,
-			Next_page:      nextPage,
-			Total:          totalPosts,
-			Tagid:          tagID,
-			Posts_per_page: postsPerPage,
-		}
-
-		content, err := ParseFileView(blogTemplatePath, templatesDir, data, "blog.html")
-		if err != nil {
-			return errors.New(ErrorMessages["parse_template_error"] + err.Error())
-		}
-
-		filename := "index.html"
+		page := feedPage{posts: posts[start:end], num: currentPage, path: permalinks.FeedPagePath(currentPage)}
 		if currentPage > 1 {
-			filename = strconv.Itoa(currentPage) + ".html"
+			page.prevURL = pageURL + permalinks.FeedPagePath(currentPage-1)
 		}
-
-		if err = ioutil.WriteFile(filepath.Join(targetDir, filename), []byte(content), 0755); err != nil {
-			return errors.New(ErrorMessages["error_creating_file"] + err.Error())
+		if end < len(posts) {
+			page.next = 1
+			page.nextURL = pageURL + permalinks.FeedPagePath(currentPage+1)
 		}
+		pages = append(pages, page)
 
 		currentPage++
 		if end == len(posts) {
@@ -239,54 +458,79 @@ func writeBlogFeedPages(blogTemplatePath string, templatesDir string, targetDir
 		}
 	}
 
-	return nil
+	return pages, nil
 }
 
-// CreateBlog формирует файлы блога.
-// settingsDir — директория файлов настроек сайта.
-// destinationBlogDir — целевая директория файлов публикаций.
-// postsSourceDir — исходная директория постов блога.
-// templatesDir — директория шаблонов сайта.
-// domain — домен сайта.
-// sitemap — содержимое файла sitemap.
-func CreateBlog(settingsDir string, destinationBlogDir string, postsSourceDir string, templatesDir string, domain string, sitemap *string) error {
-	// Количество постов блога на страницу.
-	postsPerPage := 10
-
-	// Загружаем список рубрик блога.
-	tags, err := loadTags(settingsDir)
+// writeBlogFeedPages формирует страницы ленты блога по шаблону blog.html; шаблоны, количество постов
+// на страницу и адреса страниц берутся из конфигурации сайта site.
+// targetDir — целевая директория ленты.
+// pageURL — адрес директории ленты на целевом сервере, страницы ленты добавляются в sitemap.
+// tagID — рубрика ленты, 0 для общей ленты блога.
+// posts — посты ленты, отсортированные по убыванию даты.
+func writeBlogFeedPages(site *Site, targetDir string, pageURL string, tagID int, posts []Post, sitemap *Sitemap, output *OutputWriter) error {
+	cfg := site.Config
+	postsPerPage := cfg.PostsPerPage
+	pages, err := feedPages(cfg.Permalinks, pageURL, posts, postsPerPage)
 	if err != nil {
 		return err
 	}
+	for _, page := range pages {
+		data := blogPageData{
+			Site:           site,
+			Fuseaction:     "blog.html",
+			Tags:           site.Tags,
+			Blog:           SortedBlogPostList(page.posts),
+			Pagenum:        page.num,
+			Next_page:      page.next,
+			Prev_url:       page.prevURL,
+			Next_url:       page.nextURL,
+			Total:          len(posts),
+			Tagid:          tagID,
+			Posts_per_page: postsPerPage,
+		}
 
-	// Если в целевой директории отсутствует папка блога blog, создаём её.
-	if _, err := os.Stat(destinationBlogDir); os.IsNotExist(err) {
-		if err = os.Mkdir(destinationBlogDir, 0755); err != nil {
+		if err := output.Render(permalinkFile(targetDir, page.path), filepath.Join(cfg.SettingsDir(), "blog.html"), cfg.TemplatesDir(), data, "blog.html", postSources(page.posts)...); err != nil {
 			return err
 		}
-	}
 
-	// Если в целевой папке блога отсутствует папка постов posts, создаём её.
-	postsDir := filepath.Join(destinationBlogDir, "posts")
-	if _, err := os.Stat(postsDir); os.IsNotExist(err) {
-		if err = os.Mkdir(postsDir, 0755); err != nil {
-			return err
+		// Первая страница ленты блога или рубрики и следующие страницы ленты.
+		entry := SitemapEntry{Kind: SitemapBlog, Loc: pageURL}
+		if tagID != 0 {
+			entry.Kind = SitemapTag
+		}
+		if page.num > 1 {
+			entry.Kind = SitemapBlogPage
+			entry.Loc = pageURL + page.path
 		}
+		if len(page.posts) > 0 {
+			entry.LastMod = page.posts[0].SortDate
+		}
+		sitemap.Add(entry)
 	}
 
-	// Загружаем список постов блога.
-	posts, totalPosts, err := loadBlog(postsSourceDir, tags)
-	if err != nil {
+	return nil
+}
+
+// CreateBlog формирует файлы блога.
+// site — общие данные сайта, содержащие загруженные рубрики и посты блога.
+// sitemap — записи sitemap.
+// output — запись сформированных файлов в целевую директорию.
+func CreateBlog(site *Site, sitemap *Sitemap, output *OutputWriter) error {
+	cfg := site.Config
+	settingsDir := cfg.SettingsDir()
+	templatesDir := cfg.TemplatesDir()
+	// Целевая директория блога создаётся при записи первой страницы (см. OutputWriter.writeFile).
+	destinationBlogDir := cfg.DestinationBlogDir()
+
+	// Адреса рубрик и постов задаются шаблонами permalinks и не должны совпадать.
+	if err := checkBlogPaths(site); err != nil {
 		return err
 	}
 
-	// Формируем список рубрик блога, в которых есть посты.
-	activeTags := []Tag{}
-	for _, value := range tags.Tags {
-		if value.Posts > 0 {
-			activeTags = append(activeTags, value)
-		}
-	}
+	// Посты и рубрики блога, в которых есть посты, загружены вместе с данными сайта.
+	posts := site.Posts
+	totalPosts := len(posts)
+	activeTags := site.Tags
 
 	// Проверяем наличие в директории настроек сайта шаблона ленты блога blog.html.
 	blogTemplatePath := filepath.Join(settingsDir, "blog.html")
@@ -295,28 +539,43 @@ func CreateBlog(settingsDir string, destinationBlogDir string, postsSourceDir st
 	}
 
 	// Формируем ленту блога без фильтрации.
-	if err = writeBlogFeedPages(blogTemplatePath, templatesDir, destinationBlogDir, activeTags, []Post(*posts), totalPosts, 0, postsPerPage); err != nil {
+	if err := writeBlogFeedPages(site, destinationBlogDir, cfg.BlogURL()+"/", 0, posts, sitemap, output); err != nil {
+		return err
+	}
+
+	// Формируем ленты RSS и Atom блога.
+	if err := writeBlogFeeds(cfg.Feed, destinationBlogDir, cfg.BlogURL(), posts, output); err != nil {
 		return err
 	}
 
 	// Для всех активных рубрик блога формируем собственный файл ленты.
-	tagPosts := make(map[string][]Post)
+	// Пост с несколькими рубриками попадает в ленту каждой из них.
+	tagPosts := make(map[int][]Post)
 	for _, value := range activeTags {
-		tagPosts[value.Name] = []Post{}
+		tagPosts[value.Id] = []Post{}
 	}
-	for _, value := range *posts {
-		tagPosts[value.Tag] = append(tagPosts[value.Tag], value)
+	for _, value := range posts {
+		for _, id := range value.Tagids {
+			tagPosts[id] = append(tagPosts[id], value)
+		}
 	}
 
 	for _, value := range activeTags {
-		targetDir := filepath.Join(destinationBlogDir, strconv.Itoa(value.Id))
-		if _, err := os.Stat(targetDir); os.IsNotExist(err) {
-			if err = os.Mkdir(targetDir, 0755); err != nil {
-				return err
-			}
+		// Лента рубрики формируется в директории адреса permalinks.tag.
+		targetDir := filepath.Dir(cfg.PermalinkFile(cfg.TagPath(value)))
+
+		if err := writeBlogFeedPages(site, targetDir, value.URL, value.Id, tagPosts[value.Id], sitemap, output); err != nil {
+			return err
+		}
+
+		if err := writeTagFeed(cfg.Feed, targetDir, cfg.BlogURL(), value.URL, value, tagPosts[value.Id], output); err != nil {
+			return err
 		}
+	}
 
-		if err = writeBlogFeedPages(blogTemplatePath, templatesDir, targetDir, activeTags, tagPosts[value.Name], len(tagPosts[value.Name]), value.Id, postsPerPage); err != nil {
+	// Формируем архив блога по годам и месяцам, если есть шаблон archive.html.
+	if archiveEnabled(cfg) {
+		if err := writeBlogArchive(site, filepath.Join(settingsDir, ArchiveTemplate), templatesDir, sitemap, output); err != nil {
 			return err
 		}
 	}
@@ -327,32 +586,39 @@ func CreateBlog(settingsDir string, destinationBlogDir string, postsSourceDir st
 		return errors.New("Не найден файл шаблона поста блога")
 	}
 
-	for _, value := range *posts {
+	// Соседние посты и похожие посты передаются шаблону поста (см. related.go).
+	related := newRelatedIndex(posts)
+	for i, value := range posts {
 		data := struct {
-			Fuseaction string
-			Tags       []Tag
-			Blogpost   Post
-			Total      int
+			Site         *Site
+			Fuseaction   string
+			Tags         []Tag
+			Blogpost     Post
+			Total        int
+			PrevPost     *Post
+			NextPost     *Post
+			PrevInTag    *Post
+			NextInTag    *Post
+			RelatedPosts []Post
 		}{
-			"post.html",
-			activeTags,
-			value,
-			totalPosts,
+			Site:         site,
+			Fuseaction:   "post.html",
+			Tags:         activeTags,
+			Blogpost:     value,
+			Total:        totalPosts,
+			RelatedPosts: related.Related(i, cfg.RelatedPosts),
 		}
+		data.PrevPost, data.NextPost = postNeighbours(posts, value.Source)
+		data.PrevInTag, data.NextInTag = postNeighbours(tagPosts[value.Tagid], value.Source)
 
-		content, err := ParseFileView(postTemplatePath, templatesDir, data, "post.html")
-		if err != nil {
-			return errors.New(ErrorMessages["parse_template_error"] + err.Error())
+		if err := output.Render(cfg.PermalinkFile(cfg.PostPath(value)), postTemplatePath, templatesDir, data, "post.html", postSources([]Post{value})...); err != nil {
+			return err
 		}
 
-		if err = ioutil.WriteFile(filepath.Join(postsDir, value.Fuseaction+".html"), []byte(content), 0755); err != nil {
-			return errors.New(ErrorMessages["error_creating_file"] + err.Error())
+		// Добавляем страницу поста в sitemap.
+		if !sitemapOptOut(value.Sitemap) {
+			sitemap.Add(SitemapEntry{Kind: SitemapPost, Loc: value.URL, LastMod: value.SortDate})
 		}
-
-		// URL страницы поста блога на целевом сервере.
-		url := domain + "/blog/posts/" + value.Fuseaction + ".html"
-		// Добавляем страницу в sitemap.xml.
-		*sitemap += "<url><loc>" + url + "</loc></url>"
 	}
 
 	return nil
@@ -67,7 +67,7 @@ func TestLoadBlog_InvalidTagID(t *testing.T) {
 	writeBlogPostXML(t, postsDir, "bad.xml", 999, "01.01.2026", "Плохой tagid")
 
 	tags := &TagsList{Tags: []Tag{{Id: 1, Name: "Новости"}}}
-	_, _, err := loadBlog(postsDir, tags)
+	_, _, err := loadBlog(postsDir, tags, nil)
 	if err == nil {
 		t.Fatal("ожидалась ошибка для неизвестного tagid")
 	}
@@ -89,7 +89,7 @@ func TestLoadBlog_SortsPostsByDateDescAndCountsTags(t *testing.T) {
 	writeBlogPostXML(t, postsDir, "middle.xml", 1, "01.01.2025", "Средний")
 
 	tags := &TagsList{Tags: []Tag{{Id: 1, Name: "Новости"}, {Id: 2, Name: "Разборы"}}}
-	posts, total, err := loadBlog(postsDir, tags)
+	posts, total, err := loadBlog(postsDir, tags, nil)
 	if err != nil {
 		t.Fatalf("loadBlog вернул ошибку: %v", err)
 	}
@@ -104,26 +104,103 @@ func TestLoadBlog_SortsPostsByDateDescAndCountsTags(t *testing.T) {
 	}
 }
 
-func TestWriteBlogFeedPages_DoesNotLoseTenthPost(t *testing.T) {
+func TestLoadBlog_MultipleTags(t *testing.T) {
 	t.Parallel()
 
 	dir := t.TempDir()
-	templatePath := filepath.Join(dir, "blog.html")
-	targetDir := filepath.Join(dir, "out")
+	writeTestFile(t, filepath.Join(dir, "single.xml"), `<post><date>01.01.2026</date><tagid>2</tagid><title>Одна</title></post>`)
+	writeTestFile(t, filepath.Join(dir, "repeated.xml"), `<post><date>02.01.2026</date><tagid>1</tagid><tagid>3</tagid><tagid>1</tagid><title>Повтор</title></post>`)
+	writeTestFile(t, filepath.Join(dir, "list.xml"), `<post><date>03.01.2026</date><tags>Обзоры | 1</tags><title>Список</title></post>`)
+	writeTestFile(t, filepath.Join(dir, "front.md"), "---\ntitle: Заголовок\ndate: 04.01.2026\ntagid: [3, 2]\ntags: [Новости]\n---\nТекст\n")
+
+	tags := &TagsList{Tags: []Tag{{Id: 1, Name: "Новости"}, {Id: 2, Name: "Обзоры"}, {Id: 3, Name: "Заметки"}}}
+	posts, total, err := loadBlog(dir, tags, nil)
+	if err != nil {
+		t.Fatalf("loadBlog вернул ошибку: %v", err)
+	}
+	if total != 4 {
+		t.Fatalf("total = %d, ожидалось 4", total)
+	}
+	expected := map[string]string{"Заголовок": "3:Заметки:Заметки,Обзоры,Новости", "Список": "2:Обзоры:Обзоры,Новости", "Повтор": "1:Новости:Новости,Заметки", "Одна": "2:Обзоры:Обзоры"}
+	for _, post := range *posts {
+		names := []string{}
+		for _, tag := range post.Tags {
+			names = append(names, tag.Name)
+		}
+		if actual := fmt.Sprintf("%d:%s:%s", post.Tagid, post.Tag, strings.Join(names, ",")); actual != expected[post.Title] {
+			t.Errorf("рубрики поста %s = %s, ожидалось %s", post.Title, actual, expected[post.Title])
+		}
+	}
+	if tags.Tags[0].Posts != 3 || tags.Tags[1].Posts != 3 || tags.Tags[2].Posts != 2 {
+		t.Errorf("счётчики рубрик некорректны: %+v", tags.Tags)
+	}
+
+	for name, content := range map[string]string{
+		"name.xml": `<post><date>01.01.2026</date><tags>Новости|Неизвестная</tags><title>Т</title></post>`,
+		"id.xml":   `<post><date>01.01.2026</date><tagid>1</tagid><tagid>9</tagid><title>Т</title></post>`,
+		"none.xml": `<post><date>01.01.2026</date><title>Т</title></post>`,
+	} {
+		broken := t.TempDir()
+		writeTestFile(t, filepath.Join(broken, name), content)
+		if _, _, err := loadBlog(broken, tags, nil); err == nil {
+			t.Errorf("%s: ожидалась ошибка рубрики", name)
+		}
+	}
+}
+
+func TestBuild_PostInSeveralTags(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости"></tag><tag id="2" name="Обзоры"></tag></tags>`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "blog.html"), `{{range .Blog}}{{.Title}};{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "post.html"), `{{range .Blogpost.Tags}}<a href="/blog/{{.Id}}/">{{.Name}} ({{.Posts}})</a>{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "both.xml"), `<post><date>02.02.2026</date><tagid>1</tagid><tagid>2</tagid><title>Обе</title></post>`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "news.xml"), `<post><date>01.02.2026</date><tagid>1</tagid><title>Новость</title></post>`)
+	if err := Build(cfg); err != nil {
+		t.Fatalf("Build вернул ошибку: %v", err)
+	}
+
+	files := readTree(t, cfg.Destination)
+	for rel, expected := range map[string]string{
+		filepath.Join("blog", "index.html"):         "Обе;Новость;",
+		filepath.Join("blog", "1", "index.html"):    "Обе;Новость;",
+		filepath.Join("blog", "2", "index.html"):    "Обе;",
+		filepath.Join("blog", "posts", "both.html"): `<a href="/blog/1/">Новости (2)</a><a href="/blog/2/">Обзоры (1)</a>`,
+	} {
+		if files[rel] != expected {
+			t.Errorf("%s = %q, ожидалось %q", rel, files[rel], expected)
+		}
+	}
+	for _, feed := range []string{filepath.Join("blog", "rss.xml"), filepath.Join("blog", "2", "rss.xml"), filepath.Join("blog", "atom.xml")} {
+		if !strings.Contains(files[feed], "Новости") || !strings.Contains(files[feed], "Обзоры") {
+			t.Errorf("лента %s не содержит обе рубрики поста:\n%s", feed, files[feed])
+		}
+	}
+}
+
+func TestWriteBlogFeedPages_DoesNotLoseTenthPost(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.PostsPerPage = 10
+	site := &Site{Config: cfg}
+	templatePath := filepath.Join(cfg.SettingsDir(), "blog.html")
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	targetDir := filepath.Join(cfg.Destination, "out")
 	if err := os.MkdirAll(targetDir, 0755); err != nil {
 		t.Fatalf("не удалось создать целевую директорию: %v", err)
 	}
-	if err := os.WriteFile(templatePath, []byte(`{{range .Blog}}{{.Title}}
-{{end}}`), 0644); err != nil {
-		t.Fatalf("не удалось создать шаблон блога: %v", err)
-	}
+	writeTestFile(t, templatePath, `{{range .Blog}}{{.Title}}
+{{end}}`)
 
 	posts := make([]Post, 11)
 	for i := 0; i < 11; i++ {
 		posts[i] = Post{Title: fmt.Sprintf("post-%02d", i+1)}
 	}
 
-	if err := writeBlogFeedPages(templatePath, "", targetDir, nil, posts, len(posts), 0, 10); err != nil {
+	if err := writeBlogFeedPages(site, targetDir, "", 0, posts, nil, newTestOutput(t, cfg.Destination)); err != nil {
 		t.Fatalf("writeBlogFeedPages вернул ошибку: %v", err)
 	}
 
@@ -152,17 +229,18 @@ func TestWriteBlogFeedPages_DoesNotLoseTenthPost(t *testing.T) {
 func TestWriteBlogFeedPages_EmptyBlogCreatesIndex(t *testing.T) {
 	t.Parallel()
 
-	dir := t.TempDir()
-	templatePath := filepath.Join(dir, "blog.html")
-	targetDir := filepath.Join(dir, "out")
+	cfg := newTestSiteConfig(t)
+	cfg.PostsPerPage = 10
+	site := &Site{Config: cfg}
+	templatePath := filepath.Join(cfg.SettingsDir(), "blog.html")
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	targetDir := filepath.Join(cfg.Destination, "out")
 	if err := os.MkdirAll(targetDir, 0755); err != nil {
 		t.Fatalf("не удалось создать целевую директорию: %v", err)
 	}
-	if err := os.WriteFile(templatePath, []byte(`total={{.Total}} posts={{len .Blog}}`), 0644); err != nil {
-		t.Fatalf("не удалось создать шаблон блога: %v", err)
-	}
+	writeTestFile(t, templatePath, `total={{.Total}} posts={{len .Blog}}`)
 
-	if err := writeBlogFeedPages(templatePath, "", targetDir, nil, nil, 0, 0, 10); err != nil {
+	if err := writeBlogFeedPages(site, targetDir, "", 0, nil, nil, newTestOutput(t, cfg.Destination)); err != nil {
 		t.Fatalf("writeBlogFeedPages вернул ошибку для пустого блога: %v", err)
 	}
 
@@ -178,14 +256,83 @@ func TestWriteBlogFeedPages_EmptyBlogCreatesIndex(t *testing.T) {
 func TestWriteBlogFeedPages_InvalidPostsPerPage(t *testing.T) {
 	t.Parallel()
 
-	dir := t.TempDir()
-	templatePath := filepath.Join(dir, "blog.html")
-	if err := os.WriteFile(templatePath, []byte(`template`), 0644); err != nil {
-		t.Fatalf("не удалось создать шаблон блога: %v", err)
-	}
+	cfg := newTestSiteConfig(t)
+	cfg.PostsPerPage = 0
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "blog.html"), `template`)
 
-	err := writeBlogFeedPages(templatePath, "", dir, nil, nil, 0, 0, 0)
+	err := writeBlogFeedPages(&Site{Config: cfg}, cfg.Destination, "", 0, nil, nil, newTestOutput(t, cfg.Destination))
 	if err == nil {
 		t.Fatal("ожидалась ошибка при postsPerPage <= 0")
 	}
 }
+
+func TestBuild_SkipsDraftAndFuturePosts(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости"></tag><tag id="2" name="Заметки"></tag></tags>`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "blog.html"), `{{range .Blog}}{{.Title}};{{end}}{{range .Tags}}{{.Name}}={{.Posts}};{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "post.html"), `{{.Blogpost.Title}}`)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "published.xml"), `<post><date>01.02.2026</date><tagid>1</tagid><title>Опубликован</title></post>`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "draft.xml"), `<post><date>02.02.2026</date><tagid>1</tagid><title>Черновик</title><draft>true</draft></post>`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "future.xml"), `<post><date>01.01.2099</date><tagid>1</tagid><title>Будущий</title></post>`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "note.md"), "---\ntitle: Заметка\ndate: 03.02.2026\ntagid: 2\ndraft: true\n---\nТекст\n")
+
+	build := func(drafts bool, future bool) map[string]string {
+		c := *cfg
+		c.Drafts = drafts
+		c.Future = future
+		if err := Build(&c); err != nil {
+			t.Fatalf("Build с drafts=%v, future=%v вернул ошибку: %v", drafts, future, err)
+		}
+		return readTree(t, cfg.Destination)
+	}
+
+	site, err := LoadSite(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	skipped := site.SkippedPosts()
+	if len(site.Posts) != 1 || len(skipped) != 3 {
+		t.Fatalf("опубликовано %d постов, пропущено %+v", len(site.Posts), skipped)
+	}
+	for _, post := range skipped {
+		if post.Title == "Будущий" && post.Reason != "дата публикации 01.01.2099" || post.Title != "Будущий" && post.Reason != "черновик" {
+			t.Errorf("неверная причина пропуска %+v", post)
+		}
+	}
+
+	files := build(false, false)
+	if files[filepath.Join("blog", "index.html")] != "Опубликован;Новости=1;" {
+		t.Errorf("лента блога = %q", files[filepath.Join("blog", "index.html")])
+	}
+	for rel, content := range files {
+		for _, title := range []string{"Черновик", "Будущий", "Заметка"} {
+			if strings.Contains(content, title) {
+				t.Errorf("файл %s содержит неопубликованный пост %s", rel, title)
+			}
+		}
+	}
+	for _, rel := range []string{"draft.html", "future.html", "note.html"} {
+		if _, ok := files[filepath.Join("blog", "posts", rel)]; ok {
+			t.Errorf("сформирована страница неопубликованного поста %s", rel)
+		}
+	}
+	if _, ok := files[filepath.Join("blog", "2", "index.html")]; ok {
+		t.Errorf("сформирована рубрика без опубликованных постов")
+	}
+
+	// Для предварительного просмотра публикуются все посты; повторная сборка без параметров удаляет их страницы.
+	files = build(true, true)
+	if files[filepath.Join("blog", "index.html")] != "Будущий;Заметка;Черновик;Опубликован;Новости=3;Заметки=1;" {
+		t.Errorf("лента блога с -drafts -future = %q", files[filepath.Join("blog", "index.html")])
+	}
+	if !strings.Contains(files[SitemapFile], "posts/future.html") {
+		t.Errorf("sitemap не содержит отложенный пост")
+	}
+	files = build(false, false)
+	if _, ok := files[filepath.Join("blog", "posts", "draft.html")]; ok {
+		t.Errorf("страница черновика не удалена повторной сборкой")
+	}
+}
@@ -0,0 +1,443 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Команды приложения.
+// Команда задаётся первым аргументом командной строки: googol build|serve|check|new|clean [параметры].
+// Каждая команда разбирает собственный набор параметров; googol help <команда> выводит справку по ним.
+// Запуск без команды (googol -source=...) выполняет команду build.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// command описывает команду приложения.
+type command struct {
+	// Имя команды.
+	name string
+	// Строка запуска команды.
+	usage string
+	// Описание команды.
+	description string
+}
+
+// Команды приложения.
+var commands = []command{
+	{
+		name:        "build",
+		usage:       "googol build -source=путь_к_исходной_директории [-destination=путь_к_целевой_директории] [-domain=имя_домена_сайта] [-config=файл_конфигурации] [-feed-items=20] [-feed-full] [-jobs=N] [-drafts] [-future] [-quiet|-verbose] [-log-format=text|json] [-report=отчёт.json] [-dry-run [-diff]]",
+		description: "Формирует сайт в целевой директории.\n-dry-run формирует сайт, не изменяя целевую директорию, и выводит план изменений:\nсоздаваемые и удаляемые директории, добавляемые, изменяемые и удаляемые файлы;\n-diff выводит также изменения html-страниц.",
+	},
+	{
+		name:        "serve",
+		usage:       "googol serve -source=путь_к_исходной_директории [-destination=путь_к_целевой_директории] [-host=localhost] [-port=8080] [-jobs=N] [-drafts] [-future] [-quiet|-verbose] [-log-format=text|json]",
+		description: "Формирует сайт и запускает сервер разработки, который формирует сайт заново при изменении исходных файлов.",
+	},
+	{
+		name:        "check",
+		usage:       "googol check [links] -source=путь_к_исходной_директории [-destination=путь_к_целевой_директории] [-domain=имя_домена_сайта] [-config=файл_конфигурации] [-jobs=N] [-quiet|-verbose] [-log-format=text|json]",
+		description: "Проверяет конфигурацию, шаблоны и исходные файлы сайта, формируя сайт во временной директории.\nЦелевая директория и хэши прошлых сборок не изменяются.\ngoogol check links проверяет ссылки и якоря html-файлов сформированного сайта в целевой директории\nи завершается с ненулевым кодом, если найдены битые ссылки.",
+	},
+	{
+		name:        "new",
+		usage:       "googol new post|article|qa -source=путь_к_исходной_директории [-title=заголовок] [-name=идентификатор] [-tag=id_рубрики] [-format=xml|md]",
+		description: "Создаёт заготовку поста (post), публикации (article) или записи Вопросы и ответы (qa)\nс сегодняшней датой и новым идентификатором (fuseaction).",
+	},
+	{
+		name:        "clean",
+		usage:       "googol clean -source=путь_к_исходной_директории [-destination=путь_к_целевой_директории] [-config=файл_конфигурации] [-all]",
+		description: "Удаляет из целевой директории сформированные файлы, записанные в манифест прошлых сборок,\nи копии файлов исходной директории, затем опустевшие директории; остальные файлы не удаляются.\nУдаляет хэши прошлых сборок (директорию __hash): следующая сборка сформирует все файлы заново.\n-all удаляет всё содержимое целевой директории.",
+	},
+}
+
+// usageError описывает ошибку в параметрах командной строки; вместе с ней выводится строка запуска команды.
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+// isUsageError проверяет, нужно ли вместе с ошибкой err вывести строку запуска команды.
+func isUsageError(err error) bool {
+	var usageErr *usageError
+	var configErr *ConfigError
+
+	return errors.As(err, &usageErr) || errors.As(err, &configErr)
+}
+
+// findCommand ищет команду по имени, возвращает nil для неизвестной команды.
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+
+	return nil
+}
+
+// commandRunner возвращает функцию, выполняющую команду name с аргументами, следующими за её именем.
+// Функции команд не хранятся в списке commands: справка, которую они выводят, сама обращается к этому списку.
+func commandRunner(name string) func(args []string) error {
+	switch name {
+	case "build":
+		return runBuild
+	case "serve":
+		return runServe
+	case "check":
+		return runCheck
+	case "new":
+		return runNew
+	case "clean":
+		return runClean
+	}
+
+	return nil
+}
+
+// newCommandFlagSet создаёт набор параметров команды name, выводящий справку по команде.
+func newCommandFlagSet(name string) *flag.FlagSet {
+	flagSet := flag.NewFlagSet(name, flag.ExitOnError)
+	flagSet.Usage = func() {
+		printCommandHelp(flagSet.Output(), name)
+		flagSet.PrintDefaults()
+	}
+
+	return flagSet
+}
+
+// printCommandHelp выводит описание и строку запуска команды name.
+func printCommandHelp(w io.Writer, name string) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			fmt.Fprintf(w, "%s\n\nПример использования: %s\n\nПараметры:\n", cmd.description, cmd.usage)
+			return
+		}
+	}
+}
+
+// runHelp выводит общую справку или справку по команде.
+func runHelp(args []string) error {
+	if len(args) == 0 {
+		fmt.Println(HelpMessage)
+		return nil
+	}
+
+	if findCommand(args[0]) == nil {
+		return errors.New(ErrorMessages["unknown_command"] + args[0])
+	}
+	// Набор параметров команды выводит их описание вместе со справкой.
+	flagSet := newCommandFlagSet(args[0])
+	flagSet.SetOutput(os.Stdout)
+	switch args[0] {
+	case "serve":
+		addConfigFlags(flagSet)
+		addServeFlags(flagSet)
+	case "build":
+		addConfigFlags(flagSet)
+		addBuildFlags(flagSet)
+	case "new":
+		addNewFlags(flagSet)
+	default:
+		addConfigFlags(flagSet)
+	}
+	flagSet.Usage()
+
+	return nil
+}
+
+// runBuild выполняет команду build.
+func runBuild(args []string) error {
+	cfg, err := loadConfigFromArgs(args)
+	if err != nil {
+		return err
+	}
+	if err = Build(cfg); err != nil {
+		return err
+	}
+	if cfg.DryRun {
+		return nil
+	}
+	NewLogger(os.Stdout, cfg.LogLevel, cfg.LogFormat).Info("Сайт успешно скомпилирован и скопирован в целевую директорию", nil)
+
+	return nil
+}
+
+// runCheck выполняет команду check.
+// Сайт формируется во временной директории с отдельными манифестом и графом зависимостей,
+// поэтому проверяются все страницы, а целевая директория и директория __hash не изменяются.
+func runCheck(args []string) error {
+	if len(args) > 0 && args[0] == "links" {
+		return runCheckLinks(args[1:])
+	}
+
+	flagSet := newCommandFlagSet("check")
+	flags := addConfigFlags(flagSet)
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := flags.load(flagSet)
+	if err != nil {
+		return err
+	}
+	tempDir, err := ioutil.TempDir("", "googol-check-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+	cfg.Destination = filepath.Join(tempDir, "site")
+	if err = os.Mkdir(cfg.Destination, 0755); err != nil {
+		return err
+	}
+	// Для проверки адресов страниц домен не обязателен.
+	if len(cfg.Domain) == 0 {
+		cfg.Domain = "http://localhost"
+	}
+	if err = cfg.Validate(); err != nil {
+		return err
+	}
+
+	output, err := NewOutputWriter(cfg.Destination, filepath.Join(tempDir, "hash"))
+	if err != nil {
+		return err
+	}
+	if err = buildSite(cfg, output); err != nil {
+		return err
+	}
+	NewLogger(os.Stdout, cfg.LogLevel, cfg.LogFormat).Info("Ошибок не найдено", nil)
+
+	return nil
+}
+
+// runCheckLinks выполняет команду check links: проверяет ссылки сформированного сайта в целевой директории.
+// Битые ссылки выводятся с файлом и строкой, в которых они найдены, и возвращается ошибка.
+func runCheckLinks(args []string) error {
+	flagSet := newCommandFlagSet("check")
+	flags := addConfigFlags(flagSet)
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := flags.load(flagSet)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Destination) == 0 {
+		return &usageError{ErrorMessages["required_parameter"] + "destination"}
+	}
+	// Для проверки адресов страниц домен не обязателен.
+	if len(cfg.Domain) == 0 {
+		cfg.Domain = "http://localhost"
+	}
+	if err = cfg.Validate(); err != nil {
+		return err
+	}
+
+	broken, checked, err := CheckLinks(cfg)
+	if err != nil {
+		return err
+	}
+	for _, link := range broken {
+		fmt.Println(link)
+	}
+	if len(broken) > 0 {
+		return fmt.Errorf("Найдено битых ссылок: %d из %d", len(broken), checked)
+	}
+	fmt.Printf("Проверено ссылок: %d, битых ссылок не найдено\n", checked)
+
+	return nil
+}
+
+// runClean выполняет команду clean.
+func runClean(args []string) error {
+	flagSet := newCommandFlagSet("clean")
+	flags := addConfigFlags(flagSet)
+	all := flagSet.Bool("all", false, "Удалить всё содержимое целевой директории, а не только сформированные файлы")
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := flags.load(flagSet)
+	if err != nil {
+		return err
+	}
+	if *all && len(cfg.Destination) == 0 {
+		return &usageError{ErrorMessages["required_parameter"] + "destination"}
+	}
+
+	// Хэши основной сборки и сервера разработки; манифесты читаются до их удаления.
+	hashDirs := []string{cfg.HashDir(), cfg.HashDir() + ServeHashSuffix}
+	if len(cfg.Destination) > 0 {
+		if *all {
+			if err = wipeDestination(cfg.Source, cfg.Destination); err != nil {
+				return err
+			}
+			fmt.Println("Целевая директория " + cfg.Destination + " очищена")
+		} else {
+			manifests := make([]string, 0, len(hashDirs))
+			for _, dir := range hashDirs {
+				manifests = append(manifests, filepath.Join(dir, ManifestFile))
+			}
+			removed, err := cleanDestination(cfg.Source, cfg.Destination, manifests...)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("Из целевой директории %s удалено сформированных файлов: %d\n", cfg.Destination, removed)
+		}
+	}
+
+	for _, dir := range hashDirs {
+		if err = os.RemoveAll(dir); err != nil {
+			return errors.New(ErrorMessages["directory_content_remove"] + dir + ": " + err.Error())
+		}
+	}
+	fmt.Println("Хэши прошлых сборок удалены")
+
+	return nil
+}
+
+// cleanDestination удаляет из целевой директории destination файлы, записанные в манифесты сборок manifests,
+// и копии файлов исходной директории source, затем директории, опустевшие после удаления.
+// Остальные файлы целевой директории не удаляются. Возвращает количество удалённых файлов.
+func cleanDestination(source string, destination string, manifests ...string) (int, error) {
+	if err := checkCleanDestination(source, destination); err != nil {
+		return 0, err
+	}
+	if _, err := os.Stat(destination); os.IsNotExist(err) {
+		return 0, nil
+	}
+	destination = filepath.Clean(destination)
+
+	// Пути файлов относительно целевой директории.
+	files := map[string]bool{}
+	for _, manifest := range manifests {
+		entries, err := readManifest(manifest)
+		if err != nil {
+			return 0, err
+		}
+		for rel := range entries {
+			files[filepath.FromSlash(rel)] = true
+		}
+	}
+	err := filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Служебные директории (__templates, __hash и другие) в целевую директорию не копируются;
+		// целевая директория может находиться внутри исходной.
+		if info.IsDir() && path != source && (strings.HasPrefix(info.Name(), "__") || filepath.Clean(path) == destination) {
+			return filepath.SkipDir
+		}
+		// Файлы, имя которых начинается с символа _, не копируются.
+		if !info.IsDir() && !strings.HasPrefix(info.Name(), "_") {
+			rel, err := filepath.Rel(source, path)
+			if err != nil {
+				return err
+			}
+			files[rel] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	dirs := map[string]bool{}
+	for rel := range files {
+		// Путь манифеста вне целевой директории не удаляется.
+		if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) || filepath.IsAbs(rel) {
+			continue
+		}
+		path := filepath.Join(destination, rel)
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return removed, err
+		}
+		if info.IsDir() {
+			continue
+		}
+		if err = os.Remove(path); err != nil {
+			return removed, errors.New(ErrorMessages["directory_content_remove"] + path + ": " + err.Error())
+		}
+		removed++
+		for dir := filepath.Dir(path); dir != destination && strings.HasPrefix(dir, destination); dir = filepath.Dir(dir) {
+			dirs[dir] = true
+		}
+	}
+
+	// Удаляем опустевшие директории, вложенные раньше родительских.
+	pruned := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		pruned = append(pruned, dir)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(pruned)))
+	for _, dir := range pruned {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return removed, err
+		}
+		if len(entries) > 0 {
+			continue
+		}
+		if err = os.Remove(dir); err != nil {
+			return removed, errors.New(ErrorMessages["directory_content_remove"] + dir + ": " + err.Error())
+		}
+	}
+
+	return removed, nil
+}
+
+// wipeDestination удаляет всё содержимое целевой директории destination (параметр -all команды clean).
+func wipeDestination(source string, destination string) error {
+	if err := checkCleanDestination(source, destination); err != nil {
+		return err
+	}
+
+	entries, err := ioutil.ReadDir(destination)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(destination, entry.Name())
+		if err = os.RemoveAll(path); err != nil {
+			return errors.New(ErrorMessages["directory_content_remove"] + path + ": " + err.Error())
+		}
+	}
+
+	return nil
+}
+
+// checkCleanDestination проверяет, что целевая директория destination не совпадает с исходной директорией source
+// и не содержит её: такая директория не очищается.
+func checkCleanDestination(source string, destination string) error {
+	absSource, err := filepath.Abs(source)
+	if err != nil {
+		return err
+	}
+	absDestination, err := filepath.Abs(destination)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(absDestination, absSource); err == nil && !strings.HasPrefix(rel, "..") {
+		return fmt.Errorf("целевая директория %s содержит исходную директорию, очистка отменена", destination)
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCommands_EveryCommandHasRunner(t *testing.T) {
+	t.Parallel()
+
+	for _, cmd := range commands {
+		if commandRunner(cmd.name) == nil {
+			t.Errorf("у команды %s нет функции выполнения", cmd.name)
+		}
+		if !strings.HasPrefix(cmd.usage, "googol "+cmd.name) {
+			t.Errorf("строка запуска команды %s: %s", cmd.name, cmd.usage)
+		}
+	}
+	if findCommand("unknown") != nil || commandRunner("unknown") != nil {
+		t.Errorf("неизвестная команда найдена")
+	}
+}
+
+func TestCreateScaffold_PostsWithFreshFuseactions(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="3" name="Заметки"></tag><tag id="4" name="Обзоры"></tag></tags>`)
+	date := time.Date(2026, time.March, 8, 0, 0, 0, 0, time.UTC)
+	if err := os.MkdirAll(filepath.Join(cfg.BlogDir(), "2026"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeBlogPostXML(t, filepath.Join(cfg.BlogDir(), "2026"), "20260308.xml", 3, "08.03.2026", "Существующий пост")
+
+	files, err := createScaffold(cfg, scaffold{Kind: "post", Title: "Первый <пост>", Format: ScaffoldXML, Date: date})
+	if err != nil {
+		t.Fatalf("createScaffold вернул ошибку: %v", err)
+	}
+	if len(files) != 1 || filepath.Base(files[0]) != "20260308-2.xml" {
+		t.Fatalf("ожидался файл 20260308-2.xml, получено %v", files)
+	}
+	files, err = createScaffold(cfg, scaffold{Kind: "post", Title: "Второй пост", Tag: 4, Format: ScaffoldMarkdown, Date: date})
+	if err != nil {
+		t.Fatalf("createScaffold вернул ошибку: %v", err)
+	}
+	if len(files) != 1 || filepath.Base(files[0]) != "20260308-3.md" {
+		t.Fatalf("ожидался файл 20260308-3.md, получено %v", files)
+	}
+
+	tags, err := loadTags(cfg.SettingsDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	posts, total, err := loadBlog(cfg.BlogDir(), tags, nil)
+	if err != nil {
+		t.Fatalf("заготовки не загружаются как посты: %v", err)
+	}
+	if total != 3 {
+		t.Fatalf("ожидалось 3 поста, получено %d", total)
+	}
+	byName := map[string]Post{}
+	for _, post := range *posts {
+		byName[post.Fuseaction] = post
+	}
+	if post := byName["20260308-2"]; post.Title != "Первый <пост>" || post.Tagid != 3 || post.Date != "08.03.2026" {
+		t.Errorf("пост из xml-заготовки: %+v", post)
+	}
+	if post := byName["20260308-3"]; post.Title != "Второй пост" || post.Tagid != 4 || !strings.Contains(string(post.Content), "<p>Текст поста.</p>") {
+		t.Errorf("пост из md-заготовки: %+v", post)
+	}
+
+	if _, err = createScaffold(cfg, scaffold{Kind: "post", Name: "20260308", Format: ScaffoldXML, Date: date}); err == nil {
+		t.Errorf("занятый идентификатор должен быть ошибкой")
+	}
+	if _, err = createScaffold(cfg, scaffold{Kind: "post", Tag: 9, Format: ScaffoldXML, Date: date}); err == nil {
+		t.Errorf("неизвестная рубрика должна быть ошибкой")
+	}
+	if _, err = createScaffold(cfg, scaffold{Kind: "page", Format: ScaffoldXML, Date: date}); !isUsageError(err) {
+		t.Errorf("неизвестный вид записи должен быть ошибкой параметров, получено %v", err)
+	}
+}
+
+func TestCreateScaffold_ArticleAndQA(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	date := time.Date(2026, time.May, 1, 0, 0, 0, 0, time.UTC)
+
+	files, err := createScaffold(cfg, scaffold{Kind: "article", Title: "Руководство", Format: ScaffoldXML, Date: date})
+	if err != nil {
+		t.Fatalf("createScaffold вернул ошибку: %v", err)
+	}
+	if len(files) != 2 || files[1] != filepath.Join(cfg.ArticlesDir(), "20260501", "1.html") {
+		t.Fatalf("ожидались файл публикации и её первая страница, получено %v", files)
+	}
+	articles, err := loadArticles(cfg.ArticlesDir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*articles) != 1 || (*articles)[0].Title != "Руководство" || len((*articles)[0].Content) != 1 || len((*articles)[0].Content[0]) == 0 {
+		t.Errorf("публикация из заготовки: %+v", *articles)
+	}
+
+	if _, err = createScaffold(cfg, scaffold{Kind: "qa", Title: `Как "так"?`, Format: ScaffoldMarkdown, Date: date}); err != nil {
+		t.Fatalf("createScaffold вернул ошибку: %v", err)
+	}
+	qas, total, err := loadQA(cfg.QADir(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 || (*qas)[0].Question != `Как "так"?` || (*qas)[0].Date != "01.05.2026" {
+		t.Errorf("запись Вопросы и ответы из заготовки: %+v", *qas)
+	}
+}
+
+func TestCleanDestination(t *testing.T) {
+	t.Parallel()
+
+	source := t.TempDir()
+	destination := t.TempDir()
+	manifest := filepath.Join(t.TempDir(), ManifestFile)
+	writeTestFile(t, manifest, "1\t5\tpage\tindex.html\n2\t4\tblog\tblog/2026/post.html\n3\t3\tsitemap\tsitemap.xml\n")
+	writeTestFile(t, filepath.Join(source, "assets", "style.css"), "body{}")
+	writeTestFile(t, filepath.Join(source, "__templates", "base.tmpl"), "base")
+	writeTestFile(t, filepath.Join(destination, "index.html"), "index")
+	writeTestFile(t, filepath.Join(destination, "blog", "2026", "post.html"), "post")
+	writeTestFile(t, filepath.Join(destination, "sitemap.xml"), "map")
+	writeTestFile(t, filepath.Join(destination, "assets", "style.css"), "body{}")
+	writeTestFile(t, filepath.Join(destination, "uploads.bin"), "user")
+	writeTestFile(t, filepath.Join(destination, "assets", "user.css"), "user")
+	writeTestFile(t, filepath.Join(destination, "__templates", "base.tmpl"), "user")
+
+	removed, err := cleanDestination(source, destination, manifest, filepath.Join(t.TempDir(), ManifestFile))
+	if err != nil {
+		t.Fatalf("cleanDestination вернул ошибку: %v", err)
+	}
+	if removed != 4 {
+		t.Errorf("удалено файлов: %d, ожидалось 4", removed)
+	}
+	// Файлы, не сформированные и не скопированные сборкой, не удаляются; опустевшие директории удаляются.
+	expected := map[string]string{
+		"uploads.bin":           "user",
+		"assets/user.css":       "user",
+		"__templates/base.tmpl": "user",
+	}
+	if actual := readTree(t, destination); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("целевая директория после очистки:\n%v\nожидалось:\n%v", actual, expected)
+	}
+	if _, err = os.Stat(filepath.Join(destination, "blog")); !os.IsNotExist(err) {
+		t.Errorf("опустевшая директория blog не удалена: %v", err)
+	}
+
+	// Исходная директория внутри целевой не должна быть удалена.
+	nested := filepath.Join(destination, "src")
+	writeTestFile(t, filepath.Join(nested, "index.html"), "source")
+	if _, err = cleanDestination(nested, destination); err == nil {
+		t.Errorf("очистка директории, содержащей исходную, должна быть ошибкой")
+	}
+	if _, err = cleanDestination(nested, nested); err == nil {
+		t.Errorf("очистка исходной директории должна быть ошибкой")
+	}
+	if _, err = os.Stat(filepath.Join(nested, "index.html")); err != nil {
+		t.Errorf("исходный файл удалён: %v", err)
+	}
+}
+
+func TestWipeDestination(t *testing.T) {
+	t.Parallel()
+
+	source := t.TempDir()
+	destination := t.TempDir()
+	writeTestFile(t, filepath.Join(destination, "blog", "index.html"), "blog")
+	writeTestFile(t, filepath.Join(destination, "uploads.bin"), "user")
+
+	if err := wipeDestination(source, destination); err != nil {
+		t.Fatalf("wipeDestination вернул ошибку: %v", err)
+	}
+	entries, err := os.ReadDir(destination)
+	if err != nil || len(entries) != 0 {
+		t.Errorf("целевая директория не очищена: %v, %v", entries, err)
+	}
+
+	nested := filepath.Join(destination, "src")
+	writeTestFile(t, filepath.Join(nested, "index.html"), "source")
+	if err = wipeDestination(nested, destination); err == nil {
+		t.Errorf("очистка директории, содержащей исходную, должна быть ошибкой")
+	}
+	if _, err = os.Stat(filepath.Join(nested, "index.html")); err != nil {
+		t.Errorf("исходный файл удалён: %v", err)
+	}
+}
+
+func TestRunCheck_ReportsTemplateErrorsWithoutWriting(t *testing.T) {
+	cfg := newTestSiteConfig(t)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.Source, "index.html"), "<p>{{.Site.Title}}</p>")
+
+	if err := runCheck([]string{"-source=" + cfg.Source}); err != nil {
+		t.Fatalf("runCheck вернул ошибку для корректного сайта: %v", err)
+	}
+	writeTestFile(t, filepath.Join(cfg.Source, "broken.html"), "{{.Site.Title")
+	if err := runCheck([]string{"-source=" + cfg.Source}); err == nil {
+		t.Errorf("runCheck не сообщил об ошибке шаблона")
+	}
+	if _, err := os.Stat(cfg.HashDir()); !os.IsNotExist(err) {
+		t.Errorf("runCheck создал директорию хэшей исходной директории")
+	}
+}
+
+func TestRunClean_KeepsUserFilesWithoutAll(t *testing.T) {
+	cfg := newTestSiteConfig(t)
+	cfg.LogLevel = LogQuiet
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.Source, "index.html"), "<p>Главная</p>")
+	writeTestFile(t, filepath.Join(cfg.Source, "news", "item.html"), "<p>Новость</p>")
+	if err := Build(cfg); err != nil {
+		t.Fatalf("Build вернул ошибку: %v", err)
+	}
+	writeTestFile(t, filepath.Join(cfg.Destination, "uploads.bin"), "user")
+
+	args := []string{"-source=" + cfg.Source, "-destination=" + cfg.Destination}
+	if err := runClean(args); err != nil {
+		t.Fatalf("runClean вернул ошибку: %v", err)
+	}
+	if actual := readTree(t, cfg.Destination); !reflect.DeepEqual(actual, map[string]string{"uploads.bin": "user"}) {
+		t.Errorf("целевая директория после очистки: %v", actual)
+	}
+	if _, err := os.Stat(cfg.HashDir()); !os.IsNotExist(err) {
+		t.Errorf("хэши прошлых сборок не удалены: %v", err)
+	}
+
+	if err := runClean(append(args, "-all")); err != nil {
+		t.Fatalf("runClean -all вернул ошибку: %v", err)
+	}
+	if entries, err := os.ReadDir(cfg.Destination); err != nil || len(entries) != 0 {
+		t.Errorf("целевая директория не очищена с -all: %v, %v", entries, err)
+	}
+}
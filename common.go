@@ -5,18 +5,29 @@ package main
 
 import (
 	"bytes"
+	"encoding/xml"
 	"hash/crc32"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strconv"
-	"text/template"
+	"strings"
 	"time"
 )
 
 // Подсказка по запуску приложения.
-var HelpMessage = "Пример использования: googol -source=путь_к_исходной_директории -destination=путь_к_целевой_директории -domain=имя_домена_сайта"
+var HelpMessage = `Использование: googol <команда> [параметры]
+
+Команды:
+  build   формирует сайт в целевой директории (команда по умолчанию)
+  serve   запускает сервер разработки
+  check   проверяет исходные файлы сайта, не записывая целевую директорию;
+          check links проверяет ссылки сформированного сайта
+  new     создаёт заготовку поста, публикации или записи Вопросы и ответы
+  clean   удаляет сформированные файлы и хэши прошлых сборок
+
+Справка по параметрам команды: googol help <команда>`
 
 // Сообщения об ошибках.
 var ErrorMessages = map[string]string{
@@ -28,6 +39,9 @@ var ErrorMessages = map[string]string{
 	"path_not_directory":       "Указанный путь не является директорией: ",
 	"copy_error":               "Ошибка при копировании файла или директории: ",
 	"parse_template_error":     "Ошибка парсинга шаблона страницы: ",
+	"error_creating_feed":      "Ошибка при формировании ленты: ",
+	"config_error":             "Ошибка в конфигурации сайта",
+	"unknown_command":          "Неизвестная команда: ",
 }
 
 const IEEE = 0xedb88320
@@ -102,6 +116,14 @@ func HashFileCrc32(filepath string) string {
 	return hash
 }
 
+// xmlEscape экранирует текст для xml-элемента.
+func xmlEscape(text string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(text))
+
+	return b.String()
+}
+
 // IsStringInList проверяет наличие строки в массиве строк.
 func IsStringInList(value string, list []string) bool {
 	for _, v := range list {
@@ -113,61 +135,35 @@ func IsStringInList(value string, list []string) bool {
 	return false
 }
 
-// ParseFileView парсит файл шаблона.
-// pagepath — полный путь к файлу.
-// templatesDir — директория шаблонов с расширением *.tmpl, может быть пустой строкой.
-// data — данные, передаваемые шаблону.
-// fuseaction — имя корневого шаблона.
-func ParseFileView(pagepath string, templatesDir string, data interface{}, fuseaction string) (string, error) {
-	var doc bytes.Buffer
-
-	// Функции для шаблонов.
-	funcMap := template.FuncMap{
-		// Прибавление единицы.
-		"Inc": func(i int) int {
-			return i + 1
-		},
-		// Вычитание единицы.
-		"Dec": func(i int) int {
-			return i - 1
-		},
-		// Первая буква в строке.
-		"First": func(s string) string {
-			runes := []rune(s)
-			if len(runes) == 0 {
-				return ""
-			}
-
-			return string(runes[0])
-		},
-	}
-
-	// Создаём шаблон.
-	t := template.New(fuseaction).Funcs(funcMap)
-
-	// Загружаем дополнительные шаблоны, если они есть.
-	if len(templatesDir) > 0 {
-		_, err := t.ParseGlob(filepath.Join(templatesDir, "*.tmpl"))
-		if err != nil {
-			return "", err
-		}
-	}
+// IsMarkdownFile проверяет, что файл содержит текст в формате Markdown.
+func IsMarkdownFile(path string) bool {
+	return strings.ToLower(filepath.Ext(path)) == ".md"
+}
 
-	// Загружаем файл для парсинга.
-	tmpl, err := ioutil.ReadFile(pagepath)
+// readPageTemplate читает шаблон страницы.
+// Текст файлов Markdown (*.md) преобразуется в HTML до разбора шаблона.
+func readPageTemplate(pagepath string) (string, error) {
+	raw, err := ioutil.ReadFile(pagepath)
 	if err != nil {
 		return "", err
 	}
-
-	t, err = t.Parse(string(tmpl))
-	if err != nil {
-		return "", err
+	if IsMarkdownFile(pagepath) {
+		return RenderMarkdown(string(raw)), nil
 	}
 
-	// Парсим файл шаблона.
-	if err = t.Execute(&doc, data); err != nil {
-		return "", err
-	}
+	return string(raw), nil
+}
+
+// ParseFileView парсит файл шаблона.
+// Шаблоны директории templatesDir разбираются заново при каждом вызове,
+// при формировании сайта используется кэш шаблонов (см. templates.go).
+// Страница формируется пакетом html/template с контекстным экранированием.
+// pagepath — полный путь к файлу, файлы Markdown (*.md) преобразуются в HTML.
+// templatesDir — директория шаблонов с расширением *.tmpl, может быть пустой строкой.
+// data — данные, передаваемые шаблону.
+// fuseaction — имя корневого шаблона.
+func ParseFileView(pagepath string, templatesDir string, data interface{}, fuseaction string) (string, error) {
+	var templates *TemplateCache
 
-	return doc.String(), nil
+	return templates.Render(pagepath, templatesDir, data, fuseaction, true, nil)
 }
@@ -3,158 +3,82 @@
 package main
 
 import (
-	"errors"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-// запись контента в файл на целевом сервере и сохранение md5 - хэша контента на исходном сервере
-// destination_file - файл на целевом сервере
-// content - контент для записи в целевой файл
-// fuseaction - уникальный строковый идентификатор файла
-// file - исходный файл
-// source_root - корень исходной директории
-func writeContentToDest(destination_file string, content string, fuseaction string, file string, source_root string) error {
-	//определяем файловые реквизиты исходного файла
-	sourceinfo, err := os.Stat(file)
-	if err != nil {
-		return errors.New(ErrorMessages["parse_template_error"] + err.Error())
-	}
-	//создаём файл на целевом сервере
-	err = ioutil.WriteFile(destination_file, []byte(content), sourceinfo.Mode())
-	if err != nil {
-		return errors.New(ErrorMessages["error_creating_file"] + err.Error())
-	}
-
-	err = os.Chmod(destination_file, 0644)
-	if err != nil {
-		return errors.New(ErrorMessages["error_creating_file"] + err.Error())
-	}
-
-	//сохраняем md5-хэш контента
-	err = ioutil.WriteFile(filepath.Join(source_root, "__hash", fuseaction+".crc"), []byte(HashStringCrc32(content)), sourceinfo.Mode())
-	if err != nil {
-		return errors.New(ErrorMessages["error_creating_file"] + err.Error())
-	}
-	return nil
-}
-
-// обработка файла html/php
+// обработка файла html/php/md
+// site - общие данные сайта
 // file - полный путь к исходному файлу
-// destination_root - корень целевой директории
-// source_root - корень исходной директории
-// blog - список постов блога (nil если блога нет или он пустой)
-// tags - список рубрик блога
-// articles - список публикаций
-// sitemap - содержимое файла sitemap
-// domain - целевой домен
-func handleParseFile(file string, destination_root string, source_root string, sitemap *string, domain string) error {
-	//если на исходном сервере нет директории __hash, создаём её
-	if _, err := os.Stat(filepath.Join(source_root, "__hash")); os.IsNotExist(err) {
-		err = os.Mkdir(filepath.Join(source_root, "__hash"), 0755)
-		if err != nil {
-			return errors.New(ErrorMessages["error_creating_dir"] + err.Error())
-		}
+// sitemap - записи sitemap
+// redirects - перенаправления со старых адресов страниц
+// search - поисковый индекс
+// output - запись сформированных файлов в целевую директорию
+func handleParseFile(site *Site, file string, sitemap *Sitemap, redirects *Redirects, search *SearchIndex, output *OutputWriter) error {
+	cfg := site.Config
+	source_root := cfg.Source
+	destination_root := cfg.Destination
+	//путь к файлу относительно исходной директории
+	relative := filepath.ToSlash(strings.Replace(file, source_root, "", -1))
+	//страница Markdown формируется как html-страница с тем же именем
+	if IsMarkdownFile(file) {
+		relative = strings.TrimSuffix(relative, filepath.Ext(relative)) + ".html"
 	}
 	//уникальный строковый идентификатор файла
-	fuseaction := strings.Replace(strings.TrimLeft(filepath.ToSlash(strings.Replace(file, source_root, "", -1)), "/"), "/", "-", -1)
+	fuseaction := strings.Replace(strings.TrimLeft(relative, "/"), "/", "-", -1)
 	//url старницы на целевом сервере
-	url := domain + filepath.ToSlash(strings.Replace(file, source_root, "", -1))
+	url := cfg.Domain + relative
 	//поддиректория верхнего уровня
-	top_subdir := strings.Split(fuseaction, "-")[0]
-	//файлы в поддиректории assets не обрабатываются
-	if top_subdir == "assets" {
+	top_subdir := ""
+	if parts := strings.Split(strings.TrimLeft(relative, "/"), "/"); len(parts) > 1 {
+		top_subdir = parts[0]
+	}
+	//файлы в исключённых поддиректориях (по умолчанию assets) не обрабатываются
+	if IsStringInList(top_subdir, cfg.Exclude) {
 		return nil
 	}
 
 	//директория шаблонов страниц
-	template_dir := filepath.Join(source_root, "__templates")
+	template_dir := cfg.TemplatesDir()
 	//данные для передачи шаблону
 	data := struct {
+		Site       *Site
 		Fuseaction string
 	}{
+		site,
 		fuseaction,
 	}
-	//парсим файл
-	content, err := ParseFileView(file, template_dir, data, fuseaction)
-	if err != nil {
-		return errors.New(ErrorMessages["parse_template_error"] + err.Error())
-	}
-
-	//проверяем, существует ли файл с таким именем на целевом сервере
-	destination_file := strings.Replace(file, source_root, destination_root, -1)
-	hash_file := filepath.Join(source_root, "__hash", fuseaction+".crc")
-	if _, err := os.Stat(destination_file); os.IsNotExist(err) {
-		//копируем контент в файл на целевом сервере
-		err = writeContentToDest(destination_file, content, fuseaction, file, source_root)
-		if err != nil {
-			return errors.New(ErrorMessages["error_creating_file"] + err.Error())
-		}
-	} else {
-		//существует ли хэш - файл для исходного файла
-		if _, err := os.Stat(hash_file); os.IsNotExist(err) {
-			//копируем контент в файл на целевом сервере
-			err = writeContentToDest(destination_file, content, fuseaction, file, source_root)
-			if err != nil {
-				return errors.New(ErrorMessages["error_creating_file"] + err.Error())
-			}
-		} else {
-			//загружаем старый хэш из хэш - файла
-			b, _ := ioutil.ReadFile(hash_file)
-			old_hash := string(b)
-			//подсчитываем новый хэш
-			new_hash := HashStringCrc32(content)
-			if old_hash != new_hash {
-				//копируем контент в файл на целевом сервере
-				err = writeContentToDest(destination_file, content, fuseaction, file, source_root)
-				if err != nil {
-					return errors.New(ErrorMessages["error_creating_file"] + err.Error())
-				}
-			}
-		}
-	}
-	if fuseaction != "404.html" {
-		*sitemap += "<url><loc>" + url + "</loc></url>"
+	//парсим файл и записываем контент в файл на целевом сервере, если изменились файл или используемые им шаблоны;
+	//страницы формируются параллельно, ошибки формирования возвращает output.Wait
+	destination_file := filepath.Join(destination_root, filepath.FromSlash(relative))
+	if err := output.Render(destination_file, file, template_dir, data, fuseaction); err != nil {
+		return err
 	}
-	return nil
-}
-
-// обработка файла не html/php
-// file - полный путь к исходному файлу
-// destination_root - корень целевой директории
-// source_root - корень исходной директории
-func handleCopyFile(file string, destination_root string, source_root string) error {
-	//существует ли файл с таким именем на целевом сервере
-	destination_file := strings.Replace(file, source_root, destination_root, -1)
-	if _, err := os.Stat(destination_file); os.IsNotExist(err) {
-		//пытаемся копировать файл на целевой сервер
-		err = CopyFile(file, destination_file)
-		if err != nil {
-			return errors.New(ErrorMessages["copy_error"] + destination_file)
-		}
-	} else {
-		//вычисляем crc - суммы исходного и целевого файла
-		source_crc32 := HashFileCrc32(file)
-		destination_crc32 := HashFileCrc32(destination_file)
-		if source_crc32 != destination_crc32 {
-			//копируем новую версию файла
-			err = CopyFile(file, destination_file)
-			if err != nil {
-				return errors.New(ErrorMessages["copy_error"] + destination_file)
-			}
+	//страницы, исключённые из sitemap комментарием {{/* sitemap: exclude */}}, не добавляются,
+	//исключение параметром sitemap.exclude (по умолчанию 404.html) проверяет sitemap
+	if sitemap != nil && !pageExcludedFromSitemap(file) {
+		kind := SitemapPage
+		if relative == "/index.html" {
+			kind = SitemapHome
 		}
+		sitemap.Add(SitemapEntry{Kind: kind, Loc: url, LastMod: latestModTime(file)})
 	}
+	//старые адреса страницы из комментария {{/* aliases: ... */}} перенаправляются на её адрес
+	redirects.Add(pageAliases(file), url, file)
+	//текст страницы добавляется в поисковый индекс после её формирования
+	search.AddPage(url, destination_file)
 	return nil
 }
 
 // обработка файлов в поддиректориях исходной директории
-// destination_root - корень целевой директории
-// source_root - корень исходной директории
-// sitemap - содержимое файла sitemap
-// domain - целевой домен
-func handleSourceFile(destination_root string, source_root string, sitemap *string, domain string) filepath.WalkFunc {
+// site - общие данные сайта
+// sitemap - записи sitemap
+// redirects - перенаправления со старых адресов страниц
+// search - поисковый индекс
+// output - запись сформированных файлов в целевую директорию
+func handleSourceFile(site *Site, sitemap *Sitemap, redirects *Redirects, search *SearchIndex, output *OutputWriter) filepath.WalkFunc {
+	cfg := site.Config
 	return func(current_path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -175,10 +99,11 @@ func handleSourceFile(destination_root string, source_root string, sitemap *stri
 			}
 			//по расширению файла определяем его обработчик
 			ext := filepath.Ext(filename)
-			if ext == ".html" || ext == ".php" {
-				err = handleParseFile(current_path, destination_root, source_root /*blog, tags, articles,*/, sitemap, domain)
+			if ext == ".html" || ext == ".php" || IsMarkdownFile(filename) {
+				err = handleParseFile(site, current_path, sitemap, redirects, search, output)
 			} else {
-				err = handleCopyFile(current_path, destination_root, source_root)
+				//остальные файлы копируются, если изменились
+				err = output.Copy(current_path, strings.Replace(current_path, cfg.Source, cfg.Destination, -1))
 			}
 			if err != nil {
 				return err
@@ -189,11 +114,12 @@ func handleSourceFile(destination_root string, source_root string, sitemap *stri
 }
 
 // обход поддиректорий исходной директории
-// source - исходная директория
-// destination - целевая директория
-// sitemap - содержимое файла sitemap
-// domain - целевой домен
-func HandleSourceDir(source string, destination string, sitemap *string, domain string) error {
-	err := filepath.Walk(source, handleSourceFile(destination, source, sitemap, domain))
+// site - общие данные сайта
+// sitemap - записи sitemap
+// redirects - перенаправления со старых адресов страниц
+// search - поисковый индекс
+// output - запись сформированных файлов в целевую директорию
+func HandleSourceDir(site *Site, sitemap *Sitemap, redirects *Redirects, search *SearchIndex, output *OutputWriter) error {
+	err := filepath.Walk(site.Config.Source, handleSourceFile(site, sitemap, redirects, search, output))
 	return err
 }
@@ -0,0 +1,662 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Конфигурация сайта.
+// Настройки загружаются из файла __settings/site.toml (или site.yaml, site.yml) исходной директории
+// либо из файла, указанного параметром командной строки -config. Параметры командной строки
+// переопределяют значения из файла конфигурации.
+//
+// Пример файла site.toml:
+//
+//	domain = "https://example.com"
+//	destination = "/var/www/example.com"
+//	title = "Пример"
+//	posts_per_page = 10
+//	recent_posts = 5
+//	related_posts = 5
+//
+//	[dirs]
+//	settings = "__settings"
+//	templates = "__templates"
+//	blog = "__blog"
+//	articles = "__articles"
+//	qa = "__qa"
+//	hash = "__hash"
+//
+//	[output]
+//	blog = "blog"
+//	articles = "articles"
+//	qa = "qa.html"
+//
+//	[compile]
+//	exclude = ["assets"]
+//	jobs = 4
+//	autoescape = true
+//
+//	[feed]
+//	title = "Блог примера"
+//	author = "Редакция примера"
+//	items = 20
+//	full_content = false
+//
+//	[sitemap]
+//	enabled = true
+//	exclude = ["404.html"]
+//	max_urls = 50000
+//
+//	[permalinks]
+//	post = "/:blog/posts/:slug.html"
+//	tag = "/:blog/:tagid/"
+//	pretty_urls = false
+//
+//	[redirects]
+//	stubs = true
+//	htaccess = false
+//	nginx = false
+//	netlify = false
+//
+//	[search]
+//	enabled = false
+//	file = "search.json"
+//	page = "search.html"
+//	exclude = ["404.html"]
+//	text_length = 200
+//	stemming = true
+//
+//	[validation]
+//	syntax = "error"
+//	required = "error"
+//	date = "error"
+//	tag = "error"
+//	duplicate = "error"
+//	pages = "warning"
+//	empty = "warning"
+//
+//	[params]
+//	author = "Автор сайта"
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// ConfigFiles — имена файлов конфигурации, которые ищутся в директории настроек сайта.
+var ConfigFiles = []string{"site.toml", "site.yaml", "site.yml"}
+
+// SourceDirs описывает имена служебных поддиректорий исходной директории.
+type SourceDirs struct {
+	Settings  string
+	Templates string
+	Blog      string
+	Articles  string
+	QA        string
+	Hash      string
+}
+
+// OutputNames описывает имена формируемых разделов в целевой директории.
+type OutputNames struct {
+	// Директория блога.
+	Blog string
+	// Директория публикаций.
+	Articles string
+	// Файл страницы Вопросы и ответы.
+	QA string
+}
+
+// Config описывает конфигурацию сайта.
+type Config struct {
+	// Исходная корневая директория.
+	Source string
+	// Целевая корневая директория.
+	Destination string
+	// Домен сайта вместе со схемой, без завершающего символа /.
+	Domain string
+	// Название сайта.
+	Title string
+	// Количество постов блога на страницу ленты.
+	PostsPerPage int
+	// Количество последних постов блога, доступных всем шаблонам (Site.RecentPosts).
+	RecentPosts int
+	// Количество похожих постов, передаваемых шаблону страницы поста (RelatedPosts).
+	RelatedPosts int
+	// Служебные поддиректории исходной директории.
+	Dirs SourceDirs
+	// Имена формируемых разделов в целевой директории.
+	Output OutputNames
+	// Поддиректории верхнего уровня, html-файлы которых не компилируются.
+	Exclude []string
+	// Количество страниц, формируемых одновременно; по умолчанию — количество процессоров.
+	Jobs int
+	// Формировать ли страницы пакетом html/template с контекстным экранированием;
+	// false — пакетом text/template, как до перехода на html/template (см. templates.go).
+	Autoescape bool
+	// Публиковать ли черновики (<draft>true</draft>); задаётся параметром командной строки -drafts.
+	Drafts bool
+	// Публиковать ли посты с датой позже времени сборки; задаётся параметром командной строки -future.
+	Future bool
+	// Уровень вывода хода сборки (LogQuiet, LogInfo, LogVerbose); задаётся параметрами -quiet и -verbose.
+	LogLevel int
+	// Формат вывода хода сборки: text или json; задаётся параметром -log-format.
+	LogFormat string
+	// Файл, в который записывается отчёт о сборке в формате JSON; задаётся параметром -report.
+	Report string
+	// Пробный запуск: сборка не изменяет целевую директорию, а выводит план изменений;
+	// задаётся параметром -dry-run команды build (см. plan.go).
+	DryRun bool
+	// Выводить ли в плане пробного запуска изменения html-страниц; задаётся параметром -diff.
+	Diff bool
+	// Настройки лент RSS и Atom блога.
+	Feed FeedOptions
+	// Настройки sitemap.
+	Sitemap SitemapOptions
+	// Шаблоны адресов страниц постов и рубрик блога.
+	Permalinks PermalinkOptions
+	// Настройки перенаправлений со старых адресов страниц.
+	Redirects RedirectOptions
+	// Настройки поискового индекса.
+	Search SearchOptions
+	// Уровни проблем исходных записей.
+	Validation ValidationOptions
+	// Произвольные метаданные сайта, доступные шаблонам.
+	Params map[string]interface{}
+	// Файл, из которого загружена конфигурация, пустая строка если файл не найден.
+	File string
+}
+
+// ConfigError описывает ошибки конфигурации сайта.
+type ConfigError struct {
+	File     string
+	Problems []string
+}
+
+func (e *ConfigError) Error() string {
+	header := ErrorMessages["config_error"]
+	if len(e.File) > 0 {
+		header += " " + e.File
+	}
+
+	return header + ":\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// DefaultConfig возвращает конфигурацию сайта по умолчанию.
+func DefaultConfig() *Config {
+	return &Config{
+		PostsPerPage: 10,
+		RecentPosts:  5,
+		RelatedPosts: 5,
+		Dirs: SourceDirs{
+			Settings:  "__settings",
+			Templates: "__templates",
+			Blog:      "__blog",
+			Articles:  "__articles",
+			QA:        "__qa",
+			Hash:      "__hash",
+		},
+		Output: OutputNames{
+			Blog:     "blog",
+			Articles: "articles",
+			QA:       "qa.html",
+		},
+		Exclude:    []string{"assets"},
+		Jobs:       runtime.NumCPU(),
+		Autoescape: true,
+		Feed:       FeedOptions{Items: DefaultFeedItems},
+		Sitemap:    SitemapOptions{Enabled: true, Exclude: []string{"404.html"}, MaxURLs: SitemapMaxURLs},
+		Permalinks: PermalinkOptions{Post: DefaultPostPermalink, Tag: DefaultTagPermalink},
+		Redirects:  RedirectOptions{Stubs: true},
+		Search:     SearchOptions{File: DefaultSearchIndex, Page: DefaultSearchPage, Exclude: []string{"404.html"}, TextLength: DefaultSearchTextLength, Stemming: true},
+		Validation: DefaultValidation,
+		Params:     map[string]interface{}{},
+	}
+}
+
+// LoadConfig загружает конфигурацию сайта.
+// source — исходная корневая директория.
+// file — файл конфигурации; если не указан, файл ищется в директории __settings исходной директории,
+// а при его отсутствии используется конфигурация по умолчанию.
+func LoadConfig(source string, file string) (*Config, error) {
+	cfg := DefaultConfig()
+	cfg.Source = source
+
+	if len(file) == 0 {
+		for _, name := range ConfigFiles {
+			candidate := filepath.Join(source, cfg.Dirs.Settings, name)
+			if _, err := os.Stat(candidate); err == nil {
+				file = candidate
+				break
+			}
+		}
+		if len(file) == 0 {
+			return cfg, nil
+		}
+	}
+	cfg.File = file
+
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, &ConfigError{Problems: []string{err.Error()}}
+	}
+
+	var values map[string]interface{}
+	if ext := strings.ToLower(filepath.Ext(file)); ext == ".yaml" || ext == ".yml" {
+		values, err = parseYAML(string(raw))
+	} else {
+		values, err = parseTOML(string(raw))
+	}
+	if err != nil {
+		return nil, &ConfigError{File: file, Problems: []string{err.Error()}}
+	}
+
+	d := &configDecoder{}
+	d.decode(cfg, values)
+	if len(d.problems) > 0 {
+		return nil, &ConfigError{File: file, Problems: d.problems}
+	}
+
+	// Относительная целевая директория задаётся относительно исходной директории.
+	if len(cfg.Destination) > 0 && !filepath.IsAbs(cfg.Destination) {
+		cfg.Destination = filepath.Join(source, cfg.Destination)
+	}
+
+	return cfg, nil
+}
+
+// configDecoder переносит значения файла конфигурации в Config и накапливает ошибки.
+type configDecoder struct {
+	problems []string
+}
+
+func (d *configDecoder) problem(format string, args ...interface{}) {
+	d.problems = append(d.problems, fmt.Sprintf(format, args...))
+}
+
+// unknown проверяет, что в таблице нет неизвестных ключей.
+func (d *configDecoder) unknown(values map[string]interface{}, prefix string, known ...string) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !IsStringInList(key, known) {
+			d.problem("неизвестный параметр %s%s", prefix, key)
+		}
+	}
+}
+
+func (d *configDecoder) str(values map[string]interface{}, prefix string, key string, target *string) {
+	value, ok := values[key]
+	if !ok {
+		return
+	}
+	s, ok := value.(string)
+	if !ok {
+		d.problem("параметр %s%s должен быть строкой", prefix, key)
+		return
+	}
+	*target = s
+}
+
+func (d *configDecoder) integer(values map[string]interface{}, prefix string, key string, target *int) {
+	value, ok := values[key]
+	if !ok {
+		return
+	}
+	i, ok := value.(int64)
+	if !ok {
+		d.problem("параметр %s%s должен быть целым числом", prefix, key)
+		return
+	}
+	*target = int(i)
+}
+
+func (d *configDecoder) boolean(values map[string]interface{}, prefix string, key string, target *bool) {
+	value, ok := values[key]
+	if !ok {
+		return
+	}
+	b, ok := value.(bool)
+	if !ok {
+		d.problem("параметр %s%s должен быть логическим значением", prefix, key)
+		return
+	}
+	*target = b
+}
+
+func (d *configDecoder) stringList(values map[string]interface{}, prefix string, key string, target *[]string) {
+	value, ok := values[key]
+	if !ok {
+		return
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		d.problem("параметр %s%s должен быть списком строк", prefix, key)
+		return
+	}
+	result := []string{}
+	for _, item := range list {
+		s, ok := item.(string)
+		if !ok {
+			d.problem("параметр %s%s должен быть списком строк", prefix, key)
+			return
+		}
+		result = append(result, s)
+	}
+	*target = result
+}
+
+// table возвращает вложенную таблицу key, nil если она не задана.
+func (d *configDecoder) table(values map[string]interface{}, key string) map[string]interface{} {
+	value, ok := values[key]
+	if !ok {
+		return nil
+	}
+	table, ok := value.(map[string]interface{})
+	if !ok {
+		d.problem("параметр %s должен быть таблицей", key)
+		return nil
+	}
+
+	return table
+}
+
+// decode переносит значения файла конфигурации в cfg.
+func (d *configDecoder) decode(cfg *Config, values map[string]interface{}) {
+	d.unknown(values, "", "source", "destination", "domain", "title", "posts_per_page", "recent_posts", "related_posts", "dirs", "output", "compile", "feed", "sitemap", "permalinks", "redirects", "search", "validation", "params")
+	if _, ok := values["source"]; ok {
+		d.problem("параметр source задаётся только в командной строке")
+	}
+	d.str(values, "", "destination", &cfg.Destination)
+	d.str(values, "", "domain", &cfg.Domain)
+	d.str(values, "", "title", &cfg.Title)
+	d.integer(values, "", "posts_per_page", &cfg.PostsPerPage)
+	d.integer(values, "", "recent_posts", &cfg.RecentPosts)
+	d.integer(values, "", "related_posts", &cfg.RelatedPosts)
+
+	if dirs := d.table(values, "dirs"); dirs != nil {
+		d.unknown(dirs, "dirs.", "settings", "templates", "blog", "articles", "qa", "hash")
+		d.str(dirs, "dirs.", "settings", &cfg.Dirs.Settings)
+		d.str(dirs, "dirs.", "templates", &cfg.Dirs.Templates)
+		d.str(dirs, "dirs.", "blog", &cfg.Dirs.Blog)
+		d.str(dirs, "dirs.", "articles", &cfg.Dirs.Articles)
+		d.str(dirs, "dirs.", "qa", &cfg.Dirs.QA)
+		d.str(dirs, "dirs.", "hash", &cfg.Dirs.Hash)
+	}
+
+	if output := d.table(values, "output"); output != nil {
+		d.unknown(output, "output.", "blog", "articles", "qa")
+		d.str(output, "output.", "blog", &cfg.Output.Blog)
+		d.str(output, "output.", "articles", &cfg.Output.Articles)
+		d.str(output, "output.", "qa", &cfg.Output.QA)
+	}
+
+	if compile := d.table(values, "compile"); compile != nil {
+		d.unknown(compile, "compile.", "exclude", "jobs", "autoescape")
+		d.stringList(compile, "compile.", "exclude", &cfg.Exclude)
+		d.integer(compile, "compile.", "jobs", &cfg.Jobs)
+		d.boolean(compile, "compile.", "autoescape", &cfg.Autoescape)
+	}
+
+	if feed := d.table(values, "feed"); feed != nil {
+		d.unknown(feed, "feed.", "title", "author", "items", "full_content")
+		d.str(feed, "feed.", "title", &cfg.Feed.Title)
+		d.str(feed, "feed.", "author", &cfg.Feed.Author)
+		d.integer(feed, "feed.", "items", &cfg.Feed.Items)
+		d.boolean(feed, "feed.", "full_content", &cfg.Feed.FullContent)
+	}
+
+	if sitemap := d.table(values, "sitemap"); sitemap != nil {
+		d.unknown(sitemap, "sitemap.", "enabled", "exclude", "max_urls")
+		d.boolean(sitemap, "sitemap.", "enabled", &cfg.Sitemap.Enabled)
+		d.stringList(sitemap, "sitemap.", "exclude", &cfg.Sitemap.Exclude)
+		d.integer(sitemap, "sitemap.", "max_urls", &cfg.Sitemap.MaxURLs)
+	}
+
+	if permalinks := d.table(values, "permalinks"); permalinks != nil {
+		d.unknown(permalinks, "permalinks.", "post", "tag", "pretty_urls")
+		d.str(permalinks, "permalinks.", "post", &cfg.Permalinks.Post)
+		d.str(permalinks, "permalinks.", "tag", &cfg.Permalinks.Tag)
+		d.boolean(permalinks, "permalinks.", "pretty_urls", &cfg.Permalinks.PrettyURLs)
+	}
+
+	if redirects := d.table(values, "redirects"); redirects != nil {
+		d.unknown(redirects, "redirects.", "stubs", "htaccess", "nginx", "netlify")
+		d.boolean(redirects, "redirects.", "stubs", &cfg.Redirects.Stubs)
+		d.boolean(redirects, "redirects.", "htaccess", &cfg.Redirects.Htaccess)
+		d.boolean(redirects, "redirects.", "nginx", &cfg.Redirects.Nginx)
+		d.boolean(redirects, "redirects.", "netlify", &cfg.Redirects.Netlify)
+	}
+
+	if search := d.table(values, "search"); search != nil {
+		d.unknown(search, "search.", "enabled", "file", "page", "exclude", "text_length", "stemming")
+		d.boolean(search, "search.", "enabled", &cfg.Search.Enabled)
+		d.str(search, "search.", "file", &cfg.Search.File)
+		d.str(search, "search.", "page", &cfg.Search.Page)
+		d.stringList(search, "search.", "exclude", &cfg.Search.Exclude)
+		d.integer(search, "search.", "text_length", &cfg.Search.TextLength)
+		d.boolean(search, "search.", "stemming", &cfg.Search.Stemming)
+	}
+
+	if validation := d.table(values, "validation"); validation != nil {
+		d.unknown(validation, "validation.", "syntax", "required", "date", "tag", "duplicate", "pages", "empty")
+		d.str(validation, "validation.", "syntax", &cfg.Validation.Syntax)
+		d.str(validation, "validation.", "required", &cfg.Validation.Required)
+		d.str(validation, "validation.", "date", &cfg.Validation.Date)
+		d.str(validation, "validation.", "tag", &cfg.Validation.Tag)
+		d.str(validation, "validation.", "duplicate", &cfg.Validation.Duplicate)
+		d.str(validation, "validation.", "pages", &cfg.Validation.Pages)
+		d.str(validation, "validation.", "empty", &cfg.Validation.Empty)
+	}
+
+	if params := d.table(values, "params"); params != nil {
+		cfg.Params = params
+	}
+}
+
+// Validate проверяет конфигурацию сайта и дополняет значения, вычисляемые из других параметров.
+func (cfg *Config) Validate() error {
+	var problems []string
+	problem := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if len(cfg.Source) == 0 {
+		problem("не указана исходная директория (source)")
+	}
+	if len(cfg.Destination) == 0 {
+		problem("не указана целевая директория (destination)")
+	}
+	cfg.Domain = strings.TrimRight(cfg.Domain, "/")
+	if len(cfg.Domain) == 0 {
+		problem("не указан домен сайта (domain)")
+	} else if !strings.HasPrefix(cfg.Domain, "http://") && !strings.HasPrefix(cfg.Domain, "https://") {
+		problem("домен сайта (domain) должен начинаться с http:// или https://: %s", cfg.Domain)
+	}
+	if cfg.PostsPerPage <= 0 {
+		problem("количество постов на страницу (posts_per_page) должно быть больше нуля")
+	}
+	if cfg.RecentPosts < 0 {
+		problem("количество последних постов (recent_posts) не может быть отрицательным")
+	}
+	if cfg.RelatedPosts < 0 {
+		problem("количество похожих постов (related_posts) не может быть отрицательным")
+	}
+	if cfg.Jobs <= 0 {
+		problem("количество одновременно формируемых страниц (compile.jobs) должно быть больше нуля")
+	}
+	if cfg.Feed.Items <= 0 {
+		problem("количество записей в лентах (feed.items) должно быть больше нуля")
+	}
+	if cfg.Sitemap.MaxURLs <= 0 || cfg.Sitemap.MaxURLs > SitemapMaxURLs {
+		problem("количество адресов в файле sitemap (sitemap.max_urls) должно быть от 1 до %d", SitemapMaxURLs)
+	}
+	for _, pattern := range cfg.Sitemap.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			problem("некорректный шаблон sitemap.exclude %q: %v", pattern, err)
+		}
+	}
+
+	if cfg.Search.TextLength < 0 {
+		problem("количество символов текста в поисковом индексе (search.text_length) не может быть отрицательным")
+	}
+	for _, pattern := range cfg.Search.Exclude {
+		if _, err := path.Match(pattern, ""); err != nil {
+			problem("некорректный шаблон search.exclude %q: %v", pattern, err)
+		}
+	}
+	for _, file := range []struct{ key, name, ext string }{
+		{"search.file", cfg.Search.File, ".json"},
+		{"search.page", cfg.Search.Page, ".html"},
+	} {
+		if !strings.HasSuffix(file.name, file.ext) || strings.HasPrefix(file.name, "/") || strings.HasPrefix(file.name, "_") || strings.Contains(file.name, "..") || strings.Contains(file.name, `\`) {
+			problem("имя файла %s должно иметь расширение %s и быть путём относительно корня сайта: %q", file.key, file.ext, file.name)
+		}
+	}
+
+	levels := cfg.Validation.Levels()
+	for _, kind := range sortedKeys(levels) {
+		if level := levels[kind]; level != SeverityError && level != SeverityWarning && level != SeverityIgnore {
+			problem("уровень validation.%s должен быть error, warning или ignore: %q", kind, level)
+		}
+	}
+
+	dirs := map[string]string{
+		"dirs.settings":  cfg.Dirs.Settings,
+		"dirs.templates": cfg.Dirs.Templates,
+		"dirs.blog":      cfg.Dirs.Blog,
+		"dirs.articles":  cfg.Dirs.Articles,
+		"dirs.qa":        cfg.Dirs.QA,
+		"dirs.hash":      cfg.Dirs.Hash,
+	}
+	for _, key := range sortedKeys(dirs) {
+		// Служебные директории не должны компилироваться, поэтому их имена начинаются с __.
+		if name := dirs[key]; !strings.HasPrefix(name, "__") || strings.ContainsAny(name, `/\`) {
+			problem("имя директории %s должно начинаться с __ и не содержать разделителей пути: %q", key, name)
+		}
+	}
+
+	output := map[string]string{
+		"output.blog":     cfg.Output.Blog,
+		"output.articles": cfg.Output.Articles,
+		"output.qa":       cfg.Output.QA,
+	}
+	for _, key := range sortedKeys(output) {
+		if name := output[key]; len(name) == 0 || strings.HasPrefix(name, "_") || strings.HasPrefix(name, ".") || strings.ContainsAny(name, `/\`) {
+			problem("имя %s должно быть непустым, не начинаться с _ или . и не содержать разделителей пути: %q", key, name)
+		}
+	}
+	if cfg.Output.Blog == cfg.Output.Articles {
+		problem("имена output.blog и output.articles должны различаться")
+	}
+	if !strings.HasSuffix(cfg.Output.QA, ".html") {
+		problem("имя файла output.qa должно иметь расширение .html: %q", cfg.Output.QA)
+	}
+
+	if err := checkPermalink(cfg.Permalinks.Post, postPermalinkTokens, []string{":slug", ":title"}); err != nil {
+		problem("permalinks.post: %v", err)
+	}
+	if err := checkPermalink(cfg.Permalinks.Tag, tagPermalinkTokens, []string{":tagid", ":tagslug"}); err != nil {
+		problem("permalinks.tag: %v", err)
+	} else if !strings.HasSuffix(cfg.Permalinks.Tag, "/") {
+		problem("шаблон адреса рубрики permalinks.tag должен оканчиваться символом /: %q", cfg.Permalinks.Tag)
+	}
+
+	if len(cfg.LogFormat) > 0 && cfg.LogFormat != LogFormatText && cfg.LogFormat != LogFormatJSON {
+		problem("формат вывода (-log-format) должен быть text или json: %q", cfg.LogFormat)
+	}
+
+	for _, name := range cfg.Exclude {
+		if len(name) == 0 || strings.ContainsAny(name, `/\`) {
+			problem("элемент compile.exclude должен быть именем директории: %q", name)
+		}
+	}
+
+	if len(problems) > 0 {
+		return &ConfigError{File: cfg.File, Problems: problems}
+	}
+
+	// Заголовок и автор лент по умолчанию совпадают с названием сайта или доменом.
+	if len(cfg.Title) == 0 {
+		cfg.Title = strings.TrimPrefix(strings.TrimPrefix(cfg.Domain, "https://"), "http://")
+	}
+	if len(cfg.Feed.Title) == 0 {
+		cfg.Feed.Title = cfg.Title
+	}
+	if len(cfg.Feed.Author) == 0 {
+		cfg.Feed.Author = cfg.Title
+	}
+
+	return nil
+}
+
+// sortedKeys возвращает отсортированные ключи словаря.
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// SettingsDir возвращает директорию файлов настроек сайта.
+func (cfg *Config) SettingsDir() string {
+	return filepath.Join(cfg.Source, cfg.Dirs.Settings)
+}
+
+// TemplatesDir возвращает директорию шаблонов сайта.
+func (cfg *Config) TemplatesDir() string {
+	return filepath.Join(cfg.Source, cfg.Dirs.Templates)
+}
+
+// BlogDir возвращает исходную директорию постов блога.
+func (cfg *Config) BlogDir() string {
+	return filepath.Join(cfg.Source, cfg.Dirs.Blog)
+}
+
+// ArticlesDir возвращает исходную директорию публикаций.
+func (cfg *Config) ArticlesDir() string {
+	return filepath.Join(cfg.Source, cfg.Dirs.Articles)
+}
+
+// QADir возвращает исходную директорию записей Вопросы и ответы.
+func (cfg *Config) QADir() string {
+	return filepath.Join(cfg.Source, cfg.Dirs.QA)
+}
+
+// HashDir возвращает директорию хэшей, манифеста и графа зависимостей.
+func (cfg *Config) HashDir() string {
+	return filepath.Join(cfg.Source, cfg.Dirs.Hash)
+}
+
+// DestinationBlogDir возвращает целевую директорию блога.
+func (cfg *Config) DestinationBlogDir() string {
+	return filepath.Join(cfg.Destination, cfg.Output.Blog)
+}
+
+// DestinationArticlesDir возвращает целевую директорию публикаций.
+func (cfg *Config) DestinationArticlesDir() string {
+	return filepath.Join(cfg.Destination, cfg.Output.Articles)
+}
+
+// BlogURL возвращает адрес блога на целевом сервере без завершающего символа /.
+func (cfg *Config) BlogURL() string {
+	return cfg.Domain + "/" + cfg.Output.Blog
+}
+
+// ArticleURL возвращает адрес публикации на целевом сервере: адрес директории публикации.
+func (cfg *Config) ArticleURL(article Article) string {
+	return cfg.ArticlesURL() + "/" + article.Fuseaction + "/"
+}
+
+// ArticlesURL возвращает адрес раздела публикаций на целевом сервере без завершающего символа /.
+func (cfg *Config) ArticlesURL() string {
+	return cfg.Domain + "/" + cfg.Output.Articles
+}
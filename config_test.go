@@ -0,0 +1,197 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig_TOML(t *testing.T) {
+	t.Parallel()
+
+	source := t.TempDir()
+	writeTestFile(t, filepath.Join(source, "__settings", "site.toml"), `
+domain = "https://example.test/"
+destination = "public"
+posts_per_page = 5
+
+[output]
+blog = "news"
+
+[compile]
+exclude = ["assets", "media"]
+jobs = 3
+
+[feed]
+items = 3
+
+[sitemap]
+exclude = ["404.html", "drafts"]
+max_urls = 1000
+
+[permalinks]
+post = "/:blog/:year/:slug/"
+pretty_urls = true
+
+[search]
+enabled = true
+text_length = 0
+
+[validation]
+pages = "error"
+empty = "ignore"
+
+[params]
+author = "Автор"
+`)
+
+	cfg, err := LoadConfig(source, "")
+	if err != nil {
+		t.Fatalf("LoadConfig вернул ошибку: %v", err)
+	}
+	if err = cfg.Validate(); err != nil {
+		t.Fatalf("Validate вернул ошибку: %v", err)
+	}
+	if cfg.Domain != "https://example.test" {
+		t.Fatalf("домен = %q, ожидалось без завершающего /", cfg.Domain)
+	}
+	if cfg.Destination != filepath.Join(source, "public") {
+		t.Fatalf("целевая директория = %q, ожидался путь относительно исходной директории", cfg.Destination)
+	}
+	if cfg.PostsPerPage != 5 || cfg.Feed.Items != 3 || len(cfg.Exclude) != 2 || cfg.Jobs != 3 {
+		t.Fatalf("неверно загружены параметры: %+v", cfg)
+	}
+	if cfg.BlogURL() != "https://example.test/news" || cfg.DestinationBlogDir() != filepath.Join(source, "public", "news") {
+		t.Fatalf("раздел блога = %q, %q", cfg.BlogURL(), cfg.DestinationBlogDir())
+	}
+	if cfg.Title != "example.test" || cfg.Feed.Title != "example.test" || cfg.Feed.Author != "example.test" {
+		t.Fatalf("заголовок по умолчанию = %q, заголовок ленты = %q, автор ленты = %q", cfg.Title, cfg.Feed.Title, cfg.Feed.Author)
+	}
+	if cfg.Params["author"] != "Автор" {
+		t.Fatalf("params.author = %v", cfg.Params["author"])
+	}
+	if !cfg.Sitemap.Enabled || cfg.Sitemap.MaxURLs != 1000 || strings.Join(cfg.Sitemap.Exclude, ",") != "404.html,drafts" {
+		t.Fatalf("параметры sitemap = %+v", cfg.Sitemap)
+	}
+	if cfg.Permalinks.Post != "/:blog/:year/:slug/" || cfg.Permalinks.Tag != DefaultTagPermalink || !cfg.Permalinks.PrettyURLs {
+		t.Fatalf("параметры permalinks = %+v", cfg.Permalinks)
+	}
+	if !cfg.Search.Enabled || cfg.Search.TextLength != 0 || cfg.Search.File != DefaultSearchIndex || !cfg.Search.Stemming {
+		t.Fatalf("параметры search = %+v", cfg.Search)
+	}
+	if cfg.Validation.Pages != SeverityError || cfg.Validation.Empty != SeverityIgnore || cfg.Validation.Tag != SeverityError {
+		t.Fatalf("параметры validation = %+v", cfg.Validation)
+	}
+}
+
+func TestLoadConfig_YAML(t *testing.T) {
+	t.Parallel()
+
+	source := t.TempDir()
+	file := filepath.Join(t.TempDir(), "site.yaml")
+	writeTestFile(t, file, `
+domain: https://example.test
+destination: /var/www/example
+title: Пример
+dirs:
+  blog: __news
+feed:
+  full_content: true
+`)
+
+	cfg, err := LoadConfig(source, file)
+	if err != nil {
+		t.Fatalf("LoadConfig вернул ошибку: %v", err)
+	}
+	if err = cfg.Validate(); err != nil {
+		t.Fatalf("Validate вернул ошибку: %v", err)
+	}
+	if cfg.BlogDir() != filepath.Join(source, "__news") || !cfg.Feed.FullContent || cfg.Feed.Title != "Пример" {
+		t.Fatalf("неверно загружены параметры: %+v", cfg)
+	}
+	if cfg.File != file {
+		t.Fatalf("файл конфигурации = %q, ожидалось %q", cfg.File, file)
+	}
+}
+
+func TestLoadConfig_WithoutFileUsesDefaults(t *testing.T) {
+	t.Parallel()
+
+	source := t.TempDir()
+	cfg, err := LoadConfig(source, "")
+	if err != nil {
+		t.Fatalf("LoadConfig вернул ошибку: %v", err)
+	}
+	if cfg.PostsPerPage != 10 || cfg.Output.QA != "qa.html" || cfg.TemplatesDir() != filepath.Join(source, "__templates") {
+		t.Fatalf("неверные значения по умолчанию: %+v", cfg)
+	}
+	if err = cfg.Validate(); err == nil {
+		t.Fatalf("без целевой директории и домена конфигурация не должна проходить проверку")
+	}
+}
+
+func TestLoadConfig_ReportsProblems(t *testing.T) {
+	t.Parallel()
+
+	source := t.TempDir()
+	writeTestFile(t, filepath.Join(source, "__settings", "site.toml"), `
+domain = "https://example.test"
+destination = "public"
+post_per_page = 5
+posts_per_page = "много"
+`)
+
+	_, err := LoadConfig(source, "")
+	if err == nil {
+		t.Fatalf("ожидалась ошибка конфигурации")
+	}
+	for _, expected := range []string{"post_per_page", "posts_per_page"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("в ошибке %q не упомянут параметр %s", err.Error(), expected)
+		}
+	}
+}
+
+func TestConfig_ValidateRejectsInvalidValues(t *testing.T) {
+	t.Parallel()
+
+	cfg := DefaultConfig()
+	cfg.Source = t.TempDir()
+	cfg.Destination = t.TempDir()
+	cfg.Domain = "example.test"
+	cfg.PostsPerPage = 0
+	cfg.Output.Articles = cfg.Output.Blog
+
+	err := cfg.Validate()
+	configErr, ok := err.(*ConfigError)
+	if !ok {
+		t.Fatalf("ожидалась ошибка ConfigError, получено %v", err)
+	}
+	if len(configErr.Problems) != 3 {
+		t.Fatalf("ожидалось 3 проблемы, получено %v", configErr.Problems)
+	}
+}
+
+func TestLoadConfigFromArgs_FlagsOverrideFile(t *testing.T) {
+	t.Parallel()
+
+	source := t.TempDir()
+	writeTestFile(t, filepath.Join(source, "__settings", "site.toml"), `
+domain = "https://example.test"
+destination = "public"
+
+[feed]
+items = 5
+`)
+
+	cfg, err := loadConfigFromArgs([]string{"-source=" + source, "-domain=https://other.test", "-feed-full", "-jobs=2"})
+	if err != nil {
+		t.Fatalf("loadConfigFromArgs вернул ошибку: %v", err)
+	}
+	if cfg.Domain != "https://other.test" || !cfg.Feed.FullContent || cfg.Jobs != 2 {
+		t.Fatalf("параметры командной строки не переопределили файл конфигурации: %+v", cfg)
+	}
+	if cfg.Feed.Items != 5 {
+		t.Fatalf("не указанный параметр feed-items переопределил файл конфигурации: %d", cfg.Feed.Items)
+	}
+}
@@ -0,0 +1,456 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Граф зависимостей сформированных страниц от шаблонов, файлов настроек и исходных данных.
+
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"text/template/parse"
+)
+
+// DepsFile — имя файла графа зависимостей в директории __hash.
+const DepsFile = "deps.json"
+
+// templateDefinitionsInput — имя входа страницы, хэш которого вычисляется не по файлу, а по определениям
+// шаблонов директории __templates: каким файлом определён каждый именованный шаблон. Новый файл *.tmpl,
+// переопределяющий используемый страницей шаблон, не входит в её зависимости, но изменяет этот хэш;
+// при добавлении, удалении или переносе определения любого шаблона формируются заново все страницы.
+const templateDefinitionsInput = "*.tmpl"
+
+// siteDataInput — имя входа страницы, хэш которого — контрольная сумма общих данных сайта (см. site.go).
+// Вход записывается только для страниц, шаблоны которых могут обратиться к .Site (см. siteReads), поэтому
+// изменение поста не приводит к формированию заново страниц, не использующих данные сайта.
+const siteDataInput = ".Site"
+
+// siteURLInput — имя входа страницы, хэш которого — хэш домена сайта. Функции шаблонов AbsURL, RelURL и Asset
+// выводят домен и его путь в страницу (см. siteFuncs), поэтому вход записывается для каждой страницы,
+// сформированной с этими функциями, и изменение домена формирует такие страницы заново.
+const siteURLInput = ".Domain"
+
+// pageDeps описывает зависимости одной сформированной страницы.
+type pageDeps struct {
+	// Файлы, от которых зависит страница: шаблон страницы, используемые шаблоны *.tmpl
+	// и исходные файлы данных, с их crc32-хэшами.
+	Inputs map[string]string `json:"inputs"`
+	// crc32-хэш данных, переданных шаблону.
+	Data string `json:"data"`
+}
+
+// templateIndex описывает шаблоны директории __templates.
+type templateIndex struct {
+	// Файл, в котором определён именованный шаблон.
+	files map[string]string
+	// Дерево разбора именованного шаблона.
+	trees map[string]*parse.Tree
+}
+
+// DependencyGraph хранит зависимости сформированных страниц между сборками.
+// Ключом графа является путь сформированного файла относительно целевой директории.
+type DependencyGraph struct {
+	mu       sync.Mutex
+	path     string
+	previous map[string]pageDeps
+	current  map[string]pageDeps
+	// crc32-хэши входных файлов, вычисленные в текущей сборке.
+	hashes map[string]string
+	// Разобранные директории шаблонов текущей сборки.
+	templates map[string]*templateIndex
+	// Контрольная сумма общих данных сайта текущей сборки.
+	site string
+	// Хэш домена сайта текущей сборки.
+	domain string
+}
+
+// LoadDependencyGraph загружает граф зависимостей прошлой сборки.
+// hashDir — директория, в которой хранится граф.
+func LoadDependencyGraph(hashDir string) (*DependencyGraph, error) {
+	g := &DependencyGraph{
+		path:      filepath.Join(hashDir, DepsFile),
+		previous:  map[string]pageDeps{},
+		current:   map[string]pageDeps{},
+		hashes:    map[string]string{},
+		templates: map[string]*templateIndex{},
+	}
+
+	raw, err := ioutil.ReadFile(g.path)
+	if os.IsNotExist(err) {
+		return g, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// Повреждённый граф не является ошибкой: все страницы будут сформированы заново.
+	if err = json.Unmarshal(raw, &g.previous); err != nil {
+		g.previous = map[string]pageDeps{}
+	}
+
+	return g, nil
+}
+
+// fileHash возвращает crc32-хэш входного файла, пустую строку для отсутствующего файла.
+// Для входа <директория шаблонов>/*.tmpl возвращается хэш определений шаблонов директории,
+// для входа .Site — контрольная сумма данных сайта, для входа .Domain — хэш домена сайта.
+func (g *DependencyGraph) fileHash(path string) string {
+	g.mu.Lock()
+	hash, ok := g.hashes[path]
+	switch path {
+	case siteDataInput:
+		hash, ok = g.site, true
+	case siteURLInput:
+		hash, ok = g.domain, true
+	}
+	g.mu.Unlock()
+	if ok {
+		return hash
+	}
+
+	if filepath.Base(path) == templateDefinitionsInput {
+		hash = g.definitionsHash(filepath.Dir(path))
+	} else {
+		hash = HashFileCrc32(path)
+	}
+
+	g.mu.Lock()
+	g.hashes[path] = hash
+	g.mu.Unlock()
+
+	return hash
+}
+
+// SetSite задаёт контрольную сумму общих данных сайта текущей сборки.
+func (g *DependencyGraph) SetSite(fingerprint string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.site = fingerprint
+}
+
+// SetDomain задаёт домен сайта текущей сборки.
+func (g *DependencyGraph) SetDomain(domain string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.domain = HashStringCrc32(domain)
+}
+
+// dataHash вычисляет crc32-хэш данных шаблона.
+// Общие данные сайта в хэш не входят: они учитываются входом siteDataInput (см. Site.MarshalJSON).
+// Если данные не удаётся сериализовать, возвращается пустая строка, и страница считается изменённой.
+func dataHash(data interface{}) string {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return ""
+	}
+
+	return HashStringCrc32(string(raw))
+}
+
+// Unchanged проверяет, что зависимости страницы rel не изменились с прошлой сборки.
+// Если зависимости не изменились, они переносятся в граф текущей сборки.
+func (g *DependencyGraph) Unchanged(rel string, data string) bool {
+	g.mu.Lock()
+	deps, ok := g.previous[rel]
+	g.mu.Unlock()
+	if !ok || len(data) == 0 || deps.Data != data {
+		return false
+	}
+
+	for path, hash := range deps.Inputs {
+		if g.fileHash(path) != hash {
+			return false
+		}
+	}
+
+	g.mu.Lock()
+	g.current[rel] = deps
+	g.mu.Unlock()
+
+	return true
+}
+
+// Record сохраняет зависимости страницы rel в графе текущей сборки.
+// inputs — файлы, от которых зависит страница.
+// data — crc32-хэш данных шаблона.
+func (g *DependencyGraph) Record(rel string, inputs []string, data string) {
+	deps := pageDeps{Inputs: map[string]string{}, Data: data}
+	for _, path := range inputs {
+		deps.Inputs[path] = g.fileHash(path)
+	}
+
+	g.mu.Lock()
+	g.current[rel] = deps
+	g.mu.Unlock()
+}
+
+// Save сохраняет граф текущей сборки и подготавливает граф к следующей сборке.
+func (g *DependencyGraph) Save() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	raw, err := json.MarshalIndent(g.current, "", " ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(g.path), 0755); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(g.path, raw, 0644); err != nil {
+		return err
+	}
+
+	g.previous = g.current
+	g.current = map[string]pageDeps{}
+	g.hashes = map[string]string{}
+	g.templates = map[string]*templateIndex{}
+
+	return nil
+}
+
+// templateIndex разбирает шаблоны *.tmpl директории templatesDir.
+// Порядок файлов совпадает с порядком ParseGlob, поэтому при повторном определении шаблона
+// побеждает последний файл, как и при формировании страницы.
+func (g *DependencyGraph) templateIndex(templatesDir string) (*templateIndex, error) {
+	g.mu.Lock()
+	index, ok := g.templates[templatesDir]
+	g.mu.Unlock()
+	if ok {
+		return index, nil
+	}
+
+	index = &templateIndex{files: map[string]string{}, trees: map[string]*parse.Tree{}}
+	files, err := filepath.Glob(filepath.Join(templatesDir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		trees, err := parseTemplateTrees(file)
+		if err != nil {
+			return nil, err
+		}
+		for name, tree := range trees {
+			index.files[name] = file
+			index.trees[name] = tree
+		}
+	}
+
+	g.mu.Lock()
+	g.templates[templatesDir] = index
+	g.mu.Unlock()
+
+	return index, nil
+}
+
+// definitionsHash возвращает crc32-хэш определений шаблонов директории templatesDir: имён шаблонов
+// и файлов, в которых они определены. При ошибке разбора возвращается пустая строка.
+func (g *DependencyGraph) definitionsHash(templatesDir string) string {
+	index, err := g.templateIndex(templatesDir)
+	if err != nil {
+		return ""
+	}
+
+	names := make([]string, 0, len(index.files))
+	for name := range index.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(name + "\t" + index.files[name] + "\n")
+	}
+
+	return HashStringCrc32(b.String())
+}
+
+// parseTemplateTrees разбирает файл шаблона и возвращает деревья всех определённых в нём шаблонов.
+func parseTemplateTrees(file string) (map[string]*parse.Tree, error) {
+	raw, err := readPageTemplate(file)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := template.New(filepath.Base(file)).Funcs(templateFuncs()).Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	trees := map[string]*parse.Tree{}
+	for _, defined := range t.Templates() {
+		if defined.Tree != nil {
+			trees[defined.Name()] = defined.Tree
+		}
+	}
+
+	return trees, nil
+}
+
+// TemplateFiles возвращает шаблоны *.tmpl, которые использует страница pagepath,
+// с учётом вложенных вызовов {{template}}, и вход определений шаблонов директории templatesDir
+// (templateDefinitionsInput). Если страница или используемые ею шаблоны могут обратиться к данным сайта
+// (см. siteReads), в список входит также вход siteDataInput.
+func (g *DependencyGraph) TemplateFiles(pagepath string, templatesDir string) ([]string, error) {
+	pageTrees, err := parseTemplateTrees(pagepath)
+	if err != nil {
+		return nil, err
+	}
+	index := &templateIndex{}
+	if len(templatesDir) > 0 {
+		if index, err = g.templateIndex(templatesDir); err != nil {
+			return nil, err
+		}
+	}
+
+	readsSite := false
+	used := map[string]bool{}
+	visited := map[string]bool{}
+	var queue []*parse.Tree
+	for _, tree := range pageTrees {
+		queue = append(queue, tree)
+	}
+	for len(queue) > 0 {
+		tree := queue[0]
+		queue = queue[1:]
+		readsSite = readsSite || siteReads(tree.Root)
+		for _, name := range templateCalls(tree.Root) {
+			if visited[name] {
+				continue
+			}
+			visited[name] = true
+			// Шаблоны, определённые в самой странице, переопределяют шаблоны директории __templates.
+			if pageTree, ok := pageTrees[name]; ok {
+				queue = append(queue, pageTree)
+				continue
+			}
+			if file, ok := index.files[name]; ok {
+				used[file] = true
+				queue = append(queue, index.trees[name])
+			}
+		}
+	}
+
+	files := make([]string, 0, len(used)+2)
+	for file := range used {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	if len(templatesDir) > 0 {
+		files = append(files, filepath.Join(templatesDir, templateDefinitionsInput))
+	}
+	if readsSite {
+		files = append(files, siteDataInput)
+	}
+
+	return files, nil
+}
+
+// siteReads проверяет, может ли узел дерева разбора обратиться к данным сайта. Проверка завышает:
+// кроме полей .Site, $.Site, $p.Site и .p.Site обращением считается любое использование точки или переменной
+// целиком — вывод, присваивание переменной ({{$p := .}}), передача функции (index, Dict) или в range и with, —
+// поскольку через них шаблон может прочитать данные сайта без поля Site в тексте шаблона.
+// Точка или переменная, переданная вызову {{template}} целиком, обращением не считается: вызываемые шаблоны
+// проверяются отдельно.
+func siteReads(node parse.Node) bool {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return false
+		}
+		for _, child := range n.Nodes {
+			if siteReads(child) {
+				return true
+			}
+		}
+	case *parse.ActionNode:
+		return siteReads(n.Pipe)
+	case *parse.TemplateNode:
+		if n.Pipe != nil && len(n.Pipe.Cmds) == 1 && len(n.Pipe.Cmds[0].Args) == 1 && isWholeData(n.Pipe.Cmds[0].Args[0]) {
+			return false
+		}
+		return siteReads(n.Pipe)
+	case *parse.IfNode:
+		return siteReads(n.Pipe) || siteReads(n.List) || siteReads(n.ElseList)
+	case *parse.RangeNode:
+		return siteReads(n.Pipe) || siteReads(n.List) || siteReads(n.ElseList)
+	case *parse.WithNode:
+		return siteReads(n.Pipe) || siteReads(n.List) || siteReads(n.ElseList)
+	case *parse.PipeNode:
+		if n == nil {
+			return false
+		}
+		for _, cmd := range n.Cmds {
+			if siteReads(cmd) {
+				return true
+			}
+		}
+	case *parse.CommandNode:
+		for _, arg := range n.Args {
+			if isWholeData(arg) || siteReads(arg) {
+				return true
+			}
+		}
+	case *parse.FieldNode:
+		return hasSiteIdent(n.Ident)
+	case *parse.VariableNode:
+		return hasSiteIdent(n.Ident[1:])
+	case *parse.ChainNode:
+		return hasSiteIdent(n.Field) || siteReads(n.Node)
+	}
+
+	return false
+}
+
+// isWholeData проверяет, является ли узел точкой или переменной без обращения к полям.
+func isWholeData(node parse.Node) bool {
+	switch n := node.(type) {
+	case *parse.DotNode:
+		return true
+	case *parse.VariableNode:
+		return len(n.Ident) == 1
+	}
+
+	return false
+}
+
+// hasSiteIdent проверяет, есть ли среди имён полей цепочки поле Site.
+func hasSiteIdent(idents []string) bool {
+	for _, ident := range idents {
+		if ident == "Site" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// templateCalls возвращает имена шаблонов, вызываемых через {{template}} в узле дерева разбора.
+func templateCalls(node parse.Node) []string {
+	var names []string
+
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return nil
+		}
+		for _, child := range n.Nodes {
+			names = append(names, templateCalls(child)...)
+		}
+	case *parse.TemplateNode:
+		names = append(names, n.Name)
+	case *parse.IfNode:
+		names = append(names, templateCalls(n.List)...)
+		names = append(names, templateCalls(n.ElseList)...)
+	case *parse.RangeNode:
+		names = append(names, templateCalls(n.List)...)
+		names = append(names, templateCalls(n.ElseList)...)
+	case *parse.WithNode:
+		names = append(names, templateCalls(n.List)...)
+		names = append(names, templateCalls(n.ElseList)...)
+	}
+
+	return names
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+)
+
+// renderCounter считает выполнения шаблона, вызывающего метод Touch.
+type renderCounter struct {
+	calls *int32
+}
+
+func (c renderCounter) Touch() string {
+	atomic.AddInt32(c.calls, 1)
+	return ""
+}
+
+func writeTestFile(t *testing.T, path string, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("не удалось создать директорию: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("не удалось создать файл %s: %v", path, err)
+	}
+}
+
+func TestDependencyGraph_TemplateFilesReturnsOnlyUsedTemplates(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	templatesDir := filepath.Join(dir, "__templates")
+	writeTestFile(t, filepath.Join(templatesDir, "base.tmpl"), `{{define "header"}}<h1>{{template "logo" .}}</h1>{{end}}`)
+	writeTestFile(t, filepath.Join(templatesDir, "logo.tmpl"), `{{define "logo"}}logo{{end}}`)
+	writeTestFile(t, filepath.Join(templatesDir, "sidebar.tmpl"), `{{define "sidebar"}}sidebar{{end}}`)
+	page := filepath.Join(dir, "page.html")
+	writeTestFile(t, page, `{{if true}}{{template "header" .}}{{end}}`)
+
+	graph, err := LoadDependencyGraph(filepath.Join(dir, "__hash"))
+	if err != nil {
+		t.Fatalf("LoadDependencyGraph вернул ошибку: %v", err)
+	}
+	files, err := graph.TemplateFiles(page, templatesDir)
+	if err != nil {
+		t.Fatalf("TemplateFiles вернул ошибку: %v", err)
+	}
+
+	expected := []string{filepath.Join(templatesDir, "base.tmpl"), filepath.Join(templatesDir, "logo.tmpl"), filepath.Join(templatesDir, templateDefinitionsInput)}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("шаблоны страницы = %v, ожидалось %v", files, expected)
+	}
+}
+
+func TestOutputWriter_RenderFileSkipsUnchangedPages(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	hashDir := filepath.Join(dir, "__hash")
+	destination := filepath.Join(dir, "dest")
+	templatesDir := filepath.Join(dir, "__templates")
+	writeTestFile(t, filepath.Join(templatesDir, "header.tmpl"), `{{define "header"}}header{{end}}`)
+	writeTestFile(t, filepath.Join(templatesDir, "sidebar.tmpl"), `{{define "sidebar"}}sidebar{{end}}`)
+	withHeader := filepath.Join(dir, "with_header.html")
+	withSidebar := filepath.Join(dir, "with_sidebar.html")
+	writeTestFile(t, withHeader, `{{.Touch}}{{template "header" .}}`)
+	writeTestFile(t, withSidebar, `{{.Touch}}{{template "sidebar" .}}`)
+
+	var calls int32
+	data := renderCounter{calls: &calls}
+	build := func() {
+		output, err := NewOutputWriter(destination, hashDir)
+		if err != nil {
+			t.Fatalf("NewOutputWriter вернул ошибку: %v", err)
+		}
+		for _, page := range []string{withHeader, withSidebar} {
+			if _, err = output.RenderFile(filepath.Join(destination, filepath.Base(page)), page, templatesDir, data, "page"); err != nil {
+				t.Fatalf("RenderFile вернул ошибку: %v", err)
+			}
+		}
+		if err = output.Close(); err != nil {
+			t.Fatalf("Close вернул ошибку: %v", err)
+		}
+	}
+
+	build()
+	if calls != 2 {
+		t.Fatalf("при первой сборке должны быть выполнены 2 шаблона, выполнено %d", calls)
+	}
+
+	build()
+	if calls != 2 {
+		t.Fatalf("при сборке без изменений шаблоны не должны выполняться, выполнено %d", calls-2)
+	}
+
+	writeTestFile(t, filepath.Join(templatesDir, "sidebar.tmpl"), `{{define "sidebar"}}new sidebar{{end}}`)
+	build()
+	if calls != 3 {
+		t.Fatalf("после изменения шаблона sidebar должна быть сформирована одна страница, сформировано %d", calls-2)
+	}
+	content, err := os.ReadFile(filepath.Join(destination, "with_sidebar.html"))
+	if err != nil || string(content) != "new sidebar" {
+		t.Fatalf("страница с изменённым шаблоном = %q, err=%v", string(content), err)
+	}
+
+	if err = os.Remove(filepath.Join(destination, "with_header.html")); err != nil {
+		t.Fatalf("не удалось удалить сформированный файл: %v", err)
+	}
+	build()
+	if calls != 4 {
+		t.Fatalf("удалённая из целевой директории страница должна быть сформирована заново")
+	}
+}
+
+func TestOutputWriter_RenderFileRebuildsPageWhenTemplateIsRedefined(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	hashDir := filepath.Join(dir, "__hash")
+	destination := filepath.Join(dir, "dest")
+	templatesDir := filepath.Join(dir, "__templates")
+	writeTestFile(t, filepath.Join(templatesDir, "a.tmpl"), `{{define "header"}}header{{end}}`)
+	page := filepath.Join(dir, "page.html")
+	writeTestFile(t, page, `{{.Touch}}{{template "header" .}}`)
+
+	var calls int32
+	data := renderCounter{calls: &calls}
+	build := func() string {
+		output, err := NewOutputWriter(destination, hashDir)
+		if err != nil {
+			t.Fatalf("NewOutputWriter вернул ошибку: %v", err)
+		}
+		if _, err = output.RenderFile(filepath.Join(destination, "page.html"), page, templatesDir, data, "page"); err != nil {
+			t.Fatalf("RenderFile вернул ошибку: %v", err)
+		}
+		if err = output.Close(); err != nil {
+			t.Fatalf("Close вернул ошибку: %v", err)
+		}
+		content, err := os.ReadFile(filepath.Join(destination, "page.html"))
+		if err != nil {
+			t.Fatalf("страница не сформирована: %v", err)
+		}
+		return string(content)
+	}
+
+	build()
+	build()
+	if calls != 1 {
+		t.Fatalf("страница сформирована заново без изменения шаблонов, выполнено %d", calls)
+	}
+
+	// Новый файл, разобранный позже, переопределяет шаблон header; файл a.tmpl не изменился.
+	writeTestFile(t, filepath.Join(templatesDir, "b.tmpl"), `{{define "header"}}new header{{end}}`)
+	if content := build(); calls != 2 || content != "new header" {
+		t.Fatalf("после переопределения шаблона страница = %q, выполнено %d", content, calls)
+	}
+}
+
+func TestDependencyGraph_TemplateFilesDetectsSiteReads(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	templatesDir := filepath.Join(dir, "__templates")
+	writeTestFile(t, filepath.Join(templatesDir, "base.tmpl"), `{{define "title"}}{{.Title}}{{end}}{{define "posts"}}{{range .p.Site.Posts}}{{.Title}}{{end}}{{end}}`)
+	graph, err := LoadDependencyGraph(filepath.Join(dir, "__hash"))
+	if err != nil {
+		t.Fatalf("LoadDependencyGraph вернул ошибку: %v", err)
+	}
+
+	pages := map[string]bool{
+		`{{.Title}}{{template "title" .}}`:                           false,
+		`{{range .Posts}}{{.Title}}{{end}}`:                          false,
+		`{{.Site.Title}}`:                                            true,
+		`{{range $.Site.Posts}}{{.Title}}{{end}}`:                    true,
+		`{{$p := .}}{{range $p.Site.Posts}}{{.Title}}{{end}}`:        true,
+		`{{$p := .}}{{template "title" $p}}`:                         true,
+		`{{range (index . "Site").Posts}}{{.Title}}{{end}}`:          true,
+		`{{template "posts" (Dict "p" .)}}`:                          true,
+		`{{with .Blogpost}}{{template "posts" (Dict "p" $)}}{{end}}`: true,
+		`{{printf "%v" .}}`:                                          true,
+	}
+	i := 0
+	for text, expected := range pages {
+		i++
+		page := filepath.Join(dir, "page"+strconv.Itoa(i)+".html")
+		writeTestFile(t, page, text)
+		files, err := graph.TemplateFiles(page, templatesDir)
+		if err != nil {
+			t.Fatalf("TemplateFiles(%s) вернул ошибку: %v", text, err)
+		}
+		if actual := files[len(files)-1] == siteDataInput; actual != expected {
+			t.Errorf("страница %s: обращение к данным сайта = %v, ожидалось %v", text, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Построчное сравнение файлов в формате unified diff для плана пробного запуска сборки (-dry-run -diff).
+// Общие начальные и конечные строки отбрасываются, для остальных строк вычисляется наибольшая общая
+// подпоследовательность. Если изменённая часть слишком велика, она выводится целиком как удалённая и добавленная.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Параметры сравнения файлов.
+const (
+	// Количество неизменённых строк вокруг изменений.
+	diffContext = 3
+	// Наибольшее произведение количества изменённых строк старого и нового файла, для которого
+	// вычисляется наибольшая общая подпоследовательность.
+	diffMaxCells = 4000000
+)
+
+// diffLine — строка сравнения: ' ' — общая строка, '-' — удалённая, '+' — добавленная.
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// splitLines разбивает текст на строки без символов перевода строки.
+func splitLines(text string) []string {
+	if len(text) == 0 {
+		return nil
+	}
+
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines сравнивает строки a и b.
+func diffLines(a []string, b []string) []diffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	lines := make([]diffLine, 0, len(a)+len(b))
+	for _, text := range a[:prefix] {
+		lines = append(lines, diffLine{' ', text})
+	}
+	lines = append(lines, diffMiddle(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, text := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{' ', text})
+	}
+
+	return lines
+}
+
+// diffMiddle сравнивает изменённые части строк по наибольшей общей подпоследовательности.
+func diffMiddle(a []string, b []string) []diffLine {
+	var lines []diffLine
+	if len(a)*len(b) > diffMaxCells {
+		for _, text := range a {
+			lines = append(lines, diffLine{'-', text})
+		}
+		for _, text := range b {
+			lines = append(lines, diffLine{'+', text})
+		}
+		return lines
+	}
+
+	// lcs[i][j] — длина наибольшей общей подпоследовательности a[i:] и b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || i < len(a) && lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+
+	return lines
+}
+
+// unifiedDiff возвращает изменения файла name между текстами old и new в формате unified diff
+// или пустую строку, если тексты совпадают.
+func unifiedDiff(name string, old string, new string) string {
+	lines := diffLines(splitLines(old), splitLines(new))
+
+	// Номера строк старого и нового файла перед каждой строкой сравнения.
+	oldLine := make([]int, len(lines)+1)
+	newLine := make([]int, len(lines)+1)
+	for i, line := range lines {
+		oldLine[i+1], newLine[i+1] = oldLine[i], newLine[i]
+		if line.kind != '+' {
+			oldLine[i+1]++
+		}
+		if line.kind != '-' {
+			newLine[i+1]++
+		}
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(lines); {
+		for i < len(lines) && lines[i].kind == ' ' {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+		if b.Len() == 0 {
+			b.WriteString("--- a/" + name + "\n+++ b/" + name + "\n")
+		}
+
+		// Изменения, между которыми не больше 2*diffContext общих строк, выводятся одним фрагментом.
+		start := i - diffContext
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for {
+			for end < len(lines) && lines[end].kind != ' ' {
+				end++
+			}
+			next := end
+			for next < len(lines) && lines[next].kind == ' ' {
+				next++
+			}
+			if next == len(lines) || next-end > 2*diffContext {
+				break
+			}
+			end = next
+		}
+		if end += diffContext; end > len(lines) {
+			end = len(lines)
+		}
+
+		b.WriteString(fmt.Sprintf("@@ -%s +%s @@\n", diffRange(oldLine[start], oldLine[end]-oldLine[start]), diffRange(newLine[start], newLine[end]-newLine[start])))
+		for _, line := range lines[start:end] {
+			b.WriteString(string(line.kind) + line.text + "\n")
+		}
+		i = end
+	}
+
+	return b.String()
+}
+
+// diffRange возвращает диапазон строк фрагмента: номер первой строки и количество строк.
+// Для пустого диапазона указывается номер строки перед ним.
+func diffRange(before int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", before)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", before+1)
+	}
+
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
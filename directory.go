@@ -1,5 +1,7 @@
 // Googol генератор статических html-страниц из шаблонов.
 // Синхронизация структуры поддиректорий исходной и целевой директории.
+// planDirs вычисляет изменения структуры, syncDirs выполняет их; в пробном запуске сборки (-dry-run)
+// изменения только вносятся в план сборки (см. OutputWriter.SyncDirs).
 
 package main
 
@@ -14,8 +16,9 @@ import (
 // excessDestDirs вычисляет лишние поддиректории в целевой директории.
 // destinationRoot — целевая директория.
 // sourceRoot — исходная директория.
+// keep — поддиректории целевой директории, которые не удаляются.
 // dirsToDelete — список лишних поддиректорий.
-func excessDestDirs(destinationRoot string, sourceRoot string, dirsToDelete *[]string) filepath.WalkFunc {
+func excessDestDirs(destinationRoot string, sourceRoot string, keep map[string]bool, dirsToDelete *[]string) filepath.WalkFunc {
 	return func(currentPath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -26,24 +29,29 @@ func excessDestDirs(destinationRoot string, sourceRoot string, dirsToDelete *[]s
 		if info == nil || !info.IsDir() {
 			return nil
 		}
+		if keep[currentPath] {
+			return nil
+		}
 
 		// Поддиректория с таким же именем в исходной директории.
 		sourceDir := strings.Replace(currentPath, destinationRoot, sourceRoot, 1)
 
 		// Проверяем, существует ли такая поддиректория в исходной директории.
 		if _, err := os.Stat(sourceDir); os.IsNotExist(err) {
-			// Заносим директорию в список намеченных к удалению.
+			// Заносим директорию в список намеченных к удалению; её поддиректории удаляются вместе с ней.
 			*dirsToDelete = append(*dirsToDelete, currentPath)
+			return filepath.SkipDir
 		}
 
 		return nil
 	}
 }
 
-// addDestDirs добавляет несуществующие поддиректории в целевую директорию.
+// addDestDirs вычисляет поддиректории исходной директории, которых нет в целевой директории.
 // destinationRoot — целевая директория.
 // sourceRoot — исходная директория.
-func addDestDirs(destinationRoot string, sourceRoot string) filepath.WalkFunc {
+// dirsToCreate — список поддиректорий целевой директории, которые нужно создать, родительские раньше вложенных.
+func addDestDirs(destinationRoot string, sourceRoot string, dirsToCreate *[]string) filepath.WalkFunc {
 	return func(currentPath string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -63,65 +71,90 @@ func addDestDirs(destinationRoot string, sourceRoot string) filepath.WalkFunc {
 
 		// Проверяем, существует ли такая поддиректория в целевой директории.
 		if _, err := os.Stat(destinationDir); os.IsNotExist(err) {
-			// Создаём поддиректорию.
-			if err = os.Mkdir(destinationDir, 0755); err != nil {
-				return errors.New(ErrorMessages["error_creating_dir"] + err.Error())
-			}
+			// Заносим директорию в список намеченных к созданию.
+			*dirsToCreate = append(*dirsToCreate, destinationDir)
 		}
 
 		return nil
 	}
 }
 
-// syncDirs синхронизирует структуру поддиректорий в исходной и целевой директориях.
+// planDirs вычисляет изменения структуры поддиректорий целевой директории по исходной директории.
 // source — исходная директория.
 // destination — целевая директория.
-func syncDirs(source string, destination string) error {
+// keep — поддиректории целевой директории, которые не удаляются, даже если их нет в исходной директории.
+// Возвращает лишние поддиректории целевой директории и отсутствующие в ней поддиректории исходной директории.
+func planDirs(source string, destination string, keep ...string) ([]string, []string, error) {
 	// Проверяем существование исходной директории.
 	src, err := os.Stat(source)
 	if os.IsNotExist(err) {
-		return errors.New(ErrorMessages["directory_not_exists"] + source)
+		return nil, nil, errors.New(ErrorMessages["directory_not_exists"] + source)
 	}
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	// Проверяем, является ли указанный исходный путь директорией.
 	if !src.IsDir() {
-		return errors.New(ErrorMessages["path_not_directory"] + source)
+		return nil, nil, errors.New(ErrorMessages["path_not_directory"] + source)
 	}
 
 	// Проверяем существование целевой директории.
 	dest, err := os.Stat(destination)
 	if os.IsNotExist(err) {
-		return errors.New(ErrorMessages["directory_not_exists"] + destination)
+		return nil, nil, errors.New(ErrorMessages["directory_not_exists"] + destination)
 	}
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
 	// Проверяем, является ли указанный целевой путь директорией.
 	if !dest.IsDir() {
-		return errors.New(ErrorMessages["path_not_directory"] + destination)
+		return nil, nil, errors.New(ErrorMessages["path_not_directory"] + destination)
 	}
 
 	// Обходим поддиректории целевой директории и вычисляем лишние директории.
+	keepDirs := map[string]bool{}
+	for _, dir := range keep {
+		keepDirs[filepath.Clean(dir)] = true
+	}
 	dirsToDelete := []string{}
-	if err = filepath.Walk(destination, excessDestDirs(destination, source, &dirsToDelete)); err != nil {
+	if err = filepath.Walk(destination, excessDestDirs(destination, source, keepDirs, &dirsToDelete)); err != nil {
+		return nil, nil, err
+	}
+
+	// Обходим поддиректории исходной директории и вычисляем отсутствующие в целевой директории поддиректории.
+	dirsToCreate := []string{}
+	if err = filepath.Walk(source, addDestDirs(destination, source, &dirsToCreate)); err != nil {
+		return nil, nil, err
+	}
+
+	return dirsToDelete, dirsToCreate, nil
+}
+
+// syncDirs синхронизирует структуру поддиректорий в исходной и целевой директориях.
+// source — исходная директория.
+// destination — целевая директория.
+// keep — поддиректории целевой директории, которые не удаляются, даже если их нет в исходной директории.
+func syncDirs(source string, destination string, keep ...string) error {
+	dirsToDelete, dirsToCreate, err := planDirs(source, destination, keep...)
+	if err != nil {
 		return err
 	}
 
 	// Пытаемся удалить лишние поддиректории в целевой директории.
 	for _, dir := range dirsToDelete {
-		if _, err = os.Stat(dir); err == nil {
-			if err = os.RemoveAll(dir); err != nil {
-				return errors.New(ErrorMessages["directory_content_remove"] + dir + ": " + err.Error())
-			}
-		} else if !os.IsNotExist(err) {
-			return err
+		if err = os.RemoveAll(dir); err != nil {
+			return errors.New(ErrorMessages["directory_content_remove"] + dir + ": " + err.Error())
+		}
+	}
+
+	// Создаём в целевой директории отсутствующие поддиректории.
+	for _, dir := range dirsToCreate {
+		if err = os.Mkdir(dir, 0755); err != nil {
+			return errors.New(ErrorMessages["error_creating_dir"] + err.Error())
 		}
 	}
 
-	// Обходим поддиректории исходной директории и создаём в целевой директории отсутствующие поддиректории.
-	return filepath.Walk(source, addDestDirs(destination, source))
+	return nil
 }
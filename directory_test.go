@@ -121,3 +121,27 @@ func TestSyncDirs_ReturnsErrorForFileSource(t *testing.T) {
 		t.Fatalf("ошибка = %q, ожидалось сообщение о пути, который не является директорией", err.Error())
 	}
 }
+
+func TestSyncDirs_KeepsGeneratedDirectories(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source")
+	destination := filepath.Join(dir, "destination")
+
+	if err := os.MkdirAll(source, 0755); err != nil {
+		t.Fatalf("не удалось создать исходную директорию: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(destination, "blog", "posts"), 0755); err != nil {
+		t.Fatalf("не удалось создать директорию блога: %v", err)
+	}
+
+	keep := []string{filepath.Join(destination, "blog"), filepath.Join(destination, "blog", "posts")}
+	if err := syncDirs(source, destination, keep...); err != nil {
+		t.Fatalf("syncDirs вернул ошибку: %v", err)
+	}
+
+	if info, err := os.Stat(filepath.Join(destination, "blog", "posts")); err != nil || !info.IsDir() {
+		t.Fatalf("директория со сформированными файлами должна сохраниться, err=%v", err)
+	}
+}
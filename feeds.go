@@ -0,0 +1,323 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Формирование RSS 2.0 и Atom лент блога.
+
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"path/filepath"
+	"time"
+)
+
+// FeedOptions описывает настройки лент RSS и Atom.
+type FeedOptions struct {
+	// Заголовок ленты, по умолчанию используется название сайта.
+	Title string
+	// Автор ленты Atom, по умолчанию используется название сайта. Atom требует автора у ленты
+	// или у каждой записи, а автор поста указывается не всегда.
+	Author string
+	// Максимальное количество записей в ленте.
+	Items int
+	// Включать ли в ленту полный текст поста вместо аннотации.
+	FullContent bool
+}
+
+// DefaultFeedItems — количество записей в ленте по умолчанию.
+const DefaultFeedItems = 20
+
+// rssFeed описывает документ RSS 2.0.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	DC      string     `xml:"xmlns:dc,attr"`
+	Atom    string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+// rssChannel описывает канал RSS.
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Self          rssSelf   `xml:"atom:link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+// rssSelf описывает ссылку канала на самого себя.
+type rssSelf struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+// rssGuid описывает уникальный идентификатор записи RSS.
+type rssGuid struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// rssItem описывает запись канала RSS.
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	Guid        rssGuid  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Author      string   `xml:"dc:creator,omitempty"`
+	Category    []string `xml:"category,omitempty"`
+	Description string   `xml:"description"`
+}
+
+// atomFeed описывает документ Atom.
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	Id      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Author  *atomAuthor `xml:"author,omitempty"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+// atomLink описывает ссылку Atom.
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+// atomAuthor описывает автора ленты или записи Atom.
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+// atomCategory описывает категорию записи Atom.
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// atomText описывает текстовое поле Atom с указанием типа.
+type atomText struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// atomEntry описывает запись Atom.
+type atomEntry struct {
+	Title     string         `xml:"title"`
+	Id        string         `xml:"id"`
+	Link      atomLink       `xml:"link"`
+	Published string         `xml:"published"`
+	Updated   string         `xml:"updated"`
+	Author    *atomAuthor    `xml:"author,omitempty"`
+	Category  []atomCategory `xml:"category,omitempty"`
+	Summary   *atomText      `xml:"summary,omitempty"`
+	Content   *atomText      `xml:"content,omitempty"`
+}
+
+// feedTitle возвращает заголовок ленты.
+// options — настройки ленты.
+// tag — название рубрики, пустая строка для общей ленты.
+func feedTitle(options FeedOptions, tag string) string {
+	title := options.Title
+	if len(tag) > 0 {
+		title += " — " + tag
+	}
+
+	return title
+}
+
+// feedPosts возвращает посты, попадающие в ленту.
+func feedPosts(options FeedOptions, posts []Post) []Post {
+	items := options.Items
+	if items <= 0 {
+		items = DefaultFeedItems
+	}
+	if len(posts) > items {
+		return posts[:items]
+	}
+
+	return posts
+}
+
+// postText возвращает текст поста для ленты: полный контент или аннотацию.
+func postText(options FeedOptions, post Post) string {
+	if options.FullContent && len(post.Content) > 0 {
+		return string(post.Content)
+	}
+	if len(post.Annotation) > 0 {
+		return post.Annotation
+	}
+
+	return post.Short_annotation
+}
+
+// postURL возвращает адрес страницы поста на целевом сервере.
+// blogURL — адрес блога на целевом сервере.
+func postURL(blogURL string, post Post) string {
+	if len(post.URL) > 0 {
+		return post.URL
+	}
+
+	return blogURL + "/posts/" + post.Fuseaction + ".html"
+}
+
+// buildRSS формирует документ RSS 2.0.
+// options — настройки ленты.
+// blogURL — адрес блога на целевом сервере.
+// feedURL — адрес ленты на целевом сервере.
+// pageURL — адрес html-страницы ленты на целевом сервере.
+// tag — название рубрики, пустая строка для общей ленты.
+// posts — посты, отсортированные по убыванию даты.
+func buildRSS(options FeedOptions, blogURL string, feedURL string, pageURL string, tag string, posts []Post) ([]byte, error) {
+	feed := rssFeed{
+		Version: "2.0",
+		DC:      "http://purl.org/dc/elements/1.1/",
+		Atom:    "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       feedTitle(options, tag),
+			Link:        pageURL,
+			Self:        rssSelf{Href: feedURL, Rel: "self", Type: "application/rss+xml"},
+			Description: feedTitle(options, tag),
+		},
+	}
+
+	items := feedPosts(options, posts)
+	if len(items) > 0 {
+		feed.Channel.LastBuildDate = items[0].SortDate.Format(time.RFC1123Z)
+	}
+	for _, post := range items {
+		link := postURL(blogURL, post)
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       post.Title,
+			Link:        link,
+			Guid:        rssGuid{IsPermaLink: true, Value: link},
+			PubDate:     post.SortDate.Format(time.RFC1123Z),
+			Author:      post.Author,
+			Category:    postTagNames(post),
+			Description: postText(options, post),
+		})
+	}
+
+	content, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), content...), nil
+}
+
+// buildAtom формирует документ Atom.
+// options — настройки ленты.
+// blogURL — адрес блога на целевом сервере.
+// feedURL — адрес ленты на целевом сервере.
+// pageURL — адрес html-страницы ленты на целевом сервере.
+// posts — посты, отсортированные по убыванию даты.
+func buildAtom(options FeedOptions, blogURL string, feedURL string, pageURL string, posts []Post) ([]byte, error) {
+	feed := atomFeed{
+		Title: feedTitle(options, ""),
+		Id:    feedURL,
+		Links: []atomLink{
+			{Href: pageURL, Rel: "alternate", Type: "text/html"},
+			{Href: feedURL, Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+	if len(options.Author) > 0 {
+		feed.Author = &atomAuthor{Name: options.Author}
+	}
+
+	items := feedPosts(options, posts)
+	if len(items) > 0 {
+		feed.Updated = items[0].SortDate.Format(time.RFC3339)
+	} else {
+		feed.Updated = time.Time{}.Format(time.RFC3339)
+	}
+	for _, post := range items {
+		link := postURL(blogURL, post)
+		entry := atomEntry{
+			Title:     post.Title,
+			Id:        link,
+			Link:      atomLink{Href: link, Rel: "alternate", Type: "text/html"},
+			Published: post.SortDate.Format(time.RFC3339),
+			Updated:   post.SortDate.Format(time.RFC3339),
+		}
+		if len(post.Author) > 0 {
+			entry.Author = &atomAuthor{Name: post.Author}
+		}
+		for _, name := range postTagNames(post) {
+			entry.Category = append(entry.Category, atomCategory{Term: name})
+		}
+		if options.FullContent && len(post.Content) > 0 {
+			entry.Content = &atomText{Type: "html", Value: string(post.Content)}
+		} else {
+			entry.Summary = &atomText{Type: "html", Value: postText(options, post)}
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	content, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), content...), nil
+}
+
+// writeFeed записывает документ ленты в файл.
+func writeFeed(output *OutputWriter, path string, content []byte, err error) error {
+	if err != nil {
+		return errors.New(ErrorMessages["error_creating_feed"] + err.Error())
+	}
+	_, err = output.WriteFile(path, content)
+
+	return err
+}
+
+// writeBlogFeeds формирует ленты RSS и Atom общей ленты блога.
+// options — настройки ленты.
+// destinationBlogDir — целевая директория блога.
+// blogURL — адрес блога на целевом сервере.
+// posts — посты блога, отсортированные по убыванию даты.
+// output — запись сформированных файлов в целевую директорию.
+func writeBlogFeeds(options FeedOptions, destinationBlogDir string, blogURL string, posts []Post, output *OutputWriter) error {
+	pageURL := blogURL + "/"
+
+	content, err := buildRSS(options, blogURL, pageURL+"rss.xml", pageURL, "", posts)
+	if err = writeFeed(output, filepath.Join(destinationBlogDir, "rss.xml"), content, err); err != nil {
+		return err
+	}
+
+	content, err = buildAtom(options, blogURL, pageURL+"atom.xml", pageURL, posts)
+	return writeFeed(output, filepath.Join(destinationBlogDir, "atom.xml"), content, err)
+}
+
+// writeTagFeed формирует ленту RSS рубрики блога.
+// options — настройки ленты.
+// targetDir — целевая директория рубрики.
+// blogURL — адрес блога на целевом сервере.
+// pageURL — адрес ленты рубрики на целевом сервере.
+// tag — рубрика блога.
+// posts — посты рубрики, отсортированные по убыванию даты.
+// output — запись сформированных файлов в целевую директорию.
+func writeTagFeed(options FeedOptions, targetDir string, blogURL string, pageURL string, tag Tag, posts []Post, output *OutputWriter) error {
+
+	content, err := buildRSS(options, blogURL, pageURL+"rss.xml", pageURL, tag.Name, posts)
+	return writeFeed(output, filepath.Join(targetDir, "rss.xml"), content, err)
+}
+
+// postTagNames возвращает названия рубрик поста для категорий записей лент.
+func postTagNames(post Post) []string {
+	if len(post.Tags) == 0 {
+		if len(post.Tag) == 0 {
+			return nil
+		}
+		return []string{post.Tag}
+	}
+	names := make([]string, 0, len(post.Tags))
+	for _, tag := range post.Tags {
+		names = append(names, tag.Name)
+	}
+
+	return names
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func feedTestPosts() []Post {
+	return []Post{
+		{Title: "Новый <пост>", Author: "Автор", Tag: "Новости", Annotation: "Аннотация 2", Content: "<p>Контент 2</p>", Fuseaction: "new", SortDate: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{Title: "Старый", Author: "Автор", Tag: "Новости", Annotation: "Аннотация 1", Content: "<p>Контент 1</p>", Fuseaction: "old", SortDate: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+}
+
+func TestBuildRSS_LimitsItemsAndUsesAnnotation(t *testing.T) {
+	t.Parallel()
+
+	content, err := buildRSS(FeedOptions{Title: "example.test", Items: 1}, "https://example.test/blog", "https://example.test/blog/rss.xml", "https://example.test/blog/", "", feedTestPosts())
+	if err != nil {
+		t.Fatalf("buildRSS вернул ошибку: %v", err)
+	}
+
+	var feed struct {
+		Channel struct {
+			Title string `xml:"title"`
+			Items []struct {
+				Title       string `xml:"title"`
+				Link        string `xml:"link"`
+				Guid        string `xml:"guid"`
+				PubDate     string `xml:"pubDate"`
+				Description string `xml:"description"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}
+	if err = xml.Unmarshal(content, &feed); err != nil {
+		t.Fatalf("лента RSS не является корректным xml: %v", err)
+	}
+	if feed.Channel.Title != "example.test" {
+		t.Fatalf("заголовок ленты = %q, ожидалось example.test", feed.Channel.Title)
+	}
+	if len(feed.Channel.Items) != 1 {
+		t.Fatalf("количество записей = %d, ожидалась 1", len(feed.Channel.Items))
+	}
+	item := feed.Channel.Items[0]
+	if item.Title != "Новый <пост>" {
+		t.Fatalf("заголовок записи = %q", item.Title)
+	}
+	if item.Link != "https://example.test/blog/posts/new.html" || item.Guid != item.Link {
+		t.Fatalf("ссылка записи = %q, guid = %q", item.Link, item.Guid)
+	}
+	if item.PubDate != "Fri, 02 Jan 2026 00:00:00 +0000" {
+		t.Fatalf("дата записи = %q", item.PubDate)
+	}
+	if item.Description != "Аннотация 2" {
+		t.Fatalf("описание записи = %q, ожидалась аннотация", item.Description)
+	}
+}
+
+func TestBuildAtom_FullContent(t *testing.T) {
+	t.Parallel()
+
+	content, err := buildAtom(FeedOptions{FullContent: true}, "https://example.test/blog", "https://example.test/blog/atom.xml", "https://example.test/blog/", feedTestPosts())
+	if err != nil {
+		t.Fatalf("buildAtom вернул ошибку: %v", err)
+	}
+
+	var feed struct {
+		Updated string `xml:"updated"`
+		Entries []struct {
+			Id      string `xml:"id"`
+			Content string `xml:"content"`
+			Author  string `xml:"author>name"`
+		} `xml:"entry"`
+	}
+	if err = xml.Unmarshal(content, &feed); err != nil {
+		t.Fatalf("лента Atom не является корректным xml: %v", err)
+	}
+	if feed.Updated != "2026-01-02T00:00:00Z" {
+		t.Fatalf("дата обновления ленты = %q", feed.Updated)
+	}
+	if len(feed.Entries) != 2 {
+		t.Fatalf("количество записей = %d, ожидалось 2", len(feed.Entries))
+	}
+	if feed.Entries[0].Content != "<p>Контент 2</p>" || feed.Entries[0].Author != "Автор" {
+		t.Fatalf("запись ленты сформирована некорректно: %+v", feed.Entries[0])
+	}
+}
+
+func TestBuildAtom_FeedAuthor(t *testing.T) {
+	t.Parallel()
+
+	posts := feedTestPosts()
+	for i := range posts {
+		posts[i].Author = ""
+	}
+	content, err := buildAtom(FeedOptions{Author: "example.test"}, "https://example.test/blog", "https://example.test/blog/atom.xml", "https://example.test/blog/", posts)
+	if err != nil {
+		t.Fatalf("buildAtom вернул ошибку: %v", err)
+	}
+
+	var feed struct {
+		Author  string `xml:"author>name"`
+		Entries []struct {
+			Author *struct{} `xml:"author"`
+		} `xml:"entry"`
+	}
+	if err = xml.Unmarshal(content, &feed); err != nil {
+		t.Fatalf("лента Atom не является корректным xml: %v", err)
+	}
+	// Без автора постов лента корректна только с автором самой ленты (RFC 4287, 4.1.1).
+	if feed.Author != "example.test" {
+		t.Fatalf("автор ленты = %q, ожидался example.test", feed.Author)
+	}
+	for _, entry := range feed.Entries {
+		if entry.Author != nil {
+			t.Fatalf("у записи без автора поста указан автор:\n%s", content)
+		}
+	}
+}
+
+func TestWriteBlogFeeds_CreatesFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	if err := writeBlogFeeds(FeedOptions{}, dir, "https://example.test/blog", feedTestPosts(), newTestOutput(t, dir)); err != nil {
+		t.Fatalf("writeBlogFeeds вернул ошибку: %v", err)
+	}
+
+	for _, name := range []string{"rss.xml", "atom.xml"} {
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("не создан файл %s: %v", name, err)
+		}
+		if !strings.HasPrefix(string(content), "<?xml") {
+			t.Fatalf("файл %s должен начинаться с xml-заголовка", name)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Загрузка постов, публикаций и записей Вопросы и ответы из файлов с заголовком (front matter).
+// Наряду с xml-файлами в директориях __blog, __articles и __qa могут находиться файлы .md и .html,
+// начинающиеся с заголовка в формате YAML (между строками ---) или TOML (между строками +++):
+//
+//	---
+//	title: Заголовок поста
+//	date: 02.01.2006
+//	tagid: 1
+//	cover: /images/cover.jpg
+//	---
+//	Текст поста в формате Markdown.
+//
+// Ключи заголовка совпадают с именами элементов xml-файлов, ключи, которым не соответствует
+// ни одно поле, доступны шаблонам в поле Params. Текст после заголовка становится текстом поста
+// (Content), ответом записи Вопросы и ответы (Answer) или аннотацией публикации (Annotation);
+// текст файлов .md преобразуется из Markdown в HTML.
+// Файл .md, рядом с которым лежит xml-файл с тем же именем, является текстом этого xml-файла, а не отдельной записью.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Разделители заголовка и форматы их содержимого.
+var frontMatterDelimiters = map[string]string{
+	"---": "yaml",
+	"+++": "toml",
+}
+
+// isFrontMatterCandidate проверяет, что файл может содержать запись с заголовком:
+// файл имеет расширение .md или .html и рядом с ним нет xml-файла с тем же именем.
+func isFrontMatterCandidate(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext != ".md" && ext != ".html" {
+		return false
+	}
+	if _, err := os.Stat(strings.TrimSuffix(path, filepath.Ext(path)) + ".xml"); err == nil {
+		return false
+	}
+
+	return true
+}
+
+// splitFrontMatter отделяет заголовок от текста файла.
+// Возвращает формат заголовка (yaml или toml), заголовок и текст; если файл не начинается
+// с разделителя заголовка, формат — пустая строка.
+func splitFrontMatter(src string) (string, string, string, error) {
+	src = strings.TrimPrefix(src, "\ufeff")
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+
+	firstLine := src
+	if i := strings.IndexByte(src, '\n'); i >= 0 {
+		firstLine = src[:i]
+	}
+	delimiter := strings.TrimRight(firstLine, " \t")
+	format, ok := frontMatterDelimiters[delimiter]
+	if !ok {
+		return "", "", src, nil
+	}
+
+	rest := strings.TrimPrefix(src, firstLine)
+	rest = strings.TrimPrefix(rest, "\n")
+	lines := strings.SplitAfter(rest, "\n")
+	offset := 0
+	for _, line := range lines {
+		trimmed := strings.TrimRight(line, " \t\n")
+		if trimmed == delimiter || (format == "yaml" && trimmed == "...") {
+			return format, rest[:offset], rest[offset+len(line):], nil
+		}
+		offset += len(line)
+	}
+
+	return "", "", "", fmt.Errorf("не найден конец заголовка %s", delimiter)
+}
+
+// loadFrontMatterFile загружает запись из файла с заголовком.
+// target — указатель на структуру Post, Article или QA; значения заголовка переносятся в поля,
+// имена элементов xml которых совпадают с ключами заголовка, остальные ключи — в поле Params.
+// bodyField — поле, в которое записывается текст после заголовка.
+// Возвращает false, если файл не начинается с заголовка.
+func loadFrontMatterFile(path string, target interface{}, bodyField string) (bool, error) {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return false, err
+	}
+
+	format, meta, body, err := splitFrontMatter(string(raw))
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", path, err)
+	}
+	if len(format) == 0 {
+		return false, nil
+	}
+
+	var values map[string]interface{}
+	if format == "toml" {
+		values, err = parseTOML(meta)
+	} else {
+		values, err = parseYAML(meta)
+	}
+	if err != nil {
+		// Номера строк считаются от начала файла, первая строка — разделитель заголовка.
+		if syntaxErr, ok := err.(*SyntaxError); ok {
+			shifted := *syntaxErr
+			shifted.Line++
+			err = &shifted
+		}
+		return false, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err = decodeFrontMatter(values, target); err != nil {
+		return false, fmt.Errorf("%s: %v", path, err)
+	}
+
+	// Текст после заголовка заменяет значение поля bodyField из заголовка.
+	value := reflect.ValueOf(target).Elem()
+	field := value.FieldByName(bodyField)
+	text := field.String()
+	if len(strings.TrimSpace(body)) > 0 {
+		text = body
+	}
+	contentFormat := ""
+	if formatField := value.FieldByName("Format"); formatField.IsValid() {
+		contentFormat = formatField.String()
+	}
+	if len(contentFormat) == 0 && IsMarkdownFile(path) {
+		contentFormat = MarkdownFormat
+	}
+	text, err = renderContent(contentFormat, text)
+	if err != nil {
+		return false, fmt.Errorf("%s: %v", path, err)
+	}
+	field.SetString(text)
+
+	return true, nil
+}
+
+// decodeFrontMatter переносит значения заголовка в поля структуры target по именам элементов xml.
+func decodeFrontMatter(values map[string]interface{}, target interface{}) error {
+	value := reflect.ValueOf(target).Elem()
+	valueType := value.Type()
+
+	fields := map[string]int{}
+	for i := 0; i < valueType.NumField(); i++ {
+		tag := strings.Split(valueType.Field(i).Tag.Get("xml"), ",")
+		if len(tag[0]) > 0 && tag[0] != "-" && len(tag) == 1 {
+			fields[tag[0]] = i
+		}
+	}
+
+	params := map[string]interface{}{}
+	for key, raw := range values {
+		index, ok := fields[key]
+		if !ok {
+			params[key] = raw
+			continue
+		}
+
+		field := value.Field(index)
+		switch field.Kind() {
+		case reflect.String:
+			s, err := frontMatterString(raw)
+			if err != nil {
+				return fmt.Errorf("параметр %s: %v", key, err)
+			}
+			field.SetString(s)
+		case reflect.Int:
+			n, err := frontMatterInt(raw)
+			if err != nil {
+				return fmt.Errorf("параметр %s: %v", key, err)
+			}
+			field.SetInt(int64(n))
+		case reflect.Slice:
+			// Список целых чисел (tagid поста): одно значение или список значений.
+			if field.Type().Elem().Kind() != reflect.Int {
+				continue
+			}
+			items, ok := raw.([]interface{})
+			if !ok {
+				items = []interface{}{raw}
+			}
+			list := make([]int, 0, len(items))
+			for _, item := range items {
+				n, err := frontMatterInt(item)
+				if err != nil {
+					return fmt.Errorf("параметр %s: %v", key, err)
+				}
+				list = append(list, n)
+			}
+			field.Set(reflect.ValueOf(list))
+		}
+	}
+
+	if field := value.FieldByName("Params"); field.IsValid() && len(params) > 0 {
+		field.Set(reflect.ValueOf(params))
+	}
+
+	return nil
+}
+
+// frontMatterString приводит значение заголовка к строке.
+// Даты приводятся к формату 02.01.2006, используемому в xml-файлах, списки строк объединяются через |.
+func frontMatterString(raw interface{}) (string, error) {
+	switch v := raw.(type) {
+	case time.Time:
+		return v.Format("02.01.2006"), nil
+	case []interface{}:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := frontMatterString(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, s)
+		}
+		return strings.Join(items, "|"), nil
+	case map[string]interface{}, []map[string]interface{}:
+		return "", fmt.Errorf("ожидалась строка")
+	}
+
+	return scalarString(raw), nil
+}
+
+// frontMatterInt приводит значение заголовка к целому числу.
+func frontMatterInt(raw interface{}) (int, error) {
+	switch v := raw.(type) {
+	case int64:
+		return int(v), nil
+	case string:
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if err == nil {
+			return n, nil
+		}
+	}
+
+	return 0, fmt.Errorf("ожидалось целое число")
+}
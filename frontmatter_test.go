@@ -0,0 +1,135 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	t.Parallel()
+
+	format, meta, body, err := splitFrontMatter("+++\ntitle = \"a\"\n+++\nТекст\n")
+	if err != nil || format != "toml" || meta != "title = \"a\"\n" || body != "Текст\n" {
+		t.Fatalf("splitFrontMatter = %q, %q, %q, %v", format, meta, body, err)
+	}
+
+	format, _, body, err = splitFrontMatter("<p>без заголовка</p>")
+	if err != nil || format != "" || body != "<p>без заголовка</p>" {
+		t.Fatalf("файл без заголовка: %q, %q, %v", format, body, err)
+	}
+
+	if _, _, _, err = splitFrontMatter("---\ntitle: a\n"); err == nil {
+		t.Fatalf("незакрытый заголовок должен быть ошибкой")
+	}
+}
+
+func TestLoadBlog_FrontMatterPosts(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	tags := &TagsList{Tags: []Tag{{Id: 1, Name: "Новости"}}}
+	writeTestFile(t, filepath.Join(dir, "yaml.md"), `---
+title: Пост YAML
+date: 2021-03-04
+tagid: 1
+cover: /images/cover.jpg
+---
+Текст *поста*.
+`)
+	writeTestFile(t, filepath.Join(dir, "toml.html"), `+++
+title = "Пост TOML"
+date = "05.03.2021"
+tagid = 1
+annotation = "Аннотация"
+
+[seo]
+noindex = true
++++
+<p>Текст</p>
+`)
+	writeTestFile(t, filepath.Join(dir, "notes.md"), "Файл без заголовка не является постом.")
+	writeBlogPostXML(t, dir, "xml.xml", 1, "01.01.2021", "Пост XML")
+
+	posts, total, err := loadBlog(dir, tags, nil)
+	if err != nil {
+		t.Fatalf("loadBlog вернул ошибку: %v", err)
+	}
+	if total != 3 {
+		t.Fatalf("загружено %d постов, ожидалось 3", total)
+	}
+
+	toml, yaml, xmlPost := (*posts)[0], (*posts)[1], (*posts)[2]
+	if toml.Fuseaction != "toml" || toml.Title != "Пост TOML" || toml.Content != "<p>Текст</p>\n" || toml.Annotation != "Аннотация" {
+		t.Fatalf("пост TOML: %+v", toml)
+	}
+	if seo, ok := toml.Params["seo"].(map[string]interface{}); !ok || seo["noindex"] != true {
+		t.Fatalf("дополнительные поля поста TOML: %v", toml.Params)
+	}
+	if yaml.Date != "04.03.2021" || yaml.Tag != "Новости" || yaml.Content != "<p>Текст <em>поста</em>.</p>\n" {
+		t.Fatalf("пост YAML: %+v", yaml)
+	}
+	if yaml.Params["cover"] != "/images/cover.jpg" || yaml.Source != filepath.Join(dir, "yaml.md") {
+		t.Fatalf("дополнительные поля поста YAML: %v, источник %s", yaml.Params, yaml.Source)
+	}
+	if xmlPost.Title != "Пост XML" || xmlPost.Params != nil {
+		t.Fatalf("пост XML: %+v", xmlPost)
+	}
+}
+
+func TestLoadBlog_FrontMatterErrorHasPosition(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "bad.md"), "---\ntitle: a\ntags: [1, 2\n---\n")
+
+	_, _, err := loadBlog(dir, &TagsList{}, nil)
+	if err == nil || !strings.Contains(err.Error(), "bad.md:3:") {
+		t.Fatalf("ожидалась ошибка с файлом и строкой, получено %v", err)
+	}
+}
+
+func TestLoadArticlesAndQA_FrontMatter(t *testing.T) {
+	t.Parallel()
+
+	articlesDir := t.TempDir()
+	writeTestFile(t, filepath.Join(articlesDir, "guide.md"), `---
+title: Руководство
+pages: [Введение, Установка]
+level: начальный
+---
+Краткое **описание**.
+`)
+	writeTestFile(t, filepath.Join(articlesDir, "guide", "1.md"), "Первая страница")
+
+	articles, err := loadArticles(articlesDir, nil)
+	if err != nil {
+		t.Fatalf("loadArticles вернул ошибку: %v", err)
+	}
+	if len(*articles) != 1 {
+		t.Fatalf("загружено %d публикаций", len(*articles))
+	}
+	article := (*articles)[0]
+	if article.Fuseaction != "guide" || len(article.Pagetitles) != 2 || article.Pagetitles[1] != "Установка" {
+		t.Fatalf("публикация: %+v", article)
+	}
+	if article.Annotation != "<p>Краткое <strong>описание</strong>.</p>\n" || article.Params["level"] != "начальный" {
+		t.Fatalf("аннотация = %q, дополнительные поля = %v", article.Annotation, article.Params)
+	}
+
+	qaDir := t.TempDir()
+	writeTestFile(t, filepath.Join(qaDir, "q1.md"), `+++
+date = 2021-02-01
+name = "Иван"
+question = "Как дела?"
++++
+Хорошо.
+`)
+	qas, total, err := loadQA(qaDir, nil)
+	if err != nil {
+		t.Fatalf("loadQA вернул ошибку: %v", err)
+	}
+	if total != 1 || (*qas)[0].Answer != "<p>Хорошо.</p>\n" || (*qas)[0].Month != "Февраля" {
+		t.Fatalf("записи Вопросы и ответы: %+v", *qas)
+	}
+}
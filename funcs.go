@@ -0,0 +1,553 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Функции шаблонов.
+// Функции доступны во всех шаблонах: страницах исходной директории, шаблонах __templates/*.tmpl
+// и шаблонах блога, публикаций и Вопросов и ответов. Последним аргументом функции передаётся
+// обрабатываемое значение, поэтому функции удобно использовать в конвейере: {{.Title | Truncate 60}}.
+//
+// Числа:
+//   - Inc, Dec — прибавление и вычитание единицы: {{Inc .Pagenum}};
+//   - Add, Sub, Mul, Div, Mod — арифметика целых чисел: {{Mul .Pagenum 10}}; деление на ноль — ошибка;
+//   - Min, Max — меньшее и большее из двух чисел;
+//   - Seq — последовательность чисел от первого до второго включительно: {{range Seq 1 5}}.
+//
+// Даты. Значением может быть time.Time или строка в формате 02.01.2006, 2006-01-02 или RFC 3339:
+//   - Date — дата в формате Go: {{Date "2006-01-02" .Blogpost.Date}};
+//   - RussianDate — дата с названием месяца в родительном падеже: {{RussianDate .Blogpost.Date}} — «3 февраля 2026»;
+//   - RussianMonth — название месяца в родительном падеже из RussianMonth: «Февраля».
+//
+// Строки:
+//   - First — первая буква строки;
+//   - Truncate — первые n символов строки с многоточием: {{.Annotation | Truncate 100}};
+//   - Slugify — строка для адреса: кириллица транслитерируется, остальные символы заменяются дефисом;
+//   - Lower, Upper, Trim, Replace, Split, Join, Contains, HasPrefix, HasSuffix — функции пакета strings;
+//   - StripHTML — текст без html-разметки, например для мета-описания страницы.
+//
+// Безопасные значения, которые html/template выводит без экранирования:
+//   - SafeHTML, SafeURL, SafeJS — строка, содержащая проверенный html, адрес или код JavaScript.
+//
+// Коллекции:
+//   - Dict — словарь из пар ключ-значение: {{template "card" Dict "Post" . "Wide" true}};
+//   - List — список значений;
+//   - Limit — первые n элементов списка: {{range Limit 3 .Site.Posts}};
+//   - Where — элементы списка, поле которых равно значению: {{range Where "Tagids" 2 .Site.Posts}};
+//     поле может быть вложенным (Params.author), для поля-списка проверяется наличие значения в списке;
+//   - SortBy — список, отсортированный по полю; «-» перед именем поля — по убыванию: {{range SortBy "-SortDate" .Site.Posts}};
+//   - GroupBy — группы элементов с одинаковым значением поля в порядке первого появления:
+//     {{range GroupBy "Year" .Site.Posts}}{{.Key}}{{range .Items}}...{{end}}{{end}}.
+//
+// Адреса:
+//   - AbsURL — полный адрес страницы сайта с доменом из параметра domain: {{AbsURL "blog/"}};
+//   - RelURL — адрес относительно корня сайта: {{RelURL "blog/"}} — «/blog/»;
+//   - Asset — адрес файла исходной директории с отпечатком содержимого: {{Asset "css/style.css"}} —
+//     «/css/style.css?v=1234567890»; при изменении файла адрес меняется, и браузер не использует устаревшую копию.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+)
+
+// TemplateGroup описывает группу элементов, возвращаемую функцией шаблонов GroupBy.
+type TemplateGroup struct {
+	// Значение поля, общее для элементов группы.
+	Key string
+	// Элементы группы, список того же типа, что и исходный.
+	Items interface{}
+}
+
+// Форматы строковых дат, которые понимают функции шаблонов.
+var templateDateLayouts = []string{"02.01.2006", "2006-01-02", time.RFC3339, "02.01.2006 15:04", "2006-01-02 15:04"}
+
+// Разметка, удаляемая функцией StripHTML.
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// Транслитерация кириллицы для функции Slugify.
+var slugTransliteration = map[rune]string{
+	'а': "a", 'б': "b", 'в': "v", 'г': "g", 'д': "d", 'е': "e", 'ё': "e", 'ж': "zh", 'з': "z", 'и': "i",
+	'й': "y", 'к': "k", 'л': "l", 'м': "m", 'н': "n", 'о': "o", 'п': "p", 'р': "r", 'с': "s", 'т': "t",
+	'у': "u", 'ф': "f", 'х': "h", 'ц': "ts", 'ч': "ch", 'ш': "sh", 'щ': "sch", 'ъ': "", 'ы': "y", 'ь': "",
+	'э': "e", 'ю': "yu", 'я': "ya",
+}
+
+// templateFuncs возвращает функции, доступные в шаблонах.
+// Функции AbsURL, RelURL и Asset не знают домена и исходной директории сайта: при формировании
+// страницы они заменяются функциями siteFuncs.
+func templateFuncs() template.FuncMap {
+	funcs := template.FuncMap{
+		// Прибавление единицы.
+		"Inc": func(i int) int {
+			return i + 1
+		},
+		// Вычитание единицы.
+		"Dec": func(i int) int {
+			return i - 1
+		},
+		// Первая буква в строке.
+		"First": func(s string) string {
+			runes := []rune(s)
+			if len(runes) == 0 {
+				return ""
+			}
+
+			return string(runes[0])
+		},
+
+		"Add": func(a, b int) int { return a + b },
+		"Sub": func(a, b int) int { return a - b },
+		"Mul": func(a, b int) int { return a * b },
+		"Div": func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, errors.New("деление на ноль")
+			}
+			return a / b, nil
+		},
+		"Mod": func(a, b int) (int, error) {
+			if b == 0 {
+				return 0, errors.New("деление на ноль")
+			}
+			return a % b, nil
+		},
+		"Min": func(a, b int) int {
+			if a < b {
+				return a
+			}
+			return b
+		},
+		"Max": func(a, b int) int {
+			if a > b {
+				return a
+			}
+			return b
+		},
+		"Seq": templateSeq,
+
+		"Date":         templateDate,
+		"RussianDate":  templateRussianDate,
+		"RussianMonth": templateRussianMonth,
+
+		"Truncate":  templateTruncate,
+		"Slugify":   Slugify,
+		"Lower":     strings.ToLower,
+		"Upper":     strings.ToUpper,
+		"Trim":      strings.TrimSpace,
+		"Replace":   func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"Split":     func(sep, s string) []string { return strings.Split(s, sep) },
+		"Join":      templateJoin,
+		"Contains":  func(substr, s string) bool { return strings.Contains(s, substr) },
+		"HasPrefix": func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"HasSuffix": func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"StripHTML": func(s string) string { return strings.TrimSpace(htmlTagPattern.ReplaceAllString(s, "")) },
+
+		"SafeHTML": func(s string) htmltemplate.HTML { return htmltemplate.HTML(s) },
+		"SafeURL":  func(s string) htmltemplate.URL { return htmltemplate.URL(s) },
+		"SafeJS":   func(s string) htmltemplate.JS { return htmltemplate.JS(s) },
+
+		"Dict":    templateDict,
+		"List":    func(values ...interface{}) []interface{} { return values },
+		"Limit":   templateLimit,
+		"Where":   templateWhere,
+		"SortBy":  templateSortBy,
+		"GroupBy": templateGroupBy,
+	}
+	for name, f := range siteFuncs(nil, nil) {
+		funcs[name] = f
+	}
+
+	return funcs
+}
+
+// siteFuncs возвращает функции шаблонов, зависящие от сайта: AbsURL, RelURL и Asset.
+// cfg — конфигурация сайта, nil — адреса строятся без домена, отпечатки файлов не вычисляются.
+// hash — функция, вычисляющая crc32-хэш файла; через неё формирующий страницу код узнаёт,
+// от каких файлов ресурсов зависит страница.
+func siteFuncs(cfg *Config, hash func(path string) string) template.FuncMap {
+	domain := ""
+	if cfg != nil {
+		domain = cfg.Domain
+	}
+	// Путь домена, если сайт размещён не в корне: https://example.com/site.
+	basePath := ""
+	if u, err := url.Parse(domain); err == nil {
+		basePath = strings.TrimRight(u.Path, "/")
+	}
+
+	relURL := func(link string) string {
+		if len(domain) > 0 && strings.HasPrefix(link, domain) {
+			link = strings.TrimPrefix(link, domain)
+			if !strings.HasPrefix(link, "/") {
+				link = "/" + link
+			}
+			return basePath + link
+		}
+		if strings.Contains(link, "://") || strings.HasPrefix(link, "//") {
+			return link
+		}
+
+		return basePath + "/" + strings.TrimLeft(link, "/")
+	}
+
+	return template.FuncMap{
+		"RelURL": relURL,
+		"AbsURL": func(link string) string {
+			if strings.Contains(link, "://") || strings.HasPrefix(link, "//") {
+				return link
+			}
+			return domain + strings.TrimPrefix(relURL(link), basePath)
+		},
+		"Asset": func(file string) (string, error) {
+			link := relURL(file)
+			if cfg == nil || hash == nil {
+				return link, nil
+			}
+			fingerprint := hash(filepath.Join(cfg.Source, filepath.FromSlash(strings.TrimLeft(file, "/"))))
+			if len(fingerprint) == 0 {
+				return "", fmt.Errorf("файл %s не найден в исходной директории", file)
+			}
+			return link + "?v=" + fingerprint, nil
+		},
+	}
+}
+
+// templateTime приводит значение к времени: time.Time или строка в одном из форматов templateDateLayouts.
+func templateTime(value interface{}) (time.Time, error) {
+	switch v := value.(type) {
+	case time.Time:
+		return v, nil
+	case *time.Time:
+		if v != nil {
+			return *v, nil
+		}
+	case string:
+		for _, layout := range templateDateLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("не удалось разобрать дату %q", v)
+	}
+
+	return time.Time{}, fmt.Errorf("значение %v не является датой", value)
+}
+
+// templateDate форматирует дату value в формате layout пакета time.
+func templateDate(layout string, value interface{}) (string, error) {
+	t, err := templateTime(value)
+	if err != nil {
+		return "", err
+	}
+
+	return t.Format(layout), nil
+}
+
+// templateRussianDate форматирует дату value в виде «3 февраля 2026».
+func templateRussianDate(value interface{}) (string, error) {
+	t, err := templateTime(value)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%d %s %d", t.Day(), strings.ToLower(RussianMonth[t.Month()]), t.Year()), nil
+}
+
+// templateRussianMonth возвращает название месяца даты value в родительном падеже.
+func templateRussianMonth(value interface{}) (string, error) {
+	t, err := templateTime(value)
+	if err != nil {
+		return "", err
+	}
+
+	return RussianMonth[t.Month()], nil
+}
+
+// templateTruncate возвращает первые n символов строки s; обрезанная строка завершается многоточием.
+func templateTruncate(n int, s string) string {
+	runes := []rune(s)
+	if n < 0 || len(runes) <= n {
+		return s
+	}
+
+	return strings.TrimRightFunc(string(runes[:n]), unicode.IsSpace) + "…"
+}
+
+// Slugify преобразует строку в фрагмент адреса: строчные латинские буквы, цифры и дефисы.
+// Кириллица транслитерируется: «Новости сайта» — «novosti-sayta».
+func Slugify(s string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+			dash = false
+		case len(slugTransliteration[r]) > 0:
+			b.WriteString(slugTransliteration[r])
+			dash = false
+		case r == 'ъ' || r == 'ь':
+			// Твёрдый и мягкий знаки опускаются.
+		default:
+			if !dash && b.Len() > 0 {
+				b.WriteByte('-')
+				dash = true
+			}
+		}
+	}
+
+	return strings.TrimRight(b.String(), "-")
+}
+
+// templateJoin объединяет элементы списка list через разделитель sep.
+func templateJoin(sep string, list interface{}) (string, error) {
+	if parts, ok := list.([]string); ok {
+		return strings.Join(parts, sep), nil
+	}
+	v, err := templateList("Join", list)
+	if err != nil {
+		return "", err
+	}
+
+	parts := make([]string, v.Len())
+	for i := range parts {
+		parts[i] = fmt.Sprint(v.Index(i).Interface())
+	}
+
+	return strings.Join(parts, sep), nil
+}
+
+// templateSeq возвращает числа от from до to включительно.
+func templateSeq(from, to int) []int {
+	if to < from {
+		return []int{}
+	}
+
+	seq := make([]int, 0, to-from+1)
+	for i := from; i <= to; i++ {
+		seq = append(seq, i)
+	}
+
+	return seq
+}
+
+// templateDict составляет словарь из пар ключ-значение.
+func templateDict(values ...interface{}) (map[string]interface{}, error) {
+	if len(values)%2 != 0 {
+		return nil, errors.New("Dict: нечётное количество аргументов")
+	}
+
+	dict := make(map[string]interface{}, len(values)/2)
+	for i := 0; i < len(values); i += 2 {
+		key, ok := values[i].(string)
+		if !ok {
+			return nil, fmt.Errorf("Dict: ключ %v не является строкой", values[i])
+		}
+		dict[key] = values[i+1]
+	}
+
+	return dict, nil
+}
+
+// templateList проверяет, что list — список, и возвращает его значение.
+func templateList(name string, list interface{}) (reflect.Value, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return reflect.Value{}, fmt.Errorf("%s: значение %v не является списком", name, list)
+	}
+
+	return v, nil
+}
+
+// templateLimit возвращает первые n элементов списка list.
+func templateLimit(n int, list interface{}) (interface{}, error) {
+	v, err := templateList("Limit", list)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		n = 0
+	}
+	if n > v.Len() {
+		n = v.Len()
+	}
+
+	return v.Slice(0, n).Interface(), nil
+}
+
+// templateField возвращает значение поля field элемента item.
+// Имя поля может быть составным: Params.author — ключ author словаря Params.
+func templateField(item reflect.Value, field string) (reflect.Value, error) {
+	v := item
+	for _, name := range strings.Split(field, ".") {
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return reflect.Value{}, nil
+			}
+			v = v.Elem()
+		}
+		switch v.Kind() {
+		case reflect.Struct:
+			v = v.FieldByName(name)
+			if !v.IsValid() {
+				return reflect.Value{}, fmt.Errorf("поле %s не найдено", field)
+			}
+		case reflect.Map:
+			if v.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}, fmt.Errorf("поле %s не найдено", field)
+			}
+			v = v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key()))
+			if !v.IsValid() {
+				return reflect.Value{}, nil
+			}
+		default:
+			return reflect.Value{}, fmt.Errorf("поле %s не найдено", field)
+		}
+	}
+	for v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+
+	return v, nil
+}
+
+// templateWhere возвращает элементы списка list, поле field которых равно value.
+// Если поле — список, элемент выбирается, когда значение есть в этом списке.
+func templateWhere(field string, value interface{}, list interface{}) (interface{}, error) {
+	v, err := templateList("Where", list)
+	if err != nil {
+		return nil, err
+	}
+
+	expected := fmt.Sprint(value)
+	result := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		fv, err := templateField(v.Index(i), field)
+		if err != nil {
+			return nil, fmt.Errorf("Where: %v", err)
+		}
+		if !fv.IsValid() {
+			continue
+		}
+		matched := false
+		if (fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array) && fv.Type().Elem().Kind() != reflect.Uint8 {
+			for j := 0; j < fv.Len() && !matched; j++ {
+				matched = fmt.Sprint(fv.Index(j).Interface()) == expected
+			}
+		} else {
+			matched = fmt.Sprint(fv.Interface()) == expected
+		}
+		if matched {
+			result = reflect.Append(result, v.Index(i))
+		}
+	}
+
+	return result.Interface(), nil
+}
+
+// templateLess сравнивает значения полей для сортировки SortBy.
+func templateLess(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return !a.IsValid() && b.IsValid()
+	}
+	if ta, ok := a.Interface().(time.Time); ok {
+		if tb, ok := b.Interface().(time.Time); ok {
+			return ta.Before(tb)
+		}
+	}
+	switch a.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if b.Kind() >= reflect.Int && b.Kind() <= reflect.Int64 {
+			return a.Int() < b.Int()
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if b.Kind() >= reflect.Uint && b.Kind() <= reflect.Uint64 {
+			return a.Uint() < b.Uint()
+		}
+	case reflect.Float32, reflect.Float64:
+		if b.Kind() == reflect.Float32 || b.Kind() == reflect.Float64 {
+			return a.Float() < b.Float()
+		}
+	case reflect.Bool:
+		if b.Kind() == reflect.Bool {
+			return !a.Bool() && b.Bool()
+		}
+	}
+
+	return fmt.Sprint(a.Interface()) < fmt.Sprint(b.Interface())
+}
+
+// templateSortBy возвращает копию списка list, отсортированную по полю field.
+// Символ «-» перед именем поля задаёт сортировку по убыванию; элементы с равными значениями сохраняют порядок.
+func templateSortBy(field string, list interface{}) (interface{}, error) {
+	v, err := templateList("SortBy", list)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := strings.HasPrefix(field, "-")
+	field = strings.TrimPrefix(field, "-")
+	keys := make([]reflect.Value, v.Len())
+	order := make([]int, v.Len())
+	for i := range keys {
+		if keys[i], err = templateField(v.Index(i), field); err != nil {
+			return nil, fmt.Errorf("SortBy: %v", err)
+		}
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		if desc {
+			return templateLess(keys[order[j]], keys[order[i]])
+		}
+		return templateLess(keys[order[i]], keys[order[j]])
+	})
+
+	result := reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, v.Len())
+	for _, i := range order {
+		result = reflect.Append(result, v.Index(i))
+	}
+
+	return result.Interface(), nil
+}
+
+// templateGroupBy группирует элементы списка list по значению поля field.
+// Группы следуют в порядке первого появления значения, элементы группы — в порядке списка.
+func templateGroupBy(field string, list interface{}) ([]TemplateGroup, error) {
+	v, err := templateList("GroupBy", list)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	items := map[string]reflect.Value{}
+	for i := 0; i < v.Len(); i++ {
+		fv, err := templateField(v.Index(i), field)
+		if err != nil {
+			return nil, fmt.Errorf("GroupBy: %v", err)
+		}
+		key := ""
+		if fv.IsValid() {
+			key = fmt.Sprint(fv.Interface())
+		}
+		group, ok := items[key]
+		if !ok {
+			keys = append(keys, key)
+			group = reflect.MakeSlice(reflect.SliceOf(v.Type().Elem()), 0, 1)
+		}
+		items[key] = reflect.Append(group, v.Index(i))
+	}
+
+	groups := make([]TemplateGroup, 0, len(keys))
+	for _, key := range keys {
+		groups = append(groups, TemplateGroup{Key: key, Items: items[key].Interface()})
+	}
+
+	return groups, nil
+}
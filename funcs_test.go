@@ -0,0 +1,210 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+// executeFuncs выполняет текст шаблона text с функциями шаблонов и данными data.
+func executeFuncs(t *testing.T, text string, data interface{}, funcs template.FuncMap) (string, error) {
+	t.Helper()
+
+	tmpl, err := template.New("test").Funcs(templateFuncs()).Funcs(funcs).Parse(text)
+	if err != nil {
+		t.Fatalf("ошибка разбора шаблона %q: %v", text, err)
+	}
+	var b strings.Builder
+	err = tmpl.Execute(&b, data)
+
+	return b.String(), err
+}
+
+func TestTemplateFuncs_ScalarHelpers(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2026, time.February, 3, 10, 30, 0, 0, time.UTC)
+	data := map[string]interface{}{"Date": date, "Text": "  Привет, <b>мир</b>!  ", "Tags": []string{"a", "b"}}
+	for text, expected := range map[string]string{
+		`{{Inc 1}} {{Dec 1}} {{First "Яблоко"}}`:                                                            "2 0 Я",
+		`{{Add 2 3}} {{Sub 2 3}} {{Mul 2 3}} {{Div 7 2}} {{Mod 7 2}}`:                                       "5 -1 6 3 1",
+		`{{Min 2 3}} {{Max 2 3}} {{range Seq 1 3}}{{.}}{{end}}{{Seq 3 1}}`:                                  "2 3 123[]",
+		`{{Date "2006-01-02 15:04" .Date}}`:                                                                 "2026-02-03 10:30",
+		`{{Date "02/01/2006" "03.02.2026"}} {{Date "2006" "2026-02-03"}}`:                                   "03/02/2026 2026",
+		`{{RussianDate .Date}} {{RussianMonth "01.12.2025"}}`:                                               "3 февраля 2026 Декабря",
+		`{{"Длинный заголовок поста" | Truncate 7}}|{{Truncate 50 "коротко"}}`:                              "Длинный…|коротко",
+		`{{Slugify "Новости сайта: Ёлки & Co. 2026"}}`:                                                      "novosti-sayta-elki-co-2026",
+		`{{Slugify "Объявления"}} {{Slugify "Съезд"}}`:                                                      "obyavleniya sezd",
+		`{{Lower "АБВ"}} {{Upper "abc"}} {{Trim .Text | StripHTML}}`:                                        "абв ABC Привет, мир!",
+		`{{Replace "a" "o" "banana"}} {{Join "," (Split "/" "x/y/z")}}`:                                     "bonono x,y,z",
+		`{{Join "+" .Tags}} {{Contains "ан" "банан"}} {{HasPrefix "ба" "банан"}} {{HasSuffix "x" "банан"}}`: "a+b true true false",
+		`{{SafeHTML "<b>"}} {{SafeURL "/a?b=1&c=2"}} {{SafeJS "alert(1)"}}`:                                 "<b> /a?b=1&c=2 alert(1)",
+		`{{with Dict "Name" "Пост" "Count" 2}}{{.Name}}{{.Count}}{{end}} {{List 1 "два" 3}}`:                "Пост2 [1 два 3]",
+	} {
+		result, err := executeFuncs(t, text, data, nil)
+		if err != nil {
+			t.Errorf("%s: ошибка %v", text, err)
+			continue
+		}
+		if result != expected {
+			t.Errorf("%s = %q, ожидалось %q", text, result, expected)
+		}
+	}
+
+	for _, text := range []string{`{{Div 1 0}}`, `{{Mod 1 0}}`, `{{Date "2006" "вчера"}}`, `{{Dict "a"}}`, `{{Dict 1 2}}`, `{{Join "," 5}}`} {
+		if _, err := executeFuncs(t, text, data, nil); err == nil {
+			t.Errorf("%s: ожидалась ошибка", text)
+		}
+	}
+}
+
+func TestTemplateFuncs_Collections(t *testing.T) {
+	t.Parallel()
+
+	posts := []Post{
+		{Title: "Б", Tagid: 1, Year: 2025, SortDate: time.Date(2025, time.May, 1, 0, 0, 0, 0, time.UTC), Params: map[string]interface{}{"author": "Иван"}},
+		{Title: "А", Tagid: 2, Year: 2026, SortDate: time.Date(2026, time.March, 1, 0, 0, 0, 0, time.UTC)},
+		{Title: "В", Tagid: 1, Year: 2026, SortDate: time.Date(2026, time.January, 1, 0, 0, 0, 0, time.UTC), Params: map[string]interface{}{"author": "Иван"}},
+	}
+	articles := []Article{{Title: "Вторая", Pagetitles: []string{"Введение", "Итоги"}}, {Title: "Первая", Pagetitles: []string{"Итоги"}}}
+	data := map[string]interface{}{"Posts": posts, "Articles": articles}
+
+	for text, expected := range map[string]string{
+		`{{range Limit 2 .Posts}}{{.Title}}{{end}}|{{len (Limit 10 .Posts)}}`:               "БА|3",
+		`{{range Where "Tagid" 1 .Posts}}{{.Title}}{{end}}`:                                 "БВ",
+		`{{range Where "Params.author" "Иван" .Posts}}{{.Title}}{{end}}`:                    "БВ",
+		`{{range Where "Pagetitles" "Введение" .Articles}}{{.Title}}{{end}}`:                "Вторая",
+		`{{range SortBy "Title" .Posts}}{{.Title}}{{end}}`:                                  "АБВ",
+		`{{range SortBy "-SortDate" .Posts}}{{.Title}}{{end}}`:                              "АВБ",
+		`{{range SortBy "Year" .Posts}}{{.Title}}{{end}}`:                                   "БАВ",
+		`{{range SortBy "Title" .Articles}}{{.Title}}{{end}}`:                               "ВтораяПервая",
+		`{{range GroupBy "Year" .Posts}}{{.Key}}:{{range .Items}}{{.Title}}{{end}};{{end}}`: "2025:Б;2026:АВ;",
+		`{{range GroupBy "Tagid" (SortBy "Title" .Posts)}}{{.Key}}={{len .Items}} {{end}}`:  "2=1 1=2 ",
+	} {
+		result, err := executeFuncs(t, text, data, nil)
+		if err != nil {
+			t.Errorf("%s: ошибка %v", text, err)
+			continue
+		}
+		if result != expected {
+			t.Errorf("%s = %q, ожидалось %q", text, result, expected)
+		}
+	}
+	if posts[0].Title != "Б" {
+		t.Errorf("SortBy изменил исходный список")
+	}
+
+	for _, text := range []string{`{{Where "Missing" 1 .Posts}}`, `{{SortBy "Title" 1}}`, `{{GroupBy "Title.X" .Posts}}`} {
+		if _, err := executeFuncs(t, text, data, nil); err == nil {
+			t.Errorf("%s: ожидалась ошибка", text)
+		}
+	}
+}
+
+func TestTemplateFuncs_URLs(t *testing.T) {
+	t.Parallel()
+
+	source := t.TempDir()
+	writeTestFile(t, filepath.Join(source, "css", "style.css"), "body{}")
+	cfg := &Config{Source: source, Domain: "https://example.test/site"}
+	var used []string
+	funcs := siteFuncs(cfg, func(path string) string {
+		used = append(used, path)
+		return HashFileCrc32(path)
+	})
+
+	expected := "https://example.test/site/blog/ /site/blog/ /site/posts/a.html https://cdn.test/x.js /site/css/style.css?v=" + HashStringCrc32("body{}")
+	result, err := executeFuncs(t, `{{AbsURL "blog/"}} {{RelURL "/blog/"}} {{RelURL "https://example.test/site/posts/a.html"}} {{AbsURL "https://cdn.test/x.js"}} {{Asset "css/style.css"}}`, nil, funcs)
+	if err != nil || result != expected {
+		t.Fatalf("адреса = %q, %v\nожидалось %q", result, err, expected)
+	}
+	if len(used) != 1 || used[0] != filepath.Join(source, "css", "style.css") {
+		t.Errorf("Asset не сообщил об использованном файле: %v", used)
+	}
+	if _, err = executeFuncs(t, `{{Asset "missing.css"}}`, nil, funcs); err == nil {
+		t.Errorf("Asset отсутствующего файла должен быть ошибкой")
+	}
+
+	// Без конфигурации сайта адреса строятся от корня.
+	result, err = executeFuncs(t, `{{AbsURL "a.html"}} {{RelURL "a.html"}} {{Asset "/css/style.css"}}`, nil, nil)
+	if err != nil || result != "/a.html /a.html /css/style.css" {
+		t.Errorf("адреса без конфигурации = %q, %v", result, err)
+	}
+}
+
+func TestOutputWriter_RerendersPageWhenAssetChanges(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	page := filepath.Join(cfg.Source, "index.html")
+	writeTestFile(t, page, `<link href="{{Asset "style.css"}}">`)
+	writeTestFile(t, filepath.Join(cfg.Source, "style.css"), "body{}")
+	hashDir := t.TempDir()
+	file := filepath.Join(cfg.Destination, "index.html")
+
+	render := func() string {
+		output, err := NewOutputWriter(cfg.Destination, hashDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output.SetConfig(cfg)
+		if _, err = output.RenderFile(file, page, "", nil, "index.html"); err != nil {
+			t.Fatalf("RenderFile вернул ошибку: %v", err)
+		}
+		if err = output.Close(); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(raw)
+	}
+
+	first := render()
+	writeTestFile(t, filepath.Join(cfg.Source, "style.css"), "body{color:red}")
+	second := render()
+	if first == second || !strings.Contains(second, "?v="+HashStringCrc32("body{color:red}")) {
+		t.Errorf("страница не сформирована заново после изменения ресурса: %q, %q", first, second)
+	}
+}
+
+func TestOutputWriter_RerendersPageWhenDomainChanges(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	page := filepath.Join(cfg.Source, "index.html")
+	writeTestFile(t, page, `<a href="{{AbsURL "about.html"}}">`)
+	hashDir := t.TempDir()
+	file := filepath.Join(cfg.Destination, "index.html")
+
+	render := func() string {
+		output, err := NewOutputWriter(cfg.Destination, hashDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		output.SetConfig(cfg)
+		if _, err = output.RenderFile(file, page, "", nil, "index.html"); err != nil {
+			t.Fatalf("RenderFile вернул ошибку: %v", err)
+		}
+		if err = output.Close(); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(raw)
+	}
+
+	if first := render(); first != `<a href="https://example.test/about.html">` {
+		t.Fatalf("страница = %q", first)
+	}
+	cfg.Domain = "https://example.org/site"
+	if second := render(); second != `<a href="https://example.org/site/about.html">` {
+		t.Errorf("страница не сформирована заново после изменения домена: %q", second)
+	}
+}
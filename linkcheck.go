@@ -0,0 +1,212 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Проверка ссылок сформированного сайта (googol check links).
+// В каждом html-файле целевой директории проверяются атрибуты href и src. Ссылка на страницу сайта —
+// относительная ссылка, ссылка от корня или ссылка, начинающаяся с домена сайта, — должна указывать
+// на файл целевой директории: адрес, оканчивающийся символом /, или адрес директории — на её index.html.
+// Якорь ссылки (#id) на html-файл должен совпадать с атрибутом id или name элемента этого файла.
+// Ссылки на другие сайты и ссылки mailto:, tel:, javascript: и data: не проверяются.
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Разметка html-файлов, разбираемая при проверке ссылок.
+var (
+	linkTagPattern     = regexp.MustCompile(`(?s)<[a-zA-Z][^>]*>`)
+	linkAttrPattern    = regexp.MustCompile(`(?is)\s(href|src)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	anchorAttrPattern  = regexp.MustCompile(`(?is)\s(?:id|name)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	linkIgnoredPattern = regexp.MustCompile(`(?is)<script\b.*?</script>|<style\b.*?</style>|<!--.*?-->`)
+)
+
+// BrokenLink описывает ссылку сформированной страницы, не указывающую на файл или якорь сайта.
+type BrokenLink struct {
+	// Файл страницы относительно целевой директории.
+	File string
+	// Номер строки ссылки в файле.
+	Line int
+	// Значение атрибута href или src.
+	Link string
+	// Причина: нет файла или нет якоря.
+	Reason string
+}
+
+func (l BrokenLink) String() string {
+	return fmt.Sprintf("%s:%d: %s: %s", l.File, l.Line, l.Link, l.Reason)
+}
+
+// linkChecker проверяет ссылки html-файлов целевой директории.
+type linkChecker struct {
+	cfg *Config
+	// Адрес сайта, от которого отсчитываются пути файлов целевой директории.
+	site *url.URL
+	// Якоря html-файлов, уже разобранных при проверке: путь файла — множество id.
+	anchors map[string]map[string]bool
+}
+
+// CheckLinks проверяет ссылки всех html-файлов целевой директории.
+// Возвращает битые ссылки, отсортированные по файлу и строке, и количество проверенных ссылок на страницы сайта.
+func CheckLinks(cfg *Config) ([]BrokenLink, int, error) {
+	site, err := url.Parse(cfg.Domain + "/")
+	if err != nil {
+		return nil, 0, err
+	}
+	if _, err := os.Stat(cfg.Destination); err != nil {
+		return nil, 0, err
+	}
+	checker := &linkChecker{cfg: cfg, site: site, anchors: map[string]map[string]bool{}}
+
+	var broken []BrokenLink
+	checked := 0
+	err = filepath.Walk(cfg.Destination, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !isHTMLFile(file) {
+			return nil
+		}
+		links, count, err := checker.checkFile(file)
+		broken = append(broken, links...)
+		checked += count
+		return err
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.SliceStable(broken, func(i, j int) bool {
+		if broken[i].File != broken[j].File {
+			return broken[i].File < broken[j].File
+		}
+		return broken[i].Line < broken[j].Line
+	})
+
+	return broken, checked, nil
+}
+
+// isHTMLFile проверяет, что файл — html-страница.
+func isHTMLFile(file string) bool {
+	ext := strings.ToLower(filepath.Ext(file))
+	return ext == ".html" || ext == ".htm"
+}
+
+// blankIgnored заменяет скрипты, стили и комментарии пробелами, сохраняя переводы строк,
+// чтобы номера строк ссылок не изменились.
+func blankIgnored(page string) string {
+	return linkIgnoredPattern.ReplaceAllStringFunc(page, func(s string) string {
+		return strings.Repeat("\n", strings.Count(s, "\n"))
+	})
+}
+
+// checkFile проверяет ссылки html-файла file; возвращает битые ссылки и количество проверенных ссылок.
+func (c *linkChecker) checkFile(file string) ([]BrokenLink, int, error) {
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, 0, err
+	}
+	rel, err := filepath.Rel(c.cfg.Destination, file)
+	if err != nil {
+		return nil, 0, err
+	}
+	base := c.site.ResolveReference(&url.URL{Path: filepath.ToSlash(rel)})
+
+	page := blankIgnored(string(raw))
+	var broken []BrokenLink
+	checked := 0
+	for _, tag := range linkTagPattern.FindAllStringIndex(page, -1) {
+		for _, attr := range linkAttrPattern.FindAllStringSubmatch(page[tag[0]:tag[1]], -1) {
+			link := html.UnescapeString(attr[2] + attr[3] + attr[4])
+			reason, ok := c.checkLink(base, link)
+			if !ok {
+				continue
+			}
+			checked++
+			if len(reason) > 0 {
+				line := strings.Count(page[:tag[0]], "\n") + 1
+				broken = append(broken, BrokenLink{File: filepath.ToSlash(rel), Line: line, Link: link, Reason: reason})
+			}
+		}
+	}
+
+	return broken, checked, nil
+}
+
+// checkLink проверяет ссылку link страницы с адресом base. Возвращает причину, по которой ссылка битая
+// (пустую строку для рабочей ссылки), и false, если ссылка не ведёт на страницу сайта и не проверяется.
+func (c *linkChecker) checkLink(base *url.URL, link string) (string, bool) {
+	link = strings.TrimSpace(link)
+	if len(link) == 0 || strings.HasPrefix(link, "#") && len(link) == 1 {
+		return "", false
+	}
+	ref, err := url.Parse(link)
+	if err != nil {
+		return "некорректный адрес: " + err.Error(), true
+	}
+	if len(ref.Scheme) > 0 && ref.Scheme != "http" && ref.Scheme != "https" {
+		return "", false
+	}
+	target := base.ResolveReference(ref)
+	if target.Host != c.site.Host || !strings.HasPrefix(target.Path, c.site.Path) {
+		return "", false
+	}
+
+	// Путь файла относительно целевой директории.
+	rel := strings.TrimPrefix(target.Path, c.site.Path)
+	file := filepath.Join(c.cfg.Destination, filepath.FromSlash(path.Clean("/"+rel)))
+	info, err := os.Stat(file)
+	switch {
+	case err == nil && info.IsDir():
+		file = filepath.Join(file, "index.html")
+		_, err = os.Stat(file)
+	case err == nil && strings.HasSuffix(rel, "/"):
+		// Адрес директории указывает на файл.
+		err = os.ErrNotExist
+	}
+	if err != nil {
+		return "файл не найден", true
+	}
+
+	if len(target.Fragment) == 0 || !isHTMLFile(file) {
+		return "", true
+	}
+	anchors, err := c.fileAnchors(file)
+	if err != nil {
+		return err.Error(), true
+	}
+	if !anchors[target.Fragment] {
+		return "якорь #" + target.Fragment + " не найден", true
+	}
+
+	return "", true
+}
+
+// fileAnchors возвращает значения атрибутов id и name элементов html-файла file.
+func (c *linkChecker) fileAnchors(file string) (map[string]bool, error) {
+	if anchors, ok := c.anchors[file]; ok {
+		return anchors, nil
+	}
+
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	anchors := map[string]bool{}
+	page := blankIgnored(string(raw))
+	for _, tag := range linkTagPattern.FindAllString(page, -1) {
+		for _, attr := range anchorAttrPattern.FindAllStringSubmatch(tag, -1) {
+			anchors[html.UnescapeString(attr[1]+attr[2]+attr[3])] = true
+		}
+	}
+	c.anchors[file] = anchors
+
+	return anchors, nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckLinks_ReportsBrokenLinksAndAnchors(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	writeTestFile(t, filepath.Join(cfg.Destination, "index.html"), `<html>
+<head><link rel="stylesheet" href="/css/style.css?v=1"><script>var a = '<a href="/in-script.html">';</script></head>
+<body>
+<a href="about.html#team">Команда</a> <a href="https://example.test/blog/">Блог</a> <a href='blog'>Блог</a>
+<a href="https://other.test/missing.html">Другой сайт</a> <a href="mailto:a@example.test">Почта</a>
+<img src="/images/missing.png">
+<a href="/about.html#nobody">Нет якоря</a>
+<!-- <a href="/commented.html"> -->
+<a href="#top" id="top">Наверх</a> <a href="/blog/posts/">Нет индекса</a> <a href="/about.html/">Не директория</a>
+</body></html>`)
+	writeTestFile(t, filepath.Join(cfg.Destination, "about.html"), `<h2 id="team">Команда</h2><a name="old"></a>`)
+	writeTestFile(t, filepath.Join(cfg.Destination, "css", "style.css"), `body{}`)
+	writeTestFile(t, filepath.Join(cfg.Destination, "blog", "index.html"), `<a href="../about.html#old">О нас</a> <a href="posts/a.html">Пост</a>`)
+	writeTestFile(t, filepath.Join(cfg.Destination, "blog", "posts", "a.html"), `<a href="../">Лента</a>`)
+
+	broken, checked, err := CheckLinks(cfg)
+	if err != nil {
+		t.Fatalf("CheckLinks вернул ошибку: %v", err)
+	}
+	expected := []BrokenLink{
+		{File: "index.html", Line: 6, Link: "/images/missing.png", Reason: "файл не найден"},
+		{File: "index.html", Line: 7, Link: "/about.html#nobody", Reason: "якорь #nobody не найден"},
+		{File: "index.html", Line: 9, Link: "/blog/posts/", Reason: "файл не найден"},
+		{File: "index.html", Line: 9, Link: "/about.html/", Reason: "файл не найден"},
+	}
+	if !reflect.DeepEqual(broken, expected) {
+		t.Errorf("битые ссылки:\n%v\nожидалось:\n%v", broken, expected)
+	}
+	if checked != 12 {
+		t.Errorf("проверено ссылок: %d, ожидалось 12", checked)
+	}
+}
+
+func TestRunCheckLinks_FailsOnBrokenLinks(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	writeTestFile(t, filepath.Join(cfg.Destination, "index.html"), `<a href="/about.html">О нас</a>`)
+	args := []string{"links", "-source=" + cfg.Source, "-destination=" + cfg.Destination, "-domain=" + cfg.Domain}
+	if err := runCheck(args); err == nil {
+		t.Errorf("runCheck links не сообщил о битой ссылке")
+	}
+
+	writeTestFile(t, filepath.Join(cfg.Destination, "about.html"), `<a href="/">Главная</a>`)
+	if err := runCheck(args); err != nil {
+		t.Errorf("runCheck links вернул ошибку для рабочих ссылок: %v", err)
+	}
+}
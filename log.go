@@ -0,0 +1,134 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Вывод хода сборки.
+// Уровень вывода задаётся параметрами командной строки: -quiet выводит только предупреждения и ошибки,
+// -verbose — также каждый сформированный, неизменённый, скопированный и удалённый файл.
+// Параметр -log-format=json выводит каждое сообщение строкой JSON с полями time, level, msg
+// и полями сообщения (этап сборки, его время, файл), которые удобно разбирать в скриптах развёртывания.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Уровни вывода; нулевое значение — обычный вывод.
+const (
+	LogQuiet   = -1
+	LogInfo    = 0
+	LogVerbose = 1
+)
+
+// Форматы вывода.
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// LogFields — поля сообщения, выводимые в формате JSON.
+type LogFields map[string]interface{}
+
+// Logger выводит сообщения о ходе сборки.
+// Методы nil-значения выводят сообщения в стандартный вывод в текстовом формате.
+type Logger struct {
+	mu     sync.Mutex
+	out    io.Writer
+	level  int
+	format string
+}
+
+// NewLogger создаёт вывод сообщений уровня level и ниже в формате format (text или json) в out.
+func NewLogger(out io.Writer, level int, format string) *Logger {
+	return &Logger{out: out, level: level, format: format}
+}
+
+// Info выводит сообщение о ходе сборки; не выводится с параметром -quiet.
+func (l *Logger) Info(msg string, fields LogFields) {
+	l.write(LogInfo, "info", msg, fields)
+}
+
+// Verbose выводит подробное сообщение; выводится только с параметром -verbose.
+func (l *Logger) Verbose(msg string, fields LogFields) {
+	l.write(LogVerbose, "debug", msg, fields)
+}
+
+// Warn выводит предупреждение.
+func (l *Logger) Warn(msg string, fields LogFields) {
+	l.write(LogQuiet, "warning", msg, fields)
+}
+
+// Error выводит сообщение об ошибке.
+func (l *Logger) Error(msg string, fields LogFields) {
+	l.write(LogQuiet, "error", msg, fields)
+}
+
+// Result выводит результат команды, который выводится и с параметром -quiet (план пробного запуска).
+func (l *Logger) Result(msg string, fields LogFields) {
+	l.write(LogQuiet, "info", msg, fields)
+}
+
+// JSON проверяет, выводятся ли сообщения в формате JSON.
+func (l *Logger) JSON() bool {
+	return l != nil && l.format == LogFormatJSON
+}
+
+// write выводит сообщение уровня level с названием уровня name.
+func (l *Logger) write(level int, name string, msg string, fields LogFields) {
+	out, threshold, format := io.Writer(os.Stdout), LogInfo, LogFormatText
+	if l != nil {
+		out, threshold, format = l.out, l.level, l.format
+	}
+	if level > threshold {
+		return
+	}
+
+	line := msg
+	if format == LogFormatJSON {
+		line = jsonLogLine(time.Now(), name, msg, fields)
+	}
+
+	if l != nil {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+	}
+	fmt.Fprintln(out, line)
+}
+
+// jsonLogLine формирует строку JSON сообщения: поля time, level и msg, затем поля сообщения по алфавиту.
+func jsonLogLine(now time.Time, level string, msg string, fields LogFields) string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(`{"time":` + jsonValue(now.Format(time.RFC3339)) + `,"level":` + jsonValue(level) + `,"msg":` + jsonValue(msg))
+	for _, key := range keys {
+		b.WriteString("," + jsonValue(key) + ":" + jsonValue(fields[key]))
+	}
+	b.WriteString("}")
+
+	return b.String()
+}
+
+// jsonValue возвращает значение в формате JSON; значение, которое не удаётся представить, выводится строкой.
+func jsonValue(value interface{}) string {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		raw, _ = json.Marshal(fmt.Sprint(value))
+	}
+
+	return string(raw)
+}
+
+// durationMs возвращает длительность в миллисекундах с точностью до сотых.
+func durationMs(d time.Duration) float64 {
+	return float64(d.Round(10*time.Microsecond)) / float64(time.Millisecond)
+}
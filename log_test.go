@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestLogger_LevelsAndFormats(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	log := NewLogger(&out, LogQuiet, LogFormatJSON)
+	log.Info("этап", nil)
+	log.Verbose("файл", nil)
+	log.Warn("пустой текст", LogFields{"file": "a.xml", "line": 3})
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &line); err != nil {
+		t.Fatalf("строка не разобрана: %v\n%s", err, out.String())
+	}
+	if line["level"] != "warning" || line["msg"] != "пустой текст" || line["file"] != "a.xml" || line["line"] != float64(3) || line["time"] == nil {
+		t.Errorf("строка JSON: %s", out.String())
+	}
+	if !strings.HasPrefix(out.String(), `{"time":`) {
+		t.Errorf("строка JSON начинается не с поля time: %s", out.String())
+	}
+
+	out.Reset()
+	log = NewLogger(&out, LogVerbose, LogFormatText)
+	log.Info("этап", LogFields{"stage": "blog"})
+	log.Verbose("  сформирован index.html", nil)
+	if out.String() != "этап\n  сформирован index.html\n" {
+		t.Errorf("текстовый вывод: %q", out.String())
+	}
+}
+
+func TestLoadConfigFromArgs_LogFlags(t *testing.T) {
+	t.Parallel()
+
+	source := t.TempDir()
+	cfg, err := loadConfigFromArgs([]string{"-source=" + source, "-destination=" + t.TempDir(), "-domain=https://example.test", "-verbose", "-log-format=json", "-report=build.json"})
+	if err != nil {
+		t.Fatalf("loadConfigFromArgs вернул ошибку: %v", err)
+	}
+	if cfg.LogLevel != LogVerbose || cfg.LogFormat != LogFormatJSON || cfg.Report != "build.json" {
+		t.Errorf("параметры вывода: %d %q %q", cfg.LogLevel, cfg.LogFormat, cfg.Report)
+	}
+
+	if _, err = loadConfigFromArgs([]string{"-source=" + source, "-quiet", "-verbose"}); !isUsageError(err) {
+		t.Errorf("ожидалась ошибка параметров -quiet и -verbose, получено %v", err)
+	}
+	if _, err = loadConfigFromArgs([]string{"-source=" + source, "-destination=" + t.TempDir(), "-domain=https://example.test", "-log-format=xml"}); err == nil {
+		t.Errorf("ожидалась ошибка формата вывода")
+	}
+}
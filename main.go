@@ -1,7 +1,9 @@
 // Googol генератор статических html - страниц из шаблонов
 // командная строка запуска приложения имеет вид:
 // googol --source=<исходная корневая директория> --destination=<целевая корневая директория> --domain=<имя целевого домена>
-// все параметры являются обязательными
+// обязательным является только параметр source, остальные параметры могут быть заданы в файле конфигурации сайта
+// __settings/site.toml (или site.yaml) исходной директории либо в файле, указанном параметром --config;
+// параметры командной строки переопределяют значения из файла конфигурации (см. config.go)
 // приложение выполняет следующие операции:
 // 1. синхронизирует структуру поддиректорий в исходной и целевой директориях
 //  1.1. удаление в целевой директории поддиректорий, отсутствующих в исходной директории
@@ -9,6 +11,8 @@
 //  при этом поддиректории в исходной директории, имена которых начинаются с символа _, в целевую директорию не копируются
 // 2. обходит все поддиректории исходной директории, чьи имена не начинаются с символа _, и обрабатывает все файлы, имена которых не начинаются с символа _
 // 3. для всех файлов с расширением HTML и PHP выполняется парсинг, директорией шаблонов считается поддиректория __templates
+//  файлы с расширением MD преобразуются из Markdown в HTML, после чего обрабатываются так же и записываются
+//  в целевую директорию с расширением HTML (см. markdown.go)
 //  если в целевой директории нет файла с таким именем, распарсенный файл записывается в целевую директорию
 //  если в целевой директории есть файл с таким именем,	для целевого и вновь распарсенного файла вычисляется crc - сумма и целевой файл заменяется если
 //  вновь распарсенный файл отличается от него
@@ -16,123 +20,407 @@
 //	если в целевой директории нет такого файла, туда копируется файл из исходной директории
 //  если в целевой директории есть такой файл, сравниваются crc - суммы целевого и исходного файла
 //  исходный файл копируется на место целевого в случае отличия crc - сумм
-// 5. если в исходной директории есть поддиректория с именем _blog - запускается модуль создания файлов блога
-// 6. если в исходной директории есть поддиректория с именем _articles - запускается модуль создания файлов публикаций
+// все сформированные файлы записываются через общий механизм OutputWriter: файл перезаписывается только при изменении
+// его содержимого, сведения о сформированных файлах хранятся в манифесте __hash/manifest, файлы, сформированные
+// прошлой сборкой и не сформированные текущей, удаляются из целевой директории
+// для страниц, формируемых из шаблонов, в файле __hash/deps.json хранится граф зависимостей страницы от шаблона,
+//  используемых им шаблонов __templates/*.tmpl, исходных файлов данных и самих данных шаблона;
+//  страница, зависимости которой не изменились, не формируется заново
+// 5. если в исходной директории есть поддиректория с именем __blog - запускается модуль создания файлов блога
+// 6. если в исходной директории есть поддиректория с именем __articles - запускается модуль создания файлов публикаций
+// имена служебных поддиректорий и формируемых разделов, количество постов на страницу и исключаемые из компиляции
+//  поддиректории задаются в файле конфигурации сайта
+// данные блога, публикаций и вопросов-ответов загружаются до формирования страниц и доступны всем шаблонам
+//  в поле Site (см. site.go)
 
 package main
 
 import (
 	"flag"
 	"fmt"
-
-	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 )
 
-func main() {
-	//---------------------------------------
-	//считываем параметры командной строки
-	flagSet := flag.NewFlagSet("flag_set", flag.ExitOnError)
+// configFlags описывает параметры командной строки, задающие конфигурацию сайта.
+type configFlags struct {
 	//исходная корневая директория
-	source := flagSet.String("source", "", "Укажите исходную директорию")
+	source *string
+	//файл конфигурации сайта
+	configFile *string
 	//целевая корневая директория
-	destination := flagSet.String("destination", "", "Укажите целевую директорию")
+	destination *string
 	//название целевого домена
-	domain := flagSet.String("domain", "", "Укажите домен сайта")
-	//проверяем параметры командной строки
-	//парсим набор флагов для команды
-	if err := flagSet.Parse(os.Args[1:]); err == nil {
-		//проверяем, указан ли путь к исходной директории
-		if len(*source) == 0 {
-			fmt.Println(ErrorMessages["required_parameter"], "source")
-			fmt.Println(HelpMessage)
-			return
-		}
-		//проверяем, указан ли путь к целевой директории
-		if len(*destination) == 0 {
-			fmt.Println(ErrorMessages["required_parameter"], "destination")
-			fmt.Println(HelpMessage)
-			return
+	domain *string
+	//количество записей в лентах RSS и Atom блога
+	feedItems *int
+	//включать ли в ленты полный текст постов
+	feedFull *bool
+	//количество страниц, формируемых одновременно
+	jobs *int
+	//публиковать ли черновики
+	drafts *bool
+	//публиковать ли посты с датой позже времени сборки
+	future *bool
+	//выводить только предупреждения и ошибки
+	quiet *bool
+	//выводить каждый файл целевой директории
+	verbose *bool
+	//формат вывода хода сборки
+	logFormat *string
+	//файл отчёта о сборке
+	report *string
+}
+
+// addConfigFlags добавляет в набор флагов параметры конфигурации сайта.
+func addConfigFlags(flagSet *flag.FlagSet) *configFlags {
+	return &configFlags{
+		source:      flagSet.String("source", "", "Укажите исходную директорию"),
+		configFile:  flagSet.String("config", "", "Файл конфигурации сайта (по умолчанию __settings/site.toml)"),
+		destination: flagSet.String("destination", "", "Укажите целевую директорию"),
+		domain:      flagSet.String("domain", "", "Укажите домен сайта"),
+		feedItems:   flagSet.Int("feed-items", DefaultFeedItems, "Количество записей в лентах RSS и Atom"),
+		feedFull:    flagSet.Bool("feed-full", false, "Включать в ленты RSS и Atom полный текст постов"),
+		jobs:        flagSet.Int("jobs", 0, "Количество страниц, формируемых одновременно (по умолчанию количество процессоров)"),
+		drafts:      flagSet.Bool("drafts", false, "Публиковать черновики постов"),
+		future:      flagSet.Bool("future", false, "Публиковать посты с датой позже времени сборки"),
+		quiet:       flagSet.Bool("quiet", false, "Выводить только предупреждения и ошибки"),
+		verbose:     flagSet.Bool("verbose", false, "Выводить каждый сформированный, неизменённый, скопированный и удалённый файл"),
+		logFormat:   flagSet.String("log-format", LogFormatText, "Формат вывода хода сборки: text или json"),
+		report:      flagSet.String("report", "", "Файл, в который записывается отчёт о сборке в формате JSON"),
+	}
+}
+
+// load загружает файл конфигурации сайта и переопределяет его значения параметрами,
+// явно указанными в командной строке. Конфигурация не проверяется.
+func (f *configFlags) load(flagSet *flag.FlagSet) (*Config, error) {
+	//проверяем, указан ли путь к исходной директории
+	if len(*f.source) == 0 {
+		return nil, &usageError{ErrorMessages["required_parameter"] + "source"}
+	}
+
+	//пути целевых файлов вычисляются заменой исходной директории на целевую, поэтому путь должен быть абсолютным
+	source, err := filepath.Abs(*f.source)
+	if err != nil {
+		return nil, err
+	}
+	if *f.quiet && *f.verbose {
+		return nil, &usageError{"параметры -quiet и -verbose нельзя указывать вместе"}
+	}
+	cfg, err := LoadConfig(source, *f.configFile)
+	if err != nil {
+		return nil, err
+	}
+	flagSet.Visit(func(flag *flag.Flag) {
+		switch flag.Name {
+		case "destination":
+			cfg.Destination = *f.destination
+		case "domain":
+			cfg.Domain = *f.domain
+		case "feed-items":
+			cfg.Feed.Items = *f.feedItems
+		case "feed-full":
+			cfg.Feed.FullContent = *f.feedFull
+		case "jobs":
+			cfg.Jobs = *f.jobs
+		case "drafts":
+			cfg.Drafts = *f.drafts
+		case "future":
+			cfg.Future = *f.future
+		case "quiet":
+			if *f.quiet {
+				cfg.LogLevel = LogQuiet
+			}
+		case "verbose":
+			if *f.verbose {
+				cfg.LogLevel = LogVerbose
+			}
+		case "log-format":
+			cfg.LogFormat = *f.logFormat
+		case "report":
+			cfg.Report = *f.report
 		}
-		//проверяем, указан ли домен сайта
-		if len(*domain) == 0 {
-			fmt.Println(ErrorMessages["required_parameter"], "domain")
-			fmt.Println(HelpMessage)
-			return
+	})
+
+	return cfg, nil
+}
+
+// addBuildFlags добавляет в набор флагов параметры команды build: пробный запуск и вывод изменений страниц.
+func addBuildFlags(flagSet *flag.FlagSet) (*bool, *bool) {
+	dryRun := flagSet.Bool("dry-run", false, "Не изменять целевую директорию, а вывести план изменений")
+	diff := flagSet.Bool("diff", false, "Выводить в плане пробного запуска изменения html-страниц (вместе с -dry-run)")
+
+	return dryRun, diff
+}
+
+// loadConfigFromArgs считывает параметры командной строки, загружает файл конфигурации сайта
+// и переопределяет его значения параметрами командной строки.
+func loadConfigFromArgs(args []string) (*Config, error) {
+	flagSet := newCommandFlagSet("build")
+	flags := addConfigFlags(flagSet)
+	dryRun, diff := addBuildFlags(flagSet)
+	//парсим набор флагов для команды
+	if err := flagSet.Parse(args); err != nil {
+		return nil, err
+	}
+	if *diff && !*dryRun {
+		return nil, &usageError{"параметр -diff указывается вместе с -dry-run"}
+	}
+
+	cfg, err := flags.load(flagSet)
+	if err != nil {
+		return nil, err
+	}
+	cfg.DryRun, cfg.Diff = *dryRun, *diff
+	if err = cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// Build формирует сайт в целевой директории.
+func Build(cfg *Config) error {
+	//запись сформированных файлов: загружаем манифест файлов, сформированных прошлой сборкой
+	output, err := NewOutputWriter(cfg.Destination, cfg.HashDir())
+	if err != nil {
+		return err
+	}
+
+	return buildSite(cfg, output)
+}
+
+// buildSite формирует сайт, записывая сформированные файлы через output.
+// Ход сборки выводится с уровнем и в формате из конфигурации; в конце выводится отчёт о сборке,
+// который записывается и в файл cfg.Report, если он задан, в том числе при ошибке сборки.
+// В пробном запуске (cfg.DryRun) файлы не записываются, после отчёта выводится план изменений.
+func buildSite(cfg *Config, output *OutputWriter) error {
+	log := NewLogger(os.Stdout, cfg.LogLevel, cfg.LogFormat)
+	report := NewBuildReport(cfg)
+	output.SetLogger(log)
+	output.SetReport(report)
+	var plan *BuildPlan
+	if cfg.DryRun {
+		plan = NewBuildPlan(cfg.Destination, cfg.Diff)
+		output.SetPlan(plan)
+	}
+
+	err := buildStages(cfg, output, log, report)
+	report.Finish(err)
+	if err == nil {
+		printReport(log, report)
+		if plan != nil {
+			printPlan(log, plan)
 		}
 	}
+	if reportErr := report.Write(cfg.Report); err == nil {
+		err = reportErr
+	}
+
+	return err
+}
+
+// buildStages выполняет этапы сборки сайта.
+func buildStages(cfg *Config, output *OutputWriter, log *Logger, report *BuildReport) error {
 	//---------------------------------------
 	//синхронизация структуры поддиректорий в целевой и исходной директориях
-	fmt.Print("Синхронизирую исходную и целевую директории...")
-	err := syncDirs(*source, *destination)
+	//директории со сформированными файлами прошлой сборки не удаляются
+	err := output.Stage("sync", "Синхронизирую исходную и целевую директории", func() error {
+		return output.SyncDirs(cfg.Source)
+	})
 	if err != nil {
-		fmt.Println(err.Error())
-		return
+		return err
 	}
-	fmt.Println("сделано")
 	//----------------------------------------
-	//содержимое файла файл sitemap
-	sitemap := "<?xml version=\"1.0\" encoding=\"UTF-8\"?><urlset xmlns=\"http://www.sitemaps.org/schemas/sitemap/0.9\">"
-	//директория настроек сайта
-	settings_dir := filepath.Join(*source, "__settings")
-	//директория шаблонов сайта
-	templates_dir := filepath.Join(*source, "__templates")
+	//загружаем данные блога, публикаций и вопросов-ответов, доступные всем шаблонам
+	var site *Site
+	err = output.Stage("load", "Загружаю данные сайта", func() error {
+		site, err = LoadSite(cfg)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	output.SetSite(site)
+	report.AddSite(site)
+	printSkippedPosts(log, site.SkippedPosts())
+	printWarnings(log, site.Warnings())
+	//----------------------------------------
+	//записи sitemap, которые добавляют генераторы страниц
+	sitemap := NewSitemap(cfg)
+	//перенаправления со старых адресов постов, публикаций и из файла __settings/redirects,
+	//старые адреса страниц добавляются при их обходе
+	redirects := NewRedirects(cfg)
+	redirects.AddSite(site)
+	if err = redirects.Load(); err != nil {
+		return err
+	}
+	//поисковый индекс постов, публикаций и вопросов-ответов, страницы добавляются при их обходе
+	search := NewSearchIndex(cfg)
+	search.AddSite(site)
+	//----------------------------------------
+	//формируем страницы; задачи формирования выполняются параллельно,
+	//каждый этап дожидается завершения своих задач
+	output.SetJobs(cfg.Jobs)
+	output.SetConfig(cfg)
+	if err = renderSite(site, sitemap, redirects, search, output); err != nil {
+		return err
+	}
+	//формируем страницы-заглушки и правила перенаправления со старых адресов
+	err = output.Stage("redirects", "Формирование перенаправлений", func() error {
+		return redirects.Write(output)
+	})
+	if err != nil {
+		return err
+	}
+	//записываем поисковый индекс, скрипт и страницу поиска
+	err = output.Stage("search", "Формирование поискового индекса", func() error {
+		return search.Write(site, output)
+	})
+	if err != nil {
+		return err
+	}
+	//записываем файлы sitemap в целевую директорию
+	err = output.Stage("sitemap", "Формирование sitemap", func() error {
+		return sitemap.Write(cfg.Destination, output)
+	})
+	if err != nil {
+		return err
+	}
+	//удаляем файлы, не сформированные этой сборкой, и сохраняем манифест
+	return output.Stage("cleanup", "Удаляю устаревшие файлы", output.Close)
+}
+
+// renderSite передаёт на формирование страницы публикаций, блога, Вопросов и ответов и исходной директории.
+// Каждый генератор выполняется отдельным этапом сборки (OutputWriter.Stage).
+func renderSite(site *Site, sitemap *Sitemap, redirects *Redirects, search *SearchIndex, output *OutputWriter) error {
+	cfg := site.Config
 	//----------------------------------------
 	//загружаем список публикаций, отсортированный по заголовку
-	articles_dir := filepath.Join(*source, "__articles")
-	if _, err := os.Stat(articles_dir); !os.IsNotExist(err) {
-		fmt.Print("Формирование файлов публикаций...")
-		destination_articlesdir := filepath.Join(*destination, "articles")
-		err = CreateArticles(settings_dir, articles_dir, destination_articlesdir, templates_dir, *domain, &sitemap)
+	if _, err := os.Stat(cfg.ArticlesDir()); !os.IsNotExist(err) {
+		err = output.Stage("articles", "Формирование файлов публикаций", func() error {
+			return CreateArticles(site, sitemap, output)
+		})
 		if err != nil {
-			fmt.Println(err.Error())
-			return
+			return err
 		}
-		fmt.Println("сделано")
 	}
 	//----------------------------------------
 	//запуск модуля блога
-	blog_dir := filepath.Join(*source, "__blog")
-	if _, err := os.Stat(blog_dir); !os.IsNotExist(err) {
-		fmt.Print("Формирование файлов блога...")
-		destination_blogdir := filepath.Join(*destination, "blog")
-		posts_sourcedir := filepath.Join(*source, "__blog")
-		err = CreateBlog(settings_dir, destination_blogdir, posts_sourcedir, templates_dir, *domain, &sitemap)
+	if _, err := os.Stat(cfg.BlogDir()); !os.IsNotExist(err) {
+		err = output.Stage("blog", "Формирование файлов блога", func() error {
+			return CreateBlog(site, sitemap, output)
+		})
 		if err != nil {
-			fmt.Println(err.Error())
-			return
+			return err
 		}
-		fmt.Println("сделано")
 	}
 	//--------------------------------------
 	//запуск модуля вопросов и ответов
-	qa_dir := filepath.Join(*source, "__qa")
-	if _, err := os.Stat(qa_dir); !os.IsNotExist(err) {
-		fmt.Print("Формирование страницы Вопросы и ответы...")
-		err = CreateQA(*destination, settings_dir, qa_dir, templates_dir, *domain, &sitemap)
+	if _, err := os.Stat(cfg.QADir()); !os.IsNotExist(err) {
+		err = output.Stage("qa", "Формирование страницы Вопросы и ответы", func() error {
+			return CreateQA(site, sitemap, output)
+		})
 		if err != nil {
-			fmt.Println(err.Error())
-			return
+			return err
 		}
-		fmt.Println("сделано")
 	}
 	//--------------------------------------
 	//обход поддиректорий исходной директории и обработка файлов в них
-	fmt.Print("Компилирую файлы и копирую в целевую директорию...")
-	err = HandleSourceDir(*source, *destination, &sitemap, *domain)
-	if err != nil {
-		fmt.Println(err.Error())
+	return output.Stage("pages", "Компилирую файлы и копирую в целевую директорию", func() error {
+		return HandleSourceDir(site, sitemap, redirects, search, output)
+	})
+}
+
+// printReport выводит отчёт о сборке: время этапов и количество файлов по генераторам.
+func printReport(log *Logger, report *BuildReport) {
+	fields := LogFields{"report": report}
+	log.Info(fmt.Sprintf("Сборка завершена за %s: сформировано %d, без изменений %d, скопировано %d, удалено файлов %d",
+		report.elapsed.Round(time.Millisecond),
+		report.Total.Rendered, report.Total.Unchanged, report.Total.Copied, report.Total.Deleted), fields)
+	for _, name := range report.GeneratorNames() {
+		counts := report.Generators[name]
+		log.Verbose(fmt.Sprintf("  %s: сформировано %d, без изменений %d, скопировано %d, удалено %d",
+			name, counts.Rendered, counts.Unchanged, counts.Copied, counts.Deleted), LogFields{"generator": name, "files": counts})
+	}
+}
+
+// printPlan выводит план изменений целевой директории пробного запуска; выводится и с параметром -quiet.
+// Изменения html-страниц в текстовом формате выводятся после списка файлов, в формате JSON — полем diff.
+func printPlan(log *Logger, plan *BuildPlan) {
+	changes := plan.Changes()
+	summary := plan.Summary()
+	log.Result(fmt.Sprintf("Пробный запуск, целевая директория %s не изменена. Директорий: создать %d, удалить %d; файлов: добавить %d, изменить %d, удалить %d",
+		plan.destination, summary[PlanCreateDir], summary[PlanRemoveDir], summary[PlanAdd], summary[PlanUpdate], summary[PlanRemove]),
+		LogFields{"dry_run": true, "changes": summary})
+	for _, change := range changes {
+		fields := LogFields{"action": change.Action, "file": change.Path}
+		if len(change.Diff) > 0 {
+			fields["diff"] = change.Diff
+		}
+		log.Result(planActionSigns[change.Action]+" "+change.Path, fields)
+	}
+	if log.JSON() {
 		return
 	}
-	//записываем файл sitemap.xml в целевую директорию
-	sitemap += "</urlset>"
-	err = ioutil.WriteFile(filepath.Join(*destination, "sitemap.xml"), []byte(sitemap), os.FileMode(int(0777)))
-	if err != nil {
-		fmt.Println(ErrorMessages["error_creating_file"] + err.Error())
+	for _, change := range changes {
+		if len(change.Diff) > 0 {
+			log.Result(strings.TrimSuffix(change.Diff, "\n"), nil)
+		}
+	}
+}
+
+// printSkippedPosts выводит список постов, не опубликованных сборкой, с причиной пропуска.
+func printSkippedPosts(log *Logger, skipped []SkippedPost) {
+	if len(skipped) == 0 {
 		return
 	}
-	fmt.Println("сделано")
-	//---------------------------------------
-	fmt.Println("Сайт успешно скомпилирован и скопирован в целевую директорию")
+	log.Info(fmt.Sprintf("Не опубликовано постов: %d (параметры -drafts и -future публикуют их)", len(skipped)), nil)
+	for _, post := range skipped {
+		log.Info(fmt.Sprintf("  %s %q: %s", post.Source, post.Title, post.Reason), LogFields{"file": post.Source, "reason": post.Reason})
+	}
+}
+
+// printWarnings выводит предупреждения проверки исходных записей.
+func printWarnings(log *Logger, warnings []Problem) {
+	if len(warnings) == 0 {
+		return
+	}
+	log.Warn(fmt.Sprintf("Предупреждений в исходных файлах: %d", len(warnings)), nil)
+	for _, warning := range warnings {
+		log.Warn("  "+warning.Error(), LogFields{"file": warning.File, "line": warning.Line, "column": warning.Column, "kind": warning.Kind})
+	}
+}
+
+func main() {
+	//первый аргумент, не являющийся параметром, задаёт команду; без команды сайт формируется командой build
+	args := os.Args[1:]
+	name := "build"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	if name == "help" {
+		if err := runHelp(args); err != nil {
+			fmt.Println(err.Error())
+			fmt.Println(HelpMessage)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Println(ErrorMessages["unknown_command"] + name)
+		fmt.Println(HelpMessage)
+		os.Exit(1)
+	}
+	if err := commandRunner(name)(args); err != nil {
+		fmt.Println(err.Error())
+		if isUsageError(err) {
+			fmt.Println("Пример использования: " + cmd.usage)
+		}
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,1341 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Преобразование текста Markdown в HTML.
+// Поддерживаются заголовки с якорями (id формируется из текста заголовка или задаётся явно: # Заголовок {#id}),
+// абзацы, цитаты, списки, блоки кода (с отступом и ограниченные ``` или ~~~ с указанием языка),
+// горизонтальные линии, таблицы GFM, сноски ([^1] и [^1]: текст), ссылки и изображения (в том числе
+// ссылки по определениям [текст][id]), выделение *курсивом*, **полужирным**, ~~зачёркиванием~~, `кодом`.
+// HTML-блоки и строки, состоящие только из действия шаблона {{...}}, переносятся в результат без изменений.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MarkdownFormat — значение элемента format постов и записей Вопросы и ответы с содержимым в формате Markdown.
+const MarkdownFormat = "markdown"
+
+// markdownContent возвращает текст поста или ответа записи Вопросы и ответы в формате HTML.
+// Текст в формате Markdown берётся из файла с тем же именем и расширением .md рядом с xml-файлом
+// xmlPath либо из самого элемента xml-файла, если элемент format имеет значение markdown.
+// Возвращает текст и файл Markdown, из которого он получен (пустая строка, если такого файла нет).
+func markdownContent(xmlPath string, format string, text string) (TrustedHTML, string, error) {
+	sidecar := strings.TrimSuffix(xmlPath, filepath.Ext(xmlPath)) + ".md"
+	raw, err := ioutil.ReadFile(sidecar)
+	if err == nil {
+		return TrustedHTML(RenderMarkdown(string(raw))), sidecar, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", "", err
+	}
+
+	content, err := renderContent(format, text)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %v", xmlPath, err)
+	}
+
+	return TrustedHTML(content), "", nil
+}
+
+// renderContent преобразует текст в HTML в соответствии с его форматом: html (по умолчанию) или markdown.
+func renderContent(format string, text string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "", "html":
+		return text, nil
+	case MarkdownFormat:
+		return RenderMarkdown(DedentMarkdown(text)), nil
+	}
+
+	return "", fmt.Errorf("неизвестный формат текста %q", format)
+}
+
+// Блочные теги HTML, с которых может начинаться HTML-блок.
+var markdownBlockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "details": true, "dialog": true,
+	"div": true, "dl": true, "fieldset": true, "figcaption": true, "figure": true, "footer": true,
+	"form": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true,
+	"hr": true, "iframe": true, "li": true, "main": true, "nav": true, "ol": true, "p": true, "pre": true,
+	"script": true, "section": true, "style": true, "summary": true, "table": true, "tbody": true,
+	"td": true, "textarea": true, "tfoot": true, "th": true, "thead": true, "tr": true, "ul": true,
+}
+
+var (
+	markdownATXHeading   = regexp.MustCompile(`^ {0,3}(#{1,6})(?:[ \t]+(.*?))?(?:[ \t]+#+)?[ \t]*$`)
+	markdownHeadingID    = regexp.MustCompile(`[ \t]*\{#([^{}\s]+)\}$`)
+	markdownThematic     = regexp.MustCompile(`^ {0,3}(?:(?:\*[ \t]*){3,}|(?:-[ \t]*){3,}|(?:_[ \t]*){3,})$`)
+	markdownSetext       = regexp.MustCompile(`^ {0,3}(=+|-+)[ \t]*$`)
+	markdownFence        = regexp.MustCompile("^( {0,3})(`{3,}|~{3,})[ \t]*([^`]*)$")
+	markdownBullet       = regexp.MustCompile(`^( {0,3})([-*+])(?:([ \t]+)(.*)|$)`)
+	markdownOrdered      = regexp.MustCompile(`^( {0,3})(\d{1,9})([.)])(?:([ \t]+)(.*)|$)`)
+	markdownLinkDef      = regexp.MustCompile(`^ {0,3}\[([^\]^][^\]]*)\]:[ \t]*<?([^\s>]+)>?(?:[ \t]+(?:"([^"]*)"|'([^']*)'|\(([^)]*)\)))?[ \t]*$`)
+	markdownFootnoteDef  = regexp.MustCompile(`^ {0,3}\[\^([^\]\s]+)\]:[ \t]?(.*)$`)
+	markdownTableDelim   = regexp.MustCompile(`^ *:?-+:? *$`)
+	markdownAutolink     = regexp.MustCompile(`^<([a-zA-Z][a-zA-Z0-9+.-]{1,31}:[^\s<>]*)>`)
+	markdownEmailLink    = regexp.MustCompile(`^<([a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]*[a-zA-Z0-9])?)*)>`)
+	markdownInlineTag    = regexp.MustCompile(`^(?:<[a-zA-Z][a-zA-Z0-9-]*(?:\s+[a-zA-Z_:][a-zA-Z0-9_.:-]*(?:\s*=\s*(?:[^\s"'=<>` + "`" + `]+|'[^']*'|"[^"]*"))?)*\s*/?>|</[a-zA-Z][a-zA-Z0-9-]*\s*>|<!--[\s\S]*?-->)`)
+	markdownEntity       = regexp.MustCompile(`^&(?:[a-zA-Z][a-zA-Z0-9]{1,31}|#[0-9]{1,7}|#[xX][0-9a-fA-F]{1,6});`)
+	markdownHTMLBlockTag = regexp.MustCompile(`^ {0,3}<(/?)([a-zA-Z][a-zA-Z0-9]*)(?:[\s/>]|$)`)
+)
+
+// markdownLink описывает определение ссылки [id]: адрес "заголовок".
+type markdownLink struct {
+	URL   string
+	Title string
+}
+
+// markdownRenderer хранит состояние преобразования одного документа.
+type markdownRenderer struct {
+	// Определения ссылок.
+	links map[string]markdownLink
+	// Определения сносок.
+	notes map[string][]string
+	// Сноски в порядке первой ссылки на них.
+	noteOrder []string
+	// Номера сносок.
+	noteNumbers map[string]int
+	// Количество ссылок на сноску.
+	noteRefs map[string]int
+	// Использованные якоря заголовков.
+	ids map[string]int
+}
+
+// RenderMarkdown преобразует текст Markdown в HTML.
+func RenderMarkdown(src string) string {
+	r := &markdownRenderer{
+		links:       map[string]markdownLink{},
+		notes:       map[string][]string{},
+		noteNumbers: map[string]int{},
+		noteRefs:    map[string]int{},
+		ids:         map[string]int{},
+	}
+
+	lines := r.collectDefinitions(markdownLines(src))
+	html := r.renderBlocks(lines, false)
+
+	return html + r.renderFootnotes()
+}
+
+// DedentMarkdown убирает общий отступ строк и пустые строки в начале и в конце текста.
+// Используется для текста Markdown внутри элементов xml-файлов.
+func DedentMarkdown(src string) string {
+	lines := markdownLines(src)
+	for len(lines) > 0 && isBlankLine(lines[0]) {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && isBlankLine(lines[len(lines)-1]) {
+		lines = lines[:len(lines)-1]
+	}
+
+	indent := -1
+	for _, line := range lines {
+		if isBlankLine(line) {
+			continue
+		}
+		if n := lineIndent(line); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, line := range lines {
+		if len(line) >= indent {
+			lines[i] = line[indent:]
+		} else {
+			lines[i] = ""
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// markdownLines разбивает текст на строки, заменяя символы табуляции в начале строк пробелами.
+func markdownLines(src string) []string {
+	src = strings.TrimPrefix(src, "\ufeff")
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+	lines := strings.Split(src, "\n")
+	for i, line := range lines {
+		if !strings.HasPrefix(line, "\t") && !strings.Contains(leadingSpace(line), "\t") {
+			continue
+		}
+		var b strings.Builder
+		column := 0
+		j := 0
+		for ; j < len(line) && (line[j] == ' ' || line[j] == '\t'); j++ {
+			if line[j] == '\t' {
+				spaces := 4 - column%4
+				b.WriteString(strings.Repeat(" ", spaces))
+				column += spaces
+			} else {
+				b.WriteByte(' ')
+				column++
+			}
+		}
+		lines[i] = b.String() + line[j:]
+	}
+
+	return lines
+}
+
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+func isBlankLine(line string) bool {
+	return len(strings.TrimSpace(line)) == 0
+}
+
+func lineIndent(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// collectDefinitions извлекает определения ссылок и сносок вне блоков кода.
+func (r *markdownRenderer) collectDefinitions(lines []string) []string {
+	result := make([]string, 0, len(lines))
+	fence := ""
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if m := markdownFence.FindStringSubmatch(line); m != nil {
+			if len(fence) == 0 {
+				fence = m[2]
+			} else if strings.HasPrefix(m[2], fence[:1]) && len(m[2]) >= len(fence) && len(strings.TrimSpace(m[3])) == 0 {
+				fence = ""
+			}
+		}
+		if len(fence) > 0 {
+			result = append(result, line)
+			continue
+		}
+
+		if m := markdownFootnoteDef.FindStringSubmatch(line); m != nil {
+			label := strings.ToLower(m[1])
+			body := []string{m[2]}
+			// Продолжение сноски — строки с отступом не менее 4 пробелов, возможно после пустых строк.
+			for i+1 < len(lines) {
+				next := lines[i+1]
+				if !isBlankLine(next) && lineIndent(next) >= 4 {
+					body = append(body, next[4:])
+					i++
+					continue
+				}
+				if isBlankLine(next) {
+					j := i + 1
+					for j < len(lines) && isBlankLine(lines[j]) {
+						j++
+					}
+					if j < len(lines) && lineIndent(lines[j]) >= 4 {
+						for ; i+1 < j; i++ {
+							body = append(body, "")
+						}
+						continue
+					}
+				}
+				break
+			}
+			if _, ok := r.notes[label]; !ok {
+				r.notes[label] = body
+			}
+			continue
+		}
+
+		if m := markdownLinkDef.FindStringSubmatch(line); m != nil && (len(result) == 0 || isBlankLine(result[len(result)-1]) || markdownLinkDef.MatchString(lines[i-1])) {
+			label := normalizeLabel(m[1])
+			if _, ok := r.links[label]; !ok {
+				r.links[label] = markdownLink{URL: m[2], Title: m[3] + m[4] + m[5]}
+			}
+			continue
+		}
+
+		result = append(result, line)
+	}
+
+	return result
+}
+
+// normalizeLabel приводит метку ссылки к виду, используемому для поиска определения.
+func normalizeLabel(label string) string {
+	return strings.ToLower(strings.Join(strings.Fields(label), " "))
+}
+
+// renderBlocks преобразует блоки документа.
+// tight — абзацы выводятся без тегов <p> (элементы компактного списка).
+func (r *markdownRenderer) renderBlocks(lines []string, tight bool) string {
+	var b strings.Builder
+	for i := 0; i < len(lines); {
+		line := lines[i]
+
+		switch {
+		case isBlankLine(line):
+			i++
+
+		case isTemplateLine(line):
+			b.WriteString(strings.TrimSpace(line) + "\n")
+			i++
+
+		case markdownFence.MatchString(line):
+			i = r.renderFencedCode(&b, lines, i)
+
+		case lineIndent(line) >= 4:
+			i = r.renderIndentedCode(&b, lines, i)
+
+		case markdownATXHeading.MatchString(line):
+			m := markdownATXHeading.FindStringSubmatch(line)
+			r.writeHeading(&b, len(m[1]), m[2])
+			i++
+
+		case markdownThematic.MatchString(line):
+			b.WriteString("<hr />\n")
+			i++
+
+		case strings.HasPrefix(strings.TrimLeft(line, " "), ">"):
+			i = r.renderBlockquote(&b, lines, i)
+
+		case isHTMLBlockStart(line):
+			i = renderHTMLBlock(&b, lines, i)
+
+		case isListStart(line):
+			i = r.renderList(&b, lines, i)
+
+		case isTableStart(lines, i):
+			i = r.renderTable(&b, lines, i)
+
+		default:
+			i = r.renderParagraph(&b, lines, i, tight)
+		}
+	}
+
+	return b.String()
+}
+
+// isTemplateLine проверяет, что строка состоит только из действия шаблона.
+func isTemplateLine(line string) bool {
+	trimmed := strings.TrimSpace(line)
+	return lineIndent(line) < 4 && strings.HasPrefix(trimmed, "{{") && strings.HasSuffix(trimmed, "}}") && strings.Index(trimmed, "}}") == len(trimmed)-2
+}
+
+// isHTMLBlockStart проверяет, что со строки начинается HTML-блок.
+func isHTMLBlockStart(line string) bool {
+	if strings.HasPrefix(strings.TrimLeft(line, " "), "<!--") && lineIndent(line) < 4 {
+		return true
+	}
+	m := markdownHTMLBlockTag.FindStringSubmatch(line)
+	return m != nil && markdownBlockTags[strings.ToLower(m[2])]
+}
+
+// renderHTMLBlock переносит HTML-блок в результат без изменений.
+func renderHTMLBlock(b *strings.Builder, lines []string, i int) int {
+	trimmed := strings.TrimLeft(lines[i], " ")
+	end := ""
+	if strings.HasPrefix(trimmed, "<!--") {
+		end = "-->"
+	} else {
+		m := markdownHTMLBlockTag.FindStringSubmatch(lines[i])
+		switch tag := strings.ToLower(m[2]); tag {
+		case "pre", "script", "style", "textarea":
+			if len(m[1]) == 0 {
+				end = "</" + tag + ">"
+			}
+		}
+	}
+
+	for ; i < len(lines); i++ {
+		if len(end) == 0 && isBlankLine(lines[i]) {
+			break
+		}
+		b.WriteString(lines[i] + "\n")
+		if len(end) > 0 && strings.Contains(strings.ToLower(lines[i]), end) {
+			i++
+			break
+		}
+	}
+
+	return i
+}
+
+// renderFencedCode формирует блок кода, ограниченный ``` или ~~~.
+func (r *markdownRenderer) renderFencedCode(b *strings.Builder, lines []string, i int) int {
+	m := markdownFence.FindStringSubmatch(lines[i])
+	indent := len(m[1])
+	fence := m[2]
+	info := strings.Fields(m[3])
+
+	var code []string
+	for i++; i < len(lines); i++ {
+		if c := markdownFence.FindStringSubmatch(lines[i]); c != nil && c[2][0] == fence[0] && len(c[2]) >= len(fence) && len(strings.TrimSpace(c[3])) == 0 {
+			i++
+			break
+		}
+		line := lines[i]
+		if n := lineIndent(line); n < indent {
+			line = line[n:]
+		} else {
+			line = line[indent:]
+		}
+		code = append(code, line)
+	}
+
+	b.WriteString("<pre><code")
+	if len(info) > 0 {
+		b.WriteString(` class="language-` + escapeMarkdownAttr(unescapeMarkdown(info[0])) + `"`)
+	}
+	b.WriteString(">")
+	for _, line := range code {
+		b.WriteString(escapeMarkdownText(line) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+
+	return i
+}
+
+// renderIndentedCode формирует блок кода, заданный отступом в 4 пробела.
+func (r *markdownRenderer) renderIndentedCode(b *strings.Builder, lines []string, i int) int {
+	var code []string
+	for ; i < len(lines); i++ {
+		if isBlankLine(lines[i]) {
+			code = append(code, "")
+			continue
+		}
+		if lineIndent(lines[i]) < 4 {
+			break
+		}
+		code = append(code, lines[i][4:])
+	}
+	for len(code) > 0 && code[len(code)-1] == "" {
+		code = code[:len(code)-1]
+	}
+
+	b.WriteString("<pre><code>")
+	for _, line := range code {
+		b.WriteString(escapeMarkdownText(line) + "\n")
+	}
+	b.WriteString("</code></pre>\n")
+
+	return i
+}
+
+// writeHeading формирует заголовок с якорем.
+func (r *markdownRenderer) writeHeading(b *strings.Builder, level int, text string) {
+	id := ""
+	if m := markdownHeadingID.FindStringSubmatch(text); m != nil {
+		id = m[1]
+		text = text[:len(text)-len(m[0])]
+	}
+	content := r.renderInline(strings.TrimSpace(text))
+	if len(id) == 0 {
+		id = HeadingSlug(stripMarkdownTags(content))
+	}
+	id = r.uniqueID(id)
+
+	fmt.Fprintf(b, "<h%d id=\"%s\">%s</h%d>\n", level, escapeMarkdownAttr(id), content, level)
+}
+
+// uniqueID возвращает якорь, не совпадающий с ранее использованными якорями документа.
+func (r *markdownRenderer) uniqueID(id string) string {
+	n, ok := r.ids[id]
+	r.ids[id] = n + 1
+	if !ok {
+		return id
+	}
+	for {
+		candidate := id + "-" + strconv.Itoa(n)
+		if _, used := r.ids[candidate]; !used {
+			r.ids[candidate] = 1
+			return candidate
+		}
+		n++
+	}
+}
+
+// HeadingSlug формирует якорь заголовка из его текста: буквы и цифры (в том числе кириллица)
+// приводятся к нижнему регистру, пробелы и дефисы заменяются дефисом, остальные символы отбрасываются.
+func HeadingSlug(text string) string {
+	var b strings.Builder
+	dash := false
+	for _, c := range strings.ToLower(text) {
+		switch {
+		case unicode.IsLetter(c) || unicode.IsDigit(c):
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(c)
+		case c == ' ' || c == '-' || c == '_' || unicode.IsSpace(c):
+			dash = true
+		}
+	}
+	if b.Len() == 0 {
+		return "section"
+	}
+
+	return b.String()
+}
+
+// stripMarkdownTags убирает теги из HTML и заменяет основные сущности символами.
+func stripMarkdownTags(html string) string {
+	var b strings.Builder
+	inTag := false
+	for _, c := range html {
+		switch {
+		case c == '<':
+			inTag = true
+		case c == '>' && inTag:
+			inTag = false
+		case !inTag:
+			b.WriteRune(c)
+		}
+	}
+
+	return strings.NewReplacer("&amp;", "&", "&lt;", "<", "&gt;", ">", "&quot;", `"`).Replace(b.String())
+}
+
+// renderBlockquote формирует цитату.
+func (r *markdownRenderer) renderBlockquote(b *strings.Builder, lines []string, i int) int {
+	var quote []string
+	for ; i < len(lines); i++ {
+		trimmed := strings.TrimLeft(lines[i], " ")
+		if strings.HasPrefix(trimmed, ">") && lineIndent(lines[i]) < 4 {
+			trimmed = strings.TrimPrefix(trimmed, ">")
+			trimmed = strings.TrimPrefix(trimmed, " ")
+			quote = append(quote, trimmed)
+			continue
+		}
+		// Ленивое продолжение абзаца цитаты.
+		if !isBlankLine(lines[i]) && len(quote) > 0 && !isBlankLine(quote[len(quote)-1]) && !startsBlock(lines, i) {
+			quote = append(quote, lines[i])
+			continue
+		}
+		break
+	}
+
+	b.WriteString("<blockquote>\n")
+	b.WriteString(r.renderBlocks(quote, false))
+	b.WriteString("</blockquote>\n")
+
+	return i
+}
+
+// listMarker описывает маркер элемента списка.
+type listMarker struct {
+	ordered bool
+	// Символ маркера: -, *, + для маркированного списка, . или ) для нумерованного.
+	char    byte
+	start   int
+	content int
+	text    string
+}
+
+// parseListMarker разбирает маркер элемента списка в начале строки.
+func parseListMarker(line string) (listMarker, bool) {
+	if markdownThematic.MatchString(line) {
+		return listMarker{}, false
+	}
+
+	marker := listMarker{}
+	var indent, width int
+	var spaces, rest string
+	if m := markdownBullet.FindStringSubmatch(line); m != nil {
+		marker.char = m[2][0]
+		indent, width, spaces, rest = len(m[1]), 1, m[3], m[4]
+	} else if m := markdownOrdered.FindStringSubmatch(line); m != nil {
+		marker.ordered = true
+		marker.char = m[3][0]
+		marker.start, _ = strconv.Atoi(m[2])
+		indent, width, spaces, rest = len(m[1]), len(m[2])+1, m[4], m[5]
+	} else {
+		return listMarker{}, false
+	}
+
+	switch {
+	case len(strings.TrimSpace(rest)) == 0:
+		marker.content = indent + width + 1
+	case len(spaces) > 4:
+		// Код с отступом внутри элемента списка: маркер отделяется одним пробелом.
+		marker.content = indent + width + 1
+		rest = spaces[1:] + rest
+	default:
+		marker.content = indent + width + len(spaces)
+	}
+	marker.text = rest
+
+	return marker, true
+}
+
+// sameList проверяет, что маркер продолжает список с маркером first.
+func (m listMarker) sameList(first listMarker) bool {
+	return m.ordered == first.ordered && m.char == first.char
+}
+
+func isListStart(line string) bool {
+	_, ok := parseListMarker(line)
+	return ok
+}
+
+// renderList формирует маркированный или нумерованный список.
+func (r *markdownRenderer) renderList(b *strings.Builder, lines []string, i int) int {
+	first, _ := parseListMarker(lines[i])
+	loose := false
+	var items [][]string
+
+	for i < len(lines) {
+		marker, ok := parseListMarker(lines[i])
+		if !ok || !marker.sameList(first) {
+			break
+		}
+		item := []string{marker.text}
+		i++
+
+		for i < len(lines) {
+			line := lines[i]
+			if isBlankLine(line) {
+				j := i
+				for j < len(lines) && isBlankLine(lines[j]) {
+					j++
+				}
+				if j < len(lines) && lineIndent(lines[j]) >= marker.content {
+					for ; i < j; i++ {
+						item = append(item, "")
+					}
+					loose = true
+					continue
+				}
+				break
+			}
+			if lineIndent(line) >= marker.content {
+				item = append(item, line[marker.content:])
+				i++
+				continue
+			}
+			if isListStart(line) || startsBlock(lines, i) {
+				break
+			}
+			// Ленивое продолжение абзаца элемента.
+			if len(item) > 0 && !isBlankLine(item[len(item)-1]) {
+				item = append(item, strings.TrimLeft(line, " "))
+				i++
+				continue
+			}
+			break
+		}
+		items = append(items, item)
+
+		// Пустые строки между элементами делают список разреженным.
+		j := i
+		for j < len(lines) && isBlankLine(lines[j]) {
+			j++
+		}
+		if j == i {
+			continue
+		}
+		if next, ok := parseListMarker(safeLine(lines, j)); ok && next.sameList(first) {
+			loose = true
+			i = j
+			continue
+		}
+		break
+	}
+
+	tag := "ul"
+	if first.ordered {
+		tag = "ol"
+	}
+	b.WriteString("<" + tag)
+	if first.ordered && first.start != 1 {
+		b.WriteString(` start="` + strconv.Itoa(first.start) + `"`)
+	}
+	b.WriteString(">\n")
+	for _, item := range items {
+		content := r.renderBlocks(item, !loose)
+		if loose {
+			b.WriteString("<li>\n" + content + "</li>\n")
+		} else {
+			b.WriteString("<li>" + strings.TrimSuffix(content, "\n") + "</li>\n")
+		}
+	}
+	b.WriteString("</" + tag + ">\n")
+
+	return i
+}
+
+func safeLine(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+
+	return ""
+}
+
+// splitTableRow разбивает строку таблицы на ячейки.
+func splitTableRow(line string) []string {
+	line = strings.TrimSpace(line)
+	line = strings.TrimPrefix(line, "|")
+	if strings.HasSuffix(line, "|") && !strings.HasSuffix(line, `\|`) {
+		line = line[:len(line)-1]
+	}
+
+	var cells []string
+	var cell strings.Builder
+	code := 0
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\\' && i+1 < len(line) && line[i+1] == '|':
+			cell.WriteByte('|')
+			i++
+		case c == '`':
+			code ^= 1
+			cell.WriteByte(c)
+		case c == '|' && code == 0:
+			cells = append(cells, strings.TrimSpace(cell.String()))
+			cell.Reset()
+		default:
+			cell.WriteByte(c)
+		}
+	}
+	cells = append(cells, strings.TrimSpace(cell.String()))
+
+	return cells
+}
+
+// isTableStart проверяет, что со строки i начинается таблица: строка заголовков и строка выравнивания.
+func isTableStart(lines []string, i int) bool {
+	if i+1 >= len(lines) || !strings.Contains(lines[i], "|") || lineIndent(lines[i]) >= 4 {
+		return false
+	}
+	delimiter := lines[i+1]
+	if !strings.Contains(delimiter, "-") || strings.Trim(delimiter, " |:-") != "" {
+		return false
+	}
+	cells := splitTableRow(delimiter)
+	for _, cell := range cells {
+		if !markdownTableDelim.MatchString(cell) {
+			return false
+		}
+	}
+
+	return len(cells) == len(splitTableRow(lines[i]))
+}
+
+// renderTable формирует таблицу.
+func (r *markdownRenderer) renderTable(b *strings.Builder, lines []string, i int) int {
+	header := splitTableRow(lines[i])
+	var align []string
+	for _, cell := range splitTableRow(lines[i+1]) {
+		left := strings.HasPrefix(cell, ":")
+		right := strings.HasSuffix(cell, ":")
+		switch {
+		case left && right:
+			align = append(align, "center")
+		case right:
+			align = append(align, "right")
+		case left:
+			align = append(align, "left")
+		default:
+			align = append(align, "")
+		}
+	}
+
+	writeRow := func(cells []string, tag string) {
+		b.WriteString("<tr>\n")
+		for k := range header {
+			b.WriteString("<" + tag)
+			if len(align[k]) > 0 {
+				b.WriteString(` style="text-align: ` + align[k] + `"`)
+			}
+			b.WriteString(">")
+			if k < len(cells) {
+				b.WriteString(r.renderInline(cells[k]))
+			}
+			b.WriteString("</" + tag + ">\n")
+		}
+		b.WriteString("</tr>\n")
+	}
+
+	b.WriteString("<table>\n<thead>\n")
+	writeRow(header, "th")
+	b.WriteString("</thead>\n")
+
+	i += 2
+	body := false
+	for ; i < len(lines); i++ {
+		if isBlankLine(lines[i]) || (startsBlock(lines, i) && !strings.Contains(lines[i], "|")) {
+			break
+		}
+		if !body {
+			b.WriteString("<tbody>\n")
+			body = true
+		}
+		writeRow(splitTableRow(lines[i]), "td")
+	}
+	if body {
+		b.WriteString("</tbody>\n")
+	}
+	b.WriteString("</table>\n")
+
+	return i
+}
+
+// startsBlock проверяет, что строка i прерывает абзац и начинает новый блок.
+func startsBlock(lines []string, i int) bool {
+	line := lines[i]
+	if lineIndent(line) >= 4 {
+		return false
+	}
+	if isTemplateLine(line) || markdownFence.MatchString(line) || markdownATXHeading.MatchString(line) ||
+		markdownThematic.MatchString(line) || strings.HasPrefix(strings.TrimLeft(line, " "), ">") ||
+		isHTMLBlockStart(line) || isTableStart(lines, i) {
+		return true
+	}
+	// Прервать абзац может только непустой элемент списка, нумерованный список — только с 1.
+	if marker, ok := parseListMarker(line); ok && len(strings.TrimSpace(marker.text)) > 0 {
+		return !marker.ordered || marker.start == 1
+	}
+
+	return false
+}
+
+// renderParagraph формирует абзац или заголовок, подчёркнутый символами = или -.
+func (r *markdownRenderer) renderParagraph(b *strings.Builder, lines []string, i int, tight bool) int {
+	var text []string
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		if isBlankLine(line) {
+			break
+		}
+		if len(text) > 0 {
+			if m := markdownSetext.FindStringSubmatch(line); m != nil {
+				level := 1
+				if m[1][0] == '-' {
+					level = 2
+				}
+				r.writeHeading(b, level, strings.Join(text, "\n"))
+				return i + 1
+			}
+			if startsBlock(lines, i) {
+				break
+			}
+		}
+		text = append(text, strings.TrimLeft(line, " "))
+	}
+
+	content := r.renderInline(strings.Join(text, "\n"))
+	if tight {
+		b.WriteString(content + "\n")
+	} else {
+		b.WriteString("<p>" + content + "</p>\n")
+	}
+
+	return i
+}
+
+// renderFootnotes формирует список сносок, на которые есть ссылки в документе.
+func (r *markdownRenderer) renderFootnotes() string {
+	if len(r.noteOrder) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("<section class=\"footnotes\">\n<ol>\n")
+	// Текст сноски может ссылаться на другие сноски, поэтому список может расти в процессе обхода.
+	for k := 0; k < len(r.noteOrder); k++ {
+		label := r.noteOrder[k]
+		id := escapeMarkdownAttr(footnoteID(label))
+		content := r.renderBlocks(r.notes[label], false)
+		backref := ""
+		for n := 1; n <= r.noteRefs[label]; n++ {
+			ref := "fnref-" + id
+			if n > 1 {
+				ref += "-" + strconv.Itoa(n)
+			}
+			backref += ` <a href="#` + ref + `" class="footnote-backref">&#8617;</a>`
+		}
+		if strings.HasSuffix(content, "</p>\n") {
+			content = strings.TrimSuffix(content, "</p>\n") + backref + "</p>\n"
+		} else {
+			content += backref + "\n"
+		}
+		b.WriteString(`<li id="fn-` + id + "\">\n" + content + "</li>\n")
+	}
+	b.WriteString("</ol>\n</section>\n")
+
+	return b.String()
+}
+
+// footnoteID формирует якорь сноски из её метки.
+func footnoteID(label string) string {
+	return HeadingSlug(label)
+}
+
+// renderInline преобразует строчные элементы текста.
+func (r *markdownRenderer) renderInline(text string) string {
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '\\' && i+1 < len(text) && text[i+1] == '\n':
+			b.WriteString("<br />\n")
+			i += 2
+
+		case c == '\\' && i+1 < len(text) && isMarkdownPunct(text[i+1]):
+			b.WriteString(escapeMarkdownText(text[i+1 : i+2]))
+			i += 2
+
+		case c == '\n':
+			// Два пробела в конце строки — принудительный перевод строки.
+			out := b.String()
+			trimmed := strings.TrimRight(out, " ")
+			b.Reset()
+			b.WriteString(trimmed)
+			if len(out)-len(trimmed) >= 2 {
+				b.WriteString("<br />")
+			}
+			b.WriteString("\n")
+			i++
+			for i < len(text) && text[i] == ' ' {
+				i++
+			}
+
+		case c == '{' && strings.HasPrefix(text[i:], "{{"):
+			// Действие шаблона переносится без изменений.
+			end := strings.Index(text[i+2:], "}}")
+			if end < 0 {
+				b.WriteString("{{")
+				i += 2
+				continue
+			}
+			b.WriteString(text[i : i+2+end+2])
+			i += 2 + end + 2
+
+		case c == '`':
+			n := runLength(text, i, '`')
+			end := findCodeSpanEnd(text, i+n, n)
+			if end < 0 {
+				b.WriteString(text[i : i+n])
+				i += n
+				continue
+			}
+			code := strings.ReplaceAll(text[i+n:end], "\n", " ")
+			if len(code) > 2 && code[0] == ' ' && code[len(code)-1] == ' ' && strings.Trim(code, " ") != "" {
+				code = code[1 : len(code)-1]
+			}
+			b.WriteString("<code>" + escapeMarkdownText(code) + "</code>")
+			i = end + n
+
+		case c == '*' || c == '_' || (c == '~' && strings.HasPrefix(text[i:], "~~")):
+			n := r.renderEmphasis(&b, text, i)
+			i += n
+
+		case c == '!' && strings.HasPrefix(text[i:], "!["):
+			if n, ok := r.renderLink(&b, text, i+1, true); ok {
+				i += 1 + n
+				continue
+			}
+			b.WriteString("!")
+			i++
+
+		case c == '[':
+			if n, ok := r.renderFootnoteRef(&b, text, i); ok {
+				i += n
+				continue
+			}
+			if n, ok := r.renderLink(&b, text, i, false); ok {
+				i += n
+				continue
+			}
+			b.WriteString("[")
+			i++
+
+		case c == '<':
+			rest := text[i:]
+			if m := markdownAutolink.FindStringSubmatch(rest); m != nil {
+				b.WriteString(`<a href="` + escapeMarkdownAttr(m[1]) + `">` + escapeMarkdownText(m[1]) + "</a>")
+				i += len(m[0])
+			} else if m := markdownEmailLink.FindStringSubmatch(rest); m != nil {
+				b.WriteString(`<a href="mailto:` + escapeMarkdownAttr(m[1]) + `">` + escapeMarkdownText(m[1]) + "</a>")
+				i += len(m[0])
+			} else if m := markdownInlineTag.FindString(rest); len(m) > 0 {
+				b.WriteString(m)
+				i += len(m)
+			} else {
+				b.WriteString("&lt;")
+				i++
+			}
+
+		case c == '&':
+			if m := markdownEntity.FindString(text[i:]); len(m) > 0 {
+				b.WriteString(m)
+				i += len(m)
+			} else {
+				b.WriteString("&amp;")
+				i++
+			}
+
+		case c == '>':
+			b.WriteString("&gt;")
+			i++
+
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+
+	return strings.TrimRight(b.String(), " ")
+}
+
+// isMarkdownPunct проверяет, что символ может быть экранирован обратной косой чертой.
+func isMarkdownPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
+
+// runLength возвращает длину последовательности символов c, начинающейся с позиции i.
+func runLength(text string, i int, c byte) int {
+	n := 0
+	for i+n < len(text) && text[i+n] == c {
+		n++
+	}
+
+	return n
+}
+
+// findCodeSpanEnd ищет закрывающую последовательность из n обратных апострофов.
+func findCodeSpanEnd(text string, from int, n int) int {
+	for i := from; i < len(text); {
+		if text[i] != '`' {
+			i++
+			continue
+		}
+		m := runLength(text, i, '`')
+		if m == n {
+			return i
+		}
+		i += m
+	}
+
+	return -1
+}
+
+// isFlankingSpace проверяет, что символ отсутствует или является пробельным.
+func isFlankingSpace(text string, i int) bool {
+	if i < 0 || i >= len(text) {
+		return true
+	}
+	c, _ := utf8.DecodeRuneInString(text[i:])
+	if i > 0 && !utf8.RuneStart(text[i]) {
+		c, _ = utf8.DecodeLastRuneInString(text[:i+1])
+	}
+
+	return unicode.IsSpace(c)
+}
+
+// isWordAt проверяет, что в позиции i находится буква или цифра.
+func isWordAt(text string, i int, before bool) bool {
+	if i < 0 || i >= len(text) {
+		return false
+	}
+	var c rune
+	if before {
+		c, _ = utf8.DecodeLastRuneInString(text[:i+1])
+	} else {
+		c, _ = utf8.DecodeRuneInString(text[i:])
+	}
+
+	return unicode.IsLetter(c) || unicode.IsDigit(c)
+}
+
+// findEmphasisEnd ищет закрывающую последовательность из n символов c, начиная с позиции from.
+// Вложенные выделения другой длины пропускаются.
+func findEmphasisEnd(text string, from int, c byte, n int) int {
+	for j := from; j < len(text); {
+		switch text[j] {
+		case '\\':
+			j += 2
+			continue
+		case '`':
+			m := runLength(text, j, '`')
+			if end := findCodeSpanEnd(text, j+m, m); end >= 0 {
+				j = end + m
+			} else {
+				j += m
+			}
+			continue
+		case c:
+		default:
+			j++
+			continue
+		}
+
+		m := runLength(text, j, c)
+		left := !isFlankingSpace(text, j+m)
+		right := !isFlankingSpace(text, j-1)
+		if c == '_' {
+			// Подчёркивание внутри слова не является выделением.
+			left = left && !isWordAt(text, j-1, true)
+			right = right && !isWordAt(text, j+m, false)
+		}
+		switch {
+		case right && m == n:
+			return j
+		case left:
+			end := findEmphasisEnd(text, j+m, c, m)
+			if end < 0 {
+				j += m
+			} else {
+				j = end + m
+			}
+		case right && m > n:
+			return j
+		default:
+			j += m
+		}
+	}
+
+	return -1
+}
+
+// renderEmphasis формирует выделение, начинающееся в позиции i, и возвращает количество обработанных байт.
+func (r *markdownRenderer) renderEmphasis(b *strings.Builder, text string, i int) int {
+	c := text[i]
+	n := runLength(text, i, c)
+	opening := !isFlankingSpace(text, i+n)
+	if c == '_' && isWordAt(text, i-1, true) {
+		opening = false
+	}
+	if c == '~' && n != 2 {
+		opening = false
+	}
+	if n > 3 || !opening {
+		b.WriteString(text[i : i+n])
+		return n
+	}
+
+	end := findEmphasisEnd(text, i+n, c, n)
+	if end < 0 || end == i+n {
+		b.WriteString(text[i : i+n])
+		return n
+	}
+
+	inner := r.renderInline(text[i+n : end])
+	switch {
+	case c == '~':
+		b.WriteString("<del>" + inner + "</del>")
+	case n == 1:
+		b.WriteString("<em>" + inner + "</em>")
+	case n == 2:
+		b.WriteString("<strong>" + inner + "</strong>")
+	default:
+		b.WriteString("<em><strong>" + inner + "</strong></em>")
+	}
+
+	return end + n - i
+}
+
+// findBracketEnd ищет закрывающую квадратную скобку с учётом вложенности.
+func findBracketEnd(text string, i int) int {
+	depth := 0
+	for j := i; j < len(text); j++ {
+		switch text[j] {
+		case '\\':
+			j++
+		case '`':
+			m := runLength(text, j, '`')
+			if end := findCodeSpanEnd(text, j+m, m); end >= 0 {
+				j = end + m - 1
+			} else {
+				j += m - 1
+			}
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+
+	return -1
+}
+
+// renderFootnoteRef формирует ссылку на сноску [^метка].
+func (r *markdownRenderer) renderFootnoteRef(b *strings.Builder, text string, i int) (int, bool) {
+	if !strings.HasPrefix(text[i:], "[^") {
+		return 0, false
+	}
+	end := strings.IndexByte(text[i:], ']')
+	if end < 0 {
+		return 0, false
+	}
+	label := strings.ToLower(text[i+2 : i+end])
+	if _, ok := r.notes[label]; !ok || len(label) == 0 {
+		return 0, false
+	}
+
+	number, ok := r.noteNumbers[label]
+	if !ok {
+		r.noteOrder = append(r.noteOrder, label)
+		number = len(r.noteOrder)
+		r.noteNumbers[label] = number
+	}
+	r.noteRefs[label]++
+	id := escapeMarkdownAttr(footnoteID(label))
+	ref := "fnref-" + id
+	if r.noteRefs[label] > 1 {
+		ref += "-" + strconv.Itoa(r.noteRefs[label])
+	}
+	fmt.Fprintf(b, `<sup class="footnote-ref" id="%s"><a href="#fn-%s">%d</a></sup>`, ref, id, number)
+
+	return end + 1, true
+}
+
+// parseLinkDestination разбирает адрес и заголовок ссылки в круглых скобках, начиная с позиции i (символ '(').
+func parseLinkDestination(text string, i int) (string, string, int, bool) {
+	j := i + 1
+	for j < len(text) && (text[j] == ' ' || text[j] == '\n') {
+		j++
+	}
+
+	url := ""
+	if j < len(text) && text[j] == '<' {
+		end := strings.IndexAny(text[j+1:], ">\n")
+		if end < 0 || text[j+1+end] != '>' {
+			return "", "", 0, false
+		}
+		url = text[j+1 : j+1+end]
+		j += end + 2
+	} else {
+		start := j
+		depth := 0
+		for ; j < len(text); j++ {
+			ch := text[j]
+			if ch == '\\' && j+1 < len(text) {
+				j++
+				continue
+			}
+			if ch == ' ' || ch == '\n' {
+				break
+			}
+			if ch == '(' {
+				depth++
+			}
+			if ch == ')' {
+				if depth == 0 {
+					break
+				}
+				depth--
+			}
+		}
+		url = text[start:j]
+	}
+
+	for j < len(text) && (text[j] == ' ' || text[j] == '\n') {
+		j++
+	}
+	title := ""
+	if j < len(text) && (text[j] == '"' || text[j] == '\'' || text[j] == '(') {
+		closing := text[j]
+		if closing == '(' {
+			closing = ')'
+		}
+		end := strings.IndexByte(text[j+1:], closing)
+		if end < 0 {
+			return "", "", 0, false
+		}
+		title = text[j+1 : j+1+end]
+		j += end + 2
+		for j < len(text) && (text[j] == ' ' || text[j] == '\n') {
+			j++
+		}
+	}
+	if j >= len(text) || text[j] != ')' {
+		return "", "", 0, false
+	}
+
+	return unescapeMarkdown(url), unescapeMarkdown(title), j + 1 - i, true
+}
+
+// renderLink формирует ссылку или изображение, текст которых начинается в позиции i (символ '[').
+func (r *markdownRenderer) renderLink(b *strings.Builder, text string, i int, image bool) (int, bool) {
+	end := findBracketEnd(text, i)
+	if end < 0 {
+		return 0, false
+	}
+	label := text[i+1 : end]
+	n := end + 1 - i
+
+	var link markdownLink
+	found := false
+	if end+1 < len(text) && text[end+1] == '(' {
+		if url, title, length, ok := parseLinkDestination(text, end+1); ok {
+			link = markdownLink{URL: url, Title: title}
+			n += length
+			found = true
+		}
+	}
+	if !found && end+1 < len(text) && text[end+1] == '[' {
+		if refEnd := strings.IndexByte(text[end+2:], ']'); refEnd >= 0 {
+			ref := text[end+2 : end+2+refEnd]
+			if len(strings.TrimSpace(ref)) == 0 {
+				ref = label
+			}
+			if l, ok := r.links[normalizeLabel(ref)]; ok {
+				link = l
+				n += refEnd + 2
+				found = true
+			}
+		}
+	}
+	if !found {
+		if l, ok := r.links[normalizeLabel(label)]; ok {
+			link = l
+			found = true
+		}
+	}
+	if !found {
+		return 0, false
+	}
+
+	title := ""
+	if len(link.Title) > 0 {
+		title = ` title="` + escapeMarkdownAttr(link.Title) + `"`
+	}
+	if image {
+		alt := stripMarkdownTags(r.renderInline(label))
+		b.WriteString(`<img src="` + escapeMarkdownAttr(link.URL) + `" alt="` + escapeMarkdownAttr(alt) + `"` + title + ` />`)
+	} else {
+		b.WriteString(`<a href="` + escapeMarkdownAttr(link.URL) + `"` + title + `>` + r.renderInline(label) + `</a>`)
+	}
+
+	return n, true
+}
+
+// unescapeMarkdown убирает обратную косую черту перед экранированными символами.
+func unescapeMarkdown(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && isMarkdownPunct(s[i+1]) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+
+	return b.String()
+}
+
+// escapeMarkdownText экранирует специальные символы HTML в тексте.
+func escapeMarkdownText(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// escapeMarkdownAttr экранирует специальные символы HTML в значении атрибута.
+func escapeMarkdownAttr(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", `"`, "&quot;").Replace(s)
+}
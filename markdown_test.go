@@ -0,0 +1,230 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderMarkdown_HeadingAnchors(t *testing.T) {
+	t.Parallel()
+
+	html := RenderMarkdown("# Привет, мир!\n\n## Привет, мир!\n\nЗаголовок\n---------\n\n### Свой якорь {#custom}\n")
+	expected := `<h1 id="привет-мир">Привет, мир!</h1>
+<h2 id="привет-мир-1">Привет, мир!</h2>
+<h2 id="заголовок">Заголовок</h2>
+<h3 id="custom">Свой якорь</h3>
+`
+	if html != expected {
+		t.Fatalf("заголовки:\n%s\nожидалось:\n%s", html, expected)
+	}
+}
+
+func TestRenderMarkdown_FencedCode(t *testing.T) {
+	t.Parallel()
+
+	html := RenderMarkdown("```go\nif a < b && c {\n\n    *x* = 1\n}\n```\n\n~~~\n# не заголовок\n~~~\n")
+	expected := `<pre><code class="language-go">if a &lt; b &amp;&amp; c {
+
+    *x* = 1
+}
+</code></pre>
+<pre><code># не заголовок
+</code></pre>
+`
+	if html != expected {
+		t.Fatalf("блоки кода:\n%s\nожидалось:\n%s", html, expected)
+	}
+}
+
+func TestRenderMarkdown_Table(t *testing.T) {
+	t.Parallel()
+
+	html := RenderMarkdown("| Имя | Число |\n|:----|------:|\n| a \\| b | `1|2` |\n| *c* |\n")
+	expected := `<table>
+<thead>
+<tr>
+<th style="text-align: left">Имя</th>
+<th style="text-align: right">Число</th>
+</tr>
+</thead>
+<tbody>
+<tr>
+<td style="text-align: left">a | b</td>
+<td style="text-align: right"><code>1|2</code></td>
+</tr>
+<tr>
+<td style="text-align: left"><em>c</em></td>
+<td style="text-align: right"></td>
+</tr>
+</tbody>
+</table>
+`
+	if html != expected {
+		t.Fatalf("таблица:\n%s\nожидалось:\n%s", html, expected)
+	}
+}
+
+func TestRenderMarkdown_Footnotes(t *testing.T) {
+	t.Parallel()
+
+	html := RenderMarkdown("Текст[^a] и ещё[^b], снова[^a].\n\n[^b]: Вторая\n    сноска.\n[^a]: Первая.\n[^unused]: Лишняя.\n")
+	expected := `<p>Текст<sup class="footnote-ref" id="fnref-a"><a href="#fn-a">1</a></sup> и ещё<sup class="footnote-ref" id="fnref-b"><a href="#fn-b">2</a></sup>, снова<sup class="footnote-ref" id="fnref-a-2"><a href="#fn-a">1</a></sup>.</p>
+<section class="footnotes">
+<ol>
+<li id="fn-a">
+<p>Первая. <a href="#fnref-a" class="footnote-backref">&#8617;</a> <a href="#fnref-a-2" class="footnote-backref">&#8617;</a></p>
+</li>
+<li id="fn-b">
+<p>Вторая
+сноска. <a href="#fnref-b" class="footnote-backref">&#8617;</a></p>
+</li>
+</ol>
+</section>
+`
+	if html != expected {
+		t.Fatalf("сноски:\n%s\nожидалось:\n%s", html, expected)
+	}
+}
+
+func TestRenderMarkdown_BlocksAndInline(t *testing.T) {
+	t.Parallel()
+
+	src := "{{template \"header\" .}}\n\n" +
+		"Текст *курсив*, **жирный**, _em_, snake_case, ~~del~~, [ссылка](http://example.test \"T\"), [по ссылке][ref], ![рис](a.png) & <b>html</b> < 3 {{.Site.Title}}.\n\n" +
+		"- один\n- два\n  - вложенный\n\n" +
+		"3. три\n4. четыре\n\n" +
+		"> цитата\n\n" +
+		"<div class=\"raw\">\n*как есть*\n</div>\n\n" +
+		"---\n\n" +
+		"[ref]: http://ref.test\n"
+	expected := `{{template "header" .}}
+<p>Текст <em>курсив</em>, <strong>жирный</strong>, <em>em</em>, snake_case, <del>del</del>, <a href="http://example.test" title="T">ссылка</a>, <a href="http://ref.test">по ссылке</a>, <img src="a.png" alt="рис" /> &amp; <b>html</b> &lt; 3 {{.Site.Title}}.</p>
+<ul>
+<li>один</li>
+<li>два
+<ul>
+<li>вложенный</li>
+</ul></li>
+</ul>
+<ol start="3">
+<li>три</li>
+<li>четыре</li>
+</ol>
+<blockquote>
+<p>цитата</p>
+</blockquote>
+<div class="raw">
+*как есть*
+</div>
+<hr />
+`
+	if html := RenderMarkdown(src); html != expected {
+		t.Fatalf("документ:\n%s\nожидалось:\n%s", html, expected)
+	}
+}
+
+func TestMarkdownContent(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	xmlPath := filepath.Join(dir, "post.xml")
+
+	content, source, err := markdownContent(xmlPath, "", "<p>html</p>")
+	if err != nil || content != "<p>html</p>" || source != "" {
+		t.Fatalf("текст html = %q, %q, %v", content, source, err)
+	}
+
+	content, _, err = markdownContent(xmlPath, "markdown", "\n    # Заголовок\n\n    Текст\n  ")
+	if err != nil || content != "<h1 id=\"заголовок\">Заголовок</h1>\n<p>Текст</p>\n" {
+		t.Fatalf("текст markdown = %q, %v", content, err)
+	}
+
+	if _, _, err = markdownContent(xmlPath, "rst", ""); err == nil {
+		t.Fatalf("неизвестный формат должен быть ошибкой")
+	}
+
+	writeTestFile(t, filepath.Join(dir, "post.md"), "**из файла**")
+	content, source, err = markdownContent(xmlPath, "", "<p>html</p>")
+	if err != nil || content != "<p><strong>из файла</strong></p>\n" || source != filepath.Join(dir, "post.md") {
+		t.Fatalf("текст из файла .md = %q, %q, %v", content, source, err)
+	}
+}
+
+func TestLoadBlog_MarkdownPost(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	tags := &TagsList{Tags: []Tag{{Id: 1, Name: "Новости"}}}
+	writeTestFile(t, filepath.Join(dir, "inline.xml"), `<post><date>01.01.2021</date><tagid>1</tagid><title>Курсив</title><format>markdown</format><content>
+		*курсив*
+	</content></post>`)
+	writeBlogPostXML(t, dir, "sidecar.xml", 1, "02.01.2021", "Файл")
+	writeTestFile(t, filepath.Join(dir, "sidecar.md"), "## Из файла")
+
+	posts, _, err := loadBlog(dir, tags, nil)
+	if err != nil {
+		t.Fatalf("loadBlog вернул ошибку: %v", err)
+	}
+	if (*posts)[0].Content != "<h2 id=\"из-файла\">Из файла</h2>\n" || (*posts)[0].MarkdownSource != filepath.Join(dir, "sidecar.md") {
+		t.Fatalf("пост с файлом .md: %q, %q", (*posts)[0].Content, (*posts)[0].MarkdownSource)
+	}
+	if (*posts)[1].Content != "<p><em>курсив</em></p>\n" {
+		t.Fatalf("пост в формате markdown: %q", (*posts)[1].Content)
+	}
+	if sources := postSources(*posts); len(sources) != 3 {
+		t.Fatalf("исходные файлы постов = %v", sources)
+	}
+}
+
+func TestLoadArticles_MarkdownPages(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "guide.xml"), `<article><title>Руководство</title><pages>Первая|Вторая</pages></article>`)
+	writeTestFile(t, filepath.Join(dir, "guide", "1.html"), "<p>html</p>")
+	writeTestFile(t, filepath.Join(dir, "guide", "2.md"), "# Вторая")
+
+	articles, err := loadArticles(dir, nil)
+	if err != nil {
+		t.Fatalf("loadArticles вернул ошибку: %v", err)
+	}
+	article := (*articles)[0]
+	if article.Content[0] != "<p>html</p>" || article.Content[1] != "<h1 id=\"вторая\">Вторая</h1>\n" {
+		t.Fatalf("контент страниц = %q", article.Content)
+	}
+	if article.Pagesources[1] != filepath.Join(dir, "guide", "2.md") {
+		t.Fatalf("исходные файлы страниц = %v", article.Pagesources)
+	}
+}
+
+func TestHandleParseFile_MarkdownPage(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "layout.tmpl"), `{{define "header"}}<html>{{end}}`)
+	page := filepath.Join(cfg.Source, "docs", "intro.md")
+	writeTestFile(t, page, "{{template \"header\" .}}\n\n# Введение\n\nСтраница {{.Fuseaction}}\n")
+
+	site, err := LoadSite(cfg)
+	if err != nil {
+		t.Fatalf("LoadSite вернул ошибку: %v", err)
+	}
+	sitemap := NewSitemap(cfg)
+	output := newTestOutput(t, cfg.Destination)
+	if err = handleParseFile(site, page, sitemap, nil, nil, output); err != nil {
+		t.Fatalf("handleParseFile вернул ошибку: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(cfg.Destination, "docs", "intro.html"))
+	if err != nil {
+		t.Fatalf("не удалось прочитать страницу: %v", err)
+	}
+	expected := "<html>\n<h1 id=\"введение\">Введение</h1>\n<p>Страница docs-intro.html</p>\n"
+	if string(content) != expected {
+		t.Fatalf("страница = %q, ожидалось %q", string(content), expected)
+	}
+	if entries := sitemap.Entries(); len(entries) != 1 || entries[0].Loc != "https://example.test/docs/intro.html" {
+		t.Fatalf("sitemap = %+v", entries)
+	}
+}
@@ -0,0 +1,332 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Команда new: заготовки постов, публикаций и записей Вопросы и ответы.
+// googol new post|article|qa -source=<исходная корневая директория> создаёт в директории __blog, __articles
+// или __qa файл записи с сегодняшней датой в формате 02.01.2006. Идентификатор записи (fuseaction) задаётся
+// параметром -name, по умолчанию он составляется из сегодняшней даты (20061231, 20061231-2, ...)
+// и не совпадает с идентификаторами существующих записей раздела.
+// Параметр -format=md создаёт вместо xml-файла файл Markdown с заголовком (см. frontmatter.go).
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Форматы заготовок.
+const (
+	ScaffoldXML      = "xml"
+	ScaffoldMarkdown = "md"
+)
+
+// newFlags описывает параметры команды new.
+type newFlags struct {
+	//исходная корневая директория
+	source *string
+	//файл конфигурации сайта
+	configFile *string
+	//заголовок поста или публикации, вопрос записи Вопросы и ответы
+	title *string
+	//идентификатор записи
+	name *string
+	//id рубрики поста
+	tag *int
+	//формат заготовки: xml или md
+	format *string
+}
+
+// addNewFlags добавляет в набор флагов параметры команды new.
+func addNewFlags(flagSet *flag.FlagSet) *newFlags {
+	return &newFlags{
+		source:     flagSet.String("source", "", "Укажите исходную директорию"),
+		configFile: flagSet.String("config", "", "Файл конфигурации сайта (по умолчанию __settings/site.toml)"),
+		title:      flagSet.String("title", "", "Заголовок поста или публикации, вопрос записи Вопросы и ответы"),
+		name:       flagSet.String("name", "", "Идентификатор записи (по умолчанию составляется из сегодняшней даты)"),
+		tag:        flagSet.Int("tag", 0, "id рубрики поста (по умолчанию первая рубрика из tags.xml)"),
+		format:     flagSet.String("format", ScaffoldXML, "Формат заготовки: xml или md"),
+	}
+}
+
+// scaffold описывает создаваемую заготовку.
+type scaffold struct {
+	// Вид записи: post, article или qa.
+	Kind string
+	// Заголовок поста или публикации, вопрос записи Вопросы и ответы.
+	Title string
+	// Идентификатор записи, пустая строка — составить из даты.
+	Name string
+	// id рубрики поста, 0 — первая рубрика из tags.xml.
+	Tag    int
+	Format string
+	Date   time.Time
+}
+
+// runNew выполняет команду new.
+func runNew(args []string) error {
+	// Вид записи может предшествовать параметрам или следовать за ними.
+	kind := ""
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		kind, args = args[0], args[1:]
+	}
+	flagSet := newCommandFlagSet("new")
+	flags := addNewFlags(flagSet)
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+	if len(kind) == 0 && flagSet.NArg() > 0 {
+		kind = flagSet.Arg(0)
+	}
+	if len(kind) == 0 {
+		return &usageError{ErrorMessages["required_parameter"] + "post|article|qa"}
+	}
+	if len(*flags.source) == 0 {
+		return &usageError{ErrorMessages["required_parameter"] + "source"}
+	}
+
+	cfg, err := LoadConfig(*flags.source, *flags.configFile)
+	if err != nil {
+		return err
+	}
+	files, err := createScaffold(cfg, scaffold{
+		Kind:   kind,
+		Title:  *flags.title,
+		Name:   *flags.name,
+		Tag:    *flags.tag,
+		Format: *flags.format,
+		Date:   time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		fmt.Println("Создан файл " + file)
+	}
+
+	return nil
+}
+
+// createScaffold создаёт файлы заготовки и возвращает их пути.
+func createScaffold(cfg *Config, s scaffold) ([]string, error) {
+	if s.Format != ScaffoldXML && s.Format != ScaffoldMarkdown {
+		return nil, &usageError{fmt.Sprintf("неизвестный формат заготовки %q, ожидается xml или md", s.Format)}
+	}
+
+	var dir string
+	switch s.Kind {
+	case "post":
+		dir = cfg.BlogDir()
+	case "article":
+		dir = cfg.ArticlesDir()
+	case "qa":
+		dir = cfg.QADir()
+	default:
+		return nil, &usageError{fmt.Sprintf("неизвестный вид записи %q, ожидается post, article или qa", s.Kind)}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.New(ErrorMessages["error_creating_dir"] + err.Error())
+	}
+
+	// Посты и записи Вопросы и ответы могут находиться в поддиректориях, публикации — только в корне раздела.
+	taken, err := usedFuseactions(dir, s.Kind != "article")
+	if err != nil {
+		return nil, err
+	}
+	name := s.Name
+	if len(name) == 0 {
+		name = freshFuseaction(s.Date.Format("20060102"), taken)
+	} else if strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return nil, fmt.Errorf("идентификатор записи %q не должен содержать разделителей пути и начинаться с точки", name)
+	} else if taken[name] {
+		return nil, fmt.Errorf("идентификатор записи %q уже используется", name)
+	}
+
+	date := s.Date.Format("02.01.2006")
+	var files []string
+	write := func(path string, content string) error {
+		if err := writeNewFile(path, content); err != nil {
+			return err
+		}
+		files = append(files, path)
+		return nil
+	}
+
+	switch s.Kind {
+	case "post":
+		tag, err := scaffoldTag(cfg, s.Tag)
+		if err != nil {
+			return nil, err
+		}
+		title := defaultString(s.Title, "Новый пост")
+		if s.Format == ScaffoldMarkdown {
+			err = write(filepath.Join(dir, name+".md"), "---\n"+
+				"title: "+strconv.Quote(title)+"\n"+
+				"date: "+date+"\n"+
+				"author: \"\"\n"+
+				"tagid: "+strconv.Itoa(tag)+"\n"+
+				"annotation: \"\"\n"+
+				"short_annotation: \"\"\n"+
+				"---\n\nТекст поста.\n")
+		} else {
+			err = write(filepath.Join(dir, name+".xml"), "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<post>\n"+
+				"\t<date>"+date+"</date>\n"+
+				"\t<author></author>\n"+
+				"\t<tagid>"+strconv.Itoa(tag)+"</tagid>\n"+
+				"\t<title>"+xmlEscape(title)+"</title>\n"+
+				"\t<sites></sites>\n"+
+				"\t<annotation></annotation>\n"+
+				"\t<short_annotation></short_annotation>\n"+
+				"\t<content></content>\n"+
+				"</post>\n")
+		}
+		if err != nil {
+			return nil, err
+		}
+	case "article":
+		title := defaultString(s.Title, "Новая публикация")
+		var err error
+		if s.Format == ScaffoldMarkdown {
+			err = write(filepath.Join(dir, name+".md"), "---\n"+
+				"title: "+strconv.Quote(title)+"\n"+
+				"author: \"\"\n"+
+				"keywords: \"\"\n"+
+				"description: \"\"\n"+
+				"pages: "+strconv.Quote(title)+"\n"+
+				"---\n\nАннотация публикации.\n")
+		} else {
+			err = write(filepath.Join(dir, name+".xml"), "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<article>\n"+
+				"\t<title>"+xmlEscape(title)+"</title>\n"+
+				"\t<author></author>\n"+
+				"\t<annotation></annotation>\n"+
+				"\t<keywords></keywords>\n"+
+				"\t<description></description>\n"+
+				"\t<pages>"+xmlEscape(title)+"</pages>\n"+
+				"</article>\n")
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Первая страница публикации.
+		if err = os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			return nil, errors.New(ErrorMessages["error_creating_dir"] + err.Error())
+		}
+		if s.Format == ScaffoldMarkdown {
+			err = write(filepath.Join(dir, name, "1.md"), "Текст первой страницы.\n")
+		} else {
+			err = write(filepath.Join(dir, name, "1.html"), "<p>Текст первой страницы.</p>\n")
+		}
+		if err != nil {
+			return nil, err
+		}
+	case "qa":
+		question := defaultString(s.Title, "Вопрос")
+		var err error
+		if s.Format == ScaffoldMarkdown {
+			err = write(filepath.Join(dir, name+".md"), "---\n"+
+				"date: "+date+"\n"+
+				"name: \"\"\n"+
+				"question: "+strconv.Quote(question)+"\n"+
+				"---\n\nОтвет.\n")
+		} else {
+			err = write(filepath.Join(dir, name+".xml"), "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<qa>\n"+
+				"\t<date>"+date+"</date>\n"+
+				"\t<name></name>\n"+
+				"\t<question>"+xmlEscape(question)+"</question>\n"+
+				"\t<answer></answer>\n"+
+				"</qa>\n")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// usedFuseactions возвращает идентификаторы записей раздела dir: имена файлов без расширения
+// и имена директорий публикаций. recursive — учитывать файлы поддиректорий.
+func usedFuseactions(dir string, recursive bool) (map[string]bool, error) {
+	taken := map[string]bool{}
+	err := filepath.Walk(dir, func(current string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if current == dir {
+			return nil
+		}
+		name := info.Name()
+		if info.IsDir() {
+			taken[name] = true
+			if !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		taken[strings.TrimSuffix(name, filepath.Ext(name))] = true
+		return nil
+	})
+
+	return taken, err
+}
+
+// freshFuseaction возвращает base или base-N, не совпадающий ни с одним идентификатором из taken.
+func freshFuseaction(base string, taken map[string]bool) string {
+	name := base
+	for n := 2; taken[name]; n++ {
+		name = base + "-" + strconv.Itoa(n)
+	}
+
+	return name
+}
+
+// scaffoldTag возвращает id рубрики нового поста.
+// Если рубрика не указана, выбирается первая рубрика из tags.xml; указанная рубрика должна быть в tags.xml.
+func scaffoldTag(cfg *Config, id int) (int, error) {
+	tags, err := loadTags(cfg.SettingsDir())
+	if err != nil {
+		// Без списка рубрик проверить id нельзя, сборка сообщит о неизвестной рубрике.
+		if id == 0 {
+			return 1, nil
+		}
+		return id, nil
+	}
+	if id == 0 {
+		if len(tags.Tags) == 0 {
+			return 1, nil
+		}
+		return tags.Tags[0].Id, nil
+	}
+	if findTagByID(tags, id) == nil {
+		return 0, fmt.Errorf("рубрика с id=%d не найдена в tags.xml", id)
+	}
+
+	return id, nil
+}
+
+// writeNewFile создаёт файл path; существующий файл не перезаписывается.
+func writeNewFile(path string, content string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return errors.New(ErrorMessages["error_creating_file"] + err.Error())
+	}
+	if _, err = file.WriteString(content); err != nil {
+		file.Close()
+		return errors.New(ErrorMessages["error_creating_file"] + err.Error())
+	}
+
+	return file.Close()
+}
+
+// defaultString возвращает value или fallback, если value пустая строка.
+func defaultString(value string, fallback string) string {
+	if len(value) == 0 {
+		return fallback
+	}
+
+	return value
+}
@@ -0,0 +1,511 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Запись сформированных файлов в целевую директорию только при изменении содержимого.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// ManifestFile — имя файла манифеста сформированных файлов в директории __hash.
+const ManifestFile = "manifest"
+
+// manifestEntry описывает запись манифеста о сформированном файле.
+type manifestEntry struct {
+	Hash string
+	Size int64
+	// Генератор (этап сборки), сформировавший файл.
+	Generator string
+}
+
+// OutputWriter записывает сформированные файлы в целевую директорию.
+// Файл перезаписывается только если его содержимое изменилось, поэтому время модификации
+// неизменённых файлов сохраняется. Сведения о сформированных файлах хранятся в манифесте,
+// файлы, которые были сформированы прошлой сборкой и не сформированы текущей, удаляются.
+// Для страниц, формируемых из шаблонов, ведётся граф зависимостей: страница, у которой не изменились
+// шаблоны, файлы настроек и исходные данные, не формируется заново. Шаблоны __templates разбираются
+// один раз за сборку (см. templates.go).
+// Страницы могут формироваться параллельно: методы Go, Render и Copy передают задачи пулу,
+// количество одновременно выполняемых задач задаётся методом SetJobs.
+// Каждый файл учитывается в отчёте о сборке генератором — этапом сборки (метод Stage), во время которого
+// он передан на формирование (см. report.go).
+// В пробном запуске (метод SetPlan) файлы и директории не записываются, не копируются и не удаляются,
+// а изменения вносятся в план сборки (см. plan.go).
+type OutputWriter struct {
+	mu              sync.Mutex
+	destinationRoot string
+	manifestPath    string
+	previous        map[string]manifestEntry
+	current         map[string]manifestEntry
+	deps            *DependencyGraph
+	pool            *workerPool
+	templates       *TemplateCache
+	// Конфигурация сайта для функций шаблонов AbsURL, RelURL и Asset.
+	cfg *Config
+	// Вывод хода сборки и отчёт о сборке.
+	log    *Logger
+	report *BuildReport
+	// Текущий этап сборки.
+	generator string
+	// План пробного запуска сборки; nil — файлы записываются.
+	plan *BuildPlan
+}
+
+// NewOutputWriter создаёт OutputWriter и загружает манифест прошлой сборки.
+// destinationRoot — целевая директория.
+// hashDir — директория, в которой хранится манифест.
+func NewOutputWriter(destinationRoot string, hashDir string) (*OutputWriter, error) {
+	deps, err := LoadDependencyGraph(hashDir)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &OutputWriter{
+		destinationRoot: destinationRoot,
+		manifestPath:    filepath.Join(hashDir, ManifestFile),
+		previous:        map[string]manifestEntry{},
+		current:         map[string]manifestEntry{},
+		deps:            deps,
+		templates:       NewTemplateCache(),
+	}
+	if w.previous, err = readManifest(w.manifestPath); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// readManifest читает манифест сборки path; отсутствующий манифест пуст.
+// Возвращает записи манифеста по путям относительно целевой директории.
+func readManifest(path string) (map[string]manifestEntry, error) {
+	entries := map[string]manifestEntry{}
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	// Строка манифеста имеет вид: хэш<TAB>размер<TAB>генератор<TAB>путь относительно целевой директории;
+	// в манифесте прежних версий генератора нет.
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), "\t", 4)
+		if len(fields) < 3 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		entry := manifestEntry{Hash: fields[0], Size: size}
+		if len(fields) == 4 {
+			entry.Generator = fields[2]
+		}
+		entries[fields[len(fields)-1]] = entry
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// relPath возвращает путь файла относительно целевой директории в формате манифеста.
+func (w *OutputWriter) relPath(path string) (string, error) {
+	rel, err := filepath.Rel(w.destinationRoot, path)
+	if err != nil {
+		return "", err
+	}
+	rel = filepath.ToSlash(rel)
+	if rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", errors.New("файл находится вне целевой директории: " + path)
+	}
+
+	return rel, nil
+}
+
+// unchanged проверяет, совпадает ли файл на диске с новым содержимым.
+func (w *OutputWriter) unchanged(path string, rel string, entry manifestEntry) bool {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() || info.Size() != entry.Size {
+		return false
+	}
+	if old, ok := w.previous[rel]; ok && old.Hash == entry.Hash && old.Size == entry.Size {
+		return true
+	}
+
+	// Файла нет в манифесте или его хэш изменился — сверяем с содержимым на диске.
+	hash, err := HashFileCrc32WithError(path)
+	return err == nil && hash == entry.Hash
+}
+
+// currentGenerator возвращает текущий этап сборки.
+func (w *OutputWriter) currentGenerator() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.generator
+}
+
+// record учитывает действие action с файлом rel генератора generator и выводит его с параметром -verbose.
+func (w *OutputWriter) record(generator string, rel string, action string) {
+	w.report.AddFile(generator, action)
+	w.log.Verbose("  "+fileActionNames[action]+" "+rel, LogFields{"file": rel, "action": action, "generator": generator})
+}
+
+// WriteFile записывает содержимое в файл path, если оно отличается от записанного ранее.
+// Возвращает true, если файл был записан.
+func (w *OutputWriter) WriteFile(path string, content []byte) (bool, error) {
+	return w.writeFile(w.currentGenerator(), path, content)
+}
+
+// writeFile записывает файл path генератора generator, параметры совпадают с параметрами WriteFile.
+func (w *OutputWriter) writeFile(generator string, path string, content []byte) (bool, error) {
+	rel, err := w.relPath(path)
+	if err != nil {
+		return false, err
+	}
+
+	entry := manifestEntry{Hash: HashStringCrc32(string(content)), Size: int64(len(content)), Generator: generator}
+
+	w.mu.Lock()
+	w.current[rel] = entry
+	w.mu.Unlock()
+
+	if w.unchanged(path, rel, entry) {
+		w.record(generator, rel, FileUnchanged)
+		return false, nil
+	}
+
+	if w.plan != nil {
+		w.plan.WriteFile(path, content)
+		w.record(generator, rel, FileRendered)
+		return true, nil
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return false, errors.New(ErrorMessages["error_creating_dir"] + err.Error())
+	}
+	if err = ioutil.WriteFile(path, content, 0644); err != nil {
+		return false, errors.New(ErrorMessages["error_creating_file"] + err.Error())
+	}
+	w.record(generator, rel, FileRendered)
+
+	return true, nil
+}
+
+// RenderFile формирует страницу path из шаблона pagepath и записывает её, если она изменилась.
+// Если шаблон страницы, используемые ею шаблоны *.tmpl, исходные файлы sources и данные шаблона
+// не изменились с прошлой сборки, шаблон не выполняется.
+// Возвращает true, если файл был записан.
+// path — сформированный файл в целевой директории.
+// pagepath — шаблон страницы.
+// templatesDir — директория шаблонов *.tmpl, может быть пустой строкой.
+// data — данные, передаваемые шаблону.
+// fuseaction — имя корневого шаблона.
+// sources — исходные файлы данных страницы.
+func (w *OutputWriter) RenderFile(path string, pagepath string, templatesDir string, data interface{}, fuseaction string, sources ...string) (bool, error) {
+	return w.renderFile(w.currentGenerator(), path, pagepath, templatesDir, data, fuseaction, sources...)
+}
+
+// renderFile формирует страницу path генератора generator, параметры совпадают с параметрами RenderFile.
+func (w *OutputWriter) renderFile(generator string, path string, pagepath string, templatesDir string, data interface{}, fuseaction string, sources ...string) (bool, error) {
+	rel, err := w.relPath(path)
+	if err != nil {
+		return false, err
+	}
+
+	hash := dataHash(data)
+	if w.deps.Unchanged(rel, hash) {
+		w.mu.Lock()
+		entry, ok := w.previous[rel]
+		w.mu.Unlock()
+		if info, err := os.Stat(path); ok && err == nil && info.Size() == entry.Size {
+			entry.Generator = generator
+			w.mu.Lock()
+			w.current[rel] = entry
+			w.mu.Unlock()
+			w.record(generator, rel, FileUnchanged)
+			return false, nil
+		}
+	}
+
+	// Файлы ресурсов, использованные страницей через функцию Asset, учитываются в её зависимостях.
+	// Шаблон страницы выполняется в одной горутине, поэтому список пополняется без блокировки.
+	var assets []string
+	var funcs template.FuncMap
+	if w.cfg != nil {
+		funcs = siteFuncs(w.cfg, func(path string) string {
+			assets = append(assets, path)
+			return w.deps.fileHash(path)
+		})
+	}
+	// Без конфигурации сайта страница формируется с экранированием.
+	autoescape := w.cfg == nil || w.cfg.Autoescape
+	content, err := w.templates.Render(pagepath, templatesDir, data, fuseaction, autoescape, funcs)
+	if err != nil {
+		return false, errors.New(ErrorMessages["parse_template_error"] + err.Error())
+	}
+
+	templates, err := w.deps.TemplateFiles(pagepath, templatesDir)
+	if err != nil {
+		return false, errors.New(ErrorMessages["parse_template_error"] + err.Error())
+	}
+	inputs := append([]string{pagepath}, templates...)
+	inputs = append(inputs, sources...)
+	inputs = append(inputs, assets...)
+	// Адреса, которые выводят функции сайта, зависят от домена.
+	if w.cfg != nil {
+		inputs = append(inputs, siteURLInput)
+	}
+	w.deps.Record(rel, inputs, hash)
+
+	return w.writeFile(generator, path, []byte(content))
+}
+
+// SetTemplates задаёт кэш шаблонов, общий для нескольких сборок. Наборы шаблонов, изменившиеся
+// с прошлой сборки, удаляются из кэша и будут разобраны заново.
+func (w *OutputWriter) SetTemplates(templates *TemplateCache) {
+	templates.Refresh()
+	w.templates = templates
+}
+
+// SetConfig задаёт конфигурацию сайта, по которой функции шаблонов AbsURL, RelURL и Asset
+// строят адреса, а страницы формируются с экранированием или без него (compile.autoescape).
+// Файлы, адреса которых получены функцией Asset, и домен сайта становятся зависимостями страницы.
+func (w *OutputWriter) SetConfig(cfg *Config) {
+	w.cfg = cfg
+	w.deps.SetDomain(cfg.Domain)
+}
+
+// SetSite задаёт общие данные сайта текущей сборки: страницы, шаблоны которых обращаются к .Site,
+// формируются заново при изменении данных сайта.
+func (w *OutputWriter) SetSite(site *Site) {
+	w.deps.SetSite(site.Fingerprint())
+}
+
+// SetJobs задаёт количество задач, выполняемых одновременно; jobs <= 0 — по количеству процессоров.
+// По умолчанию задачи выполняются последовательно. Вызывается до передачи задач.
+func (w *OutputWriter) SetJobs(jobs int) {
+	w.pool = newWorkerPool(jobs)
+}
+
+// Go выполняет задачу формирования файлов task. При последовательном выполнении задача выполняется сразу
+// и Go возвращает её ошибку, иначе ошибки задач возвращает Wait.
+func (w *OutputWriter) Go(task func() error) error {
+	return w.pool.Go(task)
+}
+
+// Render передаёт пулу задачу формирования страницы, параметры совпадают с параметрами RenderFile.
+func (w *OutputWriter) Render(path string, pagepath string, templatesDir string, data interface{}, fuseaction string, sources ...string) error {
+	generator := w.currentGenerator()
+	return w.Go(func() error {
+		_, err := w.renderFile(generator, path, pagepath, templatesDir, data, fuseaction, sources...)
+		return err
+	})
+}
+
+// Copy передаёт пулу задачу копирования исходного файла source в файл path целевой директории.
+// Файл копируется, только если его нет в целевой директории или его содержимое отличается.
+// Скопированные файлы не вносятся в манифест и не удаляются методом Close.
+func (w *OutputWriter) Copy(source string, path string) error {
+	generator := w.currentGenerator()
+	return w.Go(func() error {
+		rel, err := w.relPath(path)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err == nil && HashFileCrc32(source) == HashFileCrc32(path) {
+			w.record(generator, rel, FileUnchanged)
+			return nil
+		}
+		if w.plan != nil {
+			w.plan.CopyFile(path)
+		} else if err = CopyFile(source, path); err != nil {
+			return errors.New(ErrorMessages["copy_error"] + path)
+		}
+		w.record(generator, rel, FileCopied)
+		return nil
+	})
+}
+
+// Stage выполняет этап сборки name: файлы, переданные на формирование во время этапа, учитываются
+// в отчёте о сборке генератором name. Этап завершается после выполнения переданных им задач;
+// сообщение «title...сделано» с временем выполнения этапа выводится, а время записывается в отчёт.
+func (w *OutputWriter) Stage(name string, title string, run func() error) error {
+	w.mu.Lock()
+	w.generator = name
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		w.generator = ""
+		w.mu.Unlock()
+	}()
+
+	start := time.Now()
+	err := run()
+	// Ошибка страницы, переданной на формирование раньше, возвращается и при последовательной сборке.
+	if waitErr := w.Wait(); waitErr != nil {
+		err = waitErr
+	}
+	if err != nil {
+		return err
+	}
+	elapsed := time.Since(start)
+	w.report.AddStage(name, elapsed)
+	w.log.Info(title+"...сделано ("+elapsed.Round(100*time.Microsecond).String()+")", LogFields{"stage": name, "duration_ms": durationMs(elapsed)})
+
+	return nil
+}
+
+// SetPlan включает пробный запуск сборки: изменения целевой директории вносятся в план plan,
+// а манифест и граф зависимостей не сохраняются.
+func (w *OutputWriter) SetPlan(plan *BuildPlan) {
+	w.plan = plan
+}
+
+// SyncDirs синхронизирует структуру поддиректорий целевой директории с исходной директорией source.
+// Директории со сформированными файлами прошлой сборки не удаляются.
+func (w *OutputWriter) SyncDirs(source string) error {
+	if w.plan == nil {
+		return syncDirs(source, w.destinationRoot, w.Dirs()...)
+	}
+
+	dirsToDelete, dirsToCreate, err := planDirs(source, w.destinationRoot, w.Dirs()...)
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirsToDelete {
+		w.plan.RemoveDir(dir)
+	}
+	for _, dir := range dirsToCreate {
+		w.plan.CreateDir(dir)
+	}
+
+	return nil
+}
+
+// ReadFile читает сформированный файл path; в пробном запуске возвращает содержимое,
+// которое было бы записано в файл. Для nil-значения читает файл с диска.
+func (w *OutputWriter) ReadFile(path string) ([]byte, error) {
+	if w != nil && w.plan != nil {
+		if content, ok := w.plan.ReadFile(path); ok {
+			return content, nil
+		}
+	}
+
+	return ioutil.ReadFile(path)
+}
+
+// SetLogger задаёт вывод хода сборки; с параметром -verbose выводится каждый файл целевой директории.
+func (w *OutputWriter) SetLogger(log *Logger) {
+	w.log = log
+}
+
+// SetReport задаёт отчёт о сборке, в котором учитываются файлы целевой директории.
+func (w *OutputWriter) SetReport(report *BuildReport) {
+	w.report = report
+}
+
+// Generated проверяет, сформирован ли файл path текущей сборкой.
+func (w *OutputWriter) Generated(path string) bool {
+	rel, err := w.relPath(path)
+	if err != nil {
+		return false
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, ok := w.current[rel]
+
+	return ok
+}
+
+// Wait ожидает завершения переданных задач и возвращает ошибку задачи, переданной раньше других.
+func (w *OutputWriter) Wait() error {
+	return w.pool.Wait()
+}
+
+// Dirs возвращает поддиректории целевой директории, в которых находятся сформированные файлы
+// прошлой сборки. Эти директории не должны удаляться при синхронизации структуры директорий.
+func (w *OutputWriter) Dirs() []string {
+	set := map[string]bool{}
+	for rel := range w.previous {
+		for dir := filepath.Dir(filepath.FromSlash(rel)); dir != "." && !set[dir]; dir = filepath.Dir(dir) {
+			set[dir] = true
+		}
+	}
+
+	dirs := make([]string, 0, len(set))
+	for dir := range set {
+		dirs = append(dirs, filepath.Join(w.destinationRoot, dir))
+	}
+	sort.Strings(dirs)
+
+	return dirs
+}
+
+// Close ожидает завершения переданных задач, удаляет файлы, не сформированные текущей сборкой,
+// и сохраняет манифест. В пробном запуске удаление файлов вносится в план, манифест не сохраняется.
+func (w *OutputWriter) Close() error {
+	if err := w.Wait(); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for rel, entry := range w.previous {
+		if _, ok := w.current[rel]; ok {
+			continue
+		}
+		path := filepath.Join(w.destinationRoot, filepath.FromSlash(rel))
+		if w.plan != nil {
+			if _, err := os.Stat(path); err == nil {
+				w.plan.RemoveFile(path)
+			}
+		} else if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		w.record(entry.Generator, rel, FileDeleted)
+	}
+	if w.plan != nil {
+		return nil
+	}
+
+	paths := make([]string, 0, len(w.current))
+	for rel := range w.current {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+
+	var manifest strings.Builder
+	for _, rel := range paths {
+		entry := w.current[rel]
+		manifest.WriteString(entry.Hash + "\t" + strconv.FormatInt(entry.Size, 10) + "\t" + entry.Generator + "\t" + rel + "\n")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(w.manifestPath), 0755); err != nil {
+		return errors.New(ErrorMessages["error_creating_dir"] + err.Error())
+	}
+	if err := ioutil.WriteFile(w.manifestPath, []byte(manifest.String()), 0644); err != nil {
+		return errors.New(ErrorMessages["error_creating_file"] + err.Error())
+	}
+
+	w.previous = w.current
+	w.current = map[string]manifestEntry{}
+
+	return w.deps.Save()
+}
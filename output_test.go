@@ -0,0 +1,137 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestOutput создаёт OutputWriter для целевой директории dir с манифестом во временной директории.
+func newTestOutput(t *testing.T, dir string) *OutputWriter {
+	t.Helper()
+
+	output, err := NewOutputWriter(dir, t.TempDir())
+	if err != nil {
+		t.Fatalf("NewOutputWriter вернул ошибку: %v", err)
+	}
+
+	return output
+}
+
+func TestOutputWriter_SkipsUnchangedFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	hashDir := filepath.Join(dir, "__hash")
+	destination := filepath.Join(dir, "dest")
+	file := filepath.Join(destination, "blog", "index.html")
+
+	output, err := NewOutputWriter(destination, hashDir)
+	if err != nil {
+		t.Fatalf("NewOutputWriter вернул ошибку: %v", err)
+	}
+	written, err := output.WriteFile(file, []byte("content"))
+	if err != nil || !written {
+		t.Fatalf("первая запись файла: written=%v, err=%v", written, err)
+	}
+	if err = output.Close(); err != nil {
+		t.Fatalf("Close вернул ошибку: %v", err)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	if err = os.Chtimes(file, past, past); err != nil {
+		t.Fatalf("не удалось изменить время модификации: %v", err)
+	}
+
+	output, err = NewOutputWriter(destination, hashDir)
+	if err != nil {
+		t.Fatalf("NewOutputWriter вернул ошибку: %v", err)
+	}
+	written, err = output.WriteFile(file, []byte("content"))
+	if err != nil || written {
+		t.Fatalf("неизменённый файл не должен перезаписываться: written=%v, err=%v", written, err)
+	}
+	info, err := os.Stat(file)
+	if err != nil {
+		t.Fatalf("не удалось получить сведения о файле: %v", err)
+	}
+	if !info.ModTime().Equal(past) {
+		t.Fatalf("время модификации неизменённого файла изменилось: %v", info.ModTime())
+	}
+
+	written, err = output.WriteFile(file, []byte("changed"))
+	if err != nil || !written {
+		t.Fatalf("изменённый файл должен быть перезаписан: written=%v, err=%v", written, err)
+	}
+}
+
+func TestOutputWriter_SkipsIdenticalFileWithoutManifest(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "page.html")
+	if err := os.WriteFile(file, []byte("content"), 0644); err != nil {
+		t.Fatalf("не удалось создать файл: %v", err)
+	}
+
+	written, err := newTestOutput(t, dir).WriteFile(file, []byte("content"))
+	if err != nil || written {
+		t.Fatalf("совпадающий с диском файл не должен перезаписываться: written=%v, err=%v", written, err)
+	}
+}
+
+func TestOutputWriter_RemovesStaleFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	hashDir := filepath.Join(dir, "__hash")
+	destination := filepath.Join(dir, "dest")
+	keepFile := filepath.Join(destination, "keep.html")
+	staleFile := filepath.Join(destination, "blog", "posts", "stale.html")
+
+	output, err := NewOutputWriter(destination, hashDir)
+	if err != nil {
+		t.Fatalf("NewOutputWriter вернул ошибку: %v", err)
+	}
+	for _, file := range []string{keepFile, staleFile} {
+		if _, err = output.WriteFile(file, []byte(file)); err != nil {
+			t.Fatalf("WriteFile вернул ошибку: %v", err)
+		}
+	}
+	if err = output.Close(); err != nil {
+		t.Fatalf("Close вернул ошибку: %v", err)
+	}
+
+	output, err = NewOutputWriter(destination, hashDir)
+	if err != nil {
+		t.Fatalf("NewOutputWriter вернул ошибку: %v", err)
+	}
+	dirs := output.Dirs()
+	if len(dirs) != 2 || dirs[0] != filepath.Join(destination, "blog") || dirs[1] != filepath.Join(destination, "blog", "posts") {
+		t.Fatalf("директории сформированных файлов = %v", dirs)
+	}
+	if _, err = output.WriteFile(keepFile, []byte(keepFile)); err != nil {
+		t.Fatalf("WriteFile вернул ошибку: %v", err)
+	}
+	if err = output.Close(); err != nil {
+		t.Fatalf("Close вернул ошибку: %v", err)
+	}
+
+	if _, err = os.Stat(staleFile); !os.IsNotExist(err) {
+		t.Fatalf("устаревший файл должен быть удалён, err=%v", err)
+	}
+	if _, err = os.Stat(keepFile); err != nil {
+		t.Fatalf("сформированный файл должен сохраниться: %v", err)
+	}
+}
+
+func TestOutputWriter_RejectsFileOutsideDestination(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	output := newTestOutput(t, filepath.Join(dir, "dest"))
+	if _, err := output.WriteFile(filepath.Join(dir, "outside.html"), []byte("content")); err == nil {
+		t.Fatal("ожидалась ошибка при записи файла вне целевой директории")
+	}
+}
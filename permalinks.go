@@ -0,0 +1,208 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Адреса страниц постов и рубрик блога.
+// Адреса задаются шаблонами секции permalinks файла конфигурации, параметры шаблона начинаются с двоеточия:
+//
+//	[permalinks]
+//	post = "/:blog/:year/:month/:slug/"
+//	tag = "/:blog/tag/:tagslug/"
+//	pretty_urls = true
+//
+// Параметры шаблона адреса поста: :blog (директория блога output.blog), :year, :month, :day (дата поста),
+// :slug (имя файла поста), :title (заголовок поста, преобразованный Slugify), :tag и :tagid (адресное имя
+// и id основной рубрики поста). Параметры шаблона адреса рубрики: :blog, :tagid и :tagslug (атрибут slug
+// рубрики в tags.xml, по умолчанию — название рубрики, преобразованное Slugify).
+// Адрес, оканчивающийся символом /, формируется файлом index.html директории. При pretty_urls = true
+// адреса постов вида /blog/posts/a.html формируются как /blog/posts/a/, а страницы лент — как page/2/.
+
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PermalinkOptions описывает шаблоны адресов страниц блога.
+type PermalinkOptions struct {
+	// Шаблон адреса страницы поста.
+	Post string
+	// Шаблон адреса ленты рубрики, оканчивается символом /.
+	Tag string
+	// Формировать ли адреса директорий вместо адресов .html-файлов.
+	PrettyURLs bool
+}
+
+// Шаблоны адресов по умолчанию совпадают с адресами, которые формировались до появления секции permalinks.
+const (
+	DefaultPostPermalink = "/:blog/posts/:slug.html"
+	DefaultTagPermalink  = "/:blog/:tagid/"
+)
+
+// permalinkToken — параметр шаблона адреса.
+var permalinkToken = regexp.MustCompile(`:[a-z]+`)
+
+// Параметры шаблонов адресов постов и рубрик.
+var (
+	postPermalinkTokens = []string{":blog", ":year", ":month", ":day", ":slug", ":title", ":tag", ":tagid"}
+	tagPermalinkTokens  = []string{":blog", ":tagid", ":tagslug"}
+)
+
+// checkPermalink проверяет шаблон адреса pattern: адрес начинается символом /, содержит только параметры
+// tokens и хотя бы один из параметров unique, различающих страницы.
+func checkPermalink(pattern string, tokens []string, unique []string) error {
+	if !strings.HasPrefix(pattern, "/") {
+		return fmt.Errorf("шаблон адреса должен начинаться символом /: %q", pattern)
+	}
+	if strings.Contains(pattern, "..") || strings.ContainsAny(pattern, `\?#`) {
+		return fmt.Errorf("шаблон адреса содержит недопустимые символы: %q", pattern)
+	}
+	found := false
+	for _, token := range permalinkToken.FindAllString(pattern, -1) {
+		if !containsString(tokens, token) {
+			return fmt.Errorf("неизвестный параметр %s в шаблоне адреса %q, допустимы %s", token, pattern, strings.Join(tokens, ", "))
+		}
+		if containsString(unique, token) {
+			found = true
+		}
+	}
+	if !found {
+		return fmt.Errorf("шаблон адреса %q должен содержать параметр %s", pattern, strings.Join(unique, " или "))
+	}
+
+	return nil
+}
+
+// containsString проверяет, что список list содержит строку s.
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expandPermalink подставляет в шаблон адреса pattern значения параметров values.
+func expandPermalink(pattern string, values map[string]string) string {
+	return permalinkToken.ReplaceAllStringFunc(pattern, func(token string) string {
+		if value, ok := values[token]; ok {
+			return value
+		}
+		return token
+	})
+}
+
+// prettyPath заменяет адрес .html-файла адресом директории, если включены адреса директорий.
+func (cfg *Config) prettyPath(path string) string {
+	if cfg.Permalinks.PrettyURLs && strings.HasSuffix(path, ".html") && !strings.HasSuffix(path, "/index.html") {
+		return strings.TrimSuffix(path, ".html") + "/"
+	}
+
+	return path
+}
+
+// PostPath возвращает адрес страницы поста относительно домена сайта, например /blog/posts/a.html.
+// Параметры :tag и :tagid — основная (первая) рубрика поста.
+func (cfg *Config) PostPath(post Post) string {
+	values := map[string]string{
+		":blog":  cfg.Output.Blog,
+		":year":  strconv.Itoa(post.SortDate.Year()),
+		":month": fmt.Sprintf("%02d", int(post.SortDate.Month())),
+		":day":   fmt.Sprintf("%02d", post.SortDate.Day()),
+		":slug":  post.Fuseaction,
+		":title": Slugify(post.Title),
+		":tag":   "",
+		":tagid": strconv.Itoa(post.Tagid),
+	}
+	if len(post.Tags) > 0 {
+		values[":tag"] = post.Tags[0].Slug
+	}
+
+	return cfg.prettyPath(expandPermalink(cfg.Permalinks.Post, values))
+}
+
+// TagPath возвращает адрес ленты рубрики относительно домена сайта, например /blog/1/.
+func (cfg *Config) TagPath(tag Tag) string {
+	return expandPermalink(cfg.Permalinks.Tag, map[string]string{
+		":blog":    cfg.Output.Blog,
+		":tagid":   strconv.Itoa(tag.Id),
+		":tagslug": tag.Slug,
+	})
+}
+
+// FeedPagePath возвращает адрес страницы ленты с номером page относительно адреса ленты;
+// адрес первой страницы — пустая строка, это сама лента.
+func (p PermalinkOptions) FeedPagePath(page int) string {
+	if page <= 1 {
+		return ""
+	}
+	if p.PrettyURLs {
+		return "page/" + strconv.Itoa(page) + "/"
+	}
+
+	return strconv.Itoa(page) + ".html"
+}
+
+// PermalinkFile возвращает файл в целевой директории, формируемый для адреса path:
+// для адреса, оканчивающегося символом /, — файл index.html директории.
+func (cfg *Config) PermalinkFile(path string) string {
+	return permalinkFile(cfg.Destination, path)
+}
+
+// permalinkFile возвращает файл директории dir, формируемый для адреса path относительно неё.
+func permalinkFile(dir string, path string) string {
+	if len(path) == 0 || strings.HasSuffix(path, "/") {
+		path += "index.html"
+	}
+
+	return filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(path, "/")))
+}
+
+// tagSlug возвращает адресное имя рубрики: атрибут slug или название рубрики, преобразованное Slugify.
+func tagSlug(tag Tag) string {
+	if slug := strings.Trim(tag.Slug, "/ "); len(slug) > 0 {
+		return slug
+	}
+	if slug := Slugify(tag.Name); len(slug) > 0 {
+		return slug
+	}
+
+	return strconv.Itoa(tag.Id)
+}
+
+// checkBlogPaths проверяет, что файлы лент рубрик и страниц постов, формируемые по шаблонам адресов,
+// не совпадают между собой и с лентой блога.
+func checkBlogPaths(site *Site) error {
+	cfg := site.Config
+	files := map[string]string{cfg.PermalinkFile("/" + cfg.Output.Blog + "/"): "лента блога"}
+	add := func(file string, owner string) error {
+		if other, ok := files[file]; ok {
+			return fmt.Errorf("адреса совпадают: %s и %s формируются в файл %s", other, owner, file)
+		}
+		files[file] = owner
+		return nil
+	}
+
+	for _, tag := range site.Tags {
+		if err := add(cfg.PermalinkFile(cfg.TagPath(tag)), "рубрика "+tag.Name); err != nil {
+			return err
+		}
+	}
+	for _, post := range site.Posts {
+		if err := add(cfg.PermalinkFile(cfg.PostPath(post)), "пост "+post.Source); err != nil {
+			return err
+		}
+	}
+	if archiveEnabled(cfg) {
+		for _, path := range archivePaths(cfg, site.Archive) {
+			if err := add(cfg.PermalinkFile(path), "архив "+path); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfig_ValidateChecksPermalinks(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		post, tag string
+		valid     bool
+	}{
+		{DefaultPostPermalink, DefaultTagPermalink, true},
+		{"/:blog/:year/:month/:day/:title/", "/:blog/tag/:tagslug/", true},
+		{"/:tag/:slug.html", "/rubric-:tagid/", true},
+		{":blog/:slug.html", DefaultTagPermalink, false},
+		{"/:blog/:year/", DefaultTagPermalink, false},
+		{"/:blog/:author/:slug/", DefaultTagPermalink, false},
+		{"/:blog/../:slug/", DefaultTagPermalink, false},
+		{DefaultPostPermalink, "/:blog/:tagslug.html", false},
+		{DefaultPostPermalink, "/:blog/:slug/", false},
+	} {
+		cfg := newTestSiteConfig(t)
+		cfg.Permalinks.Post = test.post
+		cfg.Permalinks.Tag = test.tag
+		if err := cfg.Validate(); (err == nil) != test.valid {
+			t.Errorf("post=%q tag=%q: Validate вернул %v", test.post, test.tag, err)
+		}
+	}
+}
+
+func TestBuild_CustomPermalinks(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.PostsPerPage = 1
+	cfg.Permalinks = PermalinkOptions{Post: "/:blog/:year/:month/:slug.html", Tag: "/:blog/tag/:tagslug/", PrettyURLs: true}
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости" slug="news"></tag><tag id="2" name="Обзоры"></tag></tags>`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "blog.html"), `{{range .Blog}}{{.Title}}{{end}}|{{.Prev_url}}|{{.Next_url}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "post.html"), `{{.Blogpost.URL}}{{range .Blogpost.Tags}} {{.URL}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "first.xml"), `<post><date>01.02.2026</date><tagid>1</tagid><tagid>2</tagid><title>Первый</title></post>`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "second.xml"), `<post><date>03.03.2026</date><tagid>1</tagid><title>Второй</title></post>`)
+	if err := Build(cfg); err != nil {
+		t.Fatalf("Build вернул ошибку: %v", err)
+	}
+
+	files := readTree(t, cfg.Destination)
+	for rel, expected := range map[string]string{
+		"blog/index.html":                 "Второй||https://example.test/blog/page/2/",
+		"blog/page/2/index.html":          "Первый|https://example.test/blog/|",
+		"blog/tag/news/index.html":        "Второй||https://example.test/blog/tag/news/page/2/",
+		"blog/tag/obzory/index.html":      "Первый||",
+		"blog/2026/02/first/index.html":   "https://example.test/blog/2026/02/first/ https://example.test/blog/tag/news/ https://example.test/blog/tag/obzory/",
+		"blog/2026/03/second/index.html":  "https://example.test/blog/2026/03/second/ https://example.test/blog/tag/news/",
+		"blog/tag/news/page/2/index.html": "Первый|https://example.test/blog/tag/news/|",
+		"blog/tag/obzory/rss.xml":         "<link>https://example.test/blog/tag/obzory/</link>",
+		"blog/rss.xml":                    "<link>https://example.test/blog/2026/03/second/</link>",
+		"sitemap.xml":                     "<loc>https://example.test/blog/2026/02/first/</loc>",
+	} {
+		content, ok := files[filepath.FromSlash(rel)]
+		if !ok {
+			t.Errorf("файл %s не сформирован", rel)
+			continue
+		}
+		if strings.HasSuffix(rel, ".xml") && !strings.Contains(content, expected) || !strings.HasSuffix(rel, ".xml") && content != expected {
+			t.Errorf("%s = %q, ожидалось %q", rel, content, expected)
+		}
+	}
+	for rel := range files {
+		if strings.HasPrefix(rel, filepath.Join("blog", "posts")) || strings.HasPrefix(rel, filepath.Join("blog", "1")) {
+			t.Errorf("сформирован файл по адресу по умолчанию: %s", rel)
+		}
+	}
+}
+
+func TestBuild_RejectsCollidingPermalinks(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.Permalinks.Tag = "/:blog/:tagslug/"
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости" slug="news"></tag><tag id="2" name="News"></tag></tags>`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "blog.html"), `{{range .Blog}}{{.Title}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "post.html"), `{{.Blogpost.Title}}`)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "first.xml"), `<post><date>01.02.2026</date><tagid>1</tagid><tagid>2</tagid><title>Первый</title></post>`)
+
+	if err := Build(cfg); err == nil || !strings.Contains(err.Error(), "адреса совпадают") {
+		t.Errorf("совпадающие адреса рубрик не обнаружены: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Пробный запуск сборки.
+// Параметр -dry-run команды build загружает сайт и формирует страницы, но не изменяет целевую директорию
+// и директорию __hash: OutputWriter не записывает, не копирует и не удаляет файлы, а синхронизация директорий
+// не создаёт и не удаляет их. Вместо этого изменения вносятся в план сборки, который выводится в конце сборки:
+//
+//	+ blog/2026/          директория будет создана
+//	- old/                директория будет удалена вместе с содержимым
+//	+ blog/new.html       файл будет добавлен
+//	~ index.html          файл будет изменён
+//	- about.html          файл будет удалён
+//
+// Параметр -diff выводит также изменения html-страниц в формате unified diff (см. diff.go).
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Изменения плана сборки.
+const (
+	PlanCreateDir = "mkdir"
+	PlanRemoveDir = "rmdir"
+	PlanAdd       = "add"
+	PlanUpdate    = "update"
+	PlanRemove    = "remove"
+)
+
+// planActionSigns — обозначения изменений в текстовом выводе плана.
+var planActionSigns = map[string]string{
+	PlanCreateDir: "+",
+	PlanRemoveDir: "-",
+	PlanAdd:       "+",
+	PlanUpdate:    "~",
+	PlanRemove:    "-",
+}
+
+// PlanChange описывает изменение целевой директории.
+type PlanChange struct {
+	Action string `json:"action"`
+	// Путь относительно целевой директории; путь директории оканчивается символом /.
+	Path string `json:"path"`
+	// Изменения html-страницы в формате unified diff (параметр -diff).
+	Diff string `json:"diff,omitempty"`
+}
+
+// BuildPlan собирает изменения целевой директории, которые выполнила бы сборка.
+type BuildPlan struct {
+	mu          sync.Mutex
+	destination string
+	// Выводить ли изменения html-страниц.
+	diff    bool
+	changes map[string]PlanChange
+	// Содержимое добавляемых и изменяемых файлов, которое читают последующие этапы сборки (поисковый индекс).
+	content map[string][]byte
+}
+
+// NewBuildPlan создаёт план изменений целевой директории destination;
+// diff — выводить ли изменения html-страниц.
+func NewBuildPlan(destination string, diff bool) *BuildPlan {
+	return &BuildPlan{
+		destination: destination,
+		diff:        diff,
+		changes:     map[string]PlanChange{},
+		content:     map[string][]byte{},
+	}
+}
+
+// rel возвращает путь path относительно целевой директории в формате плана.
+func (p *BuildPlan) rel(path string, dir bool) string {
+	rel, err := filepath.Rel(p.destination, path)
+	if err != nil {
+		rel = path
+	}
+	rel = filepath.ToSlash(rel)
+	if dir {
+		rel += "/"
+	}
+
+	return rel
+}
+
+// add вносит изменение в план; блокировка должна быть захвачена.
+func (p *BuildPlan) add(change PlanChange) {
+	p.changes[change.Path] = change
+}
+
+// CreateDir вносит в план создание директории path.
+func (p *BuildPlan) CreateDir(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.add(PlanChange{Action: PlanCreateDir, Path: p.rel(path, true)})
+}
+
+// RemoveDir вносит в план удаление директории path вместе с содержимым.
+func (p *BuildPlan) RemoveDir(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.add(PlanChange{Action: PlanRemoveDir, Path: p.rel(path, true)})
+}
+
+// file вносит в план добавление файла path, если его нет, иначе изменение, а также создание
+// отсутствующих директорий файла; old — содержимое файла на диске, если файл читается (readOld).
+func (p *BuildPlan) file(path string, readOld bool) (change PlanChange, old []byte) {
+	var dirs []string
+	for dir := filepath.Dir(path); dir != p.destination && strings.HasPrefix(dir, p.destination); dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		dirs = append(dirs, dir)
+	}
+
+	change = PlanChange{Action: PlanAdd, Path: p.rel(path, false)}
+	if _, err := os.Stat(path); err == nil {
+		change.Action = PlanUpdate
+		if readOld {
+			old, _ = ioutil.ReadFile(path)
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, dir := range dirs {
+		p.add(PlanChange{Action: PlanCreateDir, Path: p.rel(dir, true)})
+	}
+	p.add(change)
+
+	return change, old
+}
+
+// WriteFile вносит в план запись содержимого content в файл path. Изменения html-страницы
+// сохраняются в плане, если задан вывод изменений.
+func (p *BuildPlan) WriteFile(path string, content []byte) {
+	diff := p.diff && isHTMLFile(path)
+	change, old := p.file(path, diff)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if diff && change.Action == PlanUpdate {
+		change.Diff = unifiedDiff(change.Path, string(old), string(content))
+		p.add(change)
+	}
+	p.content[path] = content
+}
+
+// CopyFile вносит в план копирование исходного файла в файл path.
+func (p *BuildPlan) CopyFile(path string) {
+	p.file(path, false)
+}
+
+// RemoveFile вносит в план удаление файла path.
+func (p *BuildPlan) RemoveFile(path string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.add(PlanChange{Action: PlanRemove, Path: p.rel(path, false)})
+}
+
+// ReadFile возвращает содержимое, которое было бы записано в файл path, и true, если файл есть в плане.
+func (p *BuildPlan) ReadFile(path string) ([]byte, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	content, ok := p.content[path]
+
+	return content, ok
+}
+
+// Changes возвращает изменения, отсортированные по пути.
+func (p *BuildPlan) Changes() []PlanChange {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	changes := make([]PlanChange, 0, len(p.changes))
+	for _, change := range p.changes {
+		changes = append(changes, change)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+
+	return changes
+}
+
+// Summary возвращает количество изменений по видам.
+func (p *BuildPlan) Summary() map[string]int {
+	summary := map[string]int{PlanCreateDir: 0, PlanRemoveDir: 0, PlanAdd: 0, PlanUpdate: 0, PlanRemove: 0}
+	for _, change := range p.Changes() {
+		summary[change.Action]++
+	}
+
+	return summary
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuild_DryRunWritesNothing(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.LogLevel = LogQuiet
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.Source, "index.html"), "<p>Главная</p>\n<p>Текст</p>\n")
+	writeTestFile(t, filepath.Join(cfg.Source, "about.html"), `<p>О нас</p>`)
+	writeTestFile(t, filepath.Join(cfg.Source, "assets", "style.css"), `body{}`)
+	if err := Build(cfg); err != nil {
+		t.Fatalf("Build вернул ошибку: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(cfg.Source, "about.html")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(cfg.Source, "index.html"), "<p>Главная</p>\n<p>Новый текст</p>\n")
+	writeTestFile(t, filepath.Join(cfg.Source, "news", "2026", "item.html"), `<p>Новость</p>`)
+	writeTestFile(t, filepath.Join(cfg.Source, "assets", "logo.svg"), `<svg/>`)
+	writeTestFile(t, filepath.Join(cfg.Destination, "stale", "old.html"), `<p>Старая</p>`)
+	destination := readTree(t, cfg.Destination)
+	source := readTree(t, cfg.Source)
+
+	output, err := NewOutputWriter(cfg.Destination, cfg.HashDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := NewBuildPlan(cfg.Destination, true)
+	output.SetPlan(plan)
+	if err = buildSite(cfg, output); err != nil {
+		t.Fatalf("пробный запуск вернул ошибку: %v", err)
+	}
+
+	// Целевая директория, манифест и граф зависимостей не изменились.
+	if actual := readTree(t, cfg.Destination); !reflect.DeepEqual(actual, destination) {
+		t.Errorf("целевая директория изменена:\n%v\nожидалось:\n%v", actual, destination)
+	}
+	if actual := readTree(t, cfg.Source); !reflect.DeepEqual(actual, source) {
+		t.Errorf("исходная директория изменена:\n%v\nожидалось:\n%v", actual, source)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Destination, "news")); !os.IsNotExist(err) {
+		t.Errorf("директория news создана: %v", err)
+	}
+
+	actual := []string{}
+	for _, change := range plan.Changes() {
+		actual = append(actual, change.Action+" "+change.Path)
+	}
+	expected := []string{
+		"remove about.html",
+		"add assets/logo.svg",
+		"update index.html",
+		"mkdir news/",
+		"mkdir news/2026/",
+		"add news/2026/item.html",
+		"update sitemap.xml",
+		"rmdir stale/",
+	}
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("план:\n%v\nожидалось:\n%v", actual, expected)
+	}
+
+	for _, change := range plan.Changes() {
+		if change.Path == "index.html" && !strings.Contains(change.Diff, "-<p>Текст</p>\n+<p>Новый текст</p>\n") {
+			t.Errorf("изменения index.html:\n%s", change.Diff)
+		}
+		if change.Path == "sitemap.xml" && len(change.Diff) > 0 {
+			t.Errorf("изменения выводятся только для html-страниц:\n%s", change.Diff)
+		}
+	}
+}
+
+func TestBuild_DryRunIntoEmptyDestinationCreatesNoDirs(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.LogLevel = LogQuiet
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости"></tag></tags>`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "blog.html"), `{{range .Blog}}{{.Title}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "post.html"), `{{.Blogpost.Title}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "articles.html"), `{{range .Articles}}{{.Title}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "page.html"), `{{.Content}}`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "post.xml"), `<post><date>01.01.2026</date><tagid>1</tagid><title>Пост</title><content>Текст</content></post>`)
+	writeTestFile(t, filepath.Join(cfg.ArticlesDir(), "guide.xml"), `<article><title>Руководство</title><pages>Первая</pages></article>`)
+	writeTestFile(t, filepath.Join(cfg.ArticlesDir(), "guide", "1.html"), "<p>Первая</p>")
+	source := readTree(t, cfg.Source)
+
+	output, err := NewOutputWriter(cfg.Destination, cfg.HashDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	plan := NewBuildPlan(cfg.Destination, false)
+	output.SetPlan(plan)
+	if err = buildSite(cfg, output); err != nil {
+		t.Fatalf("пробный запуск вернул ошибку: %v", err)
+	}
+
+	if entries, err := os.ReadDir(cfg.Destination); err != nil || len(entries) != 0 {
+		t.Errorf("пробный запуск изменил пустую целевую директорию: %v, %v", entries, err)
+	}
+	if actual := readTree(t, cfg.Source); !reflect.DeepEqual(actual, source) {
+		t.Errorf("исходная директория изменена:\n%v\nожидалось:\n%v", actual, source)
+	}
+	planned := map[string]string{}
+	for _, change := range plan.Changes() {
+		planned[change.Path] = change.Action
+	}
+	for _, dir := range []string{"blog/", "articles/", "articles/guide/"} {
+		if planned[dir] != PlanCreateDir {
+			t.Errorf("создание директории %s не внесено в план: %v", dir, planned)
+		}
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	t.Parallel()
+
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+	new := "1\n2\nтри\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n16\n"
+	expected := "--- a/page.html\n+++ b/page.html\n" +
+		"@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+три\n 4\n 5\n 6\n" +
+		"@@ -13,3 +13,4 @@\n 13\n 14\n 15\n+16\n"
+	if actual := unifiedDiff("page.html", old, new); actual != expected {
+		t.Errorf("unifiedDiff:\n%s\nожидалось:\n%s", actual, expected)
+	}
+	if actual := unifiedDiff("page.html", old, old); len(actual) > 0 {
+		t.Errorf("для одинаковых текстов получено:\n%s", actual)
+	}
+	if actual := unifiedDiff("new.html", "", "a\n"); actual != "--- a/new.html\n+++ b/new.html\n@@ -0,0 +1 @@\n+a\n" {
+		t.Errorf("для пустого файла получено:\n%s", actual)
+	}
+}
@@ -0,0 +1,95 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Параллельное формирование страниц.
+// Генераторы страниц передают задачи формирования страниц пулу, который выполняет одновременно
+// не больше заданного количества задач. Генераторы обходят страницы в том же порядке, что и при
+// последовательной сборке, а общие структуры (OutputWriter, DependencyGraph, Sitemap) защищены мьютексами
+// и записываются в отсортированном виде, поэтому результат сборки не зависит от количества задач.
+
+package main
+
+import (
+	"runtime"
+	"sync"
+)
+
+// workerPool выполняет задачи с ограничением количества одновременно выполняемых задач.
+// Пул с одной задачей (и nil-пул) выполняет задачи сразу в вызывающей горутине.
+type workerPool struct {
+	slots chan struct{}
+	wg    sync.WaitGroup
+
+	mu sync.Mutex
+	// Порядковый номер следующей задачи.
+	next int
+	// Ошибка задачи с наименьшим порядковым номером и этот номер.
+	err      error
+	errIndex int
+}
+
+// newWorkerPool создаёт пул, выполняющий одновременно не больше jobs задач;
+// jobs <= 0 — по количеству процессоров.
+func newWorkerPool(jobs int) *workerPool {
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+
+	return &workerPool{slots: make(chan struct{}, jobs)}
+}
+
+// Go запускает задачу task, ожидая освобождения места в пуле.
+// Если одна из запущенных задач завершилась ошибкой, новые задачи не запускаются и Go возвращает эту ошибку.
+// Задачи не должны сами передавать задачи пулу: заняв все места, они ожидали бы друг друга.
+func (p *workerPool) Go(task func() error) error {
+	if p == nil || cap(p.slots) <= 1 {
+		return task()
+	}
+
+	p.mu.Lock()
+	index := p.next
+	p.next++
+	err := p.err
+	p.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	p.slots <- struct{}{}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		defer func() { <-p.slots }()
+		if err := task(); err != nil {
+			p.fail(index, err)
+		}
+	}()
+
+	return nil
+}
+
+// fail сохраняет ошибку задачи index. Сохраняется ошибка задачи, запущенной раньше других,
+// поэтому сборка сообщает о той же ошибке, что и при последовательном формировании страниц.
+func (p *workerPool) fail(index int, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.err == nil || index < p.errIndex {
+		p.err, p.errIndex = err, index
+	}
+}
+
+// Wait ожидает завершения всех запущенных задач и возвращает ошибку задачи, запущенной раньше других.
+// После Wait пул готов к выполнению новых задач.
+func (p *workerPool) Wait() error {
+	if p == nil {
+		return nil
+	}
+
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	err := p.err
+	p.err, p.errIndex, p.next = nil, 0, 0
+
+	return err
+}
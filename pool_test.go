@@ -0,0 +1,130 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool_ReportsEarliestError(t *testing.T) {
+	t.Parallel()
+
+	pool := newWorkerPool(4)
+	var running, maxRunning int32
+	for i := 0; i < 8; i++ {
+		i := i
+		err := pool.Go(func() error {
+			current := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			for {
+				max := atomic.LoadInt32(&maxRunning)
+				if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+					break
+				}
+			}
+			// Первая ошибочная задача завершается позже второй.
+			switch i {
+			case 2:
+				time.Sleep(20 * time.Millisecond)
+				return errors.New("задача 2")
+			case 3:
+				return errors.New("задача 3")
+			}
+			return nil
+		})
+		if err != nil {
+			break
+		}
+	}
+	if err := pool.Wait(); err == nil || err.Error() != "задача 2" {
+		t.Errorf("Wait вернул %v, ожидалась ошибка задачи 2", err)
+	}
+	if maxRunning > 4 {
+		t.Errorf("одновременно выполнялось %d задач", maxRunning)
+	}
+	if err := pool.Wait(); err != nil {
+		t.Errorf("после Wait пул должен быть готов к новым задачам: %v", err)
+	}
+
+	var serial *workerPool
+	if err := serial.Go(func() error { return errors.New("сразу") }); err == nil {
+		t.Errorf("nil-пул должен выполнять задачу сразу")
+	}
+}
+
+func TestBuild_ParallelOutputMatchesSerial(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.PostsPerPage = 3
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости"></tag><tag id="2" name="Обзоры"></tag></tags>`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "blog.html"), `{{template "header" .}}{{range .Blog}}<h2>{{.Title}}</h2>{{end}}{{.Pagenum}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "post.html"), `{{template "header" .}}<h1>{{.Blogpost.Title}}</h1>{{.Blogpost.Content}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "articles.html"), `{{range .Articles}}{{.Title}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "page.html"), `{{.ThisTitle}}: {{.Content}}`)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "header.tmpl"), `{{define "header"}}<title>{{.Site.Title}} {{.Fuseaction}}</title>{{end}}`)
+	for i := 1; i <= 40; i++ {
+		name := strconv.Itoa(i)
+		writeTestFile(t, filepath.Join(cfg.BlogDir(), "post"+name+".xml"), `<post><date>`+fmt.Sprintf("%02d", i%28+1)+`.01.2026</date><tagid>`+strconv.Itoa(i%2+1)+`</tagid><title>Пост `+name+`</title><content>Текст `+name+`</content></post>`)
+		writeTestFile(t, filepath.Join(cfg.Source, "pages", "page"+name+".html"), `{{template "header" .}}<p>Страница `+name+`</p>`)
+		writeTestFile(t, filepath.Join(cfg.Source, "pages", "file"+name+".txt"), "файл "+name)
+	}
+	for i := 1; i <= 5; i++ {
+		name := "a" + strconv.Itoa(i)
+		writeTestFile(t, filepath.Join(cfg.ArticlesDir(), name+".xml"), `<article><title>Публикация `+name+`</title><pages>Первая|Вторая</pages></article>`)
+		writeTestFile(t, filepath.Join(cfg.ArticlesDir(), name, "1.html"), "<p>Первая</p>")
+		writeTestFile(t, filepath.Join(cfg.ArticlesDir(), name, "2.html"), "<p>Вторая</p>")
+	}
+
+	serial := *cfg
+	serial.Jobs = 1
+	parallel := *cfg
+	parallel.Jobs = 8
+	parallel.Destination = t.TempDir()
+	parallel.Dirs.Hash = cfg.Dirs.Hash + "_parallel"
+	for _, c := range []*Config{&serial, &parallel} {
+		if err := Build(c); err != nil {
+			t.Fatalf("Build с jobs=%d вернул ошибку: %v", c.Jobs, err)
+		}
+	}
+
+	expected := readTree(t, serial.Destination)
+	actual := readTree(t, parallel.Destination)
+	if len(expected) < 130 || len(actual) != len(expected) {
+		t.Fatalf("сформировано файлов: последовательно %d, параллельно %d", len(expected), len(actual))
+	}
+	for rel, content := range expected {
+		if actual[rel] != content {
+			t.Errorf("файл %s отличается при параллельной сборке", rel)
+		}
+	}
+}
+
+// readTree возвращает содержимое всех файлов директории dir по путям относительно неё.
+func readTree(t *testing.T, dir string) map[string]string {
+	t.Helper()
+
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		files[rel] = string(raw)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return files
+}
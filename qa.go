@@ -4,12 +4,12 @@
 package main
 
 import (
-	"encoding/xml"
 	"errors"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -24,9 +24,17 @@ type QA struct {
 	//вопрос
 	Question string `xml:"question"`
 	//ответ
-	Answer string `xml:"answer"`
+	Answer TrustedHTML `xml:"answer"`
+	//формат ответа: html (по умолчанию) или markdown
+	Format string `xml:"format"`
 	//-----------------------------
 	//вычисляемые поля
+	//исходный файл записи
+	Source string
+	//файл <имя записи>.md с ответом в формате Markdown, пустая строка если его нет
+	MarkdownSource string
+	//дополнительные поля заголовка (front matter) файла записи
+	Params map[string]interface{} `xml:"-"`
 	//дата для сортировки списка
 	SortDate  time.Time
 	Day, Year int
@@ -50,7 +58,8 @@ func (p SortedQAList) Swap(i, j int) {
 
 //------------------------------------------------------------
 //обход поддиректорий и обработка xml-файлов записей
-func handleQAFiles(qas *SortedQAList, total_qas *int) filepath.WalkFunc {
+//проблемы записей передаются проверке validator, запись с ошибкой пропускается
+func handleQAFiles(qas *SortedQAList, total_qas *int, validator *Validator) filepath.WalkFunc {
 	return func(current_path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -59,20 +68,52 @@ func handleQAFiles(qas *SortedQAList, total_qas *int) filepath.WalkFunc {
 			//обработка файла
 			_, filename := filepath.Split(current_path)
 			ext := filepath.Ext(filename)
+			var qa QA
+			var raw []byte
+			loaded := false
 			if ext == ".xml" {
-				raw, err := ioutil.ReadFile(current_path)
+				raw, err = ioutil.ReadFile(current_path)
 				if err != nil {
 					return err
 				}
-				var qa QA
-				err = xml.Unmarshal(raw, &qa)
+				if problem := decodeXMLRecord(current_path, raw, &qa); problem != nil {
+					return validator.Report(*problem)
+				}
+
+				//ответ в формате Markdown преобразуется в HTML
+				qa.Answer, qa.MarkdownSource, err = markdownContent(current_path, qa.Format, string(qa.Answer))
 				if err != nil {
 					return err
 				}
+				loaded = true
+			} else if isFrontMatterCandidate(current_path) {
+				//запись из файла с заголовком (front matter), текст файла является ответом
+				loaded, err = loadFrontMatterFile(current_path, &qa, "Answer")
+				if err != nil {
+					return validator.Report(frontMatterProblem(current_path, err))
+				}
+			}
+			if loaded {
+				//проверка полей записи
+				problems := newRecordProblems(current_path, "qa", raw)
+				if len(strings.TrimSpace(qa.Question)) == 0 {
+					problems.field(ProblemRequired, "question", "не указан вопрос (question)")
+				}
+				if len(strings.TrimSpace(qa.Date)) == 0 {
+					problems.field(ProblemRequired, "date", "не указана дата записи (date)")
+				} else if qa.SortDate, err = time.Parse("02.01.2006", qa.Date); err != nil {
+					problems.field(ProblemDate, "date", "некорректная дата %q, ожидается ДД.ММ.ГГГГ", qa.Date)
+				}
+				if len(strings.TrimSpace(string(qa.Answer))) == 0 {
+					problems.field(ProblemEmpty, "answer", "пустой ответ")
+				}
+				if skip, err := problems.report(validator); skip || err != nil {
+					return err
+				}
 
 				*total_qas++
-				//поле сортировки
-				qa.SortDate, _ = time.Parse("02.01.2006", qa.Date)
+				qa.Source = current_path
+				//поля даты для шаблонов
 				qa.Day = qa.SortDate.Day()
 				qa.Month = RussianMonth[qa.SortDate.Month()]
 				qa.Year = qa.SortDate.Year()
@@ -86,12 +127,13 @@ func handleQAFiles(qas *SortedQAList, total_qas *int) filepath.WalkFunc {
 
 //функция загрузки списка вопросов и ответов
 //qa_source_dir - исходная директория записей вопрос-ответ
-func loadQA(qa_source_dir string) (*SortedQAList, int, error) {
+//validator - проверка исходных записей, nil прерывает загрузку на первой ошибке
+func loadQA(qa_source_dir string, validator *Validator) (*SortedQAList, int, error) {
 	var qas SortedQAList
 	total_qa := 0
 	if _, err := os.Stat(qa_source_dir); err == nil {
 		//обрабатываем все файлы с расширением xml из директории Вопросы и ответы и её поддиректорий
-		err = filepath.Walk(qa_source_dir, handleQAFiles(&qas, &total_qa))
+		err = filepath.Walk(qa_source_dir, handleQAFiles(&qas, &total_qa, validator))
 		if err != nil {
 			return nil, 0, err
 		}
@@ -103,18 +145,15 @@ func loadQA(qa_source_dir string) (*SortedQAList, int, error) {
 
 //--------------------------------------------------------------------
 //формирование страницы Вопрос-ответ
-//destination_dir - целевая директория
-//settings_dir - директория файлов настроек сайта
-//qa_dir - исходная директория записей
-//templates_dir - директория шаблонов сайта
-//domain - домен сайта
-//sitemap - содержимое файла sitemap
-func CreateQA(destination_dir string, settings_dir string, qa_dir string, templates_dir string, domain string, sitemap *string) error {
-	//загружаем список вопросов и ответов
-	qas, total_qas, err := loadQA(qa_dir)
-	if err != nil {
-		return err
-	}
+//site - общие данные сайта, содержащие загруженные записи Вопросы и ответы
+//sitemap - записи sitemap
+//output - запись сформированных файлов в целевую директорию
+func CreateQA(site *Site, sitemap *Sitemap, output *OutputWriter) error {
+	cfg := site.Config
+	settings_dir := cfg.SettingsDir()
+	templates_dir := cfg.TemplatesDir()
+	//список вопросов и ответов загружен вместе с данными сайта
+	qas := site.qa
 	//проверяем наличие в директории настроек сайта шаблона страницы Вопросы и ответы qa.html
 	qa_template_path := filepath.Join(settings_dir, "qa.html")
 	if _, err := os.Stat(qa_template_path); os.IsNotExist(err) {
@@ -123,22 +162,33 @@ func CreateQA(destination_dir string, settings_dir string, qa_dir string, templa
 	//парсим шаблон страницы
 	//данные для передачи шаблону
 	data := struct {
+		Site       *Site
 		Fuseaction string
 		QA         SortedQAList
 		total_qa   int
 	}{
+		site,
 		"qa.html",
-		*qas,
-		total_qas,
+		qas,
+		site.QACount,
 	}
-	content, err := ParseFileView(qa_template_path, templates_dir, data, "qa.html")
-	if err != nil {
-		return errors.New(ErrorMessages["parse_template_error"] + err.Error())
+	//исходные файлы записей
+	sources := []string{}
+	for _, qa := range qas {
+		sources = append(sources, qa.Source)
+		if len(qa.MarkdownSource) > 0 {
+			sources = append(sources, qa.MarkdownSource)
+		}
+	}
+	//формируем и сохраняем страницу, если изменились её шаблоны или записи
+	if err := output.Render(filepath.Join(cfg.Destination, cfg.Output.QA), qa_template_path, templates_dir, data, "qa.html", sources...); err != nil {
+		return err
 	}
-	//сохраняем сформированную страницу
-	err = ioutil.WriteFile(filepath.Join(destination_dir, "qa.html"), []byte(content), 0755)
-	if err != nil {
-		return errors.New(ErrorMessages["error_creating_file"] + err.Error())
+	//добавляем страницу в sitemap, датой изменения считается дата последней записи
+	entry := SitemapEntry{Kind: SitemapQA, Loc: cfg.Domain + "/" + cfg.Output.QA}
+	if len(qas) > 0 {
+		entry.LastMod = qas[0].SortDate
 	}
+	sitemap.Add(entry)
 	return nil
 }
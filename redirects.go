@@ -0,0 +1,307 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Перенаправления со старых адресов страниц.
+// Старые адреса страницы задаются:
+//   - элементом <aliases>/old.html|/blog/posts/old.html</aliases> (ключом заголовка aliases) поста или публикации;
+//   - шаблонным комментарием {{/* aliases: /old.html /older/ */}} в исходном файле страницы;
+//   - строками «старый адрес новый адрес» файла __settings/redirects, строки, начинающиеся с #, пропускаются.
+//
+// Для каждого старого адреса формируется страница-заглушка с перенаправлением <meta http-equiv="refresh">
+// на новый адрес; адрес, оканчивающийся символом / или не имеющий расширения, — файлом index.html директории.
+// Параметры секции redirects файла конфигурации дополнительно формируют правила перенаправления для сервера:
+// .htaccess (Apache), redirects.map (nginx) и _redirects (Netlify и совместимые хостинги).
+//
+// Правило перенаправления для nginx подключается в секции http и используется в секции server:
+//
+//	map $uri $redirect_uri {
+//	    include /var/www/example.com/redirects.map;
+//	}
+//	if ($redirect_uri) {
+//	    return 301 $redirect_uri;
+//	}
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RedirectsFile — имя файла перенаправлений в директории настроек сайта.
+const RedirectsFile = "redirects"
+
+// Имена файлов правил перенаправления для сервера.
+const (
+	HtaccessFile      = ".htaccess"
+	NginxRedirectFile = "redirects.map"
+	NetlifyRedirects  = "_redirects"
+)
+
+// Шаблонный комментарий со старыми адресами страницы.
+var aliasesMarker = regexp.MustCompile(`\{\{-?\s*/\*\s*aliases:\s*(.*?)\s*\*/\s*-?\}\}`)
+
+// RedirectOptions описывает настройки перенаправлений.
+type RedirectOptions struct {
+	// Формировать ли страницы-заглушки с перенаправлением.
+	Stubs bool
+	// Формировать ли правила перенаправления .htaccess.
+	Htaccess bool
+	// Формировать ли правила перенаправления redirects.map для nginx.
+	Nginx bool
+	// Формировать ли файл _redirects.
+	Netlify bool
+}
+
+// Redirect описывает перенаправление со старого адреса на новый.
+type Redirect struct {
+	// Старый адрес относительно домена сайта, начинается символом /.
+	From string
+	// Новый адрес: адрес относительно домена сайта или адрес другого сайта.
+	To string
+	// Файл, в котором задано перенаправление.
+	Source string
+}
+
+// Redirects собирает перенаправления, которые добавляют генераторы страниц.
+// Методы nil-значения ничего не делают.
+type Redirects struct {
+	mu      sync.Mutex
+	cfg     *Config
+	entries []Redirect
+}
+
+// NewRedirects создаёт пустой список перенаправлений.
+func NewRedirects(cfg *Config) *Redirects {
+	return &Redirects{cfg: cfg}
+}
+
+// Add добавляет перенаправления со старых адресов aliases на адрес to.
+// Адреса могут начинаться с домена сайта; адрес без символа / в начале считается адресом от корня сайта.
+// source — файл, в котором заданы старые адреса.
+func (r *Redirects) Add(aliases []string, to string, source string) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, alias := range aliases {
+		if alias = strings.TrimSpace(alias); len(alias) > 0 {
+			r.entries = append(r.entries, Redirect{From: r.sitePath(alias), To: r.sitePath(to), Source: source})
+		}
+	}
+}
+
+// sitePath приводит адрес страницы сайта к адресу относительно домена; адреса других сайтов не изменяются.
+func (r *Redirects) sitePath(address string) string {
+	if rest := strings.TrimPrefix(address, r.cfg.Domain); rest != address && (len(rest) == 0 || rest[0] == '/') {
+		address = rest
+	}
+	if strings.Contains(address, "://") {
+		return address
+	}
+
+	return "/" + strings.TrimLeft(address, "/")
+}
+
+// splitAliases разбирает список старых адресов, разделённых символами |, запятой или пробелом.
+func splitAliases(value string) []string {
+	return strings.FieldsFunc(value, func(r rune) bool {
+		return r == '|' || r == ',' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
+// pageAliases возвращает старые адреса страницы из комментария {{/* aliases: ... */}} исходного файла.
+func pageAliases(pagepath string) []string {
+	raw, err := readPageTemplate(pagepath)
+	if err != nil {
+		return nil
+	}
+
+	var aliases []string
+	for _, match := range aliasesMarker.FindAllStringSubmatch(raw, -1) {
+		aliases = append(aliases, splitAliases(match[1])...)
+	}
+
+	return aliases
+}
+
+// AddSite добавляет перенаправления со старых адресов опубликованных постов и публикаций.
+func (r *Redirects) AddSite(site *Site) {
+	for _, post := range site.Posts {
+		r.Add(splitAliases(post.Aliases), post.URL, post.Source)
+	}
+	for _, article := range site.Articles {
+		r.Add(splitAliases(article.Aliases), site.Config.ArticleURL(article), article.Source)
+	}
+}
+
+// Load добавляет перенаправления из файла __settings/redirects, если он есть.
+func (r *Redirects) Load() error {
+	if r == nil {
+		return nil
+	}
+
+	file := filepath.Join(r.cfg.SettingsDir(), RedirectsFile)
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return fmt.Errorf("%s:%d: строка перенаправления должна содержать старый и новый адреса: %q", file, line, text)
+		}
+		r.Add(fields[:1], fields[1], fmt.Sprintf("%s:%d", file, line))
+	}
+
+	return scanner.Err()
+}
+
+// stubFile возвращает путь страницы-заглушки старого адреса from относительно домена сайта:
+// адрес без расширения считается адресом директории.
+func stubFile(from string) string {
+	if !strings.HasSuffix(from, "/") && len(path.Ext(from)) == 0 {
+		from += "/"
+	}
+
+	return from
+}
+
+// Entries проверяет перенаправления и возвращает их, отсортированными по старому адресу.
+// Ошибка — старый адрес, перенаправленный на разные адреса, или перенаправление адреса на самого себя.
+func (r *Redirects) Entries() ([]Redirect, error) {
+	if r == nil {
+		return nil, nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seen := map[string]Redirect{}
+	var entries []Redirect
+	for _, entry := range r.entries {
+		if strings.Contains(entry.From, "://") {
+			return nil, fmt.Errorf("%s: старый адрес %s должен быть адресом этого сайта", entry.Source, entry.From)
+		}
+		if stubFile(entry.From) == stubFile(entry.To) {
+			return nil, fmt.Errorf("%s: перенаправление адреса %s на самого себя", entry.Source, entry.From)
+		}
+		if other, ok := seen[entry.From]; ok {
+			if other.To != entry.To {
+				return nil, fmt.Errorf("старый адрес %s перенаправлен на разные адреса: %s (%s) и %s (%s)", entry.From, other.To, other.Source, entry.To, entry.Source)
+			}
+			continue
+		}
+		seen[entry.From] = entry
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].From < entries[j].From })
+
+	return entries, nil
+}
+
+// Write формирует страницы-заглушки и файлы правил перенаправления в целевой директории.
+// Старый адрес не может совпадать с адресом сформированной сборкой страницы или исходного файла.
+func (r *Redirects) Write(output *OutputWriter) error {
+	entries, err := r.Entries()
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	cfg := r.cfg
+
+	// Адреса правил сервера отсчитываются от корня сервера, а не от адреса сайта.
+	base := ""
+	if u, err := url.Parse(cfg.Domain); err == nil {
+		base = strings.TrimRight(u.Path, "/")
+	}
+	var htaccess, nginx, netlify strings.Builder
+	htaccess.WriteString("# Сформировано googol из старых адресов страниц.\n")
+	nginx.WriteString("# Сформировано googol из старых адресов страниц; подключается в секции map $uri $redirect_uri.\n")
+	for _, entry := range entries {
+		target := entry.To
+		if !strings.Contains(target, "://") {
+			target = cfg.Domain + target
+		}
+
+		if cfg.Redirects.Stubs {
+			file := cfg.PermalinkFile(stubFile(entry.From))
+			rel, _ := filepath.Rel(cfg.Destination, file)
+			if output.Generated(file) {
+				return fmt.Errorf("%s: старый адрес %s совпадает с адресом сформированной страницы", entry.Source, entry.From)
+			}
+			if _, err := os.Stat(filepath.Join(cfg.Source, rel)); err == nil {
+				return fmt.Errorf("%s: старый адрес %s совпадает с адресом файла исходной директории", entry.Source, entry.From)
+			}
+			if _, err := output.WriteFile(file, redirectStub(target)); err != nil {
+				return err
+			}
+		}
+
+		fmt.Fprintf(&htaccess, "Redirect 301 %s %s\n", base+entry.From, target)
+		fmt.Fprintf(&nginx, "%s %s;\n", base+entry.From, target)
+		fmt.Fprintf(&netlify, "%s %s 301\n", base+entry.From, target)
+	}
+
+	for _, file := range []struct {
+		enabled bool
+		name    string
+		content string
+	}{
+		{cfg.Redirects.Htaccess, HtaccessFile, htaccess.String()},
+		{cfg.Redirects.Nginx, NginxRedirectFile, nginx.String()},
+		{cfg.Redirects.Netlify, NetlifyRedirects, netlify.String()},
+	} {
+		if !file.enabled {
+			continue
+		}
+		path := filepath.Join(cfg.Destination, file.name)
+		if _, err := os.Stat(filepath.Join(cfg.Source, file.name)); err == nil || output.Generated(path) {
+			return fmt.Errorf("файл %s уже сформирован сборкой, отключите параметр секции redirects или удалите файл из исходной директории", file.name)
+		}
+		if _, err := output.WriteFile(path, []byte(file.content)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// redirectStub возвращает страницу-заглушку, перенаправляющую на адрес target.
+func redirectStub(target string) []byte {
+	escaped := html.EscapeString(target)
+
+	return []byte(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>Страница перемещена</title>
+<meta name="robots" content="noindex">
+<link rel="canonical" href="` + escaped + `">
+<meta http-equiv="refresh" content="0; url=` + escaped + `">
+</head>
+<body>
+<p>Страница перемещена: <a href="` + escaped + `">` + escaped + `</a></p>
+</body>
+</html>
+`)
+}
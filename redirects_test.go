@@ -0,0 +1,104 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeRedirectsSite создаёт исходную директорию сайта со старыми адресами поста, публикации и страницы.
+func writeRedirectsSite(t *testing.T, cfg *Config) {
+	t.Helper()
+
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости" slug="news"></tag></tags>`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "blog.html"), `{{range .Blog}}{{.Title}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "post.html"), `{{.Blogpost.Title}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "articles.html"), `{{range .Articles}}{{.Title}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "page.html"), `{{.Content}}`)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "new.xml"), `<post><date>01.02.2026</date><tagid>1</tagid><title>Пост</title><aliases>/old-post.html|https://example.test/blog/posts/legacy/</aliases></post>`)
+	writeTestFile(t, filepath.Join(cfg.ArticlesDir(), "guide.xml"), `<article><title>Руководство</title><pages>Первая</pages><aliases>articles/manual</aliases></article>`)
+	writeTestFile(t, filepath.Join(cfg.ArticlesDir(), "guide", "1.html"), "<p>Первая</p>")
+	writeTestFile(t, filepath.Join(cfg.Source, "about.html"), `{{/* aliases: /about-old.html /company/about */}}<p>О нас</p>`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), RedirectsFile), "# старый адрес  новый адрес\n\n/blog/1/ /blog/news/\n/moved.html https://other.test/x?a=1&b=2\n")
+}
+
+func TestBuild_WritesRedirects(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.Permalinks.Tag = "/:blog/:tagslug/"
+	cfg.Redirects = RedirectOptions{Stubs: true, Htaccess: true, Nginx: true, Netlify: true}
+	writeRedirectsSite(t, cfg)
+	if err := Build(cfg); err != nil {
+		t.Fatalf("Build вернул ошибку: %v", err)
+	}
+
+	files := readTree(t, cfg.Destination)
+	for rel, target := range map[string]string{
+		"old-post.html":                "https://example.test/blog/posts/new.html",
+		"blog/posts/legacy/index.html": "https://example.test/blog/posts/new.html",
+		"articles/manual/index.html":   "https://example.test/articles/guide/",
+		"about-old.html":               "https://example.test/about.html",
+		"company/about/index.html":     "https://example.test/about.html",
+		"blog/1/index.html":            "https://example.test/blog/news/",
+		"moved.html":                   "https://other.test/x?a=1&amp;b=2",
+	} {
+		stub := files[filepath.FromSlash(rel)]
+		if !strings.Contains(stub, `<meta http-equiv="refresh" content="0; url=`+target+`">`) || !strings.Contains(stub, `<link rel="canonical" href="`+target+`">`) {
+			t.Errorf("заглушка %s не перенаправляет на %s:\n%s", rel, target, stub)
+		}
+	}
+	for file, line := range map[string]string{
+		HtaccessFile:      "Redirect 301 /about-old.html https://example.test/about.html\n",
+		NginxRedirectFile: "/company/about https://example.test/about.html;\n",
+		NetlifyRedirects:  "/moved.html https://other.test/x?a=1&b=2 301\n",
+	} {
+		if !strings.Contains(files[file], line) || strings.Count(files[file], "\n") < 7 {
+			t.Errorf("%s не содержит %q:\n%s", file, line, files[file])
+		}
+	}
+	if strings.Contains(files[SitemapFile], "old") {
+		t.Errorf("sitemap содержит старые адреса:\n%s", files[SitemapFile])
+	}
+
+	// Удалённое перенаправление удаляет заглушку при следующей сборке.
+	if err := os.Remove(filepath.Join(cfg.SettingsDir(), RedirectsFile)); err != nil {
+		t.Fatal(err)
+	}
+	cfg.Redirects = RedirectOptions{Stubs: true}
+	if err := Build(cfg); err != nil {
+		t.Fatalf("повторная сборка вернула ошибку: %v", err)
+	}
+	files = readTree(t, cfg.Destination)
+	for _, rel := range []string{"moved.html", HtaccessFile, NginxRedirectFile, NetlifyRedirects} {
+		if _, ok := files[filepath.FromSlash(rel)]; ok {
+			t.Errorf("файл %s не удалён после удаления перенаправления", rel)
+		}
+	}
+	if _, ok := files["old-post.html"]; !ok {
+		t.Errorf("заглушка старого адреса поста удалена")
+	}
+}
+
+func TestBuild_RejectsInvalidRedirects(t *testing.T) {
+	t.Parallel()
+
+	for name, redirects := range map[string]string{
+		"страница":      "/about.html /index.html\n",
+		"исходный файл": "/style.css /about.html\n",
+		"разные адреса": "/old-post.html /about.html\n",
+		"на себя":       "/about /about/\n",
+		"формат":        "/only-one\n",
+		"другой сайт":   "https://other.test/a /about.html\n",
+	} {
+		cfg := newTestSiteConfig(t)
+		writeRedirectsSite(t, cfg)
+		writeTestFile(t, filepath.Join(cfg.Source, "style.css"), "body{}")
+		writeTestFile(t, filepath.Join(cfg.SettingsDir(), RedirectsFile), redirects)
+		if err := Build(cfg); err == nil {
+			t.Errorf("%s: ожидалась ошибка перенаправления %q", name, redirects)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Навигация по постам блога и похожие посты.
+// Шаблону страницы поста post.html кроме самого поста передаются:
+//   - PrevPost и NextPost — предыдущий (более старый) и следующий (более новый) посты блога;
+//   - PrevInTag и NextInTag — предыдущий и следующий посты основной (первой) рубрики поста;
+//   - RelatedPosts — не более related_posts похожих постов.
+//
+// Соседнего поста может не быть, поэтому ссылки выводятся через with:
+//
+//	{{with .PrevPost}}<a href="{{.URL}}">← {{.Title}}</a>{{end}}
+//	{{range .RelatedPosts}}<a href="{{.URL}}">{{.Title}}</a>{{end}}
+//
+// Похожесть поста — количество общих рубрик, умноженное на relatedTagWeight, плюс количество общих ключевых
+// слов: элементов <keywords> (ключа заголовка keywords) и слов заголовка длиной не меньше relatedMinWord букв.
+// Посты без общих рубрик и ключевых слов не считаются похожими, при равной похожести первым идёт более новый пост.
+
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Вес общей рубрики при подборе похожих постов.
+const relatedTagWeight = 3
+
+// Минимальная длина слова заголовка, учитываемого как ключевое слово.
+const relatedMinWord = 4
+
+// postKeywords возвращает множество ключевых слов поста в нижнем регистре:
+// элементы keywords, разделённые запятой или символом |, и слова заголовка.
+func postKeywords(post Post) map[string]bool {
+	keywords := map[string]bool{}
+	for _, keyword := range strings.FieldsFunc(post.Keywords, func(r rune) bool { return r == ',' || r == '|' }) {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); len(keyword) > 0 {
+			keywords[keyword] = true
+		}
+	}
+	for _, word := range strings.FieldsFunc(post.Title, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if utf8.RuneCountInString(word) >= relatedMinWord {
+			keywords[strings.ToLower(word)] = true
+		}
+	}
+
+	return keywords
+}
+
+// relatedIndex подбирает похожие посты для постов блога.
+type relatedIndex struct {
+	posts    []Post
+	keywords []map[string]bool
+}
+
+// newRelatedIndex вычисляет ключевые слова постов, отсортированных от новых к старым.
+func newRelatedIndex(posts []Post) *relatedIndex {
+	index := &relatedIndex{posts: posts, keywords: make([]map[string]bool, len(posts))}
+	for i, post := range posts {
+		index.keywords[i] = postKeywords(post)
+	}
+
+	return index
+}
+
+// Related возвращает не более limit постов, похожих на пост с номером i, от более похожих к менее похожим.
+func (index *relatedIndex) Related(i int, limit int) []Post {
+	post := index.posts[i]
+	type candidate struct {
+		num   int
+		score int
+	}
+	var candidates []candidate
+	for j, other := range index.posts {
+		if j == i {
+			continue
+		}
+		score := 0
+		for _, id := range other.Tagids {
+			for _, own := range post.Tagids {
+				if id == own {
+					score += relatedTagWeight
+				}
+			}
+		}
+		for keyword := range index.keywords[j] {
+			if index.keywords[i][keyword] {
+				score++
+			}
+		}
+		if score > 0 {
+			candidates = append(candidates, candidate{j, score})
+		}
+	}
+	// Посты отсортированы от новых к старым, поэтому при равной похожести сохраняется порядок по дате.
+	sort.SliceStable(candidates, func(a, b int) bool { return candidates[a].score > candidates[b].score })
+
+	related := []Post{}
+	for _, c := range candidates {
+		if len(related) == limit {
+			break
+		}
+		related = append(related, index.posts[c.num])
+	}
+
+	return related
+}
+
+// postNeighbours возвращает предыдущий (более старый) и следующий (более новый) посты для поста source
+// в списке posts, отсортированном от новых к старым; отсутствующий сосед — nil.
+func postNeighbours(posts []Post, source string) (prev *Post, next *Post) {
+	for i := range posts {
+		if posts[i].Source != source {
+			continue
+		}
+		if i+1 < len(posts) {
+			prev = &posts[i+1]
+		}
+		if i > 0 {
+			next = &posts[i-1]
+		}
+		break
+	}
+
+	return prev, next
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRelatedIndex_RanksByTagsAndKeywords(t *testing.T) {
+	t.Parallel()
+
+	posts := []Post{
+		{Fuseaction: "go-generics", Title: "Обобщения в Golang", Tagids: []int{1}, Keywords: "go, generics"},
+		{Fuseaction: "garden", Title: "Весенний сад", Tagids: []int{2}},
+		{Fuseaction: "go-modules", Title: "Модули Golang", Tagids: []int{1}, Keywords: "go"},
+		{Fuseaction: "go-errors", Title: "Ошибки", Tagids: []int{1, 3}, Keywords: "Go|generics"},
+		{Fuseaction: "cooking", Title: "Рецепты", Tagids: []int{3}},
+	}
+	index := newRelatedIndex(posts)
+
+	names := func(list []Post) []string {
+		result := []string{}
+		for _, post := range list {
+			result = append(result, post.Fuseaction)
+		}
+		return result
+	}
+	// go-errors: рубрика и два ключевых слова; go-modules: рубрика, go и «golang» из заголовка — равная похожесть,
+	// первым идёт более новый пост.
+	if got := names(index.Related(0, 5)); !reflect.DeepEqual(got, []string{"go-modules", "go-errors"}) {
+		t.Errorf("похожие посты go-generics = %v", got)
+	}
+	if got := names(index.Related(3, 1)); !reflect.DeepEqual(got, []string{"go-generics"}) {
+		t.Errorf("похожие посты go-errors с ограничением 1 = %v", got)
+	}
+	if got := names(index.Related(1, 5)); len(got) != 0 {
+		t.Errorf("у поста без общих рубрик и слов найдены похожие посты: %v", got)
+	}
+}
+
+func TestBuild_PostNavigation(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.RelatedPosts = 1
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости"></tag><tag id="2" name="Обзоры"></tag></tags>`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "blog.html"), `{{range .Blog}}{{.Title}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "post.html"),
+		`{{with .PrevPost}}{{.Title}}{{end}}[{{.Blogpost.Title}}]{{with .NextPost}}{{.Title}}{{end}}|{{with .PrevInTag}}{{.Title}}{{end}}/{{with .NextInTag}}{{.Title}}{{end}}|{{range .RelatedPosts}}{{.Title}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "a.xml"), `<post><date>01.01.2026</date><tagid>1</tagid><title>Первый</title></post>`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "b.xml"), `<post><date>02.01.2026</date><tagid>2</tagid><title>Второй</title></post>`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "c.xml"), `<post><date>03.01.2026</date><tagid>1</tagid><title>Третий</title></post>`)
+	if err := Build(cfg); err != nil {
+		t.Fatalf("Build вернул ошибку: %v", err)
+	}
+
+	files := readTree(t, cfg.Destination)
+	for rel, expected := range map[string]string{
+		"blog/posts/a.html": "[Первый]Второй|/Третий|Третий",
+		"blog/posts/b.html": "Первый[Второй]Третий|/|",
+		"blog/posts/c.html": "Второй[Третий]|Первый/|Первый",
+	} {
+		if content := files[filepath.FromSlash(rel)]; content != expected {
+			t.Errorf("%s = %q, ожидалось %q", rel, content, expected)
+		}
+	}
+}
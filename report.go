@@ -0,0 +1,205 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Отчёт о сборке.
+// OutputWriter учитывает каждый файл целевой директории: сформированный (записанный), неизменённый,
+// скопированный из исходной директории и удалённый как не сформированный сборкой. Файлы учитываются
+// по генераторам — этапам сборки, которые их сформировали (articles, blog, qa, pages, redirects, search,
+// sitemap). Отчёт содержит также время выполнения каждого этапа и выводится в конце сборки;
+// параметр -report=файл.json записывает его в файл, в том числе при ошибке сборки (поле error).
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Действия с файлами целевой директории.
+const (
+	FileRendered  = "rendered"
+	FileUnchanged = "unchanged"
+	FileCopied    = "copied"
+	FileDeleted   = "deleted"
+)
+
+// fileActionNames — названия действий с файлами в текстовом выводе.
+var fileActionNames = map[string]string{
+	FileRendered:  "сформирован",
+	FileUnchanged: "не изменился",
+	FileCopied:    "скопирован",
+	FileDeleted:   "удалён",
+}
+
+// ReportOtherGenerator — генератор файлов, сформированных вне этапов сборки
+// или удалённых, если манифест прошлой сборки не содержит их генератора.
+const ReportOtherGenerator = "other"
+
+// FileCounts — количество файлов по действиям.
+type FileCounts struct {
+	Rendered  int `json:"rendered"`
+	Unchanged int `json:"unchanged"`
+	Copied    int `json:"copied"`
+	Deleted   int `json:"deleted"`
+}
+
+// add учитывает файл с действием action.
+func (c *FileCounts) add(action string) {
+	switch action {
+	case FileRendered:
+		c.Rendered++
+	case FileUnchanged:
+		c.Unchanged++
+	case FileCopied:
+		c.Copied++
+	case FileDeleted:
+		c.Deleted++
+	}
+}
+
+// StageTiming — время выполнения этапа сборки.
+type StageTiming struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// BuildReport описывает итоги сборки. Методы nil-значения ничего не делают.
+type BuildReport struct {
+	mu sync.Mutex
+	// Время запуска и длительность сборки.
+	Started    time.Time `json:"started"`
+	DurationMs float64   `json:"duration_ms"`
+	// Исходная и целевая директории.
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+	// Этапы сборки в порядке выполнения.
+	Stages []StageTiming `json:"stages"`
+	// Количество файлов по генераторам и всего.
+	Generators map[string]*FileCounts `json:"generators"`
+	Total      FileCounts             `json:"total"`
+	// Предупреждения проверки исходных записей и неопубликованные посты.
+	Warnings     int `json:"warnings"`
+	SkippedPosts int `json:"skipped_posts"`
+	// Ошибка, прервавшая сборку.
+	Error string `json:"error,omitempty"`
+	// Пробный запуск: файлы учтены, но не записаны и не удалены.
+	DryRun bool `json:"dry_run"`
+
+	// Длительность сборки.
+	elapsed time.Duration
+}
+
+// NewBuildReport создаёт отчёт о сборке сайта с конфигурацией cfg.
+func NewBuildReport(cfg *Config) *BuildReport {
+	return &BuildReport{
+		Started:     time.Now(),
+		Source:      cfg.Source,
+		Destination: cfg.Destination,
+		DryRun:      cfg.DryRun,
+		Stages:      []StageTiming{},
+		Generators:  map[string]*FileCounts{},
+	}
+}
+
+// AddFile учитывает файл генератора generator с действием action.
+func (r *BuildReport) AddFile(generator string, action string) {
+	if r == nil {
+		return
+	}
+	if len(generator) == 0 {
+		generator = ReportOtherGenerator
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts, ok := r.Generators[generator]
+	if !ok {
+		counts = &FileCounts{}
+		r.Generators[generator] = counts
+	}
+	counts.add(action)
+	r.Total.add(action)
+}
+
+// AddStage добавляет время выполнения этапа name.
+func (r *BuildReport) AddStage(name string, elapsed time.Duration) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Stages = append(r.Stages, StageTiming{Name: name, DurationMs: durationMs(elapsed)})
+}
+
+// AddSite учитывает предупреждения и неопубликованные посты загруженного сайта.
+func (r *BuildReport) AddSite(site *Site) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Warnings = len(site.Warnings())
+	r.SkippedPosts = len(site.SkippedPosts())
+}
+
+// Finish завершает отчёт: вычисляет длительность сборки и сохраняет ошибку, если сборка прервана.
+func (r *BuildReport) Finish(err error) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.elapsed = time.Since(r.Started)
+	r.DurationMs = durationMs(r.elapsed)
+	if err != nil {
+		r.Error = err.Error()
+	}
+}
+
+// GeneratorNames возвращает генераторы, файлы которых учтены в отчёте, по алфавиту.
+func (r *BuildReport) GeneratorNames() []string {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.Generators))
+	for name := range r.Generators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Write записывает отчёт в формате JSON в файл file.
+func (r *BuildReport) Write(file string) error {
+	if r == nil || len(file) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	raw, err := json.MarshalIndent(r, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err = os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return errors.New(ErrorMessages["error_creating_dir"] + err.Error())
+	}
+	if err = ioutil.WriteFile(file, append(raw, '\n'), 0644); err != nil {
+		return errors.New(ErrorMessages["error_creating_file"] + err.Error())
+	}
+
+	return nil
+}
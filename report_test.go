@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// readReport читает отчёт о сборке из файла.
+func readReport(t *testing.T, file string) *BuildReport {
+	t.Helper()
+
+	raw, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatalf("отчёт не записан: %v", err)
+	}
+	report := &BuildReport{}
+	if err = json.Unmarshal(raw, report); err != nil {
+		t.Fatalf("отчёт не разобран: %v\n%s", err, raw)
+	}
+
+	return report
+}
+
+func TestBuild_WritesReport(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.LogLevel = LogQuiet
+	cfg.Report = filepath.Join(t.TempDir(), "reports", "build.json")
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.Source, "index.html"), `<p>Главная</p>`)
+	writeTestFile(t, filepath.Join(cfg.Source, "about.html"), `<p>О нас</p>`)
+	writeTestFile(t, filepath.Join(cfg.Source, "assets", "style.css"), `body{}`)
+	if err := Build(cfg); err != nil {
+		t.Fatalf("Build вернул ошибку: %v", err)
+	}
+
+	report := readReport(t, cfg.Report)
+	expected := map[string]*FileCounts{
+		"pages":   {Rendered: 2, Copied: 1},
+		"sitemap": {Rendered: 1},
+	}
+	if !reflect.DeepEqual(report.Generators, expected) {
+		t.Errorf("первая сборка: %+v", report.Generators)
+	}
+	stages := []string{}
+	for _, stage := range report.Stages {
+		stages = append(stages, stage.Name)
+	}
+	if !reflect.DeepEqual(stages, []string{"sync", "load", "pages", "redirects", "search", "sitemap", "cleanup"}) {
+		t.Errorf("этапы сборки: %v", stages)
+	}
+	if report.Destination != cfg.Destination || len(report.Error) > 0 {
+		t.Errorf("отчёт: %+v", report)
+	}
+
+	if err := os.Remove(filepath.Join(cfg.Source, "about.html")); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(cfg.Source, "assets", "style.css"), `body{margin:0}`)
+	if err := Build(cfg); err != nil {
+		t.Fatalf("Build вернул ошибку: %v", err)
+	}
+	report = readReport(t, cfg.Report)
+	expected = map[string]*FileCounts{
+		"pages":   {Unchanged: 1, Copied: 1, Deleted: 1},
+		"sitemap": {Rendered: 1},
+	}
+	if !reflect.DeepEqual(report.Generators, expected) || report.Total != (FileCounts{Rendered: 1, Unchanged: 1, Copied: 1, Deleted: 1}) {
+		t.Errorf("вторая сборка: %+v, всего %+v", report.Generators, report.Total)
+	}
+}
+
+func TestBuild_WritesReportOnError(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.LogLevel = LogQuiet
+	cfg.Report = filepath.Join(t.TempDir(), "build.json")
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.Source, "index.html"), `{{.Missing}`)
+	if err := Build(cfg); err == nil {
+		t.Fatal("ожидалась ошибка шаблона")
+	}
+
+	if report := readReport(t, cfg.Report); len(report.Error) == 0 {
+		t.Errorf("отчёт не содержит ошибку сборки: %+v", report)
+	}
+}
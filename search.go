@@ -0,0 +1,508 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Поисковый индекс для поиска по сайту без серверной части.
+// При enabled = true секции search файла конфигурации сборка формирует файл индекса search.json с постами,
+// страницами публикаций, записями Вопросы и ответы и страницами сайта, и скрипт поиска search.js.
+// Индекс содержит документы (заголовок, адрес, аннотацию, начало текста, рубрики и дату) и словарь terms:
+// основа слова — номера документов, в тексте которых она встречается. Основы слов вычисляются стеммингом
+// Snowball для русского языка (см. stemmer.go), скрипт поиска приводит слова запроса к тем же основам.
+//
+// Если в директории настроек есть шаблон search.html, по нему формируется страница результатов поиска.
+// Шаблону передаются адреса индекса IndexURL и скрипта ScriptURL; скрипт выводит результаты для параметра
+// адреса q в элемент с id search-results и подставляет запрос в поле с id search-query:
+//
+//	<form action="{{.Site.Domain}}/search.html"><input id="search-query" name="q"></form>
+//	<div id="search-results" data-index="{{.IndexURL}}" data-empty="Ничего не найдено"></div>
+//	<script src="{{.ScriptURL}}"></script>
+//
+// Другие страницы могут загрузить индекс и искать сами: googolSearch.load(url, function (index) {
+// googolSearch.search(index, "запрос") }) возвращает документы индекса, содержащие все слова запроса.
+// Текст страниц сайта берётся из элемента <main> (или <body>) сформированной страницы без элементов
+// <script>, <style>, <nav>, <header> и <footer>.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Имена файлов поиска по умолчанию.
+const (
+	DefaultSearchIndex = "search.json"
+	DefaultSearchPage  = "search.html"
+	// SearchScriptFile — имя файла скрипта поиска в целевой директории.
+	SearchScriptFile = "search.js"
+	// SearchTemplate — имя шаблона страницы результатов поиска в директории настроек сайта.
+	SearchTemplate = "search.html"
+	// DefaultSearchTextLength — количество символов начала текста документа в индексе по умолчанию.
+	DefaultSearchTextLength = 200
+)
+
+// Виды документов поискового индекса.
+const (
+	SearchPost    = "post"
+	SearchArticle = "article"
+	SearchQA      = "qa"
+	SearchPage    = "page"
+)
+
+// Разметка сформированных страниц, используемая при извлечении текста.
+var (
+	searchSkippedElements = regexp.MustCompile(`(?is)<script\b.*?</script>|<style\b.*?</style>|<nav\b.*?</nav>|<header\b.*?</header>|<footer\b.*?</footer>|<!--.*?-->`)
+	searchMainElement     = regexp.MustCompile(`(?is)<main\b[^>]*>(.*)</main>`)
+	searchBodyElement     = regexp.MustCompile(`(?is)<body\b[^>]*>(.*)</body>`)
+	searchTitleElement    = regexp.MustCompile(`(?is)<title\b[^>]*>(.*?)</title>`)
+)
+
+// SearchOptions описывает настройки поискового индекса.
+type SearchOptions struct {
+	// Формировать ли поисковый индекс и скрипт поиска.
+	Enabled bool
+	// Имя файла индекса в целевой директории.
+	File string
+	// Имя страницы результатов поиска, формируемой по шаблону search.html.
+	Page string
+	// Шаблоны путей страниц относительно корня сайта, не включаемых в индекс (path.Match).
+	Exclude []string
+	// Количество символов начала текста документа в индексе, 0 — не включать текст.
+	TextLength int
+	// Приводить ли русские слова к основе.
+	Stemming bool
+}
+
+// SearchDocument описывает документ поискового индекса.
+type SearchDocument struct {
+	// Вид документа: post, article, qa или page.
+	Kind  string `json:"kind"`
+	Title string `json:"title"`
+	URL   string `json:"url"`
+	// Аннотация без разметки.
+	Annotation string `json:"annotation,omitempty"`
+	// Начало текста без разметки, не длиннее search.text_length символов.
+	Text string `json:"text,omitempty"`
+	// Рубрики поста через запятую.
+	Tag string `json:"tag,omitempty"`
+	// Дата поста или записи в формате 2006-01-02.
+	Date string `json:"date,omitempty"`
+
+	// Полный текст документа, по которому вычисляются основы слов.
+	content string
+	// Сформированная страница сайта, из которой текст извлекается при записи индекса.
+	file string
+}
+
+// searchIndexFile описывает содержимое файла индекса.
+type searchIndexFile struct {
+	// Алгоритм стемминга слов индекса: russian или пустая строка.
+	Stemmer string           `json:"stemmer,omitempty"`
+	Docs    []SearchDocument `json:"docs"`
+	Terms   map[string][]int `json:"terms"`
+}
+
+// SearchIndex собирает документы поискового индекса.
+// Методы nil-значения ничего не делают: индекс не формируется.
+type SearchIndex struct {
+	mu    sync.Mutex
+	cfg   *Config
+	docs  []SearchDocument
+	pages []SearchDocument
+}
+
+// NewSearchIndex создаёт поисковый индекс сайта; если поиск отключён в конфигурации, возвращает nil.
+func NewSearchIndex(cfg *Config) *SearchIndex {
+	if !cfg.Search.Enabled {
+		return nil
+	}
+
+	return &SearchIndex{cfg: cfg}
+}
+
+// excluded проверяет, исключён ли документ с адресом url параметром search.exclude.
+func (s *SearchIndex) excluded(url string) bool {
+	return pathExcluded(strings.TrimPrefix(strings.TrimPrefix(url, s.cfg.Domain), "/"), s.cfg.Search.Exclude)
+}
+
+// add добавляет документ, если он не исключён.
+func (s *SearchIndex) add(doc SearchDocument) {
+	if s.excluded(doc.URL) {
+		return
+	}
+
+	s.mu.Lock()
+	if len(doc.file) > 0 {
+		s.pages = append(s.pages, doc)
+	} else {
+		s.docs = append(s.docs, doc)
+	}
+	s.mu.Unlock()
+}
+
+// AddSite добавляет в индекс опубликованные посты, страницы публикаций и записи Вопросы и ответы.
+func (s *SearchIndex) AddSite(site *Site) {
+	if s == nil {
+		return
+	}
+	cfg := site.Config
+
+	for _, post := range site.Posts {
+		s.add(SearchDocument{
+			Kind:       SearchPost,
+			Title:      post.Title,
+			URL:        post.URL,
+			Annotation: plainText(post.Annotation),
+			Tag:        strings.Join(postTagNames(post), ", "),
+			Date:       post.SortDate.Format("2006-01-02"),
+			content:    plainText(string(post.Content)),
+		})
+	}
+
+	_, err := os.Stat(filepath.Join(cfg.SettingsDir(), "article.html"))
+	contentsTemplateEnabled := err == nil
+	for _, article := range site.Articles {
+		for i, content := range article.Content {
+			if len(content) == 0 {
+				continue
+			}
+			title := article.Title
+			if len(article.Pagetitles) > 1 && i < len(article.Pagetitles) {
+				title += ". " + article.Pagetitles[i]
+			}
+			s.add(SearchDocument{
+				Kind:       SearchArticle,
+				Title:      title,
+				URL:        cfg.ArticleURL(article) + strings.TrimSuffix(articlePageFile(contentsTemplateEnabled, i), "index.html"),
+				Annotation: plainText(article.Annotation),
+				content:    plainText(string(content)),
+			})
+		}
+	}
+
+	for _, qa := range site.qa {
+		question := plainText(qa.Question)
+		s.add(SearchDocument{
+			Kind:    SearchQA,
+			Title:   templateTruncate(80, question),
+			URL:     cfg.Domain + "/" + cfg.Output.QA,
+			Date:    qa.SortDate.Format("2006-01-02"),
+			content: question + " " + plainText(string(qa.Answer)),
+		})
+	}
+}
+
+// AddPage добавляет в индекс страницу сайта с адресом url, сформированную в файл file.
+// Текст страницы извлекается из файла при записи индекса, когда формирование страниц завершено.
+func (s *SearchIndex) AddPage(url string, file string) {
+	if s == nil || strings.TrimPrefix(url, s.cfg.Domain+"/") == s.cfg.Search.Page {
+		return
+	}
+
+	s.add(SearchDocument{Kind: SearchPage, URL: url, file: file})
+}
+
+// plainText возвращает текст без разметки с одиночными пробелами между словами.
+func plainText(s string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(htmlTagPattern.ReplaceAllString(s, " "))), " ")
+}
+
+// pageText возвращает заголовок и текст сформированной страницы: содержимое элемента <title>
+// и текст элемента <main> или <body> без служебных элементов.
+func pageText(page string) (string, string) {
+	title := ""
+	if match := searchTitleElement.FindStringSubmatch(page); match != nil {
+		title = plainText(match[1])
+	}
+
+	page = searchSkippedElements.ReplaceAllString(page, " ")
+	if match := searchMainElement.FindStringSubmatch(page); match != nil {
+		page = match[1]
+	} else if match := searchBodyElement.FindStringSubmatch(page); match != nil {
+		page = match[1]
+	}
+
+	return title, plainText(page)
+}
+
+// Documents возвращает документы индекса: посты, страницы публикаций и записи Вопросы и ответы в порядке
+// добавления, затем страницы сайта, отсортированные по адресу. Текст страниц читается из сформированных файлов
+// через output, в пробном запуске сборки — из содержимого, которое было бы записано.
+func (s *SearchIndex) Documents(output *OutputWriter) ([]SearchDocument, error) {
+	if s == nil {
+		return nil, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pages := append([]SearchDocument(nil), s.pages...)
+	sort.Slice(pages, func(i, j int) bool { return pages[i].URL < pages[j].URL })
+	for i := range pages {
+		raw, err := output.ReadFile(pages[i].file)
+		if err != nil {
+			return nil, err
+		}
+		pages[i].Title, pages[i].content = pageText(string(raw))
+		if len(pages[i].Title) == 0 {
+			pages[i].Title = strings.TrimPrefix(pages[i].URL, s.cfg.Domain)
+		}
+	}
+
+	return append(append([]SearchDocument(nil), s.docs...), pages...), nil
+}
+
+// Index возвращает содержимое файла индекса.
+func (s *SearchIndex) Index(output *OutputWriter) ([]byte, error) {
+	docs, err := s.Documents(output)
+	if err != nil {
+		return nil, err
+	}
+
+	index := searchIndexFile{Docs: []SearchDocument{}, Terms: map[string][]int{}}
+	if s.cfg.Search.Stemming {
+		index.Stemmer = "russian"
+	}
+	for num, doc := range docs {
+		text := strings.Join([]string{doc.Title, doc.Annotation, doc.Tag, doc.content}, " ")
+		seen := map[string]bool{}
+		for _, term := range searchTerms(text, s.cfg.Search.Stemming) {
+			if !seen[term] {
+				seen[term] = true
+				index.Terms[term] = append(index.Terms[term], num)
+			}
+		}
+		if s.cfg.Search.TextLength > 0 {
+			doc.Text = templateTruncate(s.cfg.Search.TextLength, doc.content)
+		}
+		index.Docs = append(index.Docs, doc)
+	}
+
+	return json.Marshal(index)
+}
+
+// Write записывает файл индекса и скрипт поиска в целевую директорию и формирует страницу результатов поиска,
+// если есть шаблон search.html. Файлы поиска не могут совпадать со сформированными сборкой или исходными файлами.
+func (s *SearchIndex) Write(site *Site, output *OutputWriter) error {
+	if s == nil {
+		return nil
+	}
+	cfg := s.cfg
+
+	check := func(name string) (string, error) {
+		path := filepath.Join(cfg.Destination, filepath.FromSlash(name))
+		if _, err := os.Stat(filepath.Join(cfg.Source, filepath.FromSlash(name))); err == nil || output.Generated(path) {
+			return "", fmt.Errorf("файл %s уже сформирован сборкой, измените параметр секции search или удалите файл из исходной директории", name)
+		}
+		return path, nil
+	}
+
+	index, err := s.Index(output)
+	if err != nil {
+		return err
+	}
+	for _, file := range []struct {
+		name    string
+		content []byte
+	}{
+		{cfg.Search.File, index},
+		{SearchScriptFile, []byte(searchScript())},
+	} {
+		path, err := check(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := output.WriteFile(path, file.content); err != nil {
+			return err
+		}
+	}
+
+	templatePath := filepath.Join(cfg.SettingsDir(), SearchTemplate)
+	if _, err := os.Stat(templatePath); os.IsNotExist(err) {
+		return nil
+	}
+	path, err := check(cfg.Search.Page)
+	if err != nil {
+		return err
+	}
+	data := struct {
+		Site       *Site
+		Fuseaction string
+		IndexURL   string
+		ScriptURL  string
+	}{
+		site,
+		SearchTemplate,
+		cfg.Domain + "/" + cfg.Search.File,
+		cfg.Domain + "/" + SearchScriptFile,
+	}
+	_, err = output.RenderFile(path, templatePath, cfg.TemplatesDir(), data, SearchTemplate)
+
+	return err
+}
+
+// searchScript возвращает скрипт поиска search.js. Окончания стемминга подставляются из stemmer.go,
+// поэтому скрипт приводит слова запроса к тем же основам, что и сборка.
+func searchScript() string {
+	list := func(endings []string) string {
+		raw, _ := json.Marshal(endings)
+		return string(raw)
+	}
+
+	return strings.NewReplacer(
+		"$PG1", list(russianPerfectiveGerund1),
+		"$PG2", list(russianPerfectiveGerund2),
+		"$ADJ", list(russianAdjective),
+		"$PART1", list(russianParticiple1),
+		"$PART2", list(russianParticiple2),
+		"$REFL", list(russianReflexive),
+		"$VERB1", list(russianVerb1),
+		"$VERB2", list(russianVerb2),
+		"$NOUN", list(russianNoun),
+		"$SUPER", list(russianSuperlative),
+		"$DERIV", list(russianDerivational),
+		"$VOWELS", russianVowels,
+	).Replace(searchScriptTemplate)
+}
+
+// searchScriptTemplate — скрипт поиска; $-параметры заменяются списками окончаний.
+const searchScriptTemplate = `// Сформировано googol: поиск по индексу сайта.
+(function () {
+	"use strict";
+	var vowels = "$VOWELS";
+	var PG1 = $PG1, PG2 = $PG2, ADJ = $ADJ, PART1 = $PART1, PART2 = $PART2, REFL = $REFL;
+	var VERB1 = $VERB1, VERB2 = $VERB2, NOUN = $NOUN, SUPER = $SUPER, DERIV = $DERIV;
+
+	function isVowel(c) { return vowels.indexOf(c) >= 0; }
+
+	function regions(w) {
+		var rv = w.length, r1 = w.length, r2 = w.length, i;
+		for (i = 0; i < w.length; i++) { if (isVowel(w[i])) { rv = i + 1; break; } }
+		for (i = 1; i < w.length; i++) { if (!isVowel(w[i]) && isVowel(w[i - 1])) { r1 = i + 1; break; } }
+		for (i = r1 + 1; i < w.length; i++) { if (!isVowel(w[i]) && isVowel(w[i - 1])) { r2 = i + 1; break; } }
+		return [rv, r2];
+	}
+
+	function ending(w, from, withAY, plain) {
+		var best = 0, i, e, start;
+		for (i = 0; i < withAY.length; i++) {
+			e = withAY[i];
+			start = w.length - e.length;
+			if (e.length > best && start - 1 >= from && w.slice(start) === e && (w[start - 1] === "а" || w[start - 1] === "я")) { best = e.length; }
+		}
+		for (i = 0; i < plain.length; i++) {
+			e = plain[i];
+			if (e.length > best && w.length - e.length >= from && w.slice(w.length - e.length) === e) { best = e.length; }
+		}
+		return best;
+	}
+
+	function stem(word) {
+		var w = word, r = regions(w), rv = r[0], r2 = r[1], n;
+		if (rv === w.length) { return word; }
+		if ((n = ending(w, rv, PG1, PG2)) > 0) {
+			w = w.slice(0, -n);
+		} else {
+			if ((n = ending(w, rv, [], REFL)) > 0) { w = w.slice(0, -n); }
+			if ((n = ending(w, rv, [], ADJ)) > 0) {
+				w = w.slice(0, -n);
+				if ((n = ending(w, rv, PART1, PART2)) > 0) { w = w.slice(0, -n); }
+			} else if ((n = ending(w, rv, VERB1, VERB2)) > 0) {
+				w = w.slice(0, -n);
+			} else if ((n = ending(w, rv, [], NOUN)) > 0) {
+				w = w.slice(0, -n);
+			}
+		}
+		if (ending(w, rv, [], ["и"]) > 0) { w = w.slice(0, -1); }
+		if ((n = ending(w, r2, [], DERIV)) > 0) { w = w.slice(0, -n); }
+		if (ending(w, rv, [], ["нн"]) > 0) {
+			w = w.slice(0, -1);
+		} else if ((n = ending(w, rv, [], SUPER)) > 0) {
+			w = w.slice(0, -n);
+			if (ending(w, rv, [], ["нн"]) > 0) { w = w.slice(0, -1); }
+		} else if (ending(w, rv, [], ["ь"]) > 0) {
+			w = w.slice(0, -1);
+		}
+		return w;
+	}
+
+	function words(text) {
+		return (text.toLowerCase().match(/[\p{L}\p{Nd}]+/gu) || []).map(function (w) {
+			return w.replace(/ё/g, "е");
+		}).filter(function (w) { return Array.from(w).length > 1; });
+	}
+
+	function terms(index, text) {
+		var seen = {};
+		return words(text).map(index.stemmer === "russian" ? stem : function (w) { return w; }).filter(function (t) {
+			return seen[t] ? false : (seen[t] = true);
+		});
+	}
+
+	function search(index, query) {
+		var list = terms(index, query), found = null, result = [];
+		if (!list.length) { return []; }
+		list.forEach(function (t) {
+			var next = {};
+			(index.terms[t] || []).forEach(function (d) { if (!found || found[d]) { next[d] = true; } });
+			found = next;
+		});
+		Object.keys(found).forEach(function (d) {
+			var title = terms(index, index.docs[d].title);
+			result.push({num: +d, score: list.filter(function (t) { return title.indexOf(t) >= 0; }).length});
+		});
+		result.sort(function (a, b) { return b.score - a.score || a.num - b.num; });
+		return result.map(function (r) { return index.docs[r.num]; });
+	}
+
+	var cache = {};
+	function load(url, callback) {
+		cache[url] = cache[url] || fetch(url).then(function (r) { return r.json(); });
+		cache[url].then(callback);
+	}
+
+	function render(container, docs) {
+		container.textContent = "";
+		if (!docs.length) {
+			container.textContent = container.getAttribute("data-empty") || "";
+			return;
+		}
+		var list = document.createElement("ol");
+		docs.forEach(function (doc) {
+			var item = document.createElement("li"), link = document.createElement("a"), text = document.createElement("p");
+			link.href = doc.url;
+			link.textContent = doc.title;
+			item.appendChild(link);
+			if (doc.date) {
+				var date = document.createElement("time");
+				date.textContent = doc.date;
+				item.appendChild(document.createTextNode(" "));
+				item.appendChild(date);
+			}
+			text.textContent = doc.annotation || doc.text || "";
+			item.appendChild(text);
+			list.appendChild(item);
+		});
+		container.appendChild(list);
+	}
+
+	function init() {
+		var container = document.getElementById("search-results"), input = document.getElementById("search-query");
+		var query = new URLSearchParams(location.search).get("q") || "";
+		if (input) { input.value = query; }
+		if (!container || !query) { return; }
+		load(container.getAttribute("data-index"), function (index) { render(container, search(index, query)); });
+	}
+
+	window.googolSearch = {stem: stem, words: words, load: load, search: search};
+	if (document.readyState === "loading") {
+		document.addEventListener("DOMContentLoaded", init);
+	} else {
+		init();
+	}
+})();
+`
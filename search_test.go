@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestStemRussian(t *testing.T) {
+	t.Parallel()
+
+	for word, stem := range map[string]string{
+		"книги":            "книг",
+		"книгами":          "книг",
+		"красивая":         "красив",
+		"важнейшим":        "важн",
+		"программирование": "программирован",
+		"программирования": "программирован",
+		"статических":      "статическ",
+		"новостей":         "новост",
+		"читающий":         "чита",
+		"прочитав":         "прочита",
+		"елки":             "елк",
+		"go":               "go",
+		"2026":             "2026",
+	} {
+		if got := stemRussian(word); got != stem {
+			t.Errorf("stemRussian(%q) = %q, ожидалось %q", word, got, stem)
+		}
+	}
+	if got := searchTerms("Ёлки, КНИГИ и go-модули!", true); !reflect.DeepEqual(got, []string{"елк", "книг", "go", "модул"}) {
+		t.Errorf("searchTerms = %v", got)
+	}
+}
+
+func TestBuild_WritesSearchIndex(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.Search.Enabled = true
+	cfg.Search.TextLength = 12
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости"></tag></tags>`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "blog.html"), `{{range .Blog}}{{.Title}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "post.html"), `{{.Blogpost.Title}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "articles.html"), `{{range .Articles}}{{.Title}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "page.html"), `{{.Content}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "qa.html"), `{{range .QA}}{{.Question}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), SearchTemplate), `<div id="search-results" data-index="{{.IndexURL}}"></div><script src="{{.ScriptURL}}"></script>`)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "gen.xml"), `<post><date>01.03.2026</date><tagid>1</tagid><title>Генераторы сайтов</title><annotation>О &lt;b&gt;генераторах&lt;/b&gt;</annotation><content>&lt;p&gt;Статические страницы&lt;/p&gt;</content></post>`)
+	writeTestFile(t, filepath.Join(cfg.ArticlesDir(), "guide.xml"), `<article><title>Руководство</title><pages>Установка|Настройка</pages></article>`)
+	writeTestFile(t, filepath.Join(cfg.ArticlesDir(), "guide", "1.html"), "<p>Установка генератора</p>")
+	writeTestFile(t, filepath.Join(cfg.ArticlesDir(), "guide", "2.html"), "<p>Настройка шаблонов</p>")
+	writeTestFile(t, filepath.Join(cfg.QADir(), "q.xml"), `<qa><date>02.03.2026</date><question>Где шаблоны?</question><answer>В директории __templates</answer></qa>`)
+	writeTestFile(t, filepath.Join(cfg.Source, "about.html"), `<html><head><title>О сайте</title><script>var генераторы;</script></head><body><nav>Меню</nav><main><h1>О нас</h1><p>Пишем &amp; генерируем</p></main></body></html>`)
+	writeTestFile(t, filepath.Join(cfg.Source, "404.html"), `<title>Не найдено</title>`)
+	if err := Build(cfg); err != nil {
+		t.Fatalf("Build вернул ошибку: %v", err)
+	}
+
+	files := readTree(t, cfg.Destination)
+	var index searchIndexFile
+	if err := json.Unmarshal([]byte(files[DefaultSearchIndex]), &index); err != nil {
+		t.Fatalf("индекс не разобран: %v\n%s", err, files[DefaultSearchIndex])
+	}
+	expected := []SearchDocument{
+		{Kind: SearchPost, Title: "Генераторы сайтов", URL: "https://example.test/blog/posts/gen.html", Annotation: "О генераторах", Text: "Статические…", Tag: "Новости", Date: "2026-03-01"},
+		{Kind: SearchArticle, Title: "Руководство. Установка", URL: "https://example.test/articles/guide/", Text: "Установка ге…"},
+		{Kind: SearchArticle, Title: "Руководство. Настройка", URL: "https://example.test/articles/guide/2.html", Text: "Настройка ша…"},
+		{Kind: SearchQA, Title: "Где шаблоны?", URL: "https://example.test/qa.html", Text: "Где шаблоны?…", Date: "2026-03-02"},
+		{Kind: SearchPage, Title: "О сайте", URL: "https://example.test/about.html", Text: "О нас Пишем…"},
+	}
+	if !reflect.DeepEqual(index.Docs, expected) {
+		t.Errorf("документы индекса:\n%+v\nожидалось:\n%+v", index.Docs, expected)
+	}
+	for term, docs := range map[string][]int{
+		"генератор":   {0, 1},
+		"шаблон":      {2, 3},
+		"статическ":   {0},
+		"генериру":    {4},
+		"__templates": nil,
+		"меню":        nil,
+	} {
+		if !reflect.DeepEqual(index.Terms[term], docs) {
+			t.Errorf("terms[%q] = %v, ожидалось %v", term, index.Terms[term], docs)
+		}
+	}
+	if index.Stemmer != "russian" {
+		t.Errorf("stemmer = %q", index.Stemmer)
+	}
+
+	if script := files[SearchScriptFile]; !strings.Contains(script, `"ывшись"`) || strings.Contains(script, "$PG1") {
+		t.Errorf("скрипт поиска не содержит окончаний стемминга:\n%s", script)
+	}
+	if page := files[DefaultSearchPage]; page != `<div id="search-results" data-index="https://example.test/search.json"></div><script src="https://example.test/search.js"></script>` {
+		t.Errorf("страница поиска = %q", page)
+	}
+}
+
+func TestBuild_SearchDisabledByDefault(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.Source, "index.html"), `<p>Главная</p>`)
+	if err := Build(cfg); err != nil {
+		t.Fatalf("Build вернул ошибку: %v", err)
+	}
+
+	files := readTree(t, cfg.Destination)
+	for _, name := range []string{DefaultSearchIndex, SearchScriptFile, DefaultSearchPage} {
+		if _, ok := files[name]; ok {
+			t.Errorf("без search.enabled сформирован файл %s", name)
+		}
+	}
+}
@@ -0,0 +1,351 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Сервер разработки.
+// Команда googol serve формирует сайт во временной (или указанной параметром -destination) директории,
+// отдаёт её по HTTP и каждые полсекунды проверяет исходную директорию и файл конфигурации.
+// При изменении файлов сайт формируется заново — благодаря манифесту и графу зависимостей
+// перезаписываются только изменившиеся страницы, — а открытые в браузере страницы обновляются:
+// в каждую отдаваемую html-страницу добавляется скрипт, подписанный на события сервера.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// LiveReloadPath — адрес потока событий, по которому браузер узнаёт о пересборке сайта.
+const LiveReloadPath = "/__googol/livereload"
+
+// ServeHashSuffix — суффикс имени директории, в которой сервер разработки хранит манифест и граф зависимостей.
+const ServeHashSuffix = "_serve"
+
+// WatchInterval — интервал проверки изменений исходных файлов.
+const WatchInterval = 500 * time.Millisecond
+
+// Скрипт обновления страницы, добавляемый в отдаваемые html-страницы.
+var liveReloadScript = []byte(`<script>(function(){var s=new EventSource("` + LiveReloadPath + `");s.onmessage=function(e){if(e.data==="reload"){location.reload();}};})();</script>`)
+
+// fileStamp описывает состояние файла для обнаружения изменений.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// snapshotSource возвращает состояние файлов исходной директории и файла конфигурации.
+// Директория __hash и целевая директория, если она находится внутри исходной, не отслеживаются:
+// их изменяет сама сборка.
+func snapshotSource(cfg *Config) (map[string]fileStamp, error) {
+	snapshot := map[string]fileStamp{}
+	skip := map[string]bool{
+		filepath.Clean(cfg.HashDir()):   true,
+		filepath.Clean(cfg.Destination): true,
+	}
+
+	err := filepath.Walk(cfg.Source, func(current string, info os.FileInfo, err error) error {
+		if err != nil {
+			// Файл мог быть удалён во время обхода.
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() {
+			if skip[filepath.Clean(current)] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		snapshot[current] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(cfg.File) > 0 {
+		if info, err := os.Stat(cfg.File); err == nil {
+			snapshot[cfg.File] = fileStamp{modTime: info.ModTime(), size: info.Size()}
+		}
+	}
+
+	return snapshot, nil
+}
+
+// changedFiles возвращает файлы, добавленные, изменённые или удалённые между двумя состояниями.
+func changedFiles(previous map[string]fileStamp, current map[string]fileStamp) []string {
+	var changed []string
+	for file, stamp := range current {
+		if old, ok := previous[file]; !ok || !old.modTime.Equal(stamp.modTime) || old.size != stamp.size {
+			changed = append(changed, file)
+		}
+	}
+	for file := range previous {
+		if _, ok := current[file]; !ok {
+			changed = append(changed, file)
+		}
+	}
+
+	return changed
+}
+
+// liveReload рассылает открытым страницам события о пересборке сайта.
+type liveReload struct {
+	mu      sync.Mutex
+	clients map[chan string]bool
+}
+
+func newLiveReload() *liveReload {
+	return &liveReload{clients: map[chan string]bool{}}
+}
+
+// Notify сообщает всем подключённым страницам, что их нужно обновить.
+func (l *liveReload) Notify() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for client := range l.clients {
+		select {
+		case client <- "reload":
+		default:
+			// Страница ещё не получила предыдущее событие, повторное не нужно.
+		}
+	}
+}
+
+// ServeHTTP отдаёт поток событий (text/event-stream).
+func (l *liveReload) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	client := make(chan string, 1)
+	l.mu.Lock()
+	l.clients[client] = true
+	l.mu.Unlock()
+	defer func() {
+		l.mu.Lock()
+		delete(l.clients, client)
+		l.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case event := <-client:
+			fmt.Fprintf(w, "data: %s\n\n", event)
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// injectLiveReload добавляет скрипт обновления страницы перед закрывающим тегом </body>
+// или в конец страницы, если тега нет.
+func injectLiveReload(page []byte) []byte {
+	index := bytes.LastIndex(bytes.ToLower(page), []byte("</body>"))
+	if index < 0 {
+		return append(append([]byte{}, page...), liveReloadScript...)
+	}
+
+	result := make([]byte, 0, len(page)+len(liveReloadScript))
+	result = append(result, page[:index]...)
+	result = append(result, liveReloadScript...)
+	return append(result, page[index:]...)
+}
+
+// devServer отдаёт файлы целевой директории.
+type devServer struct {
+	// Блокировка на время сборки: файлы не отдаются, пока сайт формируется заново.
+	mu     *sync.RWMutex
+	root   string
+	reload *liveReload
+}
+
+func (s *devServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == LiveReloadPath {
+		s.reload.ServeHTTP(w, r)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	// path.Clean убирает из пути переходы в родительские директории.
+	file := filepath.Join(s.root, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+	info, err := os.Stat(file)
+	if err == nil && info.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			http.Redirect(w, r, r.URL.Path+"/", http.StatusMovedPermanently)
+			return
+		}
+		file = filepath.Join(file, "index.html")
+		info, err = os.Stat(file)
+	}
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(file))
+	if ext != ".html" && ext != ".htm" {
+		http.ServeFile(w, r, file)
+		return
+	}
+
+	page, err := ioutil.ReadFile(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	page = injectLiveReload(page)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Length", strconv.Itoa(len(page)))
+	w.Write(page)
+}
+
+// addServeFlags добавляет в набор флагов адрес и порт сервера разработки.
+func addServeFlags(flagSet *flag.FlagSet) (*string, *int) {
+	host := flagSet.String("host", "localhost", "Адрес сервера разработки")
+	port := flagSet.Int("port", 8080, "Порт сервера разработки")
+
+	return host, port
+}
+
+// runServe выполняет команду serve.
+func runServe(args []string) error {
+	flagSet := newCommandFlagSet("serve")
+	flags := addConfigFlags(flagSet)
+	host, port := addServeFlags(flagSet)
+	if err := flagSet.Parse(args); err != nil {
+		return err
+	}
+
+	// Конфигурация загружается заново перед каждой сборкой: файл конфигурации тоже отслеживается.
+	tempDir := ""
+	address := net.JoinHostPort(*host, strconv.Itoa(*port))
+	load := func() (*Config, error) {
+		cfg, err := flags.load(flagSet)
+		if err != nil {
+			return nil, err
+		}
+		if len(*flags.destination) == 0 {
+			if len(tempDir) == 0 {
+				if tempDir, err = ioutil.TempDir("", "googol-serve-"); err != nil {
+					return nil, err
+				}
+			}
+			cfg.Destination = tempDir
+		}
+		// Ссылки на страницы сайта должны вести на сервер разработки.
+		cfg.Domain = "http://" + address
+		// Манифест и граф зависимостей сервера хранятся отдельно от манифеста основной сборки,
+		// иначе следующая сборка сайта сочла бы файлы целевой директории актуальными.
+		cfg.Dirs.Hash += ServeHashSuffix
+		if err = cfg.Validate(); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+
+	cfg, err := load()
+	if err != nil {
+		return err
+	}
+	if len(tempDir) > 0 {
+		defer os.RemoveAll(tempDir)
+	}
+
+	mu := &sync.RWMutex{}
+	reload := newLiveReload()
+	// Шаблоны __templates разбираются заново, только если они изменились с прошлой сборки.
+	templates := NewTemplateCache()
+	build := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		output, err := NewOutputWriter(cfg.Destination, cfg.HashDir())
+		if err == nil {
+			output.SetTemplates(templates)
+			err = buildSite(cfg, output)
+		}
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+		fmt.Println("Сайт сформирован в директории " + cfg.Destination)
+	}
+	build()
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: &devServer{mu: mu, root: cfg.Destination, reload: reload}}
+	go server.Serve(listener)
+	fmt.Println("Сервер разработки запущен: http://" + address + "/ (Ctrl+C для остановки)")
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	ticker := time.NewTicker(WatchInterval)
+	defer ticker.Stop()
+
+	snapshot, err := snapshotSource(cfg)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-interrupt:
+			fmt.Println("Сервер разработки остановлен")
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			return server.Shutdown(ctx)
+		case <-ticker.C:
+			current, err := snapshotSource(cfg)
+			if err != nil {
+				fmt.Println(err.Error())
+				continue
+			}
+			changed := changedFiles(snapshot, current)
+			if len(changed) == 0 {
+				continue
+			}
+			snapshot = current
+			fmt.Printf("Изменено файлов: %d, формирую сайт заново\n", len(changed))
+
+			next, err := load()
+			if err != nil {
+				fmt.Println(err.Error())
+				continue
+			}
+			if next.Destination != cfg.Destination {
+				fmt.Println("Изменение целевой директории вступит в силу после перезапуска сервера")
+				next.Destination = cfg.Destination
+			}
+			cfg = next
+			build()
+			reload.Notify()
+		}
+	}
+}
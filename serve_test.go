@@ -0,0 +1,115 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInjectLiveReload(t *testing.T) {
+	t.Parallel()
+
+	page := string(injectLiveReload([]byte("<html><BODY><p>Текст</p></BODY></html>")))
+	if !strings.HasSuffix(page, string(liveReloadScript)+"</BODY></html>") {
+		t.Errorf("скрипт не добавлен перед </body>: %s", page)
+	}
+
+	page = string(injectLiveReload([]byte("<p>Фрагмент</p>")))
+	if page != "<p>Фрагмент</p>"+string(liveReloadScript) {
+		t.Errorf("скрипт не добавлен в конец страницы без </body>: %s", page)
+	}
+}
+
+func TestDevServer_InjectsScriptIntoHTMLOnly(t *testing.T) {
+	t.Parallel()
+
+	root := t.TempDir()
+	writeTestFile(t, filepath.Join(root, "blog", "index.html"), "<html><body>Блог</body></html>")
+	writeTestFile(t, filepath.Join(root, "style.css"), "body{}")
+
+	server := httptest.NewServer(&devServer{mu: &sync.RWMutex{}, root: root, reload: newLiveReload()})
+	defer server.Close()
+
+	get := func(url string) (int, string) {
+		response, err := http.Get(server.URL + url)
+		if err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		defer response.Body.Close()
+		body, err := ioutil.ReadAll(response.Body)
+		if err != nil {
+			t.Fatalf("GET %s: %v", url, err)
+		}
+		return response.StatusCode, string(body)
+	}
+
+	if status, body := get("/blog/"); status != http.StatusOK || !strings.Contains(body, LiveReloadPath) {
+		t.Errorf("/blog/: status=%d, body=%s", status, body)
+	}
+	if status, body := get("/style.css"); status != http.StatusOK || body != "body{}" {
+		t.Errorf("/style.css: status=%d, body=%s", status, body)
+	}
+	if status, _ := get("/missing.html"); status != http.StatusNotFound {
+		t.Errorf("/missing.html: status=%d", status)
+	}
+}
+
+func TestSnapshotSource_DetectsChanges(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	page := filepath.Join(cfg.Source, "index.html")
+	writeTestFile(t, page, "<p>1</p>")
+	writeTestFile(t, filepath.Join(cfg.HashDir(), "manifest"), "hash")
+
+	before, err := snapshotSource(cfg)
+	if err != nil {
+		t.Fatalf("snapshotSource вернул ошибку: %v", err)
+	}
+	if _, ok := before[filepath.Join(cfg.HashDir(), "manifest")]; ok {
+		t.Errorf("директория __hash не должна отслеживаться")
+	}
+
+	// Изменение директории __hash не считается изменением исходных файлов.
+	writeTestFile(t, filepath.Join(cfg.HashDir(), "manifest"), "new hash")
+	after, err := snapshotSource(cfg)
+	if err != nil {
+		t.Fatalf("snapshotSource вернул ошибку: %v", err)
+	}
+	if changed := changedFiles(before, after); len(changed) != 0 {
+		t.Errorf("лишние изменения: %v", changed)
+	}
+
+	writeTestFile(t, page, "<p>2 2</p>")
+	later := time.Now().Add(time.Second)
+	if err = os.Chtimes(page, later, later); err != nil {
+		t.Fatal(err)
+	}
+	added := filepath.Join(cfg.Source, "about.html")
+	writeTestFile(t, added, "<p>О сайте</p>")
+	after, err = snapshotSource(cfg)
+	if err != nil {
+		t.Fatalf("snapshotSource вернул ошибку: %v", err)
+	}
+	changed := changedFiles(before, after)
+	if len(changed) != 2 {
+		t.Errorf("ожидались изменения %s и %s, получено %v", page, added, changed)
+	}
+
+	if err = os.Remove(added); err != nil {
+		t.Fatal(err)
+	}
+	final, err := snapshotSource(cfg)
+	if err != nil {
+		t.Fatalf("snapshotSource вернул ошибку: %v", err)
+	}
+	if changed = changedFiles(after, final); len(changed) != 1 || changed[0] != added {
+		t.Errorf("ожидалось удаление %s, получено %v", added, changed)
+	}
+}
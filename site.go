@@ -0,0 +1,165 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Общие данные сайта.
+// Данные блога, публикаций и вопросов-ответов загружаются один раз до формирования страниц
+// и передаются каждому шаблону в поле Site: {{.Site.Title}}, {{range .Site.RecentPosts}}...{{end}}.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// Site описывает общие данные сайта, доступные всем шаблонам.
+type Site struct {
+	// Конфигурация сайта.
+	Config *Config
+	// Домен сайта вместе со схемой.
+	Domain string
+	// Название сайта.
+	Title string
+	// Произвольные метаданные сайта из секции params файла конфигурации.
+	Params map[string]interface{}
+	// Адреса разделов блога и публикаций.
+	BlogURL     string
+	ArticlesURL string
+	// Все посты блога, от новых к старым.
+	Posts []Post
+	// Последние посты блога, их количество задаётся параметром recent_posts.
+	RecentPosts []Post
+	// Рубрики блога, в которых есть посты, с количеством постов.
+	Tags []Tag
+	// Архив блога: годы и месяцы с количеством постов, от новых к старым.
+	Archive []ArchiveYear
+	// Публикации, отсортированные по заголовку.
+	Articles []Article
+	// Количество записей Вопросы и ответы.
+	QACount int
+	// Время запуска сборки. Не учитывается при проверке изменения данных страницы,
+	// поэтому страница не формируется заново только из-за нового времени сборки.
+	BuildTime time.Time `json:"-"`
+
+	// Записи Вопросы и ответы, от новых к старым.
+	qa SortedQAList
+	// Черновики и посты с датой публикации позже времени сборки, не опубликованные сборкой.
+	skipped []SkippedPost
+	// Предупреждения проверки исходных записей.
+	warnings []Problem
+	// Контрольная сумма данных сайта.
+	fingerprint string
+}
+
+// LoadSite загружает данные блога, публикаций и вопросов-ответов.
+// Разделы, исходные директории которых отсутствуют, остаются пустыми.
+// Исходные записи всех разделов проверяются до возврата ошибки: *ValidationError содержит все найденные ошибки.
+func LoadSite(cfg *Config) (*Site, error) {
+	validator := NewValidator(cfg)
+	site := &Site{
+		Config:      cfg,
+		Domain:      cfg.Domain,
+		Title:       cfg.Title,
+		Params:      cfg.Params,
+		BlogURL:     cfg.BlogURL(),
+		ArticlesURL: cfg.ArticlesURL(),
+		Posts:       []Post{},
+		RecentPosts: []Post{},
+		Tags:        []Tag{},
+		Archive:     []ArchiveYear{},
+		Articles:    []Article{},
+		BuildTime:   time.Now(),
+	}
+
+	// Загружаем рубрики и посты блога.
+	if _, err := os.Stat(cfg.BlogDir()); !os.IsNotExist(err) {
+		tags, err := loadTags(cfg.SettingsDir())
+		if err != nil {
+			return nil, err
+		}
+		// Адреса лент рубрик задаются шаблоном permalinks.tag.
+		for i := range tags.Tags {
+			tags.Tags[i].URL = cfg.Domain + cfg.TagPath(tags.Tags[i])
+		}
+		posts, _, err := loadBlog(cfg.BlogDir(), tags, validator)
+		if err != nil {
+			return nil, err
+		}
+		// Черновики и отложенные посты не попадают ни в ленты, ни в рубрики, ни в sitemap.
+		site.Posts, site.skipped = publishedPosts(*posts, site.BuildTime, cfg.Drafts, cfg.Future)
+		countTagPosts(site.Posts, tags)
+		// Адреса постов задаются шаблоном permalinks.post.
+		for i := range site.Posts {
+			site.Posts[i].URL = cfg.Domain + cfg.PostPath(site.Posts[i])
+		}
+		site.Archive = buildArchive(cfg, site.Posts)
+		for _, tag := range tags.Tags {
+			if tag.Posts > 0 {
+				site.Tags = append(site.Tags, tag)
+			}
+		}
+		recent := cfg.RecentPosts
+		if recent > len(site.Posts) {
+			recent = len(site.Posts)
+		}
+		site.RecentPosts = site.Posts[:recent]
+	}
+
+	// Загружаем публикации.
+	if _, err := os.Stat(cfg.ArticlesDir()); !os.IsNotExist(err) {
+		articles, err := loadArticles(cfg.ArticlesDir(), validator)
+		if err != nil {
+			return nil, err
+		}
+		if *articles != nil {
+			site.Articles = *articles
+		}
+	}
+
+	// Загружаем записи Вопросы и ответы.
+	if _, err := os.Stat(cfg.QADir()); !os.IsNotExist(err) {
+		qas, total, err := loadQA(cfg.QADir(), validator)
+		if err != nil {
+			return nil, err
+		}
+		site.qa = *qas
+		site.QACount = total
+	}
+
+	if err := validator.Err(); err != nil {
+		return nil, err
+	}
+	site.warnings = validator.Warnings()
+
+	// Вычисляем контрольную сумму данных сайта — вход страниц, шаблоны которых обращаются к .Site (см. deps.go).
+	type siteFields Site
+	raw, err := json.Marshal((*siteFields)(site))
+	if err != nil {
+		return nil, err
+	}
+	site.fingerprint = HashStringCrc32(string(raw))
+
+	return site, nil
+}
+
+// SkippedPosts возвращает посты, не опубликованные сборкой: черновики без параметра -drafts
+// и посты с датой публикации позже времени сборки без параметра -future.
+func (s *Site) SkippedPosts() []SkippedPost {
+	return s.skipped
+}
+
+// Warnings возвращает предупреждения проверки исходных записей блога, публикаций и вопросов-ответов.
+func (s *Site) Warnings() []Problem {
+	return s.warnings
+}
+
+// MarshalJSON представляет сайт в данных страницы пустым значением, чтобы граф зависимостей
+// не сериализовал данные сайта заново для каждой страницы. Данные сайта учитываются отдельным входом
+// страницы (siteDataInput) с контрольной суммой Fingerprint, только если шаблоны страницы обращаются к .Site.
+func (s *Site) MarshalJSON() ([]byte, error) {
+	return []byte("null"), nil
+}
+
+// Fingerprint возвращает контрольную сумму данных сайта.
+func (s *Site) Fingerprint() string {
+	return s.fingerprint
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSiteConfig(t *testing.T) *Config {
+	t.Helper()
+
+	cfg := DefaultConfig()
+	cfg.Source = t.TempDir()
+	cfg.Destination = t.TempDir()
+	cfg.Domain = "https://example.test"
+	cfg.RecentPosts = 2
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate вернул ошибку: %v", err)
+	}
+
+	return cfg
+}
+
+func TestLoadSite_CollectsSiteData(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости"></tag><tag id="2" name="Пустая"></tag></tags>`)
+	if err := os.MkdirAll(cfg.BlogDir(), 0755); err != nil {
+		t.Fatalf("не удалось создать директорию блога: %v", err)
+	}
+	writeBlogPostXML(t, cfg.BlogDir(), "old.xml", 1, "01.01.2020", "Старый")
+	writeBlogPostXML(t, cfg.BlogDir(), "middle.xml", 1, "01.01.2021", "Средний")
+	writeBlogPostXML(t, cfg.BlogDir(), "new.xml", 1, "01.01.2022", "Новый")
+	writeTestFile(t, filepath.Join(cfg.ArticlesDir(), "guide.xml"), `<article><title>Руководство</title><pages>Введение</pages></article>`)
+	writeTestFile(t, filepath.Join(cfg.QADir(), "q1.xml"), `<qa><date>01.02.2021</date><question>Вопрос?</question></qa>`)
+
+	site, err := LoadSite(cfg)
+	if err != nil {
+		t.Fatalf("LoadSite вернул ошибку: %v", err)
+	}
+	if len(site.Posts) != 3 || len(site.RecentPosts) != 2 || site.RecentPosts[0].Fuseaction != "new" {
+		t.Fatalf("посты сайта = %d, последние = %v", len(site.Posts), site.RecentPosts)
+	}
+	if len(site.Tags) != 1 || site.Tags[0].Posts != 3 {
+		t.Fatalf("рубрики сайта = %v", site.Tags)
+	}
+	if len(site.Articles) != 1 || site.QACount != 1 || len(site.qa) != 1 {
+		t.Fatalf("публикации = %v, записей Вопросы и ответы = %d", site.Articles, site.QACount)
+	}
+	if site.Title != "example.test" || site.BlogURL != "https://example.test/blog" {
+		t.Fatalf("заголовок = %q, адрес блога = %q", site.Title, site.BlogURL)
+	}
+}
+
+func TestLoadSite_WithoutSections(t *testing.T) {
+	t.Parallel()
+
+	site, err := LoadSite(newTestSiteConfig(t))
+	if err != nil {
+		t.Fatalf("LoadSite вернул ошибку: %v", err)
+	}
+	if site.Posts == nil || site.Tags == nil || site.Articles == nil || site.QACount != 0 {
+		t.Fatalf("пустые разделы должны быть пустыми списками: %+v", site)
+	}
+}
+
+func TestHandleParseFile_ExposesSite(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.Params = map[string]interface{}{"author": "Автор"}
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	page := filepath.Join(cfg.Source, "index.html")
+	writeTestFile(t, page, `{{.Site.Title}}|{{.Site.Params.author}}|{{len .Site.RecentPosts}}|{{.Fuseaction}}`)
+
+	site, err := LoadSite(cfg)
+	if err != nil {
+		t.Fatalf("LoadSite вернул ошибку: %v", err)
+	}
+	output := newTestOutput(t, cfg.Destination)
+	if err = handleParseFile(site, page, NewSitemap(cfg), nil, nil, output); err != nil {
+		t.Fatalf("handleParseFile вернул ошибку: %v", err)
+	}
+	if err = output.Close(); err != nil {
+		t.Fatalf("Close вернул ошибку: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(cfg.Destination, "index.html"))
+	if err != nil {
+		t.Fatalf("не удалось прочитать страницу: %v", err)
+	}
+	if string(content) != "example.test|Автор|0|index.html" {
+		t.Fatalf("страница = %q", string(content))
+	}
+}
+
+func TestSite_BuildTimeDoesNotChangePageData(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	first, err := LoadSite(cfg)
+	if err != nil {
+		t.Fatalf("LoadSite вернул ошибку: %v", err)
+	}
+	second, err := LoadSite(cfg)
+	if err != nil {
+		t.Fatalf("LoadSite вернул ошибку: %v", err)
+	}
+	second.BuildTime = first.BuildTime.Add(time.Hour)
+
+	if dataHash(struct{ Site *Site }{first}) != dataHash(struct{ Site *Site }{second}) {
+		t.Fatalf("время сборки не должно влиять на контрольную сумму данных страницы")
+	}
+}
+
+func TestOutputWriter_RenderFileRebuildsOnlyPagesReadingSite(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости"></tag></tags>`)
+	if err := os.MkdirAll(cfg.BlogDir(), 0755); err != nil {
+		t.Fatalf("не удалось создать директорию блога: %v", err)
+	}
+	writeBlogPostXML(t, cfg.BlogDir(), "first.xml", 1, "01.01.2021", "Первый")
+	writeBlogPostXML(t, cfg.BlogDir(), "second.xml", 1, "01.01.2022", "Второй")
+	plainPage := filepath.Join(cfg.Source, "plain.html")
+	writeTestFile(t, plainPage, `{{.Touch}}plain`)
+	sitePage := filepath.Join(cfg.Source, "posts.html")
+	writeTestFile(t, sitePage, `{{.Touch}}{{range .Site.Posts}}{{.Title}};{{end}}`)
+	// Данные сайта читаются через переменную.
+	aliasPage := filepath.Join(cfg.Source, "alias.html")
+	writeTestFile(t, aliasPage, `{{.Touch}}{{$p := .}}{{range $p.Site.Posts}}{{.Title}};{{end}}`)
+
+	var plainCalls, siteCalls, aliasCalls int32
+	build := func() {
+		site, err := LoadSite(cfg)
+		if err != nil {
+			t.Fatalf("LoadSite вернул ошибку: %v", err)
+		}
+		output, err := NewOutputWriter(cfg.Destination, cfg.HashDir())
+		if err != nil {
+			t.Fatalf("NewOutputWriter вернул ошибку: %v", err)
+		}
+		output.SetSite(site)
+		pages := []struct {
+			path  string
+			calls *int32
+		}{{plainPage, &plainCalls}, {sitePage, &siteCalls}, {aliasPage, &aliasCalls}}
+		for _, page := range pages {
+			data := struct {
+				Site *Site
+				renderCounter
+			}{site, renderCounter{calls: page.calls}}
+			if _, err = output.RenderFile(filepath.Join(cfg.Destination, filepath.Base(page.path)), page.path, cfg.TemplatesDir(), data, "page"); err != nil {
+				t.Fatalf("RenderFile вернул ошибку: %v", err)
+			}
+		}
+		if err = output.Close(); err != nil {
+			t.Fatalf("Close вернул ошибку: %v", err)
+		}
+	}
+
+	build()
+	build()
+	if plainCalls != 1 || siteCalls != 1 || aliasCalls != 1 {
+		t.Fatalf("страницы сформированы заново без изменений: plain %d, posts %d, alias %d", plainCalls, siteCalls, aliasCalls)
+	}
+
+	// Изменение поста меняет данные сайта: формируется заново только страница, обращающаяся к .Site.
+	writeBlogPostXML(t, cfg.BlogDir(), "second.xml", 1, "01.01.2022", "Второй, исправленный")
+	build()
+	if plainCalls != 1 {
+		t.Errorf("страница без .Site сформирована заново, выполнено %d", plainCalls)
+	}
+	content, err := os.ReadFile(filepath.Join(cfg.Destination, "posts.html"))
+	if err != nil {
+		t.Fatalf("страница не сформирована: %v", err)
+	}
+	if siteCalls != 2 || !strings.Contains(string(content), "Второй, исправленный;") {
+		t.Errorf("страница с .Site = %q, выполнено %d", content, siteCalls)
+	}
+	content, err = os.ReadFile(filepath.Join(cfg.Destination, "alias.html"))
+	if err != nil {
+		t.Fatalf("страница не сформирована: %v", err)
+	}
+	if aliasCalls != 2 || !strings.Contains(string(content), "Второй, исправленный;") {
+		t.Errorf("страница, читающая данные сайта через переменную, = %q, выполнено %d", content, aliasCalls)
+	}
+}
@@ -0,0 +1,310 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Формирование sitemap.
+// Генераторы страниц добавляют в Sitemap записи о сформированных страницах: адрес, дату последнего
+// изменения (дату поста или время изменения исходного файла), частоту изменения и приоритет.
+// После формирования всех страниц записи сортируются по адресу и записываются в sitemap.xml; если записей
+// больше, чем допускает протокол (50 000 адресов или 50 МБ), они разбиваются на файлы sitemap-1.xml,
+// sitemap-2.xml, ..., перечисленные в индексе sitemap_index.xml.
+//
+// Страницу можно исключить из sitemap:
+//   - шаблонным комментарием {{/* sitemap: exclude */}} в исходном файле страницы;
+//   - элементом <sitemap>false</sitemap> (или ключом заголовка sitemap: false) поста или публикации;
+//   - шаблоном пути в параметре sitemap.exclude файла конфигурации, например "404.html" или "drafts/*".
+
+package main
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ограничения протокола sitemap для одного файла.
+const (
+	SitemapMaxURLs  = 50000
+	SitemapMaxBytes = 50 * 1024 * 1024
+)
+
+// Имена формируемых файлов sitemap.
+const (
+	SitemapFile      = "sitemap.xml"
+	SitemapIndexFile = "sitemap_index.xml"
+)
+
+const (
+	sitemapHeader      = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<urlset xmlns=\"http://www.sitemaps.org/schemas/sitemap/0.9\">\n"
+	sitemapFooter      = "</urlset>\n"
+	sitemapIndexHeader = "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<sitemapindex xmlns=\"http://www.sitemaps.org/schemas/sitemap/0.9\">\n"
+	sitemapIndexFooter = "</sitemapindex>\n"
+)
+
+// Шаблонный комментарий, исключающий страницу из sitemap.
+var sitemapExcludeMarker = regexp.MustCompile(`\{\{-?\s*/\*\s*sitemap:\s*exclude\s*\*/\s*-?\}\}`)
+
+// SitemapOptions описывает настройки sitemap.
+type SitemapOptions struct {
+	// Формировать ли sitemap.
+	Enabled bool
+	// Шаблоны путей страниц относительно корня сайта, не включаемых в sitemap (path.Match).
+	// Шаблон, совпадающий с директорией, исключает все страницы директории.
+	Exclude []string
+	// Максимальное количество адресов в одном файле sitemap.
+	MaxURLs int
+}
+
+// SitemapKind — вид страницы, определяющий частоту изменения и приоритет по умолчанию.
+type SitemapKind string
+
+// Виды страниц sitemap.
+const (
+	SitemapHome     SitemapKind = "home"
+	SitemapPage     SitemapKind = "page"
+	SitemapBlog     SitemapKind = "blog"
+	SitemapBlogPage SitemapKind = "blog-page"
+	SitemapTag      SitemapKind = "tag"
+	SitemapArchive  SitemapKind = "archive"
+	SitemapPost     SitemapKind = "post"
+	SitemapArticles SitemapKind = "articles"
+	SitemapArticle  SitemapKind = "article"
+	SitemapQA       SitemapKind = "qa"
+)
+
+// sitemapDefaults — частота изменения и приоритет страниц каждого вида.
+var sitemapDefaults = map[SitemapKind]struct {
+	changeFreq string
+	priority   float64
+}{
+	SitemapHome:     {"daily", 1.0},
+	SitemapPage:     {"monthly", 0.5},
+	SitemapBlog:     {"daily", 0.8},
+	SitemapBlogPage: {"weekly", 0.3},
+	SitemapTag:      {"weekly", 0.6},
+	SitemapArchive:  {"monthly", 0.4},
+	SitemapPost:     {"monthly", 0.7},
+	SitemapArticles: {"weekly", 0.7},
+	SitemapArticle:  {"monthly", 0.6},
+	SitemapQA:       {"weekly", 0.6},
+}
+
+// SitemapEntry описывает страницу в sitemap.
+type SitemapEntry struct {
+	Kind SitemapKind
+	// Полный адрес страницы.
+	Loc string
+	// Дата последнего изменения, нулевое время — не указывать.
+	LastMod time.Time
+	// Частота изменения и приоритет; пустые значения заменяются значениями вида страницы.
+	ChangeFreq string
+	Priority   float64
+}
+
+// Sitemap собирает записи sitemap от генераторов страниц.
+// Методы nil-значения ничего не делают: sitemap не формируется.
+type Sitemap struct {
+	mu      sync.Mutex
+	domain  string
+	options SitemapOptions
+	entries map[string]SitemapEntry
+}
+
+// NewSitemap создаёт sitemap сайта; если sitemap отключён в конфигурации, возвращает nil.
+func NewSitemap(cfg *Config) *Sitemap {
+	if !cfg.Sitemap.Enabled {
+		return nil
+	}
+
+	return &Sitemap{domain: cfg.Domain, options: cfg.Sitemap, entries: map[string]SitemapEntry{}}
+}
+
+// Add добавляет страницу в sitemap. Страницы, исключённые параметром sitemap.exclude, пропускаются;
+// при повторном добавлении адреса сохраняется последняя запись.
+func (s *Sitemap) Add(entry SitemapEntry) {
+	if s == nil || s.Excluded(entry.Loc) {
+		return
+	}
+
+	if defaults, ok := sitemapDefaults[entry.Kind]; ok {
+		if len(entry.ChangeFreq) == 0 {
+			entry.ChangeFreq = defaults.changeFreq
+		}
+		if entry.Priority == 0 {
+			entry.Priority = defaults.priority
+		}
+	}
+	// Адресом индексной страницы директории считается адрес директории.
+	entry.Loc = sitemapLoc(strings.TrimSuffix(entry.Loc, "index.html"))
+
+	s.mu.Lock()
+	s.entries[entry.Loc] = entry
+	s.mu.Unlock()
+}
+
+// Excluded проверяет, исключена ли страница с адресом loc параметром sitemap.exclude.
+func (s *Sitemap) Excluded(loc string) bool {
+	if s == nil {
+		return true
+	}
+
+	return pathExcluded(strings.TrimPrefix(strings.TrimPrefix(loc, s.domain), "/"), s.options.Exclude)
+}
+
+// pathExcluded проверяет, совпадает ли путь страницы rel относительно корня сайта или одна из директорий,
+// в которых она находится, с одним из шаблонов patterns (path.Match).
+func pathExcluded(rel string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.Trim(pattern, "/")
+		for candidate := strings.TrimSuffix(rel, "/"); len(candidate) > 0 && candidate != "."; candidate = path.Dir(candidate) {
+			if matched, _ := path.Match(pattern, candidate); matched {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Entries возвращает записи sitemap, отсортированные по адресу.
+func (s *Sitemap) Entries() []SitemapEntry {
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries := make([]SitemapEntry, 0, len(s.entries))
+	for _, entry := range s.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Loc < entries[j].Loc })
+
+	return entries
+}
+
+// Write записывает файлы sitemap в целевую директорию destination.
+func (s *Sitemap) Write(destination string, output *OutputWriter) error {
+	if s == nil {
+		return nil
+	}
+
+	maxURLs := s.options.MaxURLs
+	if maxURLs <= 0 || maxURLs > SitemapMaxURLs {
+		maxURLs = SitemapMaxURLs
+	}
+
+	// Разбиваем записи на файлы с учётом ограничений на количество адресов и размер файла.
+	type chunk struct {
+		body    strings.Builder
+		count   int
+		lastMod time.Time
+	}
+	chunks := []*chunk{{}}
+	limit := SitemapMaxBytes - len(sitemapHeader) - len(sitemapFooter)
+	for _, entry := range s.Entries() {
+		element := sitemapURLElement(entry)
+		current := chunks[len(chunks)-1]
+		if current.count >= maxURLs || (current.count > 0 && current.body.Len()+len(element) > limit) {
+			current = &chunk{}
+			chunks = append(chunks, current)
+		}
+		current.body.WriteString(element)
+		current.count++
+		if entry.LastMod.After(current.lastMod) {
+			current.lastMod = entry.LastMod
+		}
+	}
+
+	if len(chunks) == 1 {
+		_, err := output.WriteFile(filepath.Join(destination, SitemapFile), []byte(sitemapHeader+chunks[0].body.String()+sitemapFooter))
+		return err
+	}
+
+	index := strings.Builder{}
+	index.WriteString(sitemapIndexHeader)
+	for i, c := range chunks {
+		name := "sitemap-" + strconv.Itoa(i+1) + ".xml"
+		if _, err := output.WriteFile(filepath.Join(destination, name), []byte(sitemapHeader+c.body.String()+sitemapFooter)); err != nil {
+			return err
+		}
+		index.WriteString("<sitemap><loc>" + xmlEscape(sitemapLoc(s.domain+"/"+name)) + "</loc>")
+		if !c.lastMod.IsZero() {
+			index.WriteString("<lastmod>" + c.lastMod.Format("2006-01-02") + "</lastmod>")
+		}
+		index.WriteString("</sitemap>\n")
+	}
+	index.WriteString(sitemapIndexFooter)
+
+	_, err := output.WriteFile(filepath.Join(destination, SitemapIndexFile), []byte(index.String()))
+	return err
+}
+
+// sitemapURLElement возвращает элемент <url> записи sitemap.
+func sitemapURLElement(entry SitemapEntry) string {
+	element := "<url><loc>" + xmlEscape(entry.Loc) + "</loc>"
+	if !entry.LastMod.IsZero() {
+		element += "<lastmod>" + entry.LastMod.Format("2006-01-02") + "</lastmod>"
+	}
+	if len(entry.ChangeFreq) > 0 {
+		element += "<changefreq>" + xmlEscape(entry.ChangeFreq) + "</changefreq>"
+	}
+	if entry.Priority > 0 {
+		element += "<priority>" + strconv.FormatFloat(entry.Priority, 'f', 1, 64) + "</priority>"
+	}
+
+	return element + "</url>\n"
+}
+
+// sitemapLoc приводит адрес страницы к виду, требуемому протоколом sitemap:
+// символы пути и параметров, не допустимые в URL (пробелы, кириллица), кодируются.
+func sitemapLoc(loc string) string {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return loc
+	}
+
+	return u.String()
+}
+
+// sitemapOptOut проверяет значение элемента sitemap поста или публикации:
+// false, no, 0 и off исключают страницу из sitemap.
+func sitemapOptOut(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "false", "no", "0", "off":
+		return true
+	}
+
+	return false
+}
+
+// pageExcludedFromSitemap проверяет, что исходный файл страницы содержит комментарий {{/* sitemap: exclude */}}.
+func pageExcludedFromSitemap(pagepath string) bool {
+	raw, err := readPageTemplate(pagepath)
+	if err != nil {
+		return false
+	}
+
+	return sitemapExcludeMarker.MatchString(raw)
+}
+
+// latestModTime возвращает время последнего изменения самого нового из файлов paths,
+// нулевое время, если ни одного файла нет.
+func latestModTime(paths ...string) time.Time {
+	var latest time.Time
+	for _, p := range paths {
+		if len(p) == 0 {
+			continue
+		}
+		if info, err := os.Stat(p); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest
+}
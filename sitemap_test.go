@@ -0,0 +1,150 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSitemap_WritesEscapedEntriesWithLastmod(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	sitemap := NewSitemap(cfg)
+	date := time.Date(2026, time.February, 3, 10, 0, 0, 0, time.UTC)
+	sitemap.Add(SitemapEntry{Kind: SitemapPost, Loc: "https://example.test/blog/posts/a&b.html", LastMod: date})
+	sitemap.Add(SitemapEntry{Kind: SitemapPage, Loc: "https://example.test/статьи/о сайте.html"})
+	sitemap.Add(SitemapEntry{Kind: SitemapHome, Loc: "https://example.test/index.html", ChangeFreq: "hourly"})
+
+	output := newTestOutput(t, cfg.Destination)
+	if err := sitemap.Write(cfg.Destination, output); err != nil {
+		t.Fatalf("Write вернул ошибку: %v", err)
+	}
+	raw, err := os.ReadFile(filepath.Join(cfg.Destination, SitemapFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := sitemapHeader +
+		"<url><loc>https://example.test/</loc><changefreq>hourly</changefreq><priority>1.0</priority></url>\n" +
+		"<url><loc>https://example.test/%D1%81%D1%82%D0%B0%D1%82%D1%8C%D0%B8/%D0%BE%20%D1%81%D0%B0%D0%B9%D1%82%D0%B5.html</loc><changefreq>monthly</changefreq><priority>0.5</priority></url>\n" +
+		"<url><loc>https://example.test/blog/posts/a&amp;b.html</loc><lastmod>2026-02-03</lastmod><changefreq>monthly</changefreq><priority>0.7</priority></url>\n" +
+		sitemapFooter
+	if string(raw) != expected {
+		t.Fatalf("sitemap.xml = %q\nожидалось %q", string(raw), expected)
+	}
+}
+
+func TestSitemap_SplitsIntoIndex(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.Sitemap.MaxURLs = 2
+	sitemap := NewSitemap(cfg)
+	for i, name := range []string{"a", "b", "c", "d", "e"} {
+		sitemap.Add(SitemapEntry{Kind: SitemapPost, Loc: "https://example.test/" + name + ".html", LastMod: time.Date(2026, time.January, i+1, 0, 0, 0, 0, time.UTC)})
+	}
+
+	output := newTestOutput(t, cfg.Destination)
+	if err := sitemap.Write(cfg.Destination, output); err != nil {
+		t.Fatalf("Write вернул ошибку: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Destination, SitemapFile)); !os.IsNotExist(err) {
+		t.Errorf("при разбиении sitemap.xml не должен формироваться")
+	}
+	index, err := os.ReadFile(filepath.Join(cfg.Destination, SitemapIndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, lastmod := range []string{"2026-01-02", "2026-01-04", "2026-01-05"} {
+		name := "sitemap-" + string(rune('1'+i)) + ".xml"
+		element := "<sitemap><loc>https://example.test/" + name + "</loc><lastmod>" + lastmod + "</lastmod></sitemap>"
+		if !strings.Contains(string(index), element) {
+			t.Errorf("индекс не содержит %s: %s", element, index)
+		}
+		part, err := os.ReadFile(filepath.Join(cfg.Destination, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count := strings.Count(string(part), "<url>"); (i < 2 && count != 2) || (i == 2 && count != 1) {
+			t.Errorf("%s содержит %d адресов", name, count)
+		}
+	}
+}
+
+func TestSitemap_Exclusions(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.Sitemap.Exclude = []string{"404.html", "drafts", "blog/posts/secret-*.html"}
+	sitemap := NewSitemap(cfg)
+	for _, loc := range []string{"404.html", "drafts/plan.html", "drafts/2026/plan.html", "blog/posts/secret-1.html", "blog/posts/public.html", "about/404.html"} {
+		sitemap.Add(SitemapEntry{Kind: SitemapPage, Loc: "https://example.test/" + loc})
+	}
+
+	var locs []string
+	for _, entry := range sitemap.Entries() {
+		locs = append(locs, entry.Loc)
+	}
+	expected := "https://example.test/about/404.html https://example.test/blog/posts/public.html"
+	if strings.Join(locs, " ") != expected {
+		t.Errorf("записи sitemap = %v, ожидалось %s", locs, expected)
+	}
+
+	cfg.Sitemap.Enabled = false
+	if disabled := NewSitemap(cfg); disabled != nil {
+		t.Errorf("отключённый sitemap должен быть nil")
+	}
+	var disabled *Sitemap
+	disabled.Add(SitemapEntry{Loc: "https://example.test/"})
+	if err := disabled.Write(cfg.Destination, nil); err != nil || len(disabled.Entries()) != 0 {
+		t.Errorf("nil sitemap не должен формироваться: %v", err)
+	}
+}
+
+func TestBuild_SitemapCoversAllGenerators(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.PostsPerPage = 1
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости"></tag></tags>`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "blog.html"), `{{range .Blog}}{{.Title}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "post.html"), `{{.Blogpost.Title}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "qa.html"), `{{range .QA}}{{.Question}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "first.xml"), `<post><date>01.02.2026</date><tagid>1</tagid><title>Первый</title></post>`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "hidden.md"), "---\ntitle: Скрытый\ndate: 05.02.2026\ntagid: 1\nsitemap: false\n---\nТекст\n")
+	writeTestFile(t, filepath.Join(cfg.QADir(), "q.xml"), `<qa><date>07.03.2026</date><question>Вопрос</question><answer>Ответ</answer></qa>`)
+	writeTestFile(t, filepath.Join(cfg.Source, "index.html"), `<p>Главная</p>`)
+	writeTestFile(t, filepath.Join(cfg.Source, "404.html"), `<p>Не найдено</p>`)
+	writeTestFile(t, filepath.Join(cfg.Source, "private.html"), `{{/* sitemap: exclude */}}<p>Личное</p>`)
+
+	if err := Build(cfg); err != nil {
+		t.Fatalf("Build вернул ошибку: %v", err)
+	}
+	raw, err := os.ReadFile(filepath.Join(cfg.Destination, SitemapFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sitemap := string(raw)
+
+	for _, element := range []string{
+		"<loc>https://example.test/</loc>",
+		"<loc>https://example.test/blog/</loc><lastmod>2026-02-05</lastmod>",
+		"<loc>https://example.test/blog/2.html</loc><lastmod>2026-02-01</lastmod>",
+		"<loc>https://example.test/blog/1/</loc>",
+		"<loc>https://example.test/blog/posts/first.html</loc><lastmod>2026-02-01</lastmod>",
+		"<loc>https://example.test/qa.html</loc><lastmod>2026-03-07</lastmod>",
+	} {
+		if !strings.Contains(sitemap, element) {
+			t.Errorf("sitemap не содержит %s:\n%s", element, sitemap)
+		}
+	}
+	for _, excluded := range []string{"404.html", "private.html", "hidden.html"} {
+		if strings.Contains(sitemap, excluded) {
+			t.Errorf("sitemap содержит исключённую страницу %s:\n%s", excluded, sitemap)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Нормализация и стемминг слов для поискового индекса.
+// Слова приводятся к нижнему регистру, буква ё заменяется буквой е, у русских слов отбрасываются окончания
+// и суффиксы по алгоритму стемминга Snowball для русского языка (https://snowballstem.org/algorithms/russian/stemmer.html).
+// Тот же алгоритм повторяет скрипт поиска search.js, поэтому слова запроса посетителя совпадают со словами индекса.
+
+package main
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Гласные русского языка, по которым определяются области слова RV, R1 и R2.
+const russianVowels = "аеиоуыэюя"
+
+// Окончания и суффиксы алгоритма Snowball. Окончания первой группы (…1) отбрасываются,
+// только если перед ними стоит буква а или я.
+var (
+	russianPerfectiveGerund1 = []string{"в", "вши", "вшись"}
+	russianPerfectiveGerund2 = []string{"ив", "ивши", "ившись", "ыв", "ывши", "ывшись"}
+	russianAdjective         = []string{"ее", "ие", "ые", "ое", "ими", "ыми", "ей", "ий", "ый", "ой", "ем", "им", "ым", "ом", "его", "ого", "ему", "ому", "их", "ых", "ую", "юю", "ая", "яя", "ою", "ею"}
+	russianParticiple1       = []string{"ем", "нн", "вш", "ющ", "щ"}
+	russianParticiple2       = []string{"ивш", "ывш", "ующ"}
+	russianReflexive         = []string{"ся", "сь"}
+	russianVerb1             = []string{"ла", "на", "ете", "йте", "ли", "й", "л", "ем", "н", "ло", "но", "ет", "ют", "ны", "ть", "ешь", "нно"}
+	russianVerb2             = []string{"ила", "ыла", "ена", "ейте", "уйте", "ите", "или", "ыли", "ей", "уй", "ил", "ыл", "им", "ым", "ен", "ило", "ыло", "ено", "ят", "ует", "уют", "ит", "ыт", "ены", "ить", "ыть", "ишь", "ую", "ю"}
+	russianNoun              = []string{"а", "ев", "ов", "ие", "ье", "е", "иями", "ями", "ами", "еи", "ии", "и", "ией", "ей", "ой", "ий", "й", "иям", "ям", "ием", "ем", "ам", "ом", "о", "у", "ах", "иях", "ях", "ы", "ь", "ию", "ью", "ю", "ия", "ья", "я"}
+	russianSuperlative       = []string{"ейш", "ейше"}
+	russianDerivational      = []string{"ост", "ость"}
+)
+
+// normalizeWord приводит слово к нижнему регистру и заменяет букву ё буквой е.
+func normalizeWord(word string) string {
+	return strings.ReplaceAll(strings.ToLower(word), "ё", "е")
+}
+
+// searchWords разбивает текст на нормализованные слова из букв и цифр; слова из одного символа пропускаются.
+func searchWords(text string) []string {
+	var words []string
+	for _, word := range strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+		if utf8.RuneCountInString(word) > 1 {
+			words = append(words, normalizeWord(word))
+		}
+	}
+
+	return words
+}
+
+// searchTerms возвращает слова текста для поискового индекса, при stemming — основы русских слов.
+func searchTerms(text string, stemming bool) []string {
+	words := searchWords(text)
+	if stemming {
+		for i, word := range words {
+			words[i] = stemRussian(word)
+		}
+	}
+
+	return words
+}
+
+// isRussianVowel проверяет, что буква — гласная русского языка.
+func isRussianVowel(r rune) bool {
+	return strings.ContainsRune(russianVowels, r)
+}
+
+// russianRegions возвращает начала областей слова: RV — после первой гласной,
+// R1 — после первой согласной, следующей за гласной, R2 — область R1 внутри R1.
+func russianRegions(word []rune) (rv int, r2 int) {
+	rv, r1, r2 := len(word), len(word), len(word)
+	for i, r := range word {
+		if isRussianVowel(r) {
+			rv = i + 1
+			break
+		}
+	}
+	for i := 1; i < len(word); i++ {
+		if !isRussianVowel(word[i]) && isRussianVowel(word[i-1]) {
+			r1 = i + 1
+			break
+		}
+	}
+	for i := r1 + 1; i < len(word); i++ {
+		if !isRussianVowel(word[i]) && isRussianVowel(word[i-1]) {
+			r2 = i + 1
+			break
+		}
+	}
+
+	return rv, r2
+}
+
+// russianEnding возвращает длину самого длинного окончания слова word, лежащего в области, начинающейся с from:
+// окончания группы withAY — только после буквы а или я из той же области, окончания группы plain — без условия.
+// Если окончания нет, возвращает 0.
+func russianEnding(word []rune, from int, withAY []string, plain []string) int {
+	best := 0
+	for _, ending := range withAY {
+		n := utf8.RuneCountInString(ending)
+		start := len(word) - n
+		if n > best && start-1 >= from && string(word[start:]) == ending && (word[start-1] == 'а' || word[start-1] == 'я') {
+			best = n
+		}
+	}
+	for _, ending := range plain {
+		n := utf8.RuneCountInString(ending)
+		if n > best && len(word)-n >= from && string(word[len(word)-n:]) == ending {
+			best = n
+		}
+	}
+
+	return best
+}
+
+// stemRussian возвращает основу нормализованного слова; слова без русских гласных не изменяются.
+func stemRussian(word string) string {
+	w := []rune(word)
+	rv, r2 := russianRegions(w)
+	if rv == len(w) {
+		return word
+	}
+
+	// Шаг 1: деепричастие либо возвратная частица и окончание прилагательного, причастия, глагола или существительного.
+	if n := russianEnding(w, rv, russianPerfectiveGerund1, russianPerfectiveGerund2); n > 0 {
+		w = w[:len(w)-n]
+	} else {
+		if n := russianEnding(w, rv, nil, russianReflexive); n > 0 {
+			w = w[:len(w)-n]
+		}
+		if n := russianEnding(w, rv, nil, russianAdjective); n > 0 {
+			w = w[:len(w)-n]
+			if n := russianEnding(w, rv, russianParticiple1, russianParticiple2); n > 0 {
+				w = w[:len(w)-n]
+			}
+		} else if n := russianEnding(w, rv, russianVerb1, russianVerb2); n > 0 {
+			w = w[:len(w)-n]
+		} else if n := russianEnding(w, rv, nil, russianNoun); n > 0 {
+			w = w[:len(w)-n]
+		}
+	}
+
+	// Шаг 2: окончание и.
+	if russianEnding(w, rv, nil, []string{"и"}) > 0 {
+		w = w[:len(w)-1]
+	}
+
+	// Шаг 3: словообразовательный суффикс в области R2.
+	if n := russianEnding(w, r2, nil, russianDerivational); n > 0 {
+		w = w[:len(w)-n]
+	}
+
+	// Шаг 4: двойная н, суффикс превосходной степени или мягкий знак.
+	if russianEnding(w, rv, nil, []string{"нн"}) > 0 {
+		w = w[:len(w)-1]
+	} else if n := russianEnding(w, rv, nil, russianSuperlative); n > 0 {
+		w = w[:len(w)-n]
+		if russianEnding(w, rv, nil, []string{"нн"}) > 0 {
+			w = w[:len(w)-1]
+		}
+	} else if russianEnding(w, rv, nil, []string{"ь"}) > 0 {
+		w = w[:len(w)-1]
+	}
+
+	return string(w)
+}
@@ -0,0 +1,216 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Кэш шаблонов.
+// Шаблоны *.tmpl директории __templates разбираются один раз и используются всеми страницами сборки:
+// для каждой страницы набор шаблонов копируется (Clone), поэтому страница может переопределить
+// шаблоны набора собственными {{define}}, не затрагивая другие страницы.
+// Сервер разработки использует один кэш во всех сборках; перед сборкой Refresh сверяет содержимое
+// шаблонов с разобранным, и заново разбираются только наборы, шаблоны которых изменились.
+//
+// Страницы формируются пакетом html/template: значения, выводимые шаблоном, экранируются в соответствии
+// с контекстом (текст, атрибут, адрес, скрипт), поэтому разметка в заголовке поста или в вопросе
+// посетителя выводится как текст. Без экранирования выводятся значения типа TrustedHTML — контент постов,
+// ответы записей Вопросы и ответы и страницы публикаций, — а также результаты функций SafeHTML, SafeURL
+// и SafeJS. Аннотации постов и публикаций являются строками и выводятся функцией SafeHTML.
+// Для шаблонов, написанных до перехода на html/template, параметр compile.autoescape = false
+// файла конфигурации возвращает формирование страниц пакетом text/template.
+
+package main
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"path/filepath"
+	"sort"
+	"sync"
+	"text/template"
+)
+
+// TrustedHTML — html-фрагмент, который формируется из исходных файлов автора сайта и выводится
+// шаблонами без экранирования.
+type TrustedHTML = htmltemplate.HTML
+
+// templateSet описывает разобранные шаблоны одной директории.
+type templateSet struct {
+	// Разобранные шаблоны; nil, если при разборе произошла ошибка.
+	base *pageTemplates
+	// Ошибка разбора шаблонов.
+	err error
+	// crc32-хэши файлов шаблонов, по которым проверяется изменение набора.
+	files map[string]string
+}
+
+// templateKey — ключ набора шаблонов в кэше: директория шаблонов и режим экранирования.
+type templateKey struct {
+	dir        string
+	autoescape bool
+}
+
+// pageTemplates — разобранный набор шаблонов: html/template с контекстным экранированием
+// либо text/template для шаблонов, написанных до перехода на html/template.
+type pageTemplates struct {
+	html *htmltemplate.Template
+	text *template.Template
+}
+
+// TemplateCache хранит разобранные шаблоны директорий __templates.
+// Методы nil-значения разбирают шаблоны при каждом обращении.
+type TemplateCache struct {
+	mu   sync.Mutex
+	sets map[templateKey]*templateSet
+	// Количество разборов наборов шаблонов.
+	loads int
+}
+
+// NewTemplateCache создаёт пустой кэш шаблонов.
+func NewTemplateCache() *TemplateCache {
+	return &TemplateCache{sets: map[templateKey]*templateSet{}}
+}
+
+// templateFiles возвращает отсортированный список шаблонов *.tmpl директории templatesDir.
+func templateFiles(templatesDir string) ([]string, error) {
+	if len(templatesDir) == 0 {
+		return nil, nil
+	}
+	files, err := filepath.Glob(filepath.Join(templatesDir, "*.tmpl"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// loadTemplateSet разбирает шаблоны *.tmpl директории templatesDir.
+// templatesDir может быть пустой строкой — тогда набор содержит только функции шаблонов.
+// autoescape — разбирать шаблоны пакетом html/template.
+func loadTemplateSet(templatesDir string, autoescape bool) (*pageTemplates, error) {
+	if autoescape {
+		t := htmltemplate.New("").Funcs(htmltemplate.FuncMap(templateFuncs()))
+		if len(templatesDir) > 0 {
+			if _, err := t.ParseGlob(filepath.Join(templatesDir, "*.tmpl")); err != nil {
+				return nil, err
+			}
+		}
+		return &pageTemplates{html: t}, nil
+	}
+
+	t := template.New("").Funcs(templateFuncs())
+	if len(templatesDir) > 0 {
+		if _, err := t.ParseGlob(filepath.Join(templatesDir, "*.tmpl")); err != nil {
+			return nil, err
+		}
+	}
+
+	return &pageTemplates{text: t}, nil
+}
+
+// executePage разбирает шаблон страницы pagepath в копии набора шаблонов base и выполняет его.
+// funcs — функции шаблонов, заменяющие функции набора для этой страницы, может быть nil.
+func executePage(base *pageTemplates, pagepath string, data interface{}, fuseaction string, funcs template.FuncMap) (string, error) {
+	// Загружаем файл для парсинга.
+	tmpl, err := readPageTemplate(pagepath)
+	if err != nil {
+		return "", err
+	}
+
+	var doc bytes.Buffer
+	if base.html != nil {
+		t, err := base.html.Clone()
+		if err != nil {
+			return "", err
+		}
+		if funcs != nil {
+			t.Funcs(htmltemplate.FuncMap(funcs))
+		}
+		if t, err = t.New(fuseaction).Parse(tmpl); err != nil {
+			return "", err
+		}
+		if err = t.Execute(&doc, data); err != nil {
+			return "", err
+		}
+		return doc.String(), nil
+	}
+
+	t, err := base.text.Clone()
+	if err != nil {
+		return "", err
+	}
+	if funcs != nil {
+		t.Funcs(funcs)
+	}
+	if t, err = t.New(fuseaction).Parse(tmpl); err != nil {
+		return "", err
+	}
+	if err = t.Execute(&doc, data); err != nil {
+		return "", err
+	}
+
+	return doc.String(), nil
+}
+
+// Load возвращает разобранные шаблоны директории templatesDir, разбирая их при первом обращении.
+// autoescape — разбирать шаблоны пакетом html/template.
+func (c *TemplateCache) Load(templatesDir string, autoescape bool) (*pageTemplates, error) {
+	if c == nil {
+		return loadTemplateSet(templatesDir, autoescape)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := templateKey{dir: templatesDir, autoescape: autoescape}
+	if set, ok := c.sets[key]; ok {
+		return set.base, set.err
+	}
+
+	set := &templateSet{files: map[string]string{}}
+	files, err := templateFiles(templatesDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		set.files[file] = HashFileCrc32(file)
+	}
+	set.base, set.err = loadTemplateSet(templatesDir, autoescape)
+	c.sets[key] = set
+	c.loads++
+
+	return set.base, set.err
+}
+
+// Render формирует страницу из шаблона pagepath с шаблонами директории templatesDir.
+// Параметры совпадают с параметрами ParseFileView; autoescape — формировать страницу пакетом html/template,
+// funcs — функции шаблонов страницы (см. executePage).
+func (c *TemplateCache) Render(pagepath string, templatesDir string, data interface{}, fuseaction string, autoescape bool, funcs template.FuncMap) (string, error) {
+	base, err := c.Load(templatesDir, autoescape)
+	if err != nil {
+		return "", err
+	}
+
+	return executePage(base, pagepath, data, fuseaction, funcs)
+}
+
+// Refresh удаляет из кэша наборы шаблонов, файлы которых добавлены, удалены или изменились
+// с момента разбора. Вызывается перед каждой сборкой.
+func (c *TemplateCache) Refresh() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, set := range c.sets {
+		files, err := templateFiles(key.dir)
+		if err != nil || len(files) != len(set.files) {
+			delete(c.sets, key)
+			continue
+		}
+		for _, file := range files {
+			if hash, ok := set.files[file]; !ok || hash != HashFileCrc32(file) {
+				delete(c.sets, key)
+				break
+			}
+		}
+	}
+}
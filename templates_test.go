@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTemplateCache_ParsesOncePerSet(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	templatesDir := filepath.Join(dir, "__templates")
+	writeTestFile(t, filepath.Join(templatesDir, "layout.tmpl"), `{{define "title"}}Сайт{{end}}{{define "layout"}}<h1>{{template "title" .}}</h1>{{end}}`)
+	first := filepath.Join(dir, "first.html")
+	second := filepath.Join(dir, "second.html")
+	writeTestFile(t, first, `{{define "title"}}Первая{{end}}{{template "layout" .}}`)
+	writeTestFile(t, second, `{{template "layout" .}} {{Inc 1}}`)
+
+	cache := NewTemplateCache()
+	for i := 0; i < 3; i++ {
+		result, err := cache.Render(first, templatesDir, nil, "first.html", true, nil)
+		if err != nil || result != "<h1>Первая</h1>" {
+			t.Fatalf("первая страница = %q, %v", result, err)
+		}
+		// Шаблон, переопределённый первой страницей, не влияет на вторую.
+		result, err = cache.Render(second, templatesDir, nil, "second.html", true, nil)
+		if err != nil || result != "<h1>Сайт</h1> 2" {
+			t.Fatalf("вторая страница = %q, %v", result, err)
+		}
+	}
+	if cache.loads != 1 {
+		t.Errorf("шаблоны разобраны %d раз, ожидался один разбор", cache.loads)
+	}
+
+	// Неизменённые шаблоны не разбираются заново.
+	cache.Refresh()
+	if _, err := cache.Load(templatesDir, true); err != nil || cache.loads != 1 {
+		t.Errorf("неизменённые шаблоны разобраны заново: %d, %v", cache.loads, err)
+	}
+
+	// Изменённые и добавленные шаблоны разбираются заново.
+	writeTestFile(t, filepath.Join(templatesDir, "layout.tmpl"), `{{define "title"}}Новый{{end}}{{define "layout"}}<h2>{{template "title" .}}</h2>{{end}}`)
+	cache.Refresh()
+	result, err := cache.Render(second, templatesDir, nil, "second.html", true, nil)
+	if err != nil || result != "<h2>Новый</h2> 2" || cache.loads != 2 {
+		t.Errorf("после изменения шаблона страница = %q, разборов %d, %v", result, cache.loads, err)
+	}
+	writeTestFile(t, filepath.Join(templatesDir, "broken.tmpl"), `{{define "broken"}}`)
+	cache.Refresh()
+	if _, err = cache.Render(second, templatesDir, nil, "second.html", true, nil); err == nil {
+		t.Errorf("ошибка в добавленном шаблоне не обнаружена")
+	}
+}
+
+func TestBuild_EscapesQAMarkup(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости"></tag></tags>`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "blog.html"), `{{range .Blog}}{{.Title}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "post.html"), `<h1>{{.Blogpost.Title}}</h1>{{.Blogpost.Content}}`)
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "qa.html"), `{{range .QA}}<a title="{{.Name}}">{{.Name}}</a>: {{.Question}} {{.Answer}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.TemplatesDir(), "base.tmpl"), `{{define "base"}}{{end}}`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "post.xml"), `<post><date>01.02.2026</date><tagid>1</tagid><title>Пост &lt;i&gt;</title><content><![CDATA[<p>Текст</p>]]></content></post>`)
+	writeTestFile(t, filepath.Join(cfg.QADir(), "q.xml"), `<qa><date>07.03.2026</date><name>Иван &amp; Co</name><question>&lt;script&gt;alert(1)&lt;/script&gt;</question><answer><![CDATA[<p>Ответ</p>]]></answer></qa>`)
+
+	legacy := *cfg
+	legacy.Autoescape = false
+	legacy.Destination = t.TempDir()
+	legacy.Dirs.Hash = cfg.Dirs.Hash + "_legacy"
+	for _, c := range []*Config{cfg, &legacy} {
+		if err := Build(c); err != nil {
+			t.Fatalf("Build с autoescape=%v вернул ошибку: %v", c.Autoescape, err)
+		}
+	}
+
+	for _, test := range []struct {
+		dir, file, expected string
+	}{
+		{cfg.Destination, cfg.Output.QA, `<a title="Иван &amp; Co">Иван &amp; Co</a>: &lt;script&gt;alert(1)&lt;/script&gt; <p>Ответ</p>`},
+		{cfg.Destination, filepath.Join("blog", "posts", "post.html"), `<h1>Пост &lt;i&gt;</h1><p>Текст</p>`},
+		{legacy.Destination, cfg.Output.QA, `<a title="Иван & Co">Иван & Co</a>: <script>alert(1)</script> <p>Ответ</p>`},
+		{legacy.Destination, filepath.Join("blog", "posts", "post.html"), `<h1>Пост <i></h1><p>Текст</p>`},
+	} {
+		raw, err := os.ReadFile(filepath.Join(test.dir, test.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(raw) != test.expected {
+			t.Errorf("%s = %q, ожидалось %q", test.file, raw, test.expected)
+		}
+	}
+}
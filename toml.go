@@ -0,0 +1,560 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Разбор файлов в формате TOML.
+// Поддерживается подмножество TOML, достаточное для файлов настроек и front matter:
+// таблицы и массивы таблиц, составные ключи, строки всех видов, целые и дробные числа,
+// логические значения, даты, массивы и встроенные таблицы.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// SyntaxError описывает ошибку разбора файла с указанием позиции.
+type SyntaxError struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("строка %d, позиция %d: %s", e.Line, e.Column, e.Message)
+}
+
+// tomlParser выполняет разбор документа TOML.
+type tomlParser struct {
+	src string
+	pos int
+	// Корневая таблица документа.
+	root map[string]interface{}
+	// Текущая таблица, в которую добавляются пары ключ-значение.
+	current map[string]interface{}
+	// Таблицы, заголовки которых уже встречались в документе.
+	defined map[string]bool
+}
+
+// parseTOML разбирает документ TOML и возвращает корневую таблицу.
+func parseTOML(src string) (map[string]interface{}, error) {
+	p := &tomlParser{
+		src:     strings.TrimPrefix(src, "\ufeff"),
+		root:    map[string]interface{}{},
+		defined: map[string]bool{},
+	}
+	p.current = p.root
+
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+
+	return p.root, nil
+}
+
+// errorf возвращает ошибку разбора в текущей позиции.
+func (p *tomlParser) errorf(format string, args ...interface{}) error {
+	line, column := position(p.src, p.pos)
+	return &SyntaxError{Line: line, Column: column, Message: fmt.Sprintf(format, args...)}
+}
+
+// position вычисляет номер строки и позицию в строке для смещения offset.
+func position(src string, offset int) (int, int) {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	line := strings.Count(src[:offset], "\n") + 1
+	lineStart := strings.LastIndex(src[:offset], "\n") + 1
+
+	return line, utf8.RuneCountInString(src[lineStart:offset]) + 1
+}
+
+func (p *tomlParser) eof() bool {
+	return p.pos >= len(p.src)
+}
+
+func (p *tomlParser) peek() byte {
+	if p.eof() {
+		return 0
+	}
+	return p.src[p.pos]
+}
+
+// skipSpaces пропускает пробелы и табуляции.
+func (p *tomlParser) skipSpaces() {
+	for !p.eof() && (p.peek() == ' ' || p.peek() == '\t') {
+		p.pos++
+	}
+}
+
+// skipComment пропускает комментарий до конца строки.
+func (p *tomlParser) skipComment() {
+	if p.peek() == '#' {
+		for !p.eof() && p.peek() != '\n' {
+			p.pos++
+		}
+	}
+}
+
+// skipBlank пропускает пробелы, переводы строк и комментарии.
+func (p *tomlParser) skipBlank() {
+	for !p.eof() {
+		switch p.peek() {
+		case ' ', '\t', '\r', '\n':
+			p.pos++
+		case '#':
+			p.skipComment()
+		default:
+			return
+		}
+	}
+}
+
+// endOfLine проверяет, что после значения до конца строки нет ничего, кроме комментария.
+func (p *tomlParser) endOfLine() error {
+	p.skipSpaces()
+	p.skipComment()
+	if p.peek() == '\r' {
+		p.pos++
+	}
+	if !p.eof() && p.peek() != '\n' {
+		return p.errorf("ожидался конец строки")
+	}
+
+	return nil
+}
+
+func (p *tomlParser) parse() error {
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil
+		}
+
+		var err error
+		if p.peek() == '[' {
+			err = p.parseTableHeader()
+		} else {
+			err = p.parseKeyValue(p.current)
+		}
+		if err != nil {
+			return err
+		}
+		if err = p.endOfLine(); err != nil {
+			return err
+		}
+	}
+}
+
+// parseTableHeader разбирает заголовок таблицы [a.b] или массива таблиц [[a.b]].
+func (p *tomlParser) parseTableHeader() error {
+	p.pos++
+	array := false
+	if p.peek() == '[' {
+		array = true
+		p.pos++
+	}
+
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.peek() != ']' {
+		return p.errorf("ожидался символ ]")
+	}
+	p.pos++
+	if array {
+		if p.peek() != ']' {
+			return p.errorf("ожидался символ ]")
+		}
+		p.pos++
+	}
+
+	table := p.root
+	for i, key := range keys {
+		last := i == len(keys)-1
+		value, exists := table[key]
+		switch {
+		case last && array:
+			list, ok := value.([]map[string]interface{})
+			if exists && !ok {
+				return p.errorf("ключ %q уже определён", strings.Join(keys, "."))
+			}
+			next := map[string]interface{}{}
+			table[key] = append(list, next)
+			table = next
+		case !exists:
+			next := map[string]interface{}{}
+			table[key] = next
+			table = next
+		default:
+			switch v := value.(type) {
+			case map[string]interface{}:
+				table = v
+			case []map[string]interface{}:
+				table = v[len(v)-1]
+			default:
+				return p.errorf("ключ %q уже определён", strings.Join(keys[:i+1], "."))
+			}
+		}
+	}
+
+	if !array {
+		name := strings.Join(keys, "\x00")
+		if p.defined[name] {
+			return p.errorf("таблица [%s] определена повторно", strings.Join(keys, "."))
+		}
+		p.defined[name] = true
+	}
+	p.current = table
+
+	return nil
+}
+
+// parseKey разбирает простой или составной ключ.
+func (p *tomlParser) parseKey() ([]string, error) {
+	var keys []string
+	for {
+		p.skipSpaces()
+		var key string
+		switch c := p.peek(); {
+		case c == '"':
+			s, err := p.parseBasicString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		case c == '\'':
+			s, err := p.parseLiteralString()
+			if err != nil {
+				return nil, err
+			}
+			key = s
+		default:
+			start := p.pos
+			for !p.eof() && isBareKeyChar(p.peek()) {
+				p.pos++
+			}
+			if start == p.pos {
+				return nil, p.errorf("ожидался ключ")
+			}
+			key = p.src[start:p.pos]
+		}
+		keys = append(keys, key)
+
+		p.skipSpaces()
+		if p.peek() != '.' {
+			return keys, nil
+		}
+		p.pos++
+	}
+}
+
+// isBareKeyChar проверяет, может ли символ входить в ключ без кавычек.
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// parseKeyValue разбирает пару ключ = значение и добавляет её в таблицу table.
+func (p *tomlParser) parseKeyValue(table map[string]interface{}) error {
+	keys, err := p.parseKey()
+	if err != nil {
+		return err
+	}
+	if p.peek() != '=' {
+		return p.errorf("ожидался символ =")
+	}
+	p.pos++
+	p.skipSpaces()
+
+	value, err := p.parseValue()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys[:len(keys)-1] {
+		next, exists := table[key]
+		if !exists {
+			child := map[string]interface{}{}
+			table[key] = child
+			table = child
+			continue
+		}
+		child, ok := next.(map[string]interface{})
+		if !ok {
+			return p.errorf("ключ %q уже определён", key)
+		}
+		table = child
+	}
+
+	key := keys[len(keys)-1]
+	if _, exists := table[key]; exists {
+		return p.errorf("ключ %q определён повторно", strings.Join(keys, "."))
+	}
+	table[key] = value
+
+	return nil
+}
+
+// parseValue разбирает значение.
+func (p *tomlParser) parseValue() (interface{}, error) {
+	switch c := p.peek(); {
+	case c == '"':
+		if strings.HasPrefix(p.src[p.pos:], `"""`) {
+			return p.parseMultilineString(`"""`)
+		}
+		return p.parseBasicString()
+	case c == '\'':
+		if strings.HasPrefix(p.src[p.pos:], "'''") {
+			return p.parseMultilineString("'''")
+		}
+		return p.parseLiteralString()
+	case c == '[':
+		return p.parseArray()
+	case c == '{':
+		return p.parseInlineTable()
+	case strings.HasPrefix(p.src[p.pos:], "true"):
+		p.pos += 4
+		return true, nil
+	case strings.HasPrefix(p.src[p.pos:], "false"):
+		p.pos += 5
+		return false, nil
+	case c == '+' || c == '-' || c >= '0' && c <= '9' || strings.HasPrefix(p.src[p.pos:], "inf") || strings.HasPrefix(p.src[p.pos:], "nan"):
+		return p.parseNumberOrDate()
+	case p.eof():
+		return nil, p.errorf("ожидалось значение")
+	default:
+		return nil, p.errorf("некорректное значение")
+	}
+}
+
+// parseBasicString разбирает строку в двойных кавычках с escape-последовательностями.
+func (p *tomlParser) parseBasicString() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.eof() || p.peek() == '\n' {
+			return "", p.errorf("незакрытая строка")
+		}
+		c := p.peek()
+		if c == '"' {
+			p.pos++
+			return b.String(), nil
+		}
+		if c == '\\' {
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+}
+
+// parseEscape разбирает escape-последовательность строки.
+func (p *tomlParser) parseEscape(b *strings.Builder) error {
+	p.pos++
+	c := p.peek()
+	p.pos++
+	switch c {
+	case 'b':
+		b.WriteByte('\b')
+	case 't':
+		b.WriteByte('\t')
+	case 'n':
+		b.WriteByte('\n')
+	case 'f':
+		b.WriteByte('\f')
+	case 'r':
+		b.WriteByte('\r')
+	case '"':
+		b.WriteByte('"')
+	case '\\':
+		b.WriteByte('\\')
+	case 'u', 'U':
+		size := 4
+		if c == 'U' {
+			size = 8
+		}
+		if p.pos+size > len(p.src) {
+			return p.errorf("некорректная escape-последовательность")
+		}
+		code, err := strconv.ParseUint(p.src[p.pos:p.pos+size], 16, 32)
+		if err != nil {
+			return p.errorf("некорректная escape-последовательность")
+		}
+		b.WriteRune(rune(code))
+		p.pos += size
+	default:
+		return p.errorf("некорректная escape-последовательность \\%c", c)
+	}
+
+	return nil
+}
+
+// parseLiteralString разбирает строку в одинарных кавычках.
+func (p *tomlParser) parseLiteralString() (string, error) {
+	p.pos++
+	start := p.pos
+	for !p.eof() && p.peek() != '\'' {
+		if p.peek() == '\n' {
+			return "", p.errorf("незакрытая строка")
+		}
+		p.pos++
+	}
+	if p.eof() {
+		return "", p.errorf("незакрытая строка")
+	}
+	s := p.src[start:p.pos]
+	p.pos++
+
+	return s, nil
+}
+
+// parseMultilineString разбирает многострочную строку.
+// Перевод строки сразу после открывающих кавычек не входит в значение.
+func (p *tomlParser) parseMultilineString(quote string) (string, error) {
+	p.pos += 3
+	if strings.HasPrefix(p.src[p.pos:], "\r\n") {
+		p.pos += 2
+	} else if p.peek() == '\n' {
+		p.pos++
+	}
+
+	var b strings.Builder
+	for {
+		if p.eof() {
+			return "", p.errorf("незакрытая многострочная строка")
+		}
+		if strings.HasPrefix(p.src[p.pos:], quote) {
+			p.pos += 3
+			return b.String(), nil
+		}
+		c := p.peek()
+		if quote == `"""` && c == '\\' {
+			// Обратная косая черта в конце строки убирает перевод строки и начальные пробелы следующей строки.
+			rest := strings.TrimLeft(p.src[p.pos+1:], " \t")
+			if strings.HasPrefix(rest, "\n") || strings.HasPrefix(rest, "\r\n") {
+				p.pos = len(p.src) - len(strings.TrimLeft(rest, " \t\r\n"))
+				continue
+			}
+			if err := p.parseEscape(&b); err != nil {
+				return "", err
+			}
+			continue
+		}
+		b.WriteByte(c)
+		p.pos++
+	}
+}
+
+// parseArray разбирает массив.
+func (p *tomlParser) parseArray() (interface{}, error) {
+	p.pos++
+	list := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return nil, p.errorf("незакрытый массив")
+		}
+		if p.peek() == ']' {
+			p.pos++
+			return list, nil
+		}
+
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, value)
+
+		p.skipBlank()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+		default:
+			return nil, p.errorf("ожидался символ , или ]")
+		}
+	}
+}
+
+// parseInlineTable разбирает встроенную таблицу.
+func (p *tomlParser) parseInlineTable() (interface{}, error) {
+	p.pos++
+	table := map[string]interface{}{}
+	p.skipSpaces()
+	if p.peek() == '}' {
+		p.pos++
+		return table, nil
+	}
+	for {
+		if err := p.parseKeyValue(table); err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+			p.pos++
+			return table, nil
+		default:
+			return nil, p.errorf("ожидался символ , или }")
+		}
+	}
+}
+
+// tomlDateLayouts — поддерживаемые форматы дат и времени.
+var tomlDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// parseNumberOrDate разбирает число, дату или время.
+func (p *tomlParser) parseNumberOrDate() (interface{}, error) {
+	start := p.pos
+	for !p.eof() {
+		c := p.peek()
+		if c == ',' || c == ']' || c == '}' || c == '#' || c == '\n' || c == '\r' || c == '\t' {
+			break
+		}
+		// Пробел допустим внутри даты-времени вида 2006-01-02 15:04:05.
+		if c == ' ' && !(p.pos-start == 10 && p.pos+1 < len(p.src) && p.src[p.pos+1] >= '0' && p.src[p.pos+1] <= '9') {
+			break
+		}
+		p.pos++
+	}
+	token := p.src[start:p.pos]
+
+	if len(token) >= 10 && token[4] == '-' && token[7] == '-' {
+		for _, layout := range tomlDateLayouts {
+			if t, err := time.Parse(layout, token); err == nil {
+				return t, nil
+			}
+		}
+		p.pos = start
+		return nil, p.errorf("некорректная дата %q", token)
+	}
+
+	clean := strings.ReplaceAll(token, "_", "")
+	switch clean {
+	case "inf", "+inf", "-inf", "nan", "+nan", "-nan":
+		f, _ := strconv.ParseFloat(clean, 64)
+		return f, nil
+	}
+	if i, err := strconv.ParseInt(clean, 0, 64); err == nil {
+		return i, nil
+	}
+	if f, err := strconv.ParseFloat(clean, 64); err == nil {
+		return f, nil
+	}
+
+	p.pos = start
+	return nil, p.errorf("некорректное число %q", token)
+}
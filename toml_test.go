@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTOML(t *testing.T) {
+	t.Parallel()
+
+	values, err := parseTOML(`
+# комментарий
+title = "Привет\tмир"
+count = 1_000
+ratio = 0.5
+enabled = true
+date = 2021-03-04
+tags = ["a", 'b',
+  "c"]
+
+[server.http]
+port = 8080
+inline = { name = "x", on = false }
+
+[[items]]
+id = 1
+
+[[items]]
+id = 2
+`)
+	if err != nil {
+		t.Fatalf("parseTOML вернул ошибку: %v", err)
+	}
+	if values["title"] != "Привет\tмир" || values["count"] != int64(1000) || values["ratio"] != 0.5 || values["enabled"] != true {
+		t.Fatalf("неверные скалярные значения: %v", values)
+	}
+	if date, ok := values["date"].(time.Time); !ok || date.Day() != 4 {
+		t.Fatalf("date = %v", values["date"])
+	}
+	if tags, ok := values["tags"].([]interface{}); !ok || len(tags) != 3 {
+		t.Fatalf("tags = %v", values["tags"])
+	}
+	http := values["server"].(map[string]interface{})["http"].(map[string]interface{})
+	if http["port"] != int64(8080) || http["inline"].(map[string]interface{})["on"] != false {
+		t.Fatalf("server.http = %v", http)
+	}
+	if items, ok := values["items"].([]map[string]interface{}); !ok || len(items) != 2 || items[1]["id"] != int64(2) {
+		t.Fatalf("items = %v", values["items"])
+	}
+}
+
+func TestParseTOML_ReportsPosition(t *testing.T) {
+	t.Parallel()
+
+	_, err := parseTOML("a = 1\nb = \"незакрытая строка\n")
+	syntaxErr, ok := err.(*SyntaxError)
+	if !ok {
+		t.Fatalf("ожидалась ошибка SyntaxError, получено %v", err)
+	}
+	if syntaxErr.Line != 2 {
+		t.Fatalf("строка ошибки = %d, ожидалось 2", syntaxErr.Line)
+	}
+
+	if _, err = parseTOML("a = 1\na = 2\n"); err == nil {
+		t.Fatalf("повторное определение ключа должно быть ошибкой")
+	}
+}
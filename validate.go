@@ -0,0 +1,291 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Проверка исходных записей: постов, публикаций и записей Вопросы и ответы.
+// Загрузчики записей сообщают о каждой найденной проблеме с файлом, строкой и позицией и продолжают
+// загрузку, поэтому сборка выводит все проблемы сразу. Виды проблем:
+//   - syntax — ошибка разбора xml-файла или заголовка (front matter), запись пропускается;
+//   - required — не заполнено обязательное поле (заголовок, дата, рубрика, вопрос, страницы публикации);
+//   - date — дата не в формате ДД.ММ.ГГГГ;
+//   - tag — неизвестная рубрика поста;
+//   - duplicate — записи с одинаковым идентификатором (fuseaction) в разных поддиректориях;
+//   - pages — количество страниц публикации не совпадает с файлами N.html (N.md) её директории;
+//   - empty — пустой текст поста, ответа или страницы публикации.
+//
+// Секция validation файла конфигурации задаёт уровень каждого вида: error прерывает сборку после проверки
+// всех записей, warning выводит предупреждение, ignore не выводит ничего. Запись с проблемой syntax, required,
+// date, tag или duplicate не публикуется и при уровне warning или ignore.
+//
+//	[validation]
+//	pages = "error"
+//	empty = "ignore"
+
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Виды проблем исходных записей.
+const (
+	ProblemSyntax    = "syntax"
+	ProblemRequired  = "required"
+	ProblemDate      = "date"
+	ProblemTag       = "tag"
+	ProblemDuplicate = "duplicate"
+	ProblemPages     = "pages"
+	ProblemEmpty     = "empty"
+)
+
+// Уровни проблем.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityIgnore  = "ignore"
+)
+
+// ValidationOptions описывает уровни проблем исходных записей (секция validation).
+type ValidationOptions struct {
+	Syntax    string
+	Required  string
+	Date      string
+	Tag       string
+	Duplicate string
+	Pages     string
+	Empty     string
+}
+
+// DefaultValidation — уровни проблем по умолчанию.
+var DefaultValidation = ValidationOptions{
+	Syntax:    SeverityError,
+	Required:  SeverityError,
+	Date:      SeverityError,
+	Tag:       SeverityError,
+	Duplicate: SeverityError,
+	Pages:     SeverityWarning,
+	Empty:     SeverityWarning,
+}
+
+// Levels возвращает уровни проблем по видам.
+func (o ValidationOptions) Levels() map[string]string {
+	return map[string]string{
+		ProblemSyntax:    o.Syntax,
+		ProblemRequired:  o.Required,
+		ProblemDate:      o.Date,
+		ProblemTag:       o.Tag,
+		ProblemDuplicate: o.Duplicate,
+		ProblemPages:     o.Pages,
+		ProblemEmpty:     o.Empty,
+	}
+}
+
+// Problem описывает проблему исходной записи.
+type Problem struct {
+	// Вид проблемы.
+	Kind string
+	// Уровень проблемы: error или warning.
+	Severity string
+	// Исходный файл записи.
+	File string
+	// Строка и позиция в строке, начиная с 1; 0 — позиция неизвестна.
+	Line   int
+	Column int
+	// Описание проблемы.
+	Message string
+}
+
+func (p Problem) Error() string {
+	switch {
+	case p.Line > 0 && p.Column > 0:
+		return fmt.Sprintf("%s:%d:%d: %s", p.File, p.Line, p.Column, p.Message)
+	case p.Line > 0:
+		return fmt.Sprintf("%s:%d: %s", p.File, p.Line, p.Message)
+	}
+
+	return fmt.Sprintf("%s: %s", p.File, p.Message)
+}
+
+// ValidationError описывает ошибки исходных записей, найденные при загрузке сайта.
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems))
+	for _, problem := range e.Problems {
+		lines = append(lines, problem.Error())
+	}
+
+	return fmt.Sprintf("Ошибки в исходных файлах (%d):\n  %s", len(e.Problems), strings.Join(lines, "\n  "))
+}
+
+// Validator собирает проблемы исходных записей.
+// Методы nil-значения не собирают проблемы: Report возвращает проблему уровня error по умолчанию как ошибку.
+type Validator struct {
+	mu       sync.Mutex
+	severity map[string]string
+	problems []Problem
+}
+
+// NewValidator создаёт проверку исходных записей с уровнями проблем из секции validation конфигурации.
+func NewValidator(cfg *Config) *Validator {
+	return &Validator{severity: cfg.Validation.Levels()}
+}
+
+// Report сообщает о проблеме. Возвращает проблему как ошибку, если проверка не задана (nil)
+// и уровень проблемы по умолчанию — error; тогда загрузчик прерывает загрузку, как до появления проверки.
+func (v *Validator) Report(problem Problem) error {
+	if v == nil {
+		if DefaultValidation.Levels()[problem.Kind] == SeverityError {
+			return problem
+		}
+		return nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	problem.Severity = v.severity[problem.Kind]
+	if problem.Severity != SeverityIgnore {
+		v.problems = append(v.problems, problem)
+	}
+
+	return nil
+}
+
+// problemsOf возвращает проблемы уровня severity, отсортированные по файлу и позиции.
+func (v *Validator) problemsOf(severity string) []Problem {
+	if v == nil {
+		return nil
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	var problems []Problem
+	for _, problem := range v.problems {
+		if problem.Severity == severity {
+			problems = append(problems, problem)
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool {
+		a, b := problems[i], problems[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+
+	return problems
+}
+
+// Warnings возвращает предупреждения.
+func (v *Validator) Warnings() []Problem {
+	return v.problemsOf(SeverityWarning)
+}
+
+// Err возвращает *ValidationError со всеми ошибками или nil, если ошибок нет.
+func (v *Validator) Err() error {
+	if problems := v.problemsOf(SeverityError); len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+
+	return nil
+}
+
+// fieldPosition возвращает позицию поля name записи: элемента <name> xml-файла или ключа name заголовка.
+// Если поля нет, возвращается позиция корневого элемента root xml-файла, иначе начало файла.
+func fieldPosition(raw []byte, name string, root string) (int, int) {
+	patterns := []string{`<` + regexp.QuoteMeta(name) + `[\s/>]`, `(?m)^[ \t]*` + regexp.QuoteMeta(name) + `[ \t]*[:=]`, `<` + regexp.QuoteMeta(root) + `[\s/>]`}
+	for _, pattern := range patterns {
+		if loc := regexp.MustCompile(pattern).FindIndex(raw); loc != nil {
+			match := raw[loc[0]:loc[1]]
+			return position(string(raw), loc[1]-len(bytes.TrimLeft(match, " \t")))
+		}
+	}
+
+	return 1, 1
+}
+
+// decodeXMLRecord разбирает xml-файл записи; возвращает проблему syntax с позицией ошибки разбора или nil.
+func decodeXMLRecord(file string, raw []byte, target interface{}) *Problem {
+	decoder := xml.NewDecoder(bytes.NewReader(raw))
+	err := decoder.Decode(target)
+	if err == nil {
+		return nil
+	}
+
+	line, column := position(string(raw), int(decoder.InputOffset()))
+	var syntaxErr *xml.SyntaxError
+	message := err.Error()
+	if errors.As(err, &syntaxErr) {
+		message = syntaxErr.Msg
+	}
+
+	return &Problem{Kind: ProblemSyntax, File: file, Line: line, Column: column, Message: "ошибка разбора xml: " + message}
+}
+
+// frontMatterProblem преобразует ошибку загрузки файла с заголовком в проблему syntax с позицией.
+func frontMatterProblem(file string, err error) Problem {
+	problem := Problem{Kind: ProblemSyntax, File: file, Message: strings.TrimPrefix(err.Error(), file+": ")}
+	var syntaxErr *SyntaxError
+	if errors.As(err, &syntaxErr) {
+		problem.Line, problem.Column, problem.Message = syntaxErr.Line, syntaxErr.Column, syntaxErr.Message
+	}
+
+	return problem
+}
+
+// recordProblems собирает проблемы одной исходной записи.
+type recordProblems struct {
+	file string
+	// Содержимое файла записи; читается при первой проблеме поля, если не передано.
+	raw []byte
+	// Корневой элемент xml-файла записи.
+	root     string
+	problems []Problem
+}
+
+// newRecordProblems создаёт список проблем записи из файла file с корневым элементом root.
+func newRecordProblems(file string, root string, raw []byte) *recordProblems {
+	return &recordProblems{file: file, root: root, raw: raw}
+}
+
+// add добавляет проблему записи без позиции в файле.
+func (r *recordProblems) add(kind string, format string, args ...interface{}) {
+	r.problems = append(r.problems, Problem{Kind: kind, File: r.file, Message: fmt.Sprintf(format, args...)})
+}
+
+// field добавляет проблему поля записи с позицией поля в файле.
+func (r *recordProblems) field(kind string, name string, format string, args ...interface{}) {
+	if r.raw == nil {
+		r.raw, _ = ioutil.ReadFile(r.file)
+	}
+	line, column := fieldPosition(r.raw, name, r.root)
+	r.problems = append(r.problems, Problem{Kind: kind, File: r.file, Line: line, Column: column, Message: fmt.Sprintf(format, args...)})
+}
+
+// report сообщает о проблемах записи. Возвращает true, если запись не публикуется: среди проблем есть
+// проблема вида, отличного от pages и empty.
+func (r *recordProblems) report(v *Validator) (bool, error) {
+	skip := false
+	for _, problem := range r.problems {
+		if err := v.Report(problem); err != nil {
+			return true, err
+		}
+		if problem.Kind != ProblemPages && problem.Kind != ProblemEmpty {
+			skip = true
+		}
+	}
+
+	return skip, nil
+}
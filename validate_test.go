@@ -0,0 +1,139 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// problemSummaries возвращает проблемы в виде "вид файл:строка:позиция" с путём файла относительно dir.
+func problemSummaries(t *testing.T, dir string, problems []Problem) []string {
+	t.Helper()
+
+	summaries := []string{}
+	for _, problem := range problems {
+		rel, err := filepath.Rel(dir, problem.File)
+		if err != nil {
+			t.Fatalf("путь проблемы %s: %v", problem.File, err)
+		}
+		summaries = append(summaries, fmt.Sprintf("%s %s:%d:%d", problem.Kind, filepath.ToSlash(rel), problem.Line, problem.Column))
+	}
+
+	return summaries
+}
+
+// writeInvalidSources создаёт исходные записи со всеми видами проблем.
+func writeInvalidSources(t *testing.T, cfg *Config) {
+	t.Helper()
+
+	writeTestFile(t, filepath.Join(cfg.SettingsDir(), "tags.xml"), `<tags><tag id="1" name="Новости"></tag></tags>`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "good.xml"), `<post><date>01.03.2026</date><tagid>1</tagid><title>Хороший</title><content>Текст</content></post>`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "2026", "good.xml"), `<post><date>02.03.2026</date><tagid>1</tagid><title>Повтор</title><content>Текст</content></post>`)
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "broken.xml"), "<post>\n  <title>Сломанный</title>\n  <date>01.03.2026</date>\n</pots>")
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "date.xml"), "<post>\n  <tagid>1</tagid>\n  <title>Дата</title>\n  <date>2026-03-01</date>\n  <content>Текст</content>\n</post>")
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "tag.xml"), "<post>\n  <date>01.03.2026</date>\n    <tagid>7</tagid>\n  <title>Рубрика</title>\n  <content>Текст</content>\n</post>")
+	writeTestFile(t, filepath.Join(cfg.BlogDir(), "front.md"), "---\ntitle: Без даты\ntags: [Новости]\n---\n")
+	writeTestFile(t, filepath.Join(cfg.ArticlesDir(), "guide.xml"), "<article>\n  <title>Руководство</title>\n  <pages>Первая|Вторая</pages>\n</article>")
+	writeTestFile(t, filepath.Join(cfg.ArticlesDir(), "guide", "1.html"), "  \n")
+	writeTestFile(t, filepath.Join(cfg.ArticlesDir(), "guide", "3.md"), "Лишняя")
+	writeTestFile(t, filepath.Join(cfg.ArticlesDir(), "notitle.xml"), "<article>\n  <pages>Одна</pages>\n</article>")
+	writeTestFile(t, filepath.Join(cfg.QADir(), "q.xml"), "<qa>\n  <date>31.02.2026</date>\n  <question>Когда?</question>\n  <answer>Скоро</answer>\n</qa>")
+	writeTestFile(t, filepath.Join(cfg.QADir(), "empty.xml"), "<qa>\n  <date>01.03.2026</date>\n  <question>Что?</question>\n</qa>")
+}
+
+func TestLoadSite_ReportsAllProblems(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	writeInvalidSources(t, cfg)
+
+	_, err := LoadSite(cfg)
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("ожидалась ошибка проверки, получено %v", err)
+	}
+	expected := []string{
+		"required __articles/notitle.xml:1:1",
+		"syntax __blog/broken.xml:4:8",
+		"date __blog/date.xml:4:3",
+		"required __blog/front.md:1:1",
+		"duplicate __blog/good.xml:0:0",
+		"tag __blog/tag.xml:3:5",
+		"date __qa/q.xml:2:3",
+	}
+	if actual := problemSummaries(t, cfg.Source, validationErr.Problems); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("ошибки:\n%v\nожидалось:\n%v", actual, expected)
+	}
+	if message := err.Error(); !strings.Contains(message, "date.xml:4:3: некорректная дата \"2026-03-01\"") || !strings.Contains(message, "(7)") {
+		t.Errorf("текст ошибки:\n%s", message)
+	}
+}
+
+func TestLoadSite_ValidationSeverity(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	writeInvalidSources(t, cfg)
+	cfg.Validation = ValidationOptions{
+		Syntax:    SeverityWarning,
+		Required:  SeverityWarning,
+		Date:      SeverityIgnore,
+		Tag:       SeverityWarning,
+		Duplicate: SeverityIgnore,
+		Pages:     SeverityWarning,
+		Empty:     SeverityWarning,
+	}
+
+	site, err := LoadSite(cfg)
+	if err != nil {
+		t.Fatalf("LoadSite вернул ошибку: %v", err)
+	}
+	expected := []string{
+		"pages __articles/guide.xml:3:3",
+		"pages __articles/guide.xml:3:3",
+		"empty __articles/guide/1.html:0:0",
+		"required __articles/notitle.xml:1:1",
+		"pages __articles/notitle.xml:2:3",
+		"syntax __blog/broken.xml:4:8",
+		"required __blog/front.md:1:1",
+		"empty __blog/front.md:1:1",
+		"tag __blog/tag.xml:3:5",
+		"empty __qa/empty.xml:1:1",
+	}
+	if actual := problemSummaries(t, cfg.Source, site.Warnings()); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("предупреждения:\n%v\nожидалось:\n%v", actual, expected)
+	}
+
+	// Записи с проблемами, кроме pages и empty, не публикуются.
+	titles := []string{}
+	for _, post := range site.Posts {
+		titles = append(titles, post.Title)
+	}
+	if len(titles) != 1 || site.QACount != 1 || len(site.Articles) != 1 || site.Articles[0].Title != "Руководство" {
+		t.Errorf("опубликованы посты %v, записей %d, публикаций %+v", titles, site.QACount, site.Articles)
+	}
+}
+
+func TestLoadQA_ReportsInvalidDate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeTestFile(t, filepath.Join(dir, "q.xml"), `<qa><date>1 марта</date><question>Когда?</question><answer>Скоро</answer></qa>`)
+	if _, _, err := loadQA(dir, nil); err == nil || !strings.Contains(err.Error(), "q.xml:1:5: некорректная дата") {
+		t.Errorf("ожидалась ошибка даты с позицией, получено %v", err)
+	}
+}
+
+func TestConfigValidate_ValidationLevels(t *testing.T) {
+	t.Parallel()
+
+	cfg := newTestSiteConfig(t)
+	cfg.Validation.Empty = "fatal"
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "validation.empty") {
+		t.Errorf("ожидалась ошибка уровня validation.empty, получено %v", err)
+	}
+}
@@ -0,0 +1,581 @@
+// Googol генератор статических html-страниц из шаблонов.
+// Разбор файлов в формате YAML.
+// Поддерживается подмножество YAML, достаточное для файлов настроек и front matter:
+// вложенные словари и списки, строки в кавычках и без, блочные строки | и >,
+// числа, логические значения, null, даты и однострочные коллекции [..] и {..}.
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// yamlLine описывает значимую строку документа YAML.
+type yamlLine struct {
+	// Номер строки в документе.
+	num int
+	// Отступ строки.
+	indent int
+	// Текст строки без отступа и комментария.
+	text string
+	// Исходный текст строки, используется блочными строками.
+	raw string
+}
+
+// yamlParser выполняет разбор документа YAML.
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML разбирает документ YAML, корнем которого является словарь.
+func parseYAML(src string) (map[string]interface{}, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(strings.TrimPrefix(src, "\ufeff"), "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		text := strings.TrimLeft(raw, " ")
+		if strings.HasPrefix(text, "\t") {
+			return nil, &SyntaxError{Line: i + 1, Column: len(raw) - len(text) + 1, Message: "табуляция в отступе недопустима"}
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(raw) - len(text), text: stripYAMLComment(text), raw: raw})
+	}
+
+	p.skipBlank()
+	if p.pos < len(p.lines) && p.lines[p.pos].text == "---" {
+		p.pos++
+	}
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return map[string]interface{}{}, nil
+	}
+
+	node, err := p.parseNode(p.lines[p.pos].indent)
+	if err != nil {
+		return nil, err
+	}
+	p.skipBlank()
+	if p.pos < len(p.lines) {
+		return nil, p.errorf(p.lines[p.pos], "некорректный отступ")
+	}
+
+	root, ok := node.(map[string]interface{})
+	if !ok {
+		return nil, &SyntaxError{Line: 1, Column: 1, Message: "документ должен быть словарём"}
+	}
+
+	return root, nil
+}
+
+// stripYAMLComment удаляет комментарий в конце строки, не затрагивая символ # внутри кавычек.
+func stripYAMLComment(text string) string {
+	quote := byte(0)
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else if c == '\\' && quote == '"' {
+				i++
+			}
+		case c == '"' || c == '\'':
+			// Кавычка открывает строку только в начале значения.
+			if i == 0 || strings.ContainsRune(" [{,:-", rune(text[i-1])) {
+				quote = c
+			}
+		case c == '#' && (i == 0 || text[i-1] == ' '):
+			return strings.TrimRight(text[:i], " ")
+		}
+	}
+
+	return strings.TrimRight(text, " ")
+}
+
+func (p *yamlParser) errorf(line yamlLine, message string) error {
+	return &SyntaxError{Line: line.num, Column: line.indent + 1, Message: message}
+}
+
+// skipBlank пропускает пустые строки и строки-комментарии.
+func (p *yamlParser) skipBlank() {
+	for p.pos < len(p.lines) && len(p.lines[p.pos].text) == 0 {
+		p.pos++
+	}
+}
+
+// isSequenceItem проверяет, является ли строка элементом списка.
+func isSequenceItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// parseNode разбирает словарь, список или скаляр, начинающийся с отступом indent.
+func (p *yamlParser) parseNode(indent int) (interface{}, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+
+	line := p.lines[p.pos]
+	if isSequenceItem(line.text) {
+		return p.parseSequence(indent)
+	}
+	if _, _, ok := splitYAMLKey(line.text); ok {
+		return p.parseMapping(indent)
+	}
+
+	// Многострочный скаляр без кавычек.
+	parts := []string{}
+	for p.pos < len(p.lines) && (len(p.lines[p.pos].text) == 0 || p.lines[p.pos].indent >= indent) {
+		if len(p.lines[p.pos].text) > 0 {
+			parts = append(parts, p.lines[p.pos].text)
+		}
+		p.pos++
+	}
+
+	return p.parseInline(line, strings.Join(parts, " "))
+}
+
+// splitYAMLKey разделяет строку словаря на ключ и значение.
+func splitYAMLKey(text string) (string, string, bool) {
+	if strings.HasPrefix(text, `"`) || strings.HasPrefix(text, "'") {
+		end := strings.IndexByte(text[1:], text[0])
+		if end < 0 {
+			return "", "", false
+		}
+		rest := text[end+2:]
+		if !strings.HasPrefix(rest, ":") {
+			return "", "", false
+		}
+		key, err := unquoteYAML(text[:end+2])
+		if err != nil {
+			return "", "", false
+		}
+		return key, strings.TrimSpace(rest[1:]), true
+	}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == ':' && (i == len(text)-1 || text[i+1] == ' ') {
+			return strings.TrimSpace(text[:i]), strings.TrimSpace(text[i+1:]), i > 0
+		}
+		if text[i] == '[' || text[i] == '{' {
+			return "", "", false
+		}
+	}
+
+	return "", "", false
+}
+
+// parseMapping разбирает словарь с отступом indent.
+func (p *yamlParser) parseMapping(indent int) (interface{}, error) {
+	result := map[string]interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) || p.lines[p.pos].indent < indent {
+			return result, nil
+		}
+
+		line := p.lines[p.pos]
+		if line.indent > indent {
+			return nil, p.errorf(line, "некорректный отступ")
+		}
+		key, value, ok := splitYAMLKey(line.text)
+		if !ok {
+			return nil, p.errorf(line, "ожидалась пара ключ: значение")
+		}
+		if _, exists := result[key]; exists {
+			return nil, p.errorf(line, "ключ "+strconv.Quote(key)+" определён повторно")
+		}
+		p.pos++
+
+		node, err := p.parseValue(line, indent, value)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = node
+	}
+}
+
+// parseSequence разбирает список с отступом indent.
+func (p *yamlParser) parseSequence(indent int) (interface{}, error) {
+	result := []interface{}{}
+	for {
+		p.skipBlank()
+		if p.pos >= len(p.lines) || p.lines[p.pos].indent < indent {
+			return result, nil
+		}
+
+		line := p.lines[p.pos]
+		if line.indent > indent || !isSequenceItem(line.text) {
+			if line.indent == indent {
+				return result, nil
+			}
+			return nil, p.errorf(line, "некорректный отступ")
+		}
+
+		rest := strings.TrimLeft(strings.TrimPrefix(line.text, "-"), " ")
+		if len(rest) == 0 {
+			p.pos++
+			node, err := p.parseChild(line, indent)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, node)
+			continue
+		}
+
+		// Содержимое элемента списка разбирается как узел с отступом, равным позиции содержимого.
+		offset := len(line.text) - len(rest)
+		p.lines[p.pos] = yamlLine{num: line.num, indent: line.indent + offset, text: rest, raw: line.raw}
+		node, err := p.parseNode(line.indent + offset)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, node)
+	}
+}
+
+// parseChild разбирает вложенный узел, следующий за строкой line с отступом indent.
+func (p *yamlParser) parseChild(line yamlLine, indent int) (interface{}, error) {
+	p.skipBlank()
+	if p.pos >= len(p.lines) {
+		return nil, nil
+	}
+	next := p.lines[p.pos]
+	// Список, являющийся значением ключа, может иметь тот же отступ, что и ключ.
+	if next.indent > indent || next.indent == indent && isSequenceItem(next.text) && !isSequenceItem(line.text) {
+		return p.parseNode(next.indent)
+	}
+
+	return nil, nil
+}
+
+// parseValue разбирает значение ключа словаря.
+func (p *yamlParser) parseValue(line yamlLine, indent int, value string) (interface{}, error) {
+	switch {
+	case len(value) == 0:
+		return p.parseChild(line, indent)
+	case strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">"):
+		return p.parseBlockScalar(indent, value)
+	case strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{"):
+		// Коллекция может занимать несколько строк.
+		for !flowBalanced(value) && p.pos < len(p.lines) {
+			value += " " + strings.TrimSpace(p.lines[p.pos].text)
+			p.pos++
+		}
+	}
+
+	return p.parseInline(line, value)
+}
+
+// flowBalanced проверяет, закрыты ли все скобки однострочной коллекции.
+func flowBalanced(value string) bool {
+	depth := 0
+	quote := byte(0)
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[' || c == '{':
+			depth++
+		case c == ']' || c == '}':
+			depth--
+		}
+	}
+
+	return depth <= 0
+}
+
+// parseBlockScalar разбирает блочную строку | или >.
+func (p *yamlParser) parseBlockScalar(indent int, header string) (interface{}, error) {
+	folded := strings.HasPrefix(header, ">")
+	chomp := strings.TrimLeft(header[1:], "0123456789")
+
+	var lines []string
+	blockIndent := -1
+	for p.pos < len(p.lines) {
+		line := p.lines[p.pos]
+		if len(strings.TrimSpace(line.raw)) == 0 {
+			lines = append(lines, "")
+			p.pos++
+			continue
+		}
+		if line.indent <= indent {
+			break
+		}
+		if blockIndent < 0 {
+			blockIndent = line.indent
+		}
+		if line.indent < blockIndent {
+			break
+		}
+		lines = append(lines, line.raw[blockIndent:])
+		p.pos++
+	}
+
+	// Завершающие пустые строки обрабатываются в соответствии с индикатором.
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+
+	var text string
+	if folded {
+		var b strings.Builder
+		for i, line := range lines {
+			switch {
+			case i == 0:
+			case line == "" || lines[i-1] == "" || strings.HasPrefix(line, " "):
+				b.WriteString("\n")
+			default:
+				b.WriteString(" ")
+			}
+			b.WriteString(line)
+		}
+		text = b.String()
+	} else {
+		text = strings.Join(lines, "\n")
+	}
+
+	switch chomp {
+	case "-":
+	case "+":
+		text += "\n" + strings.Repeat("\n", trailing)
+	default:
+		if len(lines) > 0 {
+			text += "\n"
+		}
+	}
+
+	return text, nil
+}
+
+// parseInline разбирает однострочное значение: скаляр или коллекцию [..], {..}.
+func (p *yamlParser) parseInline(line yamlLine, value string) (interface{}, error) {
+	f := &yamlFlow{src: value}
+	node, err := f.parse()
+	if err == nil {
+		f.skipSpaces()
+		if f.pos < len(f.src) {
+			err = errYAMLFlow("лишние символы после значения")
+		}
+	}
+	if err != nil {
+		return nil, &SyntaxError{Line: line.num, Column: line.indent + 1, Message: err.Error()}
+	}
+
+	return node, nil
+}
+
+// errYAMLFlow описывает ошибку разбора однострочного значения.
+type errYAMLFlow string
+
+func (e errYAMLFlow) Error() string {
+	return string(e)
+}
+
+// yamlFlow разбирает однострочные значения.
+type yamlFlow struct {
+	src string
+	pos int
+}
+
+func (f *yamlFlow) skipSpaces() {
+	for f.pos < len(f.src) && f.src[f.pos] == ' ' {
+		f.pos++
+	}
+}
+
+func (f *yamlFlow) parse() (interface{}, error) {
+	f.skipSpaces()
+	if f.pos >= len(f.src) {
+		return nil, nil
+	}
+
+	switch f.src[f.pos] {
+	case '[':
+		f.pos++
+		list := []interface{}{}
+		for {
+			f.skipSpaces()
+			if f.pos < len(f.src) && f.src[f.pos] == ']' {
+				f.pos++
+				return list, nil
+			}
+			item, err := f.parseItem(",]")
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+			if err = f.separator(']'); err != nil {
+				return nil, err
+			}
+		}
+	case '{':
+		f.pos++
+		dict := map[string]interface{}{}
+		for {
+			f.skipSpaces()
+			if f.pos < len(f.src) && f.src[f.pos] == '}' {
+				f.pos++
+				return dict, nil
+			}
+			key, err := f.parseItem(":,}")
+			if err != nil {
+				return nil, err
+			}
+			f.skipSpaces()
+			var value interface{}
+			if f.pos < len(f.src) && f.src[f.pos] == ':' {
+				f.pos++
+				if value, err = f.parseItem(",}"); err != nil {
+					return nil, err
+				}
+			}
+			dict[scalarString(key)] = value
+			if err = f.separator('}'); err != nil {
+				return nil, err
+			}
+		}
+	case '"', '\'':
+		return f.parseQuoted()
+	}
+
+	return f.parsePlain("")
+}
+
+// separator разбирает разделитель элементов коллекции.
+func (f *yamlFlow) separator(closing byte) error {
+	f.skipSpaces()
+	if f.pos >= len(f.src) {
+		return errYAMLFlow("незакрытая коллекция")
+	}
+	switch f.src[f.pos] {
+	case ',':
+		f.pos++
+	case closing:
+	default:
+		return errYAMLFlow("ожидался символ , или " + string(closing))
+	}
+
+	return nil
+}
+
+// parseItem разбирает элемент коллекции, ограниченный символами stops.
+func (f *yamlFlow) parseItem(stops string) (interface{}, error) {
+	f.skipSpaces()
+	if f.pos < len(f.src) && strings.IndexByte("[{\"'", f.src[f.pos]) >= 0 {
+		return f.parse()
+	}
+
+	return f.parsePlain(stops)
+}
+
+// parseQuoted разбирает строку в кавычках.
+func (f *yamlFlow) parseQuoted() (interface{}, error) {
+	quote := f.src[f.pos]
+	end := f.pos + 1
+	for end < len(f.src) {
+		if f.src[end] == '\\' && quote == '"' {
+			end += 2
+			continue
+		}
+		if f.src[end] == quote {
+			if quote == '\'' && end+1 < len(f.src) && f.src[end+1] == '\'' {
+				end += 2
+				continue
+			}
+			break
+		}
+		end++
+	}
+	if end >= len(f.src) {
+		return nil, errYAMLFlow("незакрытая строка")
+	}
+
+	s, err := unquoteYAML(f.src[f.pos : end+1])
+	if err != nil {
+		return nil, err
+	}
+	f.pos = end + 1
+
+	return s, nil
+}
+
+// parsePlain разбирает скаляр без кавычек до одного из символов stops.
+func (f *yamlFlow) parsePlain(stops string) (interface{}, error) {
+	start := f.pos
+	for f.pos < len(f.src) && strings.IndexByte(stops, f.src[f.pos]) < 0 {
+		f.pos++
+	}
+
+	return resolveYAMLScalar(strings.TrimSpace(f.src[start:f.pos])), nil
+}
+
+// unquoteYAML снимает кавычки со строки и обрабатывает escape-последовательности.
+func unquoteYAML(s string) (string, error) {
+	if strings.HasPrefix(s, "'") {
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+
+	value, err := strconv.Unquote(s)
+	if err != nil {
+		return "", errYAMLFlow("некорректная строка " + s)
+	}
+
+	return value, nil
+}
+
+// resolveYAMLScalar определяет тип скаляра без кавычек.
+func resolveYAMLScalar(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+
+	if i, err := strconv.ParseInt(strings.ReplaceAll(s, "_", ""), 0, 64); err == nil {
+		return i
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && strings.ContainsAny(s, "0123456789") {
+		return f
+	}
+	if len(s) >= 10 && s[4] == '-' && s[7] == '-' {
+		for _, layout := range tomlDateLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t
+			}
+		}
+	}
+
+	return s
+}
+
+// scalarString приводит скаляр к строке.
+func scalarString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		return v.Format("2006-01-02")
+	}
+
+	return ""
+}
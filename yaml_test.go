@@ -0,0 +1,57 @@
+package main
+
+import "testing"
+
+func TestParseYAML(t *testing.T) {
+	t.Parallel()
+
+	values, err := parseYAML(`
+# комментарий
+title: "Привет: мир"
+count: 10
+enabled: true
+empty: ~
+tags: [a, "b c"]
+dirs:
+  blog: __blog # комментарий в строке
+list:
+  - one
+  - name: two
+    id: 2
+text: |
+  первая строка
+  вторая строка
+`)
+	if err != nil {
+		t.Fatalf("parseYAML вернул ошибку: %v", err)
+	}
+	if values["title"] != "Привет: мир" || values["count"] != int64(10) || values["enabled"] != true || values["empty"] != nil {
+		t.Fatalf("неверные скалярные значения: %v", values)
+	}
+	if tags, ok := values["tags"].([]interface{}); !ok || len(tags) != 2 || tags[1] != "b c" {
+		t.Fatalf("tags = %v", values["tags"])
+	}
+	if values["dirs"].(map[string]interface{})["blog"] != "__blog" {
+		t.Fatalf("dirs = %v", values["dirs"])
+	}
+	list, ok := values["list"].([]interface{})
+	if !ok || len(list) != 2 || list[0] != "one" || list[1].(map[string]interface{})["id"] != int64(2) {
+		t.Fatalf("list = %v", values["list"])
+	}
+	if values["text"] != "первая строка\nвторая строка\n" {
+		t.Fatalf("text = %q", values["text"])
+	}
+}
+
+func TestParseYAML_ReportsPosition(t *testing.T) {
+	t.Parallel()
+
+	_, err := parseYAML("a: 1\nb: [1, 2\n")
+	syntaxErr, ok := err.(*SyntaxError)
+	if !ok {
+		t.Fatalf("ожидалась ошибка SyntaxError, получено %v", err)
+	}
+	if syntaxErr.Line != 2 {
+		t.Fatalf("строка ошибки = %d, ожидалось 2", syntaxErr.Line)
+	}
+}